@@ -26,7 +26,7 @@ type DataRecord struct {
 }
 
 func InsertData(ctx context.Context, alert model.Alert, args model.ActionArgs) (any, error) {
-	table, err := setupTable(ctx, args)
+	dst, err := parseTableArgs(args)
 	if err != nil {
 		return nil, err
 	}
@@ -70,6 +70,15 @@ func InsertData(ctx context.Context, alert model.Alert, args model.ActionArgs) (
 		}
 	}
 
+	if ctxutil.IsDryRun(ctx) {
+		return dst.dryRun(row), nil
+	}
+
+	table, err := dst.setup(ctx)
+	if err != nil {
+		return nil, err
+	}
+
 	return nil, insert(ctx, table, schema, row)
 }
 
@@ -96,7 +105,7 @@ type AttrRecord struct {
 }
 
 func InsertAlert(ctx context.Context, alert model.Alert, args model.ActionArgs) (any, error) {
-	table, err := setupTable(ctx, args)
+	dst, err := parseTableArgs(args)
 	if err != nil {
 		return nil, err
 	}
@@ -139,10 +148,25 @@ func InsertAlert(ctx context.Context, alert model.Alert, args model.ActionArgs)
 		}
 	}
 
+	if ctxutil.IsDryRun(ctx) {
+		return dst.dryRun(row), nil
+	}
+
+	table, err := dst.setup(ctx)
+	if err != nil {
+		return nil, err
+	}
+
 	return nil, insert(ctx, table, schema, row)
 }
 
-func setupTable(ctx context.Context, args model.ActionArgs) (*bigquery.Table, error) {
+type tableArgs struct {
+	projectID string
+	datasetID string
+	tableID   string
+}
+
+func parseTableArgs(args model.ActionArgs) (*tableArgs, error) {
 	projectID, ok := args["project_id"].(string)
 	if !ok {
 		return nil, goerr.Wrap(types.ErrActionInvalidArgument, "project_id is required")
@@ -156,14 +180,27 @@ func setupTable(ctx context.Context, args model.ActionArgs) (*bigquery.Table, er
 		return nil, goerr.Wrap(types.ErrActionInvalidArgument, "table_id is required")
 	}
 
-	c, err := bigquery.NewClient(ctx, projectID)
+	return &tableArgs{projectID: projectID, datasetID: datasetID, tableID: tableID}, nil
+}
+
+func (x *tableArgs) setup(ctx context.Context) (*bigquery.Table, error) {
+	c, err := bigquery.NewClient(ctx, x.projectID)
 	if err != nil {
 		return nil, goerr.Wrap(err, "Fail to create BigQuery client")
 	}
 
-	dataSet := c.Dataset(datasetID)
+	dataSet := c.Dataset(x.datasetID)
+
+	return dataSet.Table(x.tableID), nil
+}
 
-	return dataSet.Table(tableID), nil
+func (x *tableArgs) dryRun(row any) *model.DryRunResult {
+	return model.NewDryRunResult(map[string]any{
+		"project_id": x.projectID,
+		"dataset_id": x.datasetID,
+		"table_id":   x.tableID,
+		"row":        row,
+	})
 }
 
 func insert(ctx context.Context, table *bigquery.Table, schema bigquery.Schema, data any) error {
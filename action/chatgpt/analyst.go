@@ -33,22 +33,21 @@ func Query(ctx context.Context, alert model.Alert, args model.ActionArgs) (any,
 		prompt = v
 	}
 
+	req := openai.ChatCompletionRequest{
+		Model: openai.GPT4o,
+		Messages: []openai.ChatCompletionMessage{
+			{
+				Role:    openai.ChatMessageRoleUser,
+				Content: prompt,
+			},
+		},
+	}
+
 	if ctxutil.IsDryRun(ctx) {
-		return nil, nil
+		return model.NewDryRunResult(req), nil
 	}
 
-	resp, err := client.CreateChatCompletion(
-		ctx,
-		openai.ChatCompletionRequest{
-			Model: openai.GPT4o,
-			Messages: []openai.ChatCompletionMessage{
-				{
-					Role:    openai.ChatMessageRoleUser,
-					Content: prompt,
-				},
-			},
-		},
-	)
+	resp, err := client.CreateChatCompletion(ctx, req)
 	if err != nil {
 		return nil, goerr.Wrap(err, "Failed to call OpenAI API")
 	}
@@ -57,15 +57,23 @@ func CreateComment(ctx context.Context, alert model.Alert, args model.ActionArgs
 		return nil, goerr.Wrap(types.ErrActionInvalidArgument, "body is required")
 	}
 
-	if ctxutil.IsDryRun(ctx) {
-		return nil, nil
-	}
-
 	req := &github.IssueComment{
 		Body: &body,
 	}
 
-	rt := http.DefaultTransport
+	if ctxutil.IsDryRun(ctx) {
+		return model.NewDryRunResult(map[string]any{
+			"owner":        owner,
+			"repo":         repo,
+			"issue_number": int(issue_number),
+			"comment":      req,
+		}), nil
+	}
+
+	rt := ctxutil.HTTPClient(ctx).Transport
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
 
 	itr, err := ghinstallation.New(rt, int64(appID), int64(installID), []byte(privateKey))
 	if err != nil {
@@ -83,10 +83,17 @@ func CreateIssue(ctx context.Context, alert model.Alert, args model.ActionArgs)
 	}
 
 	if ctxutil.IsDryRun(ctx) {
-		return nil, nil
+		return model.NewDryRunResult(map[string]any{
+			"owner": owner,
+			"repo":  repo,
+			"issue": req,
+		}), nil
 	}
 
-	rt := http.DefaultTransport
+	rt := ctxutil.HTTPClient(ctx).Transport
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
 
 	itr, err := ghinstallation.New(rt, int64(appID), int64(installID), []byte(privateKey))
 	if err != nil {
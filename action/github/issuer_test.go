@@ -174,7 +174,7 @@ jM1rsSGIP5FFS056O92OpA3f3r7MPd2LFTBrQoxNIIqn9Lq+F+dX
 
 func TestIssuerDryRun(t *testing.T) {
 	ctx := ctxutil.SetDryRun(context.Background(), true)
-	_, err := github.CreateIssue(ctx, model.Alert{}, model.ActionArgs{
+	resp, err := github.CreateIssue(ctx, model.Alert{}, model.ActionArgs{
 		"app_id":             float64(123),
 		"install_id":         float64(123),
 		"secret_private_key": dummyPrivateKey,
@@ -182,6 +182,9 @@ func TestIssuerDryRun(t *testing.T) {
 		"repo":               "repo",
 	})
 	gt.NoError(t, err)
+	result := gt.Cast[*model.DryRunResult](t, resp)
+	gt.B(t, result.DryRun).True()
+	gt.V(t, result.Request).NotNil()
 }
 
 func TestIssuerValidationFail(t *testing.T) {
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"io"
 	"net/http"
+	"sort"
 	"strings"
 
 	"github.com/m-mizutani/goerr/v2"
@@ -25,7 +26,8 @@ func Fetch(ctx context.Context, _ model.Alert, args model.ActionArgs) (any, erro
 	}
 
 	var reqBody io.Reader
-	if data, ok := args["data"].(string); ok {
+	data, hasData := args["data"].(string)
+	if hasData {
 		reqBody = strings.NewReader(data)
 	}
 
@@ -40,7 +42,26 @@ func Fetch(ctx context.Context, _ model.Alert, args model.ActionArgs) (any, erro
 		}
 	}
 
-	resp, err := http.DefaultClient.Do(req)
+	if ctxutil.IsDryRun(ctx) {
+		// Values of header are not returned because they may contain credentials
+		var headerNames []string
+		for name := range req.Header {
+			headerNames = append(headerNames, name)
+		}
+		sort.Strings(headerNames)
+
+		dryRun := map[string]any{
+			"method":       method,
+			"url":          url,
+			"header_names": headerNames,
+		}
+		if hasData {
+			dryRun["data"] = data
+		}
+		return model.NewDryRunResult(dryRun), nil
+	}
+
+	resp, err := ctxutil.HTTPClient(ctx).Do(req)
 	if err != nil {
 		return nil, goerr.Wrap(err, "Fail to send HTTP request")
 	}
@@ -6,6 +6,7 @@ import (
 
 	"github.com/andygrunwald/go-jira"
 	"github.com/m-mizutani/goerr/v2"
+	"github.com/secmon-lab/alertchain/pkg/ctxutil"
 	"github.com/secmon-lab/alertchain/pkg/domain/model"
 	"github.com/secmon-lab/alertchain/pkg/utils"
 )
@@ -34,8 +35,9 @@ func AddAttachment(ctx context.Context, alert model.Alert, args model.ActionArgs
 	}
 
 	tp := jira.BasicAuthTransport{
-		Username: userName,
-		Password: token,
+		Username:  userName,
+		Password:  token,
+		Transport: ctxutil.HTTPClient(ctx).Transport,
 	}
 
 	jiraClient, err := jira.NewClient(tp.Client(), baseURL)
@@ -43,6 +45,15 @@ func AddAttachment(ctx context.Context, alert model.Alert, args model.ActionArgs
 		return nil, goerr.Wrap(err, "Failed to create JIRA client")
 	}
 
+	if ctxutil.IsDryRun(ctx) {
+		return model.NewDryRunResult(map[string]any{
+			"base_url":  baseURL,
+			"issue_id":  issueID,
+			"file_name": fileName,
+			"size":      len(data),
+		}), nil
+	}
+
 	body := strings.NewReader(data)
 	attach, _, err := jiraClient.Issue.PostAttachmentWithContext(ctx, issueID, body, fileName)
 	if err != nil {
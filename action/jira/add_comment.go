@@ -6,6 +6,7 @@ import (
 
 	"github.com/andygrunwald/go-jira"
 	"github.com/m-mizutani/goerr/v2"
+	"github.com/secmon-lab/alertchain/pkg/ctxutil"
 	"github.com/secmon-lab/alertchain/pkg/domain/model"
 	"github.com/secmon-lab/alertchain/pkg/utils"
 )
@@ -32,8 +33,9 @@ func AddComment(ctx context.Context, alert model.Alert, args model.ActionArgs) (
 	}
 
 	tp := jira.BasicAuthTransport{
-		Username: userName,
-		Password: token,
+		Username:  userName,
+		Password:  token,
+		Transport: ctxutil.HTTPClient(ctx).Transport,
 	}
 
 	jiraClient, err := jira.NewClient(tp.Client(), baseURL)
@@ -47,6 +49,15 @@ func AddComment(ctx context.Context, alert model.Alert, args model.ActionArgs) (
 		},
 		Body: body,
 	}
+
+	if ctxutil.IsDryRun(ctx) {
+		return model.NewDryRunResult(map[string]any{
+			"base_url": baseURL,
+			"issue_id": issueID,
+			"comment":  input,
+		}), nil
+	}
+
 	comment, _, err := jiraClient.Issue.AddCommentWithContext(ctx, issueID, input)
 	if err != nil {
 		return nil, goerr.Wrap(err, "Failed to add comment")
@@ -11,6 +11,7 @@ import (
 
 	"github.com/andygrunwald/go-jira"
 	"github.com/m-mizutani/goerr/v2"
+	"github.com/secmon-lab/alertchain/pkg/ctxutil"
 	"github.com/secmon-lab/alertchain/pkg/domain/model"
 	"github.com/secmon-lab/alertchain/pkg/utils"
 )
@@ -62,8 +63,9 @@ func CreateIssue(ctx context.Context, alert model.Alert, args model.ActionArgs)
 	}
 
 	tp := jira.BasicAuthTransport{
-		Username: userName,
-		Password: token,
+		Username:  userName,
+		Password:  token,
+		Transport: ctxutil.HTTPClient(ctx).Transport,
 	}
 
 	jiraClient, err := jira.NewClient(tp.Client(), baseURL)
@@ -100,6 +102,14 @@ func CreateIssue(ctx context.Context, alert model.Alert, args model.ActionArgs)
 		}
 	}
 
+	if ctxutil.IsDryRun(ctx) {
+		return model.NewDryRunResult(map[string]any{
+			"base_url":   baseURL,
+			"issue":      i,
+			"attachment": fmt.Sprintf("alert-%s.json", alert.ID),
+		}), nil
+	}
+
 	issue, resp, err := jiraClient.Issue.CreateWithContext(ctx, &i)
 	if err != nil {
 		data, _ := io.ReadAll(resp.Body)
@@ -7,6 +7,7 @@ import (
 	"github.com/m-mizutani/goerr/v2"
 	og_alert "github.com/opsgenie/opsgenie-go-sdk-v2/alert"
 	"github.com/opsgenie/opsgenie-go-sdk-v2/client"
+	"github.com/secmon-lab/alertchain/pkg/ctxutil"
 	"github.com/secmon-lab/alertchain/pkg/domain/model"
 	"github.com/secmon-lab/alertchain/pkg/utils"
 )
@@ -58,6 +59,10 @@ func CreateAlert(ctx context.Context, alert model.Alert, args model.ActionArgs)
 		})
 	}
 
+	if ctxutil.IsDryRun(ctx) {
+		return model.NewDryRunResult(req), nil
+	}
+
 	resp, err := c.Create(ctx, req)
 	if err != nil {
 		return nil, goerr.Wrap(err, "Failed to create OpsGenie alert")
@@ -16,7 +16,8 @@ func ReplaceHTTPClient(client *http.Client) {
 	httpClient = client
 }
 
-var httpClient = http.DefaultClient
+// httpClient overrides HTTP client given by context. It is for testing.
+var httpClient *http.Client
 
 func Indicator(ctx context.Context, _ model.Alert, args model.ActionArgs) (any, error) {
 	api_key, ok := args["secret_api_key"].(string)
@@ -47,13 +48,24 @@ func Indicator(ctx context.Context, _ model.Alert, args model.ActionArgs) (any,
 
 	url := "https://otx.alienvault.com/api/v1/indicators/" + indicatorType + "/" + indicator + "/" + section
 
+	if ctxutil.IsDryRun(ctx) {
+		return model.NewDryRunResult(map[string]any{
+			"method": http.MethodGet,
+			"url":    url,
+		}), nil
+	}
+
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
 		return nil, goerr.Wrap(err, "Fail to create HTTP request for OTX")
 	}
 	req.Header.Set("X-OTX-API-KEY", api_key)
 
-	resp, err := httpClient.Do(req)
+	client := httpClient
+	if client == nil {
+		client = ctxutil.HTTPClient(ctx)
+	}
+	resp, err := client.Do(req)
 	if err != nil {
 		return nil, goerr.Wrap(err, "Fail to send HTTP request to OTX")
 	}
@@ -78,10 +78,10 @@ func Post(ctx context.Context, alert model.Alert, args model.ActionArgs) (any, e
 	msg.Channel = channel
 
 	if ctxutil.IsDryRun(ctx) {
-		return nil, nil
+		return model.NewDryRunResult(msg), nil
 	}
 
-	if err := slack.PostWebhookContext(ctx, url, msg); err != nil {
+	if err := slack.PostWebhookCustomHTTPContext(ctx, url, ctxutil.HTTPClient(ctx), msg); err != nil {
 		raw, _ := json.Marshal(msg)
 		return nil, goerr.Wrap(err, "failed to post slack message", goerr.V("body", string(raw)), goerr.T(types.ErrTagAction))
 	}
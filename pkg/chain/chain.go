@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"net/http"
 	"time"
 
 	"github.com/m-mizutani/goerr/v2"
@@ -14,9 +15,12 @@ import (
 	"github.com/secmon-lab/alertchain/pkg/domain/model"
 	"github.com/secmon-lab/alertchain/pkg/domain/types"
 	"github.com/secmon-lab/alertchain/pkg/infra/memory"
+	"github.com/secmon-lab/alertchain/pkg/infra/metrics"
 	"github.com/secmon-lab/alertchain/pkg/infra/policy"
+	"github.com/secmon-lab/alertchain/pkg/infra/tracing"
 	"github.com/secmon-lab/alertchain/pkg/service"
 	"github.com/secmon-lab/alertchain/pkg/utils"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 type Chain struct {
@@ -24,13 +28,21 @@ type Chain struct {
 	actionPolicy *policy.Client
 	dbClient     interfaces.Database
 
-	recorder   interfaces.ScenarioRecorder
-	actionMock interfaces.ActionMock
-	actionMap  map[types.ActionName]model.RunAction
-
-	timeout      time.Duration
-	enablePrint  bool
-	maxSequences int
+	recorder     interfaces.ScenarioRecorder
+	actionMock   interfaces.ActionMock
+	actionMap    map[types.ActionName]model.RunAction
+	decisionSink interfaces.DecisionLogSink
+	inflight     *inflight
+	instrument   interfaces.Instrument
+	events       interfaces.WorkflowEventPublisher
+	httpClient   *http.Client
+
+	timeout       time.Duration
+	enablePrint   bool
+	enableExplain bool
+	maxSequences  int
+	// playMode is true if scenario recorder is set by WithScenarioRecorder
+	playMode bool
 
 	now func() time.Time
 	env interfaces.Env
@@ -46,12 +58,21 @@ func New(options ...Option) (*Chain, error) {
 		maxSequences: types.DefaultMaxSequences,
 		now:          time.Now,
 		env:          utils.Env,
+		inflight:     newInflight(),
+		instrument:   metrics.Nop{},
 	}
 
 	for _, opt := range options {
 		opt(c)
 	}
 
+	// Schema is given by client in server mode. Bound the schema labels by the alert policy to avoid unbounded cardinality
+	var packages []string
+	if c.alertPolicy != nil {
+		packages = c.alertPolicy.Packages()
+	}
+	c.instrument = metrics.WithKnownSchemas(c.instrument, packages)
+
 	return c, nil
 }
 
@@ -75,6 +96,13 @@ func WithEnablePrint() Option {
 	}
 }
 
+// WithEnableExplain enables OPA tracing of alert and action policy. A condensed explanation of each evaluation is printed to terminal in CLI mode, or stored into the scenario log if a scenario recorder is set.
+func WithEnableExplain() Option {
+	return func(c *Chain) {
+		c.enableExplain = true
+	}
+}
+
 func WithExtraAction(name types.ActionName, action model.RunAction) Option {
 	return func(c *Chain) {
 		if _, ok := c.actionMap[name]; ok {
@@ -93,6 +121,7 @@ func WithActionMock(mock interfaces.ActionMock) Option {
 func WithScenarioRecorder(logger interfaces.ScenarioRecorder) Option {
 	return func(c *Chain) {
 		c.recorder = logger
+		c.playMode = true
 	}
 }
 
@@ -108,8 +137,50 @@ func WithDatabase(db interfaces.Database) Option {
 	}
 }
 
+// WithDecisionLogSink records every evaluation of alert and action policy into the sink.
+func WithDecisionLogSink(sink interfaces.DecisionLogSink) Option {
+	return func(c *Chain) {
+		c.decisionSink = sink
+	}
+}
+
+// WithWorkflowEventPublisher publishes progress of each workflow, e.g. for GraphQL subscription.
+func WithWorkflowEventPublisher(pub interfaces.WorkflowEventPublisher) Option {
+	return func(c *Chain) {
+		c.events = pub
+	}
+}
+
+// WithHTTPClient sets HTTP client used by built-in actions to call external services. http.DefaultClient is used if not set.
+func WithHTTPClient(client *http.Client) Option {
+	return func(c *Chain) {
+		c.httpClient = client
+	}
+}
+
+// WithInstrument sets a collector of runtime measurements, such as action latency and number of detected alerts. Schema without alert.<schema> package in the alert policy is recorded as "unknown".
+func WithInstrument(inst interfaces.Instrument) Option {
+	return func(c *Chain) {
+		c.instrument = inst
+	}
+}
+
+// Policies returns the configured alert and action policies.
+func (x *Chain) Policies() []*policy.Client {
+	var policies []*policy.Client
+	for _, p := range []*policy.Client{x.alertPolicy, x.actionPolicy} {
+		if p != nil {
+			policies = append(policies, p)
+		}
+	}
+	return policies
+}
+
 // HandleAlert is main function of alert chain. It receives alert data and execute actions according to the Rego policies.
-func (x *Chain) HandleAlert(ctx context.Context, schema types.Schema, data any) ([]*model.Alert, error) {
+func (x *Chain) HandleAlert(ctx context.Context, schema types.Schema, data any) (_ []*model.Alert, err error) {
+	ctx, span := tracing.Start(ctx, "HandleAlert", attribute.String("alertchain.schema", string(schema)))
+	defer func() { tracing.End(span, err) }()
+
 	logger := ctxutil.Logger(ctx)
 	logger.Debug("[input] detect alert", slog.Any("data", data), slog.Any("schema", schema))
 
@@ -118,22 +189,30 @@ func (x *Chain) HandleAlert(ctx context.Context, schema types.Schema, data any)
 		return nil, err
 	}
 
+	x.instrument.AlertDetected(ctx, schema, len(alertResult.Alerts))
+	span.SetAttributes(attribute.Int("alertchain.alerts", len(alertResult.Alerts)))
 	if len(alertResult.Alerts) == 0 {
 		return nil, nil
 	}
 
+	eventMeta := ctxutil.GetEventMeta(ctx)
 	alerts := make([]model.Alert, len(alertResult.Alerts))
 	for i, meta := range alertResult.Alerts {
 		alerts[i] = model.NewAlert(meta, schema, data)
+		alerts[i].Meta = eventMeta.Copy()
 	}
 
 	logger.Debug("[output] detect alert", slog.Any("alerts", alerts))
 
 	svc := service.New(x.dbClient)
+	if ctxutil.IsDryRun(ctx) {
+		// Records of workflows in dry-run mode are discarded
+		svc = service.New(memory.New())
+	}
 
 	for _, alert := range alerts {
 		newCtx := ctxutil.InjectLogger(ctx, logger.With("alert_id", alert.ID))
-		if err := x.runWorkflow(newCtx, alert, svc); err != nil {
+		if _, err := x.runWorkflow(newCtx, alert, svc); err != nil {
 			return nil, err
 		}
 	}
@@ -141,17 +220,45 @@ func (x *Chain) HandleAlert(ctx context.Context, schema types.Schema, data any)
 	return utils.ToPtrSlice(alerts), nil
 }
 
-func (x *Chain) queryAlertPolicy(ctx context.Context, schema types.Schema, in, out any) error {
+// RerunAlert runs a new workflow for the alert that has been already detected, e.g. alert stored in database. Alert policy is not evaluated again. It returns ID of the new workflow even if the workflow failed.
+func (x *Chain) RerunAlert(ctx context.Context, alert model.Alert) (_ types.WorkflowID, err error) {
+	ctx, span := tracing.Start(ctx, "RerunAlert", attribute.String("alertchain.alert_id", string(alert.ID)))
+	defer func() { tracing.End(span, err) }()
+
+	ctx = ctxutil.InjectLogger(ctx, ctxutil.Logger(ctx).With("alert_id", alert.ID))
+	return x.runWorkflow(ctx, alert, service.New(x.dbClient))
+}
+
+func (x *Chain) queryAlertPolicy(ctx context.Context, schema types.Schema, in, out any) (err error) {
 	if x.alertPolicy == nil {
 		return nil
 	}
 
+	ctx, span := tracing.Start(ctx, "alert_policy", attribute.String("alertchain.schema", string(schema)))
+	defer func() { tracing.End(span, err) }()
+
 	options := []policy.QueryOption{
 		policy.WithPackageSuffix(string(schema)),
+		policy.WithInstrument(x.instrument),
 	}
 	if x.enablePrint {
 		options = append(options, policy.WithRegoPrint(makeRegoPrint(ctx)))
 	}
+	if x.enableExplain {
+		options = append(options, policy.WithTrace(x.makeTrace(ctx)))
+	}
+	if x.decisionSink != nil {
+		options = append(options, policy.WithDecisionLogSink(x.decisionSink))
+	}
+	if meta := ctxutil.GetEventMeta(ctx); meta != nil {
+		doc, err := utils.ToAny(meta)
+		if err != nil {
+			return err
+		}
+		options = append(options, policy.WithData(map[string]any{
+			"alertchain": map[string]any{"meta": doc},
+		}))
+	}
 
 	if err := x.alertPolicy.Query(ctx, in, out, options...); err != nil && !errors.Is(err, types.ErrNoPolicyResult) {
 		return goerr.Wrap(err, "failed to evaluate alert policy", goerr.V("request", in), goerr.T(types.ErrTagPolicy))
@@ -161,15 +268,30 @@ func (x *Chain) queryAlertPolicy(ctx context.Context, schema types.Schema, in, o
 	return nil
 }
 
-func (x *Chain) queryActionPolicy(ctx context.Context, in, out any) error {
+func (x *Chain) queryActionPolicy(ctx context.Context, in, out any) (err error) {
 	if x.actionPolicy == nil {
 		return nil
 	}
 
-	var options []policy.QueryOption
+	var attrs []attribute.KeyValue
+	if req, ok := in.(*model.ActionRunRequest); ok {
+		attrs = append(attrs, attribute.Int("alertchain.seq", req.Seq))
+	}
+	ctx, span := tracing.Start(ctx, "action_policy", attrs...)
+	defer func() { tracing.End(span, err) }()
+
+	options := []policy.QueryOption{
+		policy.WithInstrument(x.instrument),
+	}
 	if x.enablePrint {
 		options = append(options, policy.WithRegoPrint(makeRegoPrint(ctx)))
 	}
+	if x.enableExplain {
+		options = append(options, policy.WithTrace(x.makeTrace(ctx)))
+	}
+	if x.decisionSink != nil {
+		options = append(options, policy.WithDecisionLogSink(x.decisionSink))
+	}
 
 	if err := x.actionPolicy.Query(ctx, in, out, options...); err != nil && !errors.Is(err, types.ErrNoPolicyResult) {
 		return goerr.Wrap(err, "failed to evaluate action policy", goerr.V("request", in), goerr.T(types.ErrTagPolicy))
@@ -3,17 +3,29 @@ package chain_test
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"net/http"
 	"sync"
+	"time"
 
 	"testing"
 
 	"github.com/m-mizutani/goerr/v2"
 	"github.com/m-mizutani/gt"
 	"github.com/secmon-lab/alertchain/pkg/chain"
+	"github.com/secmon-lab/alertchain/pkg/ctxutil"
 	"github.com/secmon-lab/alertchain/pkg/domain/model"
+	"github.com/secmon-lab/alertchain/pkg/domain/types"
 	"github.com/secmon-lab/alertchain/pkg/infra/memory"
+	"github.com/secmon-lab/alertchain/pkg/infra/metrics"
 	"github.com/secmon-lab/alertchain/pkg/infra/policy"
 	"github.com/secmon-lab/alertchain/pkg/infra/recorder"
+	"github.com/secmon-lab/alertchain/pkg/infra/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace/noop"
 )
 
 func TestBasic(t *testing.T) {
@@ -334,3 +346,467 @@ func TestForceAction(t *testing.T) {
 	gt.True(t, calledStep[2])
 	gt.False(t, calledStep[3])
 }
+
+type decisionSink struct {
+	logs []*model.DecisionLog
+}
+
+func (x *decisionSink) Write(ctx context.Context, log *model.DecisionLog) error {
+	x.logs = append(x.logs, log)
+	return nil
+}
+
+func TestDecisionLog(t *testing.T) {
+	var alertData any
+	sccData := gt.R1(read("testdata/basic/input/scc.json")).NoError(t)
+	gt.NoError(t, json.Unmarshal([]byte(sccData), &alertData))
+
+	alertPolicy := gt.R1(policy.New(
+		policy.WithPackage("alert"),
+		policy.WithFile("testdata/basic/alert.rego"),
+		policy.WithReadFile(read),
+	)).NoError(t)
+
+	actionPolicy := gt.R1(policy.New(
+		policy.WithPackage("action"),
+		policy.WithFile("testdata/basic/action.rego"),
+		policy.WithReadFile(read),
+	)).NoError(t)
+
+	sink := &decisionSink{}
+	c := gt.R1(chain.New(
+		chain.WithPolicyAlert(alertPolicy),
+		chain.WithPolicyAction(actionPolicy),
+		chain.WithExtraAction("mock", func(ctx context.Context, _ model.Alert, args model.ActionArgs) (any, error) {
+			return nil, nil
+		}),
+		chain.WithDecisionLogSink(sink),
+	)).NoError(t)
+
+	ctx := context.Background()
+	alerts := gt.R1(c.HandleAlert(ctx, "scc", alertData)).NoError(t)
+	gt.A(t, alerts).Length(1)
+
+	// alert policy, action policy (seq 0) and action policy (seq 1)
+	gt.A(t, sink.logs).Length(3).
+		At(0, func(t testing.TB, v *model.DecisionLog) {
+			gt.V(t, v.Package).Equal("alert.scc")
+			gt.V(t, v.PolicyHash).Equal(alertPolicy.Hash())
+			gt.V(t, v.AlertID).Equal("")
+		}).
+		At(1, func(t testing.TB, v *model.DecisionLog) {
+			gt.V(t, v.Package).Equal("action")
+			gt.V(t, v.PolicyHash).Equal(actionPolicy.Hash())
+			gt.V(t, v.AlertID).Equal(alerts[0].ID)
+			gt.V(t, v.WorkflowID).NotEqual("")
+		})
+}
+
+func TestExplain(t *testing.T) {
+	alertPolicy := gt.R1(policy.New(
+		policy.WithPackage("alert"),
+		policy.WithFile("testdata/play/alert.rego"),
+		policy.WithReadFile(read),
+	)).NoError(t)
+
+	actionPolicy := gt.R1(policy.New(
+		policy.WithPackage("action"),
+		policy.WithFile("testdata/play/action.rego"),
+		policy.WithReadFile(read),
+	)).NoError(t)
+
+	var playbook model.Playbook
+	gt.NoError(t, model.ParsePlaybook("testdata/play/playbook.jsonnet", read, &playbook))
+
+	recorder := recorder.NewMemory(playbook.Scenarios[0])
+	c := gt.R1(chain.New(
+		chain.WithPolicyAlert(alertPolicy),
+		chain.WithPolicyAction(actionPolicy),
+		chain.WithExtraAction("mock", func(ctx context.Context, _ model.Alert, _ model.ActionArgs) (any, error) {
+			return nil, nil
+		}),
+		chain.WithActionMock(&playbook.Scenarios[0].Events[0]),
+		chain.WithScenarioRecorder(recorder),
+		chain.WithEnableExplain(),
+	)).NoError(t)
+
+	ctx := context.Background()
+	gt.R1(c.HandleAlert(ctx, "my_test", playbook.Scenarios[0].Events[0].Input)).NoError(t)
+
+	gt.A(t, recorder.Log.Traces).Longer(1).
+		At(0, func(t testing.TB, v *model.PolicyTrace) {
+			gt.V(t, v.Package).Equal("alert.my_test")
+			gt.A(t, v.Rules).Length(1).At(0, func(t testing.TB, v model.RuleTrace) {
+				gt.V(t, v.Name).Equal("alert")
+				gt.B(t, v.Result).True()
+			})
+		}).
+		At(1, func(t testing.TB, v *model.PolicyTrace) {
+			gt.V(t, v.Package).Equal("action")
+		})
+}
+
+func TestInterrupt(t *testing.T) {
+	alertPolicy := gt.R1(policy.New(
+		policy.WithPackage("alert"),
+		policy.WithFile("testdata/global_attr/alert.rego"),
+		policy.WithReadFile(read),
+	)).NoError(t)
+
+	actionPolicy := gt.R1(policy.New(
+		policy.WithPackage("action"),
+		policy.WithFile("testdata/global_attr/action.rego"),
+		policy.WithReadFile(read),
+	)).NoError(t)
+
+	// The first call of mock action blocks until the workflow is interrupted
+	var calledMock int
+	started := make(chan struct{})
+	mock := func(ctx context.Context, alert model.Alert, _ model.ActionArgs) (any, error) {
+		calledMock++
+		if calledMock == 1 {
+			close(started)
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}
+		return nil, nil
+	}
+
+	dbClient := memory.New()
+	c := gt.R1(chain.New(
+		chain.WithPolicyAlert(alertPolicy),
+		chain.WithPolicyAction(actionPolicy),
+		chain.WithExtraAction("mock", mock),
+		chain.WithDatabase(dbClient),
+	)).NoError(t)
+
+	ctx := context.Background()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, _ = c.HandleAlert(ctx, "my_alert", nil)
+	}()
+
+	<-started
+	c.Interrupt(ctx)
+	<-done
+
+	workflows := gt.R1(dbClient.GetWorkflows(ctx, 0, 10)).NoError(t)
+	gt.A(t, workflows).Length(1).At(0, func(t testing.TB, v model.WorkflowRecord) {
+		gt.V(t, v.Status).Equal(model.WorkflowStatusInterrupted)
+		gt.V(t, v.FinishedAt).NotNil()
+	})
+
+	// namespace lock must be released by Interrupt, then next workflow can run
+	gt.R1(c.HandleAlert(ctx, "my_alert", nil)).NoError(t)
+	gt.N(t, calledMock).Equal(2)
+
+	workflows = gt.R1(dbClient.GetWorkflows(ctx, 0, 10)).NoError(t)
+	gt.A(t, workflows).Length(2)
+	var statuses []string
+	for _, wf := range workflows {
+		statuses = append(statuses, wf.Status)
+	}
+	gt.A(t, statuses).Have(model.WorkflowStatusCompleted).Have(model.WorkflowStatusInterrupted)
+}
+
+// interruptOnLock calls the hook right after acquiring the namespace lock to simulate Interrupt called before the lock is registered
+type interruptOnLock struct {
+	*memory.Client
+	hook func()
+}
+
+func (x *interruptOnLock) Lock(ctx context.Context, ns types.Namespace, timeout time.Time) error {
+	if err := x.Client.Lock(ctx, ns, timeout); err != nil {
+		return err
+	}
+	if x.hook != nil {
+		x.hook()
+	}
+	return nil
+}
+
+func TestInterruptWhileLocking(t *testing.T) {
+	alertPolicy := gt.R1(policy.New(
+		policy.WithPackage("alert"),
+		policy.WithFile("testdata/global_attr/alert.rego"),
+		policy.WithReadFile(read),
+	)).NoError(t)
+
+	actionPolicy := gt.R1(policy.New(
+		policy.WithPackage("action"),
+		policy.WithFile("testdata/global_attr/action.rego"),
+		policy.WithReadFile(read),
+	)).NoError(t)
+
+	var calledMock int
+	mock := func(ctx context.Context, alert model.Alert, _ model.ActionArgs) (any, error) {
+		calledMock++
+		return nil, nil
+	}
+
+	dbClient := &interruptOnLock{Client: memory.New()}
+	c := gt.R1(chain.New(
+		chain.WithPolicyAlert(alertPolicy),
+		chain.WithPolicyAction(actionPolicy),
+		chain.WithExtraAction("mock", mock),
+		chain.WithDatabase(dbClient),
+	)).NoError(t)
+
+	ctx := context.Background()
+	dbClient.hook = func() { c.Interrupt(ctx) }
+	gt.R1(c.HandleAlert(ctx, "my_alert", nil)).Error(t)
+	gt.N(t, calledMock).Equal(0)
+
+	// namespace lock must be released by the interrupted workflow, then next workflow can run without waiting timeout
+	dbClient.hook = nil
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		gt.R1(c.HandleAlert(ctx, "my_alert", nil)).NoError(t)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("namespace lock is not released")
+	}
+	gt.N(t, calledMock).Equal(1)
+}
+
+type recordInstrument struct {
+	metrics.Nop
+	mutex     sync.Mutex
+	alerts    map[types.Schema]int
+	actions   []types.ActionName
+	packages  []string
+	sequences []int
+	lockWaits int
+}
+
+func (x *recordInstrument) AlertDetected(ctx context.Context, schema types.Schema, count int) {
+	x.mutex.Lock()
+	defer x.mutex.Unlock()
+	x.alerts[schema] += count
+}
+
+func (x *recordInstrument) ActionExecuted(ctx context.Context, uses types.ActionName, duration time.Duration, err error) {
+	x.mutex.Lock()
+	defer x.mutex.Unlock()
+	x.actions = append(x.actions, uses)
+}
+
+func (x *recordInstrument) PolicyEvaluated(ctx context.Context, pkg string, duration time.Duration, err error) {
+	x.mutex.Lock()
+	defer x.mutex.Unlock()
+	x.packages = append(x.packages, pkg)
+}
+
+func (x *recordInstrument) WorkflowFinished(ctx context.Context, sequences int, err error) {
+	x.mutex.Lock()
+	defer x.mutex.Unlock()
+	x.sequences = append(x.sequences, sequences)
+}
+
+func (x *recordInstrument) LockWaited(ctx context.Context, ns types.Namespace, duration time.Duration) {
+	x.mutex.Lock()
+	defer x.mutex.Unlock()
+	x.lockWaits++
+}
+
+func TestInstrument(t *testing.T) {
+	alertPolicy := gt.R1(policy.New(
+		policy.WithPackage("alert"),
+		policy.WithFile("testdata/global_attr/alert.rego"),
+		policy.WithReadFile(read),
+	)).NoError(t)
+
+	actionPolicy := gt.R1(policy.New(
+		policy.WithPackage("action"),
+		policy.WithFile("testdata/global_attr/action.rego"),
+		policy.WithReadFile(read),
+	)).NoError(t)
+
+	mock := func(ctx context.Context, alert model.Alert, _ model.ActionArgs) (any, error) {
+		return nil, nil
+	}
+
+	inst := &recordInstrument{alerts: map[types.Schema]int{}}
+	c := gt.R1(chain.New(
+		chain.WithPolicyAlert(alertPolicy),
+		chain.WithPolicyAction(actionPolicy),
+		chain.WithExtraAction("mock", mock),
+		chain.WithInstrument(inst),
+	)).NoError(t)
+
+	ctx := context.Background()
+	gt.R1(c.HandleAlert(ctx, "my_alert", nil)).NoError(t)
+
+	gt.N(t, inst.alerts["my_alert"]).Equal(1)
+	gt.A(t, inst.actions).Equal([]types.ActionName{"mock"})
+	// alert policy, then action policy for 1st sequence (run mock) and 2nd sequence (no action)
+	gt.A(t, inst.packages).Equal([]string{"alert.my_alert", "action", "action"})
+	gt.A(t, inst.sequences).Equal([]int{2})
+	gt.N(t, inst.lockWaits).Equal(1)
+}
+
+func TestTracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(noop.NewTracerProvider()) })
+
+	alertPolicy := gt.R1(policy.New(
+		policy.WithPackage("alert"),
+		policy.WithFile("testdata/global_attr/alert.rego"),
+		policy.WithReadFile(read),
+	)).NoError(t)
+
+	actionPolicy := gt.R1(policy.New(
+		policy.WithPackage("action"),
+		policy.WithFile("testdata/global_attr/action.rego"),
+		policy.WithReadFile(read),
+	)).NoError(t)
+
+	mock := func(ctx context.Context, alert model.Alert, _ model.ActionArgs) (any, error) {
+		return nil, errors.New("something wrong")
+	}
+
+	c := gt.R1(chain.New(
+		chain.WithPolicyAlert(alertPolicy),
+		chain.WithPolicyAction(actionPolicy),
+		chain.WithExtraAction("mock", mock),
+		chain.WithDatabase(tracing.NewDatabase(memory.New())),
+	)).NoError(t)
+
+	_, err := c.HandleAlert(context.Background(), "my_alert", nil)
+	gt.Error(t, err)
+
+	spans := map[string]sdktrace.ReadOnlySpan{}
+	for _, span := range recorder.Ended() {
+		spans[span.Name()] = span
+	}
+	for _, name := range []string{"HandleAlert", "alert_policy", "workflow", "action_policy", "action", "db.Lock", "db.GetAttrs", "db.Unlock"} {
+		gt.M(t, spans).HasKey(name)
+	}
+
+	gt.V(t, spans["workflow"].Parent().SpanID()).Equal(spans["HandleAlert"].SpanContext().SpanID())
+	gt.V(t, spans["action"].Parent().SpanID()).Equal(spans["workflow"].SpanContext().SpanID())
+	gt.V(t, spans["action"].Status().Code).Equal(codes.Error)
+	gt.V(t, spans["HandleAlert"].Status().Code).Equal(codes.Error)
+}
+
+func TestEventMeta(t *testing.T) {
+	alertPolicy := gt.R1(policy.New(
+		policy.WithPackage("alert"),
+		policy.WithPolicyData("alert.rego", `package alert.my_alert
+
+alert contains {"title": "meta test", "source": data.alertchain.meta.subscription} if {
+	input.color == "blue"
+	data.alertchain.meta.attributes.env == "prod"
+}
+`),
+	)).NoError(t)
+
+	actionPolicy := gt.R1(policy.New(
+		policy.WithPackage("action"),
+		policy.WithPolicyData("action.rego", `package action
+
+run contains {"id": "notify", "uses": "mock"} if input.alert.meta.transport == "pubsub"
+`),
+	)).NoError(t)
+
+	var called []model.Alert
+	mock := func(ctx context.Context, alert model.Alert, _ model.ActionArgs) (any, error) {
+		called = append(called, alert)
+		return nil, nil
+	}
+
+	db := memory.New()
+	c := gt.R1(chain.New(
+		chain.WithPolicyAlert(alertPolicy),
+		chain.WithPolicyAction(actionPolicy),
+		chain.WithExtraAction("mock", mock),
+		chain.WithDatabase(db),
+	)).NoError(t)
+
+	data := map[string]any{"color": "blue"}
+	newCtx := func(env string) context.Context {
+		return ctxutil.InjectEventMeta(context.Background(), &model.EventMeta{
+			Transport:    model.TransportPubSub,
+			Subscription: "projects/test/subscriptions/alert",
+			Attributes:   map[string]string{"env": env},
+			ReceivedAt:   time.Now(),
+		})
+	}
+
+	t.Run("metadata is available in alert policy", func(t *testing.T) {
+		alerts := gt.R1(c.HandleAlert(newCtx("prod"), "my_alert", data)).NoError(t)
+		gt.A(t, alerts).Length(1)
+		gt.V(t, alerts[0].Source).Equal("projects/test/subscriptions/alert")
+		gt.V(t, alerts[0].Meta.Attributes["env"]).Equal("prod")
+
+		gt.A(t, called).Length(1)
+		gt.V(t, called[0].Meta.Transport).Equal(model.TransportPubSub)
+
+		stored := gt.R1(db.GetAlert(context.Background(), alerts[0].ID)).NoError(t)
+		gt.V(t, stored.Meta.Subscription).Equal("projects/test/subscriptions/alert")
+	})
+
+	t.Run("suppressed by metadata", func(t *testing.T) {
+		alerts := gt.R1(c.HandleAlert(newCtx("staging"), "my_alert", data)).NoError(t)
+		gt.A(t, alerts).Length(0)
+	})
+
+	t.Run("bare payload without metadata", func(t *testing.T) {
+		alerts := gt.R1(c.HandleAlert(context.Background(), "my_alert", data)).NoError(t)
+		gt.A(t, alerts).Length(0)
+	})
+}
+
+func TestHTTPClient(t *testing.T) {
+	var alertData any
+	sccData := gt.R1(read("testdata/basic/input/scc.json")).NoError(t)
+	gt.NoError(t, json.Unmarshal([]byte(sccData), &alertData))
+
+	alertPolicy := gt.R1(policy.New(
+		policy.WithPackage("alert"),
+		policy.WithFile("testdata/basic/alert.rego"),
+		policy.WithReadFile(read),
+	)).NoError(t)
+
+	actionPolicy := gt.R1(policy.New(
+		policy.WithPackage("action"),
+		policy.WithFile("testdata/basic/action.rego"),
+		policy.WithReadFile(read),
+	)).NoError(t)
+
+	var got *http.Client
+	mock := func(ctx context.Context, _ model.Alert, args model.ActionArgs) (any, error) {
+		got = ctxutil.HTTPClient(ctx)
+		return nil, nil
+	}
+
+	t.Run("default client", func(t *testing.T) {
+		c := gt.R1(chain.New(
+			chain.WithPolicyAlert(alertPolicy),
+			chain.WithPolicyAction(actionPolicy),
+			chain.WithExtraAction("mock", mock),
+		)).NoError(t)
+
+		gt.R1(c.HandleAlert(context.Background(), "scc", alertData)).NoError(t)
+		gt.B(t, got == http.DefaultClient).True()
+	})
+
+	t.Run("client given by option", func(t *testing.T) {
+		client := &http.Client{}
+		c := gt.R1(chain.New(
+			chain.WithPolicyAlert(alertPolicy),
+			chain.WithPolicyAction(actionPolicy),
+			chain.WithExtraAction("mock", mock),
+			chain.WithHTTPClient(client),
+		)).NoError(t)
+
+		gt.R1(c.HandleAlert(context.Background(), "scc", alertData)).NoError(t)
+		gt.B(t, got == client).True()
+	})
+}
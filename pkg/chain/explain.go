@@ -0,0 +1,56 @@
+package chain
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+
+	"github.com/fatih/color"
+	"github.com/secmon-lab/alertchain/pkg/ctxutil"
+	"github.com/secmon-lab/alertchain/pkg/domain/model"
+	"github.com/secmon-lab/alertchain/pkg/infra/policy"
+)
+
+func (x *Chain) makeTrace(ctx context.Context) policy.Trace {
+	return func(trace *model.PolicyTrace) {
+		// The explanation is stored with the scenario log instead of printing in play mode
+		if x.playMode {
+			x.recorder.LogTrace(trace)
+			return
+		}
+
+		if ctxutil.IsCLI(ctx) {
+			printTrace(os.Stdout, trace)
+		} else {
+			ctxutil.Logger(ctx).Info("policy explanation", slog.Any("trace", trace))
+		}
+	}
+}
+
+var (
+	traceTrue    = color.New(color.FgGreen, color.Bold)
+	traceFalse   = color.New(color.FgRed, color.Bold)
+	traceSkipped = color.New(color.FgHiBlack)
+)
+
+func printTrace(w io.Writer, trace *model.PolicyTrace) {
+	fmt.Fprintf(w, "[explain] %s\n", trace.Package)
+
+	for _, rule := range trace.Rules {
+		switch {
+		case rule.Result:
+			fmt.Fprintf(w, "  %s %s (%s)\n", traceTrue.Sprint("true "), rule.Name, rule.Location)
+
+		case !rule.Evaluated:
+			fmt.Fprintf(w, "  %s %s (%s) not evaluated, indexed condition did not match\n", traceSkipped.Sprint("skip "), rule.Name, rule.Location)
+
+		default:
+			fmt.Fprintf(w, "  %s %s (%s)\n", traceFalse.Sprint("false"), rule.Name, rule.Location)
+			for _, expr := range rule.Fails {
+				fmt.Fprintf(w, "        failed: %s (%s)\n", expr.Expr, expr.Location)
+			}
+		}
+	}
+}
@@ -8,5 +8,6 @@ import (
 )
 
 func (x *Chain) RunWorkflow(ctx context.Context, alert model.Alert, svc *service.Services) error {
-	return x.runWorkflow(ctx, alert, svc)
+	_, err := x.runWorkflow(ctx, alert, svc)
+	return err
 }
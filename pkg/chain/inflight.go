@@ -0,0 +1,97 @@
+package chain
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+
+	"github.com/secmon-lab/alertchain/pkg/ctxutil"
+	"github.com/secmon-lab/alertchain/pkg/domain/types"
+	"github.com/secmon-lab/alertchain/pkg/logging"
+	"github.com/secmon-lab/alertchain/pkg/service"
+)
+
+// inflight tracks running workflows so that they can be interrupted on shutdown.
+type inflight struct {
+	mutex     sync.Mutex
+	workflows map[types.WorkflowID]*inflightWorkflow
+}
+
+type inflightWorkflow struct {
+	workflow    *service.Workflow
+	cancel      context.CancelFunc
+	namespace   types.Namespace // not empty while the namespace is locked by the workflow
+	interrupted bool
+}
+
+func newInflight() *inflight {
+	return &inflight{
+		workflows: make(map[types.WorkflowID]*inflightWorkflow),
+	}
+}
+
+func (x *inflight) add(wf *service.Workflow, cancel context.CancelFunc) {
+	x.mutex.Lock()
+	defer x.mutex.Unlock()
+	x.workflows[wf.ID()] = &inflightWorkflow{workflow: wf, cancel: cancel}
+}
+
+// locked registers the namespace lock held by the workflow. It returns false if the workflow has been interrupted while acquiring the lock. Then Interrupt could not release the lock and the caller must release it by itself.
+func (x *inflight) locked(id types.WorkflowID, ns types.Namespace) bool {
+	x.mutex.Lock()
+	defer x.mutex.Unlock()
+	entry, ok := x.workflows[id]
+	if !ok || entry.interrupted {
+		return false
+	}
+	entry.namespace = ns
+	return true
+}
+
+// unlock returns true if the workflow still owns the namespace lock and should release it. The lock has been already released if the workflow was interrupted.
+func (x *inflight) unlock(id types.WorkflowID) bool {
+	x.mutex.Lock()
+	defer x.mutex.Unlock()
+	entry, ok := x.workflows[id]
+	if !ok || entry.interrupted {
+		return false
+	}
+	entry.namespace = ""
+	return true
+}
+
+// remove returns false if the workflow was interrupted. Then the workflow record should not be updated anymore.
+func (x *inflight) remove(id types.WorkflowID) bool {
+	x.mutex.Lock()
+	defer x.mutex.Unlock()
+	entry, ok := x.workflows[id]
+	delete(x.workflows, id)
+	return ok && !entry.interrupted
+}
+
+// Interrupt stops running workflows, marks them as interrupted in the database and releases their namespace locks. It should be called on shutdown after in-flight requests are drained as far as possible.
+func (x *Chain) Interrupt(ctx context.Context) {
+	x.inflight.mutex.Lock()
+	defer x.inflight.mutex.Unlock()
+
+	logger := ctxutil.Logger(ctx)
+	for id, entry := range x.inflight.workflows {
+		if entry.interrupted {
+			continue
+		}
+		entry.interrupted = true
+		entry.cancel()
+
+		if err := entry.workflow.Interrupt(ctx); err != nil {
+			logger.Error("failed to mark workflow as interrupted", slog.Any("workflow_id", id), logging.ErrAttr(err))
+		}
+
+		if entry.namespace != "" {
+			if err := x.dbClient.Unlock(ctx, entry.namespace); err != nil {
+				logger.Error("failed to release namespace lock", slog.Any("namespace", entry.namespace), logging.ErrAttr(err))
+			}
+		}
+
+		logger.Warn("interrupted workflow", slog.Any("workflow_id", id), slog.Any("namespace", entry.namespace))
+	}
+}
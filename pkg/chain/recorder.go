@@ -13,8 +13,9 @@ func (*dummyScenarioRecorder) NewAlertRecorder(alert *model.Alert) interfaces.Al
 
 var _ interfaces.ScenarioRecorder = &dummyScenarioRecorder{}
 
-func (x *dummyScenarioRecorder) LogError(err error) {}
-func (x *dummyScenarioRecorder) Flush() error       { return nil }
+func (x *dummyScenarioRecorder) LogError(err error)                {}
+func (x *dummyScenarioRecorder) LogTrace(trace *model.PolicyTrace) {}
+func (x *dummyScenarioRecorder) Flush() error                      { return nil }
 
 type dummyAlertRecorder struct{}
 
@@ -4,41 +4,115 @@ import (
 	"context"
 	"errors"
 	"log/slog"
+	"time"
 
 	"github.com/m-mizutani/goerr/v2"
+	"github.com/m-mizutani/gots/ptr"
 	"github.com/secmon-lab/alertchain/pkg/ctxutil"
 	"github.com/secmon-lab/alertchain/pkg/domain/interfaces"
 	"github.com/secmon-lab/alertchain/pkg/domain/model"
 	"github.com/secmon-lab/alertchain/pkg/domain/types"
+	"github.com/secmon-lab/alertchain/pkg/infra/tracing"
+	"github.com/secmon-lab/alertchain/pkg/logging"
 	"github.com/secmon-lab/alertchain/pkg/service"
+	"go.opentelemetry.io/otel/attribute"
 )
 
-func (x *Chain) runWorkflow(ctx context.Context, alert model.Alert, svc *service.Services) error {
+func (x *Chain) runWorkflow(ctx context.Context, alert model.Alert, svc *service.Services) (wfID types.WorkflowID, err error) {
+	ctx, span := tracing.Start(ctx, "workflow",
+		attribute.String("alertchain.alert_id", string(alert.ID)),
+		attribute.String("alertchain.namespace", string(alert.Namespace)),
+	)
+	defer func() { tracing.End(span, err) }()
+
 	wfSvc, err := svc.Workflow.Create(ctx, alert)
 	if err != nil {
-		return err
+		return "", err
 	}
+	wfID = wfSvc.ID()
+	span.SetAttributes(attribute.String("alertchain.workflow_id", string(wfSvc.ID())))
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	x.inflight.add(wfSvc, cancel)
+
+	if x.httpClient != nil {
+		ctx = ctxutil.InjectHTTPClient(ctx, x.httpClient)
+	}
+
+	x.publishEvent(ctx, &model.WorkflowEvent{
+		Type:       model.WorkflowEventCreated,
+		WorkflowID: wfID,
+		AlertID:    alert.ID,
+	})
+
+	var sequences int
+	defer func() {
+		x.instrument.WorkflowFinished(ctx, sequences, err)
+	}()
+	defer func() {
+		status := model.WorkflowStatusCompleted
+		if err != nil {
+			status = model.WorkflowStatusFailed
+		}
+
+		// Interrupted workflow has been already recorded by Interrupt
+		if !x.inflight.remove(wfSvc.ID()) {
+			status = model.WorkflowStatusInterrupted
+		} else if finErr := wfSvc.Finish(context.WithoutCancel(ctx), err); finErr != nil {
+			ctxutil.Logger(ctx).Error("failed to update workflow status", logging.ErrAttr(finErr))
+		}
+
+		event := &model.WorkflowEvent{
+			Type:       model.WorkflowEventFinished,
+			WorkflowID: wfID,
+			AlertID:    alert.ID,
+			Status:     &status,
+		}
+		if err != nil {
+			event.Error = ptr.To(err.Error())
+		}
+		x.publishEvent(ctx, event)
+	}()
 
 	copied := alert.Copy()
 	AlertRecorder := x.recorder.NewAlertRecorder(&copied)
 	logger := ctxutil.Logger(ctx)
 
 	ctx = ctxutil.InjectAlert(ctx, &alert)
+	ctx = ctxutil.InjectWorkflowID(ctx, wfSvc.ID())
 
-	if alert.Namespace != "" {
+	dryRun := ctxutil.IsDryRun(ctx)
+	span.SetAttributes(attribute.Bool("alertchain.dry_run", dryRun))
+
+	// Namespace is not locked in dry-run mode to avoid blocking workflows of actual alerts
+	if alert.Namespace != "" && !dryRun {
 		timeoutAt := x.now().Add(x.timeout)
+		lockStartedAt := time.Now()
 		if err := x.dbClient.Lock(ctx, alert.Namespace, timeoutAt); err != nil {
-			return goerr.Wrap(err, "failed to lock namespace")
+			return wfID, goerr.Wrap(err, "failed to lock namespace")
+		}
+		x.instrument.LockWaited(ctx, alert.Namespace, time.Since(lockStartedAt))
+		if !x.inflight.locked(wfSvc.ID(), alert.Namespace) {
+			if err := x.dbClient.Unlock(context.WithoutCancel(ctx), alert.Namespace); err != nil {
+				logger.Error("failed to unlock", slog.Any("alert", alert))
+			}
+			return wfID, goerr.New("workflow interrupted while locking namespace", goerr.V("namespace", alert.Namespace))
 		}
 		defer func() {
-			if err := x.dbClient.Unlock(ctx, alert.Namespace); err != nil {
+			if !x.inflight.unlock(wfSvc.ID()) {
+				return
+			}
+			if err := x.dbClient.Unlock(context.WithoutCancel(ctx), alert.Namespace); err != nil {
 				logger.Error("failed to unlock", slog.Any("alert", alert))
 			}
 		}()
+	}
 
+	if alert.Namespace != "" {
 		persistent, err := x.dbClient.GetAttrs(ctx, alert.Namespace)
 		if err != nil {
-			return goerr.Wrap(err, "failed to get persistent attrs")
+			return wfID, goerr.Wrap(err, "failed to get persistent attrs")
 		}
 
 		logger.Debug("loaded persistent attributes", slog.Any("attrs", persistent))
@@ -57,11 +131,18 @@ func (x *Chain) runWorkflow(ctx context.Context, alert model.Alert, svc *service
 			queryActionPolicy: x.queryActionPolicy,
 			actionMap:         x.actionMap,
 			actionMock:        x.actionMock,
+			instrument:        x.instrument,
+			publishEvent:      x.publishEvent,
 		}
+		sequences = i + 1
 
 		results, err := seq.evaluateAndRunActions(ctx)
 		if err != nil {
-			return err
+			// Keep the failed action in the workflow record for troubleshooting and stats
+			if addErr := wfSvc.AddActions(context.WithoutCancel(ctx), i, results); addErr != nil {
+				logger.Error("failed to record actions of failed sequence", logging.ErrAttr(addErr))
+			}
+			return wfID, err
 		}
 
 		if len(results) > 0 {
@@ -72,6 +153,21 @@ func (x *Chain) runWorkflow(ctx context.Context, alert model.Alert, svc *service
 				history.add(*r)
 			}
 		}
+		if err := wfSvc.AddActions(ctx, i, results); err != nil {
+			return wfID, err
+		}
+		x.publishEvent(ctx, &model.WorkflowEvent{
+			Type:       model.WorkflowEventSequenceEvaluated,
+			WorkflowID: wfID,
+			AlertID:    alert.ID,
+			Seq:        ptr.To(i),
+			Actions:    ptr.To(len(results)),
+		})
+		if timeline := ctxutil.GetTimeline(ctx); timeline != nil {
+			for _, r := range results {
+				timeline.Add(alert.ID, i, r)
+			}
+		}
 
 		finalized := alert.Attrs.Copy()
 		for _, r := range results {
@@ -87,7 +183,7 @@ func (x *Chain) runWorkflow(ctx context.Context, alert model.Alert, svc *service
 
 	}
 
-	if alert.Namespace != "" {
+	if alert.Namespace != "" && !dryRun {
 		var persistent model.Attributes
 		for i := range alert.Attrs {
 			if alert.Attrs[i].Persist {
@@ -96,17 +192,26 @@ func (x *Chain) runWorkflow(ctx context.Context, alert model.Alert, svc *service
 		}
 
 		if err := x.dbClient.PutAttrs(ctx, alert.Namespace, persistent); err != nil {
-			return goerr.Wrap(err, "failed to put persistent attrs")
+			return wfID, goerr.Wrap(err, "failed to put persistent attrs")
 		}
 
 		logger.Debug("saved persistent attributes", slog.Any("attrs", persistent))
 	}
 
 	if err := wfSvc.UpdateLastAttrs(ctx, alert.Attrs); err != nil {
-		return err
+		return wfID, err
 	}
 
-	return nil
+	return wfID, nil
+}
+
+// publishEvent sends the event to the publisher if configured. Timestamp is set by the function.
+func (x *Chain) publishEvent(ctx context.Context, event *model.WorkflowEvent) {
+	if x.events == nil {
+		return
+	}
+	event.Timestamp = ctxutil.Now(ctx)
+	x.events.Publish(ctx, event)
 }
 
 type actionHistory struct {
@@ -143,6 +248,8 @@ type sequence struct {
 	queryActionPolicy func(ctx context.Context, in, out any) error
 	actionMock        interfaces.ActionMock
 	actionMap         map[types.ActionName]model.RunAction
+	instrument        interfaces.Instrument
+	publishEvent      func(ctx context.Context, event *model.WorkflowEvent)
 }
 
 func (x *sequence) evaluateAndRunActions(ctx context.Context) ([]*model.ActionResult, error) {
@@ -170,7 +277,7 @@ func (x *sequence) evaluateAndRunActions(ctx context.Context) ([]*model.ActionRe
 			if errors.Is(err, errActionAbort) {
 				continue
 			}
-			return nil, err
+			return runActions, err
 		}
 	}
 
@@ -179,8 +286,8 @@ func (x *sequence) evaluateAndRunActions(ctx context.Context) ([]*model.ActionRe
 
 var errActionAbort = goerr.New("action aborted")
 
-// runAction runs an action and returns the result. If the action is already called, it returns nil.
-func (x *sequence) runAction(ctx context.Context, baseAction model.Action) (*model.ActionResult, error) {
+// runAction runs an action and returns the result. If the action is already called, it returns nil. If the action failed, it returns the result with the error message together with the error.
+func (x *sequence) runAction(ctx context.Context, baseAction model.Action) (_ *model.ActionResult, err error) {
 	copied := baseAction.Copy()
 
 	if copied.ID == "" {
@@ -195,7 +302,9 @@ func (x *sequence) runAction(ctx context.Context, baseAction model.Action) (*mod
 		return nil, errActionAbort
 	}
 
+	startedAt := ctxutil.Now(ctx)
 	var result any
+	var forcedErr string
 	if copied.Uses != "" {
 		run, ok := x.actionMap[copied.Uses]
 		if !ok {
@@ -204,13 +313,57 @@ func (x *sequence) runAction(ctx context.Context, baseAction model.Action) (*mod
 
 		logger.Debug("run action", slog.Any("proc", copied))
 
+		x.publishEvent(ctx, &model.WorkflowEvent{
+			Type:       model.WorkflowEventActionStarted,
+			WorkflowID: ctxutil.GetWorkflowID(ctx),
+			AlertID:    x.alert.ID,
+			Seq:        ptr.To(x.idx),
+			ActionID:   ptr.To(string(copied.ID)),
+			Uses:       ptr.To(string(copied.Uses)),
+		})
+		defer func() {
+			event := &model.WorkflowEvent{
+				Type:       model.WorkflowEventActionFinished,
+				WorkflowID: ctxutil.GetWorkflowID(ctx),
+				AlertID:    x.alert.ID,
+				Seq:        ptr.To(x.idx),
+				ActionID:   ptr.To(string(copied.ID)),
+				Uses:       ptr.To(string(copied.Uses)),
+			}
+			if err != nil {
+				event.Error = ptr.To(err.Error())
+			} else if forcedErr != "" {
+				event.Error = &forcedErr
+			}
+			x.publishEvent(ctx, event)
+		}()
+
+		ctx, span := tracing.Start(ctx, "action",
+			attribute.String("alertchain.action.uses", string(copied.Uses)),
+			attribute.String("alertchain.action.id", string(copied.ID)),
+			attribute.Bool("alertchain.action.mock", x.actionMock != nil),
+		)
+		defer func() { tracing.End(span, err) }()
+
 		// Run action. If actionMock is set, use it instead of action.Run()
 		if x.actionMock != nil {
 			result = x.actionMock.GetResult(copied.Uses)
 		} else {
+			runAt := time.Now()
 			resp, err := run(ctx, x.alert, copied.Args)
+			x.instrument.ActionExecuted(ctx, copied.Uses, time.Since(runAt), err)
 			if err != nil && !copied.Force {
-				return nil, goerr.Wrap(err, "failed to run action", goerr.V("action", copied), goerr.T(types.ErrTagAction))
+				failed := &model.ActionResult{
+					Action:     copied,
+					StartedAt:  startedAt,
+					FinishedAt: ctxutil.Now(ctx),
+					Error:      err.Error(),
+				}
+				return failed, goerr.Wrap(err, "failed to run action", goerr.V("action", copied), goerr.T(types.ErrTagAction))
+			} else if err != nil {
+				// Error of forced action is ignored in the workflow, but keep it in the trace and the workflow record
+				span.RecordError(err)
+				forcedErr = err.Error()
 			}
 			result = resp
 		}
@@ -221,6 +374,11 @@ func (x *sequence) runAction(ctx context.Context, baseAction model.Action) (*mod
 	for _, c := range baseAction.Commit {
 		resolved, err := c.ToAttr(result)
 		if err != nil {
+			// Result of action in dry-run mode does not have actual response to be committed
+			if ctxutil.IsDryRun(ctx) {
+				logger.Debug("skip commit in dry-run mode", slog.Any("commit", c), logging.ErrAttr(err))
+				continue
+			}
 			return nil, err
 		}
 		if resolved == nil {
@@ -233,8 +391,11 @@ func (x *sequence) runAction(ctx context.Context, baseAction model.Action) (*mod
 	}
 
 	actionResult := model.ActionResult{
-		Action: copied,
-		Result: result,
+		Action:     copied,
+		Result:     result,
+		StartedAt:  startedAt,
+		FinishedAt: ctxutil.Now(ctx),
+		Error:      forcedErr,
 	}
 
 	return &actionResult, nil
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"testing"
 
 	"github.com/m-mizutani/gt"
@@ -82,3 +83,103 @@ func TestWorkflow(t *testing.T) {
 		})
 	})
 }
+
+type eventRecorder struct {
+	events []*model.WorkflowEvent
+}
+
+func (x *eventRecorder) Publish(_ context.Context, event *model.WorkflowEvent) {
+	x.events = append(x.events, event)
+}
+
+func TestWorkflowEvents(t *testing.T) {
+	actionPolicy := gt.R1(policy.New(
+		policy.WithPackage("action"),
+		policy.WithFile("testdata/play_workflow/action.rego"),
+		policy.WithReadFile(read),
+	)).NoError(t)
+
+	events := &eventRecorder{}
+	mock := func(ctx context.Context, alert model.Alert, _ model.ActionArgs) (any, error) {
+		return nil, nil
+	}
+	c := gt.R1(chain.New(
+		chain.WithPolicyAction(actionPolicy),
+		chain.WithExtraAction("mock", mock),
+		chain.WithEnv(func() types.EnvVars { return types.EnvVars{} }),
+		chain.WithWorkflowEventPublisher(events),
+	)).NoError(t)
+
+	ctx := context.Background()
+	alert := model.NewAlert(model.AlertMetaData{
+		Title: "test-alert",
+	}, "test-alert", "test-data")
+	gt.NoError(t, c.RunWorkflow(ctx, alert, service.New(memory.New())))
+
+	var got []string
+	for _, ev := range events.events {
+		gt.V(t, ev.AlertID).Equal(alert.ID)
+		gt.V(t, ev.WorkflowID).Equal(events.events[0].WorkflowID)
+		gt.B(t, ev.Timestamp.IsZero()).False()
+		got = append(got, ev.Type)
+	}
+	gt.V(t, got).Equal([]string{
+		model.WorkflowEventCreated,
+		model.WorkflowEventActionStarted,
+		model.WorkflowEventActionFinished,
+		model.WorkflowEventSequenceEvaluated,
+		model.WorkflowEventActionStarted,
+		model.WorkflowEventActionFinished,
+		model.WorkflowEventSequenceEvaluated,
+		model.WorkflowEventSequenceEvaluated,
+		model.WorkflowEventFinished,
+	})
+
+	started := events.events[4]
+	gt.V(t, *started.Seq).Equal(1)
+	gt.V(t, *started.ActionID).Equal("2nd")
+	gt.V(t, *started.Uses).Equal("mock")
+
+	evaluated := events.events[7]
+	gt.V(t, *evaluated.Seq).Equal(2)
+	gt.V(t, *evaluated.Actions).Equal(0)
+
+	finished := events.events[8]
+	gt.V(t, *finished.Status).Equal(model.WorkflowStatusCompleted)
+	gt.V(t, finished.Error).Nil()
+}
+
+func TestWorkflowRecordsFailedAction(t *testing.T) {
+	actionPolicy := gt.R1(policy.New(
+		policy.WithPackage("action"),
+		policy.WithPolicyData("action.rego", `package action
+
+run contains {"id": "fail", "uses": "mock"} if input.seq == 0
+`),
+	)).NoError(t)
+
+	dbClient := memory.New()
+	c := gt.R1(chain.New(
+		chain.WithPolicyAction(actionPolicy),
+		chain.WithExtraAction("mock", func(ctx context.Context, alert model.Alert, _ model.ActionArgs) (any, error) {
+			return nil, errors.New("connection refused")
+		}),
+		chain.WithEnv(func() types.EnvVars { return types.EnvVars{} }),
+		chain.WithDatabase(dbClient),
+	)).NoError(t)
+
+	ctx := context.Background()
+	alert := model.NewAlert(model.AlertMetaData{Title: "test-alert"}, "test-alert", "test-data")
+	gt.Error(t, c.RunWorkflow(ctx, alert, service.New(dbClient)))
+
+	workflows := gt.R1(dbClient.GetWorkflows(ctx, 0, 10)).NoError(t)
+	gt.A(t, workflows).Length(1).At(0, func(t testing.TB, v model.WorkflowRecord) {
+		gt.V(t, v.Status).Equal(model.WorkflowStatusFailed)
+		gt.A(t, v.Actions).Length(1).At(0, func(t testing.TB, v *model.ActionRecord) {
+			gt.V(t, v.ID).Equal("fail")
+			gt.V(t, v.Uses).Equal("mock")
+			gt.V(t, v.Error).Must().NotNil()
+			gt.S(t, *v.Error).Contains("connection refused")
+		})
+	})
+}
@@ -45,6 +45,7 @@ func New() *CLI {
 		Commands: []*cli.Command{
 			cmdServe(),
 			cmdRun(),
+			cmdWatch(),
 			cmdPlay(),
 			cmdEnhance(),
 			cmdNew(),
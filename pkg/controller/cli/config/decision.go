@@ -0,0 +1,73 @@
+package config
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+
+	"github.com/m-mizutani/goerr/v2"
+	"github.com/secmon-lab/alertchain/pkg/domain/interfaces"
+	"github.com/secmon-lab/alertchain/pkg/domain/types"
+	"github.com/secmon-lab/alertchain/pkg/infra/decision"
+	"github.com/secmon-lab/alertchain/pkg/utils"
+	"github.com/urfave/cli/v3"
+)
+
+type DecisionLog struct {
+	sinkType string
+	file     string
+}
+
+func (x *DecisionLog) Flags() []cli.Flag {
+	category := "Decision Log"
+
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:        "decision-log",
+			Usage:       "Sink of policy decision log (stdout, file, database). Disabled if empty",
+			Category:    category,
+			Sources:     cli.EnvVars("ALERTCHAIN_DECISION_LOG"),
+			Destination: &x.sinkType,
+		},
+		&cli.StringFlag{
+			Name:        "decision-log-file",
+			Usage:       "File path of JSONL decision log. Required if decision-log is 'file'",
+			Category:    category,
+			Sources:     cli.EnvVars("ALERTCHAIN_DECISION_LOG_FILE"),
+			Destination: &x.file,
+		},
+	}
+}
+
+// New creates a sink of decision log. It returns nil sink if decision log is disabled. The db is used only for "database" sink and can be nil for other sinks.
+func (x *DecisionLog) New(ctx context.Context, db interfaces.Database) (interfaces.DecisionLogSink, func(), error) {
+	nopCloser := func() {}
+
+	switch x.sinkType {
+	case "":
+		return nil, nopCloser, nil
+
+	case "stdout", "-":
+		return decision.NewJSONL(os.Stdout), nopCloser, nil
+
+	case "file":
+		if x.file == "" {
+			return nil, nopCloser, goerr.New("decision-log-file is required for file sink", goerr.T(types.ErrTagConfig))
+		}
+
+		f, err := os.OpenFile(filepath.Clean(x.file), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+		if err != nil {
+			return nil, nopCloser, goerr.Wrap(err, "failed to open decision log file", goerr.V("path", x.file), goerr.T(types.ErrTagConfig))
+		}
+		return decision.NewJSONL(f), func() { utils.SafeClose(ctx, f) }, nil
+
+	case "database":
+		if db == nil {
+			return nil, nopCloser, goerr.New("database sink is not available in this command", goerr.T(types.ErrTagConfig))
+		}
+		return decision.NewDatabase(db), nopCloser, nil
+
+	default:
+		return nil, nopCloser, goerr.New("invalid decision-log", goerr.V("decision-log", x.sinkType), goerr.T(types.ErrTagConfig))
+	}
+}
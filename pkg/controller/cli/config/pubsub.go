@@ -0,0 +1,76 @@
+package config
+
+import (
+	"context"
+	"time"
+
+	"github.com/secmon-lab/alertchain/pkg/ctxutil"
+	"github.com/secmon-lab/alertchain/pkg/infra/oidc"
+	"github.com/urfave/cli/v3"
+)
+
+type PubSub struct {
+	audience string
+	emails   []string
+	jwksFile string
+	dedupTTL time.Duration
+}
+
+func (x *PubSub) Flags() []cli.Flag {
+	category := "Pub/Sub"
+
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:        "pubsub-audience",
+			Usage:       "Audience of ID token in Pub/Sub push request. ID token verification is enabled if set",
+			Category:    category,
+			Sources:     cli.EnvVars("ALERTCHAIN_PUBSUB_AUDIENCE"),
+			Destination: &x.audience,
+		},
+		&cli.StringSliceFlag{
+			Name:        "pubsub-email",
+			Usage:       "Allowed service account email of ID token in Pub/Sub push request. Any verified email is allowed if not set",
+			Category:    category,
+			Sources:     cli.EnvVars("ALERTCHAIN_PUBSUB_EMAIL"),
+			Destination: &x.emails,
+		},
+		&cli.StringFlag{
+			Name:        "pubsub-jwks-file",
+			Usage:       "Load JWKS from the file instead of Google's JWKS endpoint",
+			Category:    category,
+			Sources:     cli.EnvVars("ALERTCHAIN_PUBSUB_JWKS_FILE"),
+			Destination: &x.jwksFile,
+		},
+		&cli.DurationFlag{
+			Name:        "pubsub-dedup-ttl",
+			Usage:       "Duration to remember processed Pub/Sub message IDs to skip redelivered messages. 0 disables deduplication",
+			Category:    category,
+			Sources:     cli.EnvVars("ALERTCHAIN_PUBSUB_DEDUP_TTL"),
+			Value:       24 * time.Hour,
+			Destination: &x.dedupTTL,
+		},
+	}
+}
+
+// NewVerifier creates a verifier of ID token in Pub/Sub push request. It returns nil if the verification is disabled.
+func (x *PubSub) NewVerifier(ctx context.Context) (*oidc.Verifier, error) {
+	if x.audience == "" {
+		ctxutil.Logger(ctx).Warn("ID token verification of Pub/Sub push request is disabled")
+		return nil, nil
+	}
+
+	options := []oidc.Option{
+		oidc.WithAudience(x.audience),
+		oidc.WithEmail(x.emails...),
+	}
+	if x.jwksFile != "" {
+		options = append(options, oidc.WithJWKSFile(x.jwksFile))
+	}
+
+	return oidc.New(options...)
+}
+
+// DedupTTL returns duration to remember processed message IDs. Zero means deduplication is disabled.
+func (x *PubSub) DedupTTL() time.Duration {
+	return x.dedupTTL
+}
@@ -0,0 +1,121 @@
+package config
+
+import (
+	"strings"
+
+	"github.com/m-mizutani/goerr/v2"
+	"github.com/secmon-lab/alertchain/pkg/domain/types"
+	"github.com/secmon-lab/alertchain/pkg/infra/ratelimit"
+	"github.com/urfave/cli/v3"
+)
+
+type RateLimit struct {
+	global     string
+	remote     string
+	schemas    []string
+	classes    []string
+	sampleRate float64
+}
+
+func (x *RateLimit) Flags() []cli.Flag {
+	category := "Rate limit"
+
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:        "rate-limit-global",
+			Usage:       "Limit of all /alert/* requests in N/UNIT[:BURST] format (UNIT is s, m or h), e.g. 100/s",
+			Category:    category,
+			Sources:     cli.EnvVars("ALERTCHAIN_RATE_LIMIT_GLOBAL"),
+			Destination: &x.global,
+		},
+		&cli.StringFlag{
+			Name:        "rate-limit-remote",
+			Usage:       "Limit of /alert/* requests per remote address in N/UNIT[:BURST] format",
+			Category:    category,
+			Sources:     cli.EnvVars("ALERTCHAIN_RATE_LIMIT_REMOTE"),
+			Destination: &x.remote,
+		},
+		&cli.StringSliceFlag{
+			Name:        "rate-limit-schema",
+			Usage:       "Limit of /alert/* requests per schema in SCHEMA=N/UNIT[:BURST] format. SCHEMA '*' is applied to schemas without own limit",
+			Category:    category,
+			Sources:     cli.EnvVars("ALERTCHAIN_RATE_LIMIT_SCHEMA"),
+			Destination: &x.schemas,
+		},
+		&cli.StringSliceFlag{
+			Name:        "rate-limit-class",
+			Usage:       "Rate class selected by rate_class of authz policy output in CLASS=N/UNIT[:BURST] format. It is applied per remote address in addition to other limits",
+			Category:    category,
+			Sources:     cli.EnvVars("ALERTCHAIN_RATE_LIMIT_CLASS"),
+			Destination: &x.classes,
+		},
+		&cli.FloatFlag{
+			Name:        "rate-limit-sample",
+			Usage:       "Process the ratio (0.0 - 1.0) of requests exceeding the limit and drop others with 202, instead of rejecting them with 429",
+			Category:    category,
+			Sources:     cli.EnvVars("ALERTCHAIN_RATE_LIMIT_SAMPLE"),
+			Destination: &x.sampleRate,
+		},
+	}
+}
+
+func parseNamedLimit(s string) (string, ratelimit.Limit, error) {
+	name, spec, found := strings.Cut(s, "=")
+	if !found || name == "" {
+		return "", ratelimit.Limit{}, goerr.New("rate limit must be NAME=N/UNIT[:BURST]", goerr.V("limit", s))
+	}
+
+	limit, err := ratelimit.ParseLimit(spec)
+	if err != nil {
+		return "", ratelimit.Limit{}, err
+	}
+	return name, limit, nil
+}
+
+// New creates a rate limiter. It returns nil if no limit is configured.
+func (x *RateLimit) New() (*ratelimit.Limiter, error) {
+	if x.global == "" && x.remote == "" && len(x.schemas) == 0 && len(x.classes) == 0 {
+		return nil, nil
+	}
+	if x.sampleRate < 0 || x.sampleRate > 1 {
+		return nil, goerr.New("sample ratio of rate limit must be between 0.0 and 1.0", goerr.V("sample", x.sampleRate))
+	}
+
+	options := []ratelimit.Option{
+		ratelimit.WithSampling(x.sampleRate),
+	}
+
+	if x.global != "" {
+		limit, err := ratelimit.ParseLimit(x.global)
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, ratelimit.WithGlobal(limit))
+	}
+
+	if x.remote != "" {
+		limit, err := ratelimit.ParseLimit(x.remote)
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, ratelimit.WithRemote(limit))
+	}
+
+	for _, s := range x.schemas {
+		schema, limit, err := parseNamedLimit(s)
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, ratelimit.WithSchema(types.Schema(schema), limit))
+	}
+
+	for _, s := range x.classes {
+		class, limit, err := parseNamedLimit(s)
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, ratelimit.WithClass(class, limit))
+	}
+
+	return ratelimit.New(options...), nil
+}
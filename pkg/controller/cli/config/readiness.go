@@ -0,0 +1,133 @@
+package config
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/m-mizutani/goerr/v2"
+	"github.com/secmon-lab/alertchain/pkg/controller/server"
+	"github.com/secmon-lab/alertchain/pkg/domain/types"
+	"github.com/secmon-lab/alertchain/pkg/utils"
+	"github.com/urfave/cli/v3"
+)
+
+type Readiness struct {
+	requiredEnv   []string
+	probes        []string
+	probeTokens   []string
+	probeInterval time.Duration
+}
+
+func (x *Readiness) Flags() []cli.Flag {
+	category := "Readiness"
+
+	return []cli.Flag{
+		&cli.StringSliceFlag{
+			Name:        "ready-require-env",
+			Usage:       "Environment variable that must be set for /ready, e.g. credential used by actions",
+			Category:    category,
+			Sources:     cli.EnvVars("ALERTCHAIN_READY_REQUIRE_ENV"),
+			Destination: &x.requiredEnv,
+		},
+		&cli.StringSliceFlag{
+			Name:        "ready-probe",
+			Usage:       "HTTP endpoint probed by /ready in NAME=URL format. 2xx response is ready",
+			Category:    category,
+			Sources:     cli.EnvVars("ALERTCHAIN_READY_PROBE"),
+			Destination: &x.probes,
+		},
+		&cli.StringSliceFlag{
+			Name:        "ready-probe-token",
+			Usage:       "Environment variable of bearer token for the probe in NAME=ENV_VAR format, e.g. slack=SLACK_TOKEN",
+			Category:    category,
+			Sources:     cli.EnvVars("ALERTCHAIN_READY_PROBE_TOKEN"),
+			Destination: &x.probeTokens,
+		},
+		&cli.DurationFlag{
+			Name:        "ready-probe-interval",
+			Usage:       "Duration to cache result of the probe",
+			Category:    category,
+			Sources:     cli.EnvVars("ALERTCHAIN_READY_PROBE_INTERVAL"),
+			Value:       time.Minute,
+			Destination: &x.probeInterval,
+		},
+	}
+}
+
+// Checks returns readiness checks of required environment variables and probes of action credentials.
+func (x *Readiness) Checks() ([]server.ReadinessCheck, error) {
+	var checks []server.ReadinessCheck
+
+	for _, name := range x.requiredEnv {
+		checks = append(checks, server.ReadinessCheck{
+			Name: "env:" + name,
+			Check: func(ctx context.Context) error {
+				if os.Getenv(name) == "" {
+					return goerr.New("required environment variable is not set", goerr.V("name", name), goerr.T(types.ErrTagConfig))
+				}
+				return nil
+			},
+		})
+	}
+
+	tokens := map[string]string{}
+	for _, s := range x.probeTokens {
+		name, env, found := strings.Cut(s, "=")
+		if !found || name == "" || env == "" {
+			return nil, goerr.New("probe token must be NAME=ENV_VAR", goerr.V("token", s), goerr.T(types.ErrTagConfig))
+		}
+		tokens[name] = env
+	}
+
+	for _, s := range x.probes {
+		name, url, found := strings.Cut(s, "=")
+		if !found || name == "" || url == "" {
+			return nil, goerr.New("probe must be NAME=URL", goerr.V("probe", s), goerr.T(types.ErrTagConfig))
+		}
+		env := tokens[name]
+		delete(tokens, name)
+
+		checks = append(checks, server.ReadinessCheck{
+			Name:     "probe:" + name,
+			Interval: x.probeInterval,
+			Check: func(ctx context.Context) error {
+				return probeHTTP(ctx, url, env)
+			},
+		})
+	}
+
+	for name := range tokens {
+		return nil, goerr.New("probe token has no probe", goerr.V("name", name), goerr.T(types.ErrTagConfig))
+	}
+
+	return checks, nil
+}
+
+func probeHTTP(ctx context.Context, url, tokenEnv string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return goerr.Wrap(err, "failed to create probe request", goerr.V("url", url))
+	}
+
+	if tokenEnv != "" {
+		token := os.Getenv(tokenEnv)
+		if token == "" {
+			return goerr.New("token of probe is not set", goerr.V("env", tokenEnv), goerr.T(types.ErrTagConfig))
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return goerr.Wrap(err, "failed to send probe request", goerr.V("url", url))
+	}
+	defer utils.SafeClose(ctx, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return goerr.New("probe returned non-2xx status", goerr.V("url", url), goerr.V("status", resp.StatusCode))
+	}
+	return nil
+}
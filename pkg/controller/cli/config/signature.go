@@ -0,0 +1,72 @@
+package config
+
+import (
+	"os"
+	"strings"
+
+	"github.com/m-mizutani/goerr/v2"
+	"github.com/secmon-lab/alertchain/pkg/domain/types"
+	"github.com/secmon-lab/alertchain/pkg/infra/signature"
+	"github.com/urfave/cli/v3"
+)
+
+type Signature struct {
+	rules []string
+}
+
+func (x *Signature) Flags() []cli.Flag {
+	category := "Signature"
+
+	return []cli.Flag{
+		&cli.StringSliceFlag{
+			Name:        "signature",
+			Usage:       "HMAC signature verification of request body in format of 'type:path:secret_env[:header]'. type is github, slack or generic. path can end with '*' for prefix match. secret_env is name of environment variable that has the secret",
+			Category:    category,
+			Sources:     cli.EnvVars("ALERTCHAIN_SIGNATURE"),
+			Destination: &x.rules,
+		},
+	}
+}
+
+// Rules parses the flag values into signature verification rules.
+func (x *Signature) Rules() ([]signature.Rule, error) {
+	var rules []signature.Rule
+
+	for _, v := range x.rules {
+		parts := strings.Split(v, ":")
+		if len(parts) < 3 || len(parts) > 4 || parts[1] == "" || parts[2] == "" {
+			return nil, goerr.New("invalid signature format, expected 'type:path:secret_env[:header]'", goerr.V("signature", v), goerr.T(types.ErrTagConfig))
+		}
+		sigType, path, secretEnv := parts[0], parts[1], parts[2]
+
+		secret, ok := os.LookupEnv(secretEnv)
+		if !ok || secret == "" {
+			return nil, goerr.New("secret of signature is not set", goerr.V("env", secretEnv), goerr.T(types.ErrTagConfig))
+		}
+
+		var header string
+		if len(parts) == 4 {
+			header = parts[3]
+		}
+
+		if header != "" && sigType != "generic" {
+			return nil, goerr.New("header can be specified only for generic signature", goerr.V("signature", v), goerr.T(types.ErrTagConfig))
+		}
+
+		var verifier signature.Verifier
+		switch sigType {
+		case "github":
+			verifier = signature.NewGitHub(secret)
+		case "slack":
+			verifier = signature.NewSlack(secret)
+		case "generic":
+			verifier = signature.NewGeneric(secret, header)
+		default:
+			return nil, goerr.New("unsupported signature type", goerr.V("type", sigType), goerr.T(types.ErrTagConfig))
+		}
+
+		rules = append(rules, signature.Rule{Path: path, Verifier: verifier})
+	}
+
+	return rules, nil
+}
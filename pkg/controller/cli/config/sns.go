@@ -0,0 +1,29 @@
+package config
+
+import (
+	"github.com/secmon-lab/alertchain/pkg/infra/sns"
+	"github.com/urfave/cli/v3"
+)
+
+type SNS struct {
+	topicARNs []string
+}
+
+func (x *SNS) Flags() []cli.Flag {
+	category := "SNS"
+
+	return []cli.Flag{
+		&cli.StringSliceFlag{
+			Name:        "sns-topic-arn",
+			Usage:       "Allowed SNS topic ARN of /alert/sns/{schema}. Messages of other topics are rejected, and all messages are rejected if not set",
+			Category:    category,
+			Sources:     cli.EnvVars("ALERTCHAIN_SNS_TOPIC_ARN"),
+			Destination: &x.topicARNs,
+		},
+	}
+}
+
+// NewClient creates a client to verify and confirm SNS messages of the allowed topics.
+func (x *SNS) NewClient() *sns.Client {
+	return sns.New(sns.WithTopicARN(x.topicARNs...))
+}
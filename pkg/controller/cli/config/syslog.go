@@ -0,0 +1,103 @@
+package config
+
+import (
+	"crypto/tls"
+
+	"github.com/m-mizutani/goerr/v2"
+	"github.com/secmon-lab/alertchain/pkg/controller/syslog"
+	"github.com/secmon-lab/alertchain/pkg/domain/interfaces"
+	"github.com/secmon-lab/alertchain/pkg/domain/types"
+	"github.com/urfave/cli/v3"
+)
+
+type Syslog struct {
+	udpAddr      string
+	tcpAddr      string
+	tcpTLS       bool
+	schemaRules  []string
+	parsePayload bool
+	workers      int64
+}
+
+func (x *Syslog) Flags() []cli.Flag {
+	category := "Syslog"
+
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:        "syslog-udp",
+			Usage:       "Bind address to receive syslog over UDP, e.g. :514",
+			Category:    category,
+			Sources:     cli.EnvVars("ALERTCHAIN_SYSLOG_UDP"),
+			Destination: &x.udpAddr,
+		},
+		&cli.StringFlag{
+			Name:        "syslog-tcp",
+			Usage:       "Bind address to receive syslog over TCP, e.g. :6514",
+			Category:    category,
+			Sources:     cli.EnvVars("ALERTCHAIN_SYSLOG_TCP"),
+			Destination: &x.tcpAddr,
+		},
+		&cli.BoolFlag{
+			Name:        "syslog-tls",
+			Usage:       "Receive syslog over TLS on --syslog-tcp with the certificate of --tls-cert and --tls-key",
+			Category:    category,
+			Sources:     cli.EnvVars("ALERTCHAIN_SYSLOG_TLS"),
+			Destination: &x.tcpTLS,
+		},
+		&cli.StringSliceFlag{
+			Name:        "syslog-schema",
+			Usage:       "Schema of syslog message in 'FIELD:VALUE=SCHEMA' or '*=SCHEMA' format. FIELD is app, facility, hostname or vendor. The first matched rule is used",
+			Category:    category,
+			Sources:     cli.EnvVars("ALERTCHAIN_SYSLOG_SCHEMA"),
+			Destination: &x.schemaRules,
+		},
+		&cli.BoolFlag{
+			Name:        "syslog-parse-cef",
+			Usage:       "Parse CEF and LEEF payload of syslog message",
+			Category:    category,
+			Sources:     cli.EnvVars("ALERTCHAIN_SYSLOG_PARSE_CEF"),
+			Destination: &x.parsePayload,
+		},
+		&cli.IntFlag{
+			Name:        "syslog-workers",
+			Usage:       "Number of syslog messages handled concurrently",
+			Category:    category,
+			Sources:     cli.EnvVars("ALERTCHAIN_SYSLOG_WORKERS"),
+			Value:       4,
+			Destination: &x.workers,
+		},
+	}
+}
+
+// New creates syslog listener. It returns nil if neither UDP nor TCP is enabled. tlsConfig is the server TLS config and is required by --syslog-tls.
+func (x *Syslog) New(hdlr interfaces.AlertHandler, tlsConfig *tls.Config, options ...syslog.Option) (*syslog.Listener, error) {
+	if x.udpAddr == "" && x.tcpAddr == "" {
+		return nil, nil
+	}
+
+	if x.workers < 1 {
+		return nil, goerr.New("syslog-workers must be positive", goerr.V("workers", x.workers), goerr.T(types.ErrTagConfig))
+	}
+	options = append(options, syslog.WithUDP(x.udpAddr), syslog.WithTCP(x.tcpAddr), syslog.WithWorkers(int(x.workers)))
+
+	if x.tcpTLS {
+		if x.tcpAddr == "" || tlsConfig == nil {
+			return nil, goerr.New("syslog-tls requires syslog-tcp, tls-cert and tls-key", goerr.T(types.ErrTagConfig))
+		}
+		options = append(options, syslog.WithTLSConfig(tlsConfig))
+	}
+
+	for _, s := range x.schemaRules {
+		rule, err := syslog.ParseSchemaRule(s)
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, syslog.WithSchemaRule(rule))
+	}
+
+	if x.parsePayload {
+		options = append(options, syslog.WithParsePayload())
+	}
+
+	return syslog.New(hdlr, options...), nil
+}
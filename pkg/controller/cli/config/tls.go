@@ -0,0 +1,101 @@
+package config
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"os"
+	"path/filepath"
+
+	"github.com/m-mizutani/goerr/v2"
+	"github.com/secmon-lab/alertchain/pkg/domain/types"
+	"github.com/urfave/cli/v3"
+)
+
+type TLS struct {
+	certFile           string
+	keyFile            string
+	clientCAFile       string
+	clientCertOptional bool
+}
+
+func (x *TLS) Flags() []cli.Flag {
+	category := "TLS"
+
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:        "tls-cert",
+			Usage:       "PEM encoded server certificate file. HTTPS is enabled if set with --tls-key",
+			Category:    category,
+			Sources:     cli.EnvVars("ALERTCHAIN_TLS_CERT"),
+			Destination: &x.certFile,
+		},
+		&cli.StringFlag{
+			Name:        "tls-key",
+			Usage:       "PEM encoded private key file of the server certificate",
+			Category:    category,
+			Sources:     cli.EnvVars("ALERTCHAIN_TLS_KEY"),
+			Destination: &x.keyFile,
+		},
+		&cli.StringFlag{
+			Name:        "client-ca",
+			Usage:       "PEM encoded CA certificates file to verify client certificate (mutual TLS)",
+			Category:    category,
+			Sources:     cli.EnvVars("ALERTCHAIN_CLIENT_CA"),
+			Destination: &x.clientCAFile,
+		},
+		&cli.BoolFlag{
+			Name:        "client-cert-optional",
+			Usage:       "Accept request without client certificate when --client-ca is set. Authz policy can check input.client_cert instead",
+			Category:    category,
+			Sources:     cli.EnvVars("ALERTCHAIN_CLIENT_CERT_OPTIONAL"),
+			Destination: &x.clientCertOptional,
+		},
+	}
+}
+
+// New creates TLS config of the server. It returns nil if TLS is disabled.
+func (x *TLS) New() (*tls.Config, error) {
+	if x.certFile == "" && x.keyFile == "" {
+		if x.clientCAFile != "" {
+			return nil, goerr.New("client-ca requires tls-cert and tls-key", goerr.T(types.ErrTagConfig))
+		}
+		return nil, nil
+	}
+	if x.certFile == "" || x.keyFile == "" {
+		return nil, goerr.New("both of tls-cert and tls-key are required", goerr.T(types.ErrTagConfig))
+	}
+
+	cert, err := tls.LoadX509KeyPair(x.certFile, x.keyFile)
+	if err != nil {
+		return nil, goerr.Wrap(err, "failed to load server certificate",
+			goerr.V("cert", x.certFile),
+			goerr.V("key", x.keyFile),
+			goerr.T(types.ErrTagConfig),
+		)
+	}
+
+	cfg := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	if x.clientCAFile != "" {
+		raw, err := os.ReadFile(filepath.Clean(x.clientCAFile))
+		if err != nil {
+			return nil, goerr.Wrap(err, "failed to read client CA file", goerr.V("path", x.clientCAFile), goerr.T(types.ErrTagConfig))
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(raw) {
+			return nil, goerr.New("no certificate in client CA file", goerr.V("path", x.clientCAFile), goerr.T(types.ErrTagConfig))
+		}
+
+		cfg.ClientCAs = pool
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+		if x.clientCertOptional {
+			cfg.ClientAuth = tls.VerifyClientCertIfGiven
+		}
+	}
+
+	return cfg, nil
+}
@@ -0,0 +1,169 @@
+package config
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/m-mizutani/goerr/v2"
+	"github.com/secmon-lab/alertchain/pkg/ctxutil"
+	"github.com/secmon-lab/alertchain/pkg/domain/interfaces"
+	"github.com/secmon-lab/alertchain/pkg/domain/types"
+	"github.com/secmon-lab/alertchain/pkg/infra/tracing"
+	"github.com/secmon-lab/alertchain/pkg/logging"
+	"github.com/urfave/cli/v3"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+type Tracing struct {
+	exporter    string
+	endpoint    string
+	insecure    bool
+	sampleRate  float64
+	serviceName string
+}
+
+func (x *Tracing) Flags() []cli.Flag {
+	category := "Tracing"
+
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:        "trace-exporter",
+			Usage:       "OpenTelemetry trace exporter (otlp, stdout). Tracing is disabled if not set",
+			Category:    category,
+			Sources:     cli.EnvVars("ALERTCHAIN_TRACE_EXPORTER"),
+			Destination: &x.exporter,
+		},
+		&cli.StringFlag{
+			Name:        "trace-endpoint",
+			Usage:       "OTLP gRPC endpoint (host:port). OTEL_EXPORTER_OTLP_ENDPOINT is used if not set",
+			Category:    category,
+			Sources:     cli.EnvVars("ALERTCHAIN_TRACE_ENDPOINT"),
+			Destination: &x.endpoint,
+		},
+		&cli.BoolFlag{
+			Name:        "trace-insecure",
+			Usage:       "Disable TLS of connection to OTLP endpoint",
+			Category:    category,
+			Sources:     cli.EnvVars("ALERTCHAIN_TRACE_INSECURE"),
+			Destination: &x.insecure,
+		},
+		&cli.FloatFlag{
+			Name:        "trace-sample-rate",
+			Usage:       "Sampling ratio of new traces (0.0 - 1.0). Sampling decision of parent span in incoming request is respected",
+			Category:    category,
+			Sources:     cli.EnvVars("ALERTCHAIN_TRACE_SAMPLE_RATE"),
+			Value:       1.0,
+			Destination: &x.sampleRate,
+		},
+		&cli.StringFlag{
+			Name:        "trace-service-name",
+			Usage:       "Service name of traces",
+			Category:    category,
+			Sources:     cli.EnvVars("ALERTCHAIN_TRACE_SERVICE_NAME"),
+			Value:       "alertchain",
+			Destination: &x.serviceName,
+		},
+	}
+}
+
+// Enabled returns true if a trace exporter is configured.
+func (x *Tracing) Enabled() bool {
+	return x.exporter != ""
+}
+
+// Configure sets global tracer provider and W3C trace context propagator. Returned function flushes remaining spans and must be called before exit.
+func (x *Tracing) Configure(ctx context.Context) (func(), error) {
+	if !x.Enabled() {
+		return func() {}, nil
+	}
+
+	var exporter sdktrace.SpanExporter
+	switch x.exporter {
+	case "otlp":
+		var options []otlptracegrpc.Option
+		if x.endpoint != "" {
+			options = append(options, otlptracegrpc.WithEndpoint(x.endpoint))
+		}
+		if x.insecure {
+			options = append(options, otlptracegrpc.WithInsecure())
+		}
+		exp, err := otlptracegrpc.New(ctx, options...)
+		if err != nil {
+			return nil, goerr.Wrap(err, "failed to create OTLP trace exporter", goerr.V("endpoint", x.endpoint))
+		}
+		exporter = exp
+
+	case "stdout":
+		exp, err := stdouttrace.New()
+		if err != nil {
+			return nil, goerr.Wrap(err, "failed to create stdout trace exporter")
+		}
+		exporter = exp
+
+	default:
+		return nil, goerr.New("unsupported trace exporter", goerr.V("exporter", x.exporter))
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(
+		attribute.String("service.name", x.serviceName),
+		attribute.String("service.version", types.AppVersion),
+	))
+	if err != nil {
+		return nil, goerr.Wrap(err, "failed to build trace resource")
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(x.sampleRate))),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	ctxutil.Logger(ctx).Info("OpenTelemetry tracing is enabled",
+		slog.String("exporter", x.exporter),
+		slog.String("endpoint", x.endpoint),
+		slog.Float64("sample_rate", x.sampleRate),
+	)
+
+	return func() {
+		shutdownCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+		defer cancel()
+		if err := provider.Shutdown(shutdownCtx); err != nil {
+			ctxutil.Logger(ctx).Warn("failed to flush traces", logging.ErrAttr(err))
+		}
+	}, nil
+}
+
+// HTTPClient returns HTTP client for actions that records outbound requests as spans if tracing is enabled. Trace context is not propagated by the client because actions call external services. It returns nil if tracing is disabled.
+func (x *Tracing) HTTPClient() *http.Client {
+	if !x.Enabled() {
+		return nil
+	}
+
+	return &http.Client{
+		Transport: otelhttp.NewTransport(http.DefaultTransport,
+			otelhttp.WithPropagators(propagation.NewCompositeTextMapPropagator()),
+		),
+	}
+}
+
+// WrapDatabase returns database client that creates a span for each operation if tracing is enabled.
+func (x *Tracing) WrapDatabase(db interfaces.Database) interfaces.Database {
+	if !x.Enabled() {
+		return db
+	}
+	return tracing.NewDatabase(db)
+}
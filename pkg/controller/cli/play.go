@@ -3,6 +3,7 @@ package cli
 import (
 	"context"
 
+	"github.com/secmon-lab/alertchain/pkg/chain"
 	"github.com/secmon-lab/alertchain/pkg/controller/cli/config"
 	"github.com/secmon-lab/alertchain/pkg/ctxutil"
 	"github.com/secmon-lab/alertchain/pkg/usecase"
@@ -11,7 +12,8 @@ import (
 
 func cmdPlay() *cli.Command {
 	var (
-		input usecase.PlayInput
+		input   usecase.PlayInput
+		explain bool
 
 		policyCfg config.Policy
 	)
@@ -40,6 +42,12 @@ func cmdPlay() *cli.Command {
 			Sources:     cli.EnvVars("ALERTCHAIN_TARGET"),
 			Destination: &input.Targets,
 		},
+		&cli.BoolFlag{
+			Name:        "explain",
+			Usage:       "Explain evaluation of alert and action policy with OPA tracing. The explanation is stored in the scenario log",
+			Sources:     cli.EnvVars("ALERTCHAIN_EXPLAIN"),
+			Destination: &explain,
+		},
 	}
 	flags = append(flags, policyCfg.Flags()...)
 
@@ -56,6 +64,9 @@ func cmdPlay() *cli.Command {
 			if err != nil {
 				return err
 			}
+			if explain {
+				coreOptions = append(coreOptions, chain.WithEnableExplain())
+			}
 			input.CoreOptions = coreOptions
 
 			if err := usecase.Play(ctx, input); err != nil {
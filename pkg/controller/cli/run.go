@@ -13,15 +13,21 @@ import (
 	"github.com/secmon-lab/alertchain/pkg/chain"
 	"github.com/secmon-lab/alertchain/pkg/controller/cli/config"
 	"github.com/secmon-lab/alertchain/pkg/ctxutil"
+	"github.com/secmon-lab/alertchain/pkg/domain/model"
 	"github.com/secmon-lab/alertchain/pkg/domain/types"
+	"github.com/secmon-lab/alertchain/pkg/logging"
 	"github.com/urfave/cli/v3"
 )
 
 func cmdRun() *cli.Command {
 	var (
-		input     string
-		schema    types.Schema
-		policyCfg config.Policy
+		input       string
+		schema      types.Schema
+		policyCfg   config.Policy
+		decisionCfg config.DecisionLog
+		traceCfg    config.Tracing
+		explain     bool
+		dryRun      bool
 	)
 
 	flags := []cli.Flag{
@@ -42,8 +48,22 @@ func cmdRun() *cli.Command {
 			Required:    true,
 			Destination: (*string)(&schema),
 		},
+		&cli.BoolFlag{
+			Name:        "explain",
+			Usage:       "Explain evaluation of alert and action policy with OPA tracing",
+			Sources:     cli.EnvVars("ALERTCHAIN_EXPLAIN"),
+			Destination: &explain,
+		},
+		&cli.BoolFlag{
+			Name:        "dry-run",
+			Usage:       "Evaluate policies without side effects of actions and print planned actions as JSON",
+			Sources:     cli.EnvVars("ALERTCHAIN_DRY_RUN"),
+			Destination: &dryRun,
+		},
 	}
 	flags = append(flags, policyCfg.Flags()...)
+	flags = append(flags, decisionCfg.Flags()...)
+	flags = append(flags, traceCfg.Flags()...)
 
 	return &cli.Command{
 		Name:    "run",
@@ -51,7 +71,30 @@ func cmdRun() *cli.Command {
 		Usage:   "Run alertchain policy at once and exit in",
 		Flags:   flags,
 		Action: func(ctx context.Context, cmd *cli.Command) error {
+			ctx = ctxutil.SetCLI(ctx)
+
+			traceCloser, err := traceCfg.Configure(ctx)
+			if err != nil {
+				return err
+			}
+			defer traceCloser()
+
 			var chainOptions []chain.Option
+			if client := traceCfg.HTTPClient(); client != nil {
+				chainOptions = append(chainOptions, chain.WithHTTPClient(client))
+			}
+			if explain {
+				chainOptions = append(chainOptions, chain.WithEnableExplain())
+			}
+
+			decisionSink, decisionCloser, err := decisionCfg.New(ctx, nil)
+			if err != nil {
+				return err
+			}
+			defer decisionCloser()
+			if decisionSink != nil {
+				chainOptions = append(chainOptions, chain.WithDecisionLogSink(decisionSink))
+			}
 
 			chain, err := buildChain(ctx, &policyCfg, chainOptions...)
 			if err != nil {
@@ -76,10 +119,33 @@ func cmdRun() *cli.Command {
 
 			ctxutil.Logger(ctx).Info("starting alertchain with run mode", slog.Any("data", data))
 
-			if _, err := chain.HandleAlert(ctx, schema, data); err != nil {
+			var timeline *model.Timeline
+			if dryRun {
+				timeline = &model.Timeline{}
+				ctx = ctxutil.InjectTimeline(ctxutil.SetDryRun(ctx, true), timeline)
+			}
+
+			alerts, err := chain.HandleAlert(ctx, schema, data)
+			if err != nil {
 				return goerr.Wrap(err, "failed to handle alert")
 			}
 
+			if timeline != nil {
+				out := struct {
+					Alerts   []*model.Alert `json:"alerts"`
+					Timeline any            `json:"timeline"`
+				}{
+					Alerts:   alerts,
+					Timeline: logging.Redact(timeline.Entries()),
+				}
+
+				encoder := json.NewEncoder(os.Stdout)
+				encoder.SetIndent("", "  ")
+				if err := encoder.Encode(out); err != nil {
+					return goerr.Wrap(err, "failed to write dry-run result")
+				}
+			}
+
 			return nil
 		},
 	}
@@ -3,12 +3,21 @@ package cli
 import (
 	"context"
 	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
+	"github.com/m-mizutani/goerr/v2"
 	"github.com/secmon-lab/alertchain/pkg/chain"
 	"github.com/secmon-lab/alertchain/pkg/controller/cli/config"
 	"github.com/secmon-lab/alertchain/pkg/controller/graphql"
 	"github.com/secmon-lab/alertchain/pkg/controller/server"
+	"github.com/secmon-lab/alertchain/pkg/controller/syslog"
 	"github.com/secmon-lab/alertchain/pkg/ctxutil"
+	"github.com/secmon-lab/alertchain/pkg/domain/types"
+	"github.com/secmon-lab/alertchain/pkg/infra/broker"
+	"github.com/secmon-lab/alertchain/pkg/infra/metrics"
 	"github.com/secmon-lab/alertchain/pkg/service"
 	"github.com/secmon-lab/alertchain/pkg/utils"
 	"github.com/urfave/cli/v3"
@@ -17,13 +26,28 @@ import (
 func cmdServe() *cli.Command {
 	var (
 		addr          string
+		gracePeriod   time.Duration
 		disableAction bool
 		playground    bool
 		graphQL       bool
+		enableConsole bool
+		enableMetrics bool
+		metaHeaders   []string
+		maxBodySize   int64
+		maxBatchSize  int64
 
-		dbCfg     config.Database
-		policyCfg config.Policy
-		sentryCfg config.Sentry
+		dbCfg       config.Database
+		policyCfg   config.Policy
+		sentryCfg   config.Sentry
+		decisionCfg config.DecisionLog
+		pubsubCfg   config.PubSub
+		snsCfg      config.SNS
+		sigCfg      config.Signature
+		traceCfg    config.Tracing
+		rateCfg     config.RateLimit
+		tlsCfg      config.TLS
+		readyCfg    config.Readiness
+		syslogCfg   config.Syslog
 	)
 
 	flags := []cli.Flag{
@@ -35,6 +59,39 @@ func cmdServe() *cli.Command {
 			Value:       "127.0.0.1:8080",
 			Destination: &addr,
 		},
+		&cli.DurationFlag{
+			Name:        "grace-period",
+			Usage:       "Duration to wait for running workflows on SIGTERM/SIGINT. Unfinished workflows are marked as interrupted after the period",
+			Sources:     cli.EnvVars("ALERTCHAIN_GRACE_PERIOD"),
+			Value:       8 * time.Second,
+			Destination: &gracePeriod,
+		},
+		&cli.StringSliceFlag{
+			Name:        "meta-header",
+			Usage:       "HTTP header passed to alert policy as data.alertchain.meta.headers and stored with alert, e.g. User-Agent",
+			Sources:     cli.EnvVars("ALERTCHAIN_META_HEADER"),
+			Destination: &metaHeaders,
+		},
+		&cli.IntFlag{
+			Name:        "max-body-size",
+			Usage:       "Max size of HTTP request body in bytes. Larger request is rejected with 413",
+			Sources:     cli.EnvVars("ALERTCHAIN_MAX_BODY_SIZE"),
+			Value:       10 * 1024 * 1024,
+			Destination: &maxBodySize,
+		},
+		&cli.IntFlag{
+			Name:        "max-batch-size",
+			Usage:       "Max number of events in a batch request. Larger batch is rejected with 413",
+			Sources:     cli.EnvVars("ALERTCHAIN_MAX_BATCH_SIZE"),
+			Value:       1000,
+			Destination: &maxBatchSize,
+		},
+		&cli.BoolFlag{
+			Name:        "metrics",
+			Usage:       "Enable Prometheus metrics endpoint (/metrics)",
+			Sources:     cli.EnvVars("ALERTCHAIN_METRICS"),
+			Destination: &enableMetrics,
+		},
 		&cli.BoolFlag{
 			Name:        "graphql",
 			Usage:       "Enable GraphQL",
@@ -49,10 +106,26 @@ func cmdServe() *cli.Command {
 			Value:       false,
 			Destination: &playground,
 		},
+		&cli.BoolFlag{
+			Name:        "console",
+			Usage:       "Enable web console (/console). It requires GraphQL",
+			Sources:     cli.EnvVars("ALERTCHAIN_CONSOLE"),
+			Value:       false,
+			Destination: &enableConsole,
+		},
 	}
 	flags = append(flags, dbCfg.Flags()...)
 	flags = append(flags, policyCfg.Flags()...)
 	flags = append(flags, sentryCfg.Flags()...)
+	flags = append(flags, decisionCfg.Flags()...)
+	flags = append(flags, pubsubCfg.Flags()...)
+	flags = append(flags, snsCfg.Flags()...)
+	flags = append(flags, sigCfg.Flags()...)
+	flags = append(flags, traceCfg.Flags()...)
+	flags = append(flags, rateCfg.Flags()...)
+	flags = append(flags, tlsCfg.Flags()...)
+	flags = append(flags, readyCfg.Flags()...)
+	flags = append(flags, syslogCfg.Flags()...)
 
 	return &cli.Command{
 		Name:    "serve",
@@ -70,18 +143,50 @@ func cmdServe() *cli.Command {
 			// Build chain
 			var chainOpt []chain.Option
 
+			sentryCloser, err := sentryCfg.Configure(ctx)
+			if err != nil {
+				return err
+			}
+			defer sentryCloser()
+
+			traceCloser, err := traceCfg.Configure(ctx)
+			if err != nil {
+				return err
+			}
+			defer traceCloser()
+
 			dbClient, dbCloser, err := dbCfg.New(ctx)
 			if err != nil {
 				return err
 			}
 			defer dbCloser()
+			dbClient = traceCfg.WrapDatabase(dbClient)
 			chainOpt = append(chainOpt, chain.WithDatabase(dbClient))
+			if client := traceCfg.HTTPClient(); client != nil {
+				chainOpt = append(chainOpt, chain.WithHTTPClient(client))
+			}
 
-			sentryCloser, err := sentryCfg.Configure(ctx)
+			decisionSink, decisionCloser, err := decisionCfg.New(ctx, dbClient)
 			if err != nil {
 				return err
 			}
-			defer sentryCloser()
+			defer decisionCloser()
+			if decisionSink != nil {
+				chainOpt = append(chainOpt, chain.WithDecisionLogSink(decisionSink))
+			}
+
+			var prom *metrics.Prometheus
+			if enableMetrics {
+				prom = metrics.NewPrometheus()
+				chainOpt = append(chainOpt, chain.WithInstrument(prom))
+			}
+
+			// Workflow events are published only for GraphQL subscription
+			var events *broker.Broker
+			if graphQL {
+				events = broker.New()
+				chainOpt = append(chainOpt, chain.WithWorkflowEventPublisher(events))
+			}
 
 			chain, err := buildChain(ctx, &policyCfg, chainOpt...)
 			if err != nil {
@@ -96,23 +201,135 @@ func cmdServe() *cli.Command {
 				return err
 			}
 			serverOpt = append(serverOpt, server.WithAuthzPolicy(authz))
+			if decisionSink != nil {
+				serverOpt = append(serverOpt, server.WithDecisionLogSink(decisionSink))
+			}
+
+			pubsubVerifier, err := pubsubCfg.NewVerifier(ctx)
+			if err != nil {
+				return err
+			}
+			if pubsubVerifier != nil {
+				serverOpt = append(serverOpt, server.WithPubSubVerifier(pubsubVerifier))
+			}
+			if ttl := pubsubCfg.DedupTTL(); ttl > 0 {
+				serverOpt = append(serverOpt, server.WithPubSubDeduplication(dbClient, ttl))
+			}
+
+			serverOpt = append(serverOpt, server.WithSNSClient(snsCfg.NewClient()))
+
+			sigRules, err := sigCfg.Rules()
+			if err != nil {
+				return err
+			}
+			serverOpt = append(serverOpt, server.WithSignatureRules(sigRules...))
+			serverOpt = append(serverOpt, server.WithMetaHeaders(metaHeaders...))
+			serverOpt = append(serverOpt,
+				server.WithMaxBodySize(maxBodySize),
+				server.WithMaxBatchSize(int(maxBatchSize)),
+			)
+
+			rateLimiter, err := rateCfg.New()
+			if err != nil {
+				return err
+			}
+			if rateLimiter != nil {
+				serverOpt = append(serverOpt, server.WithRateLimiter(rateLimiter))
+			}
+
+			if prom != nil {
+				serverOpt = append(serverOpt,
+					server.WithInstrument(prom),
+					server.WithMetricsHandler(prom.Handler()),
+				)
+			}
 
 			if graphQL {
-				resolver := graphql.NewResolver(service.New(dbClient))
+				resolver := graphql.NewResolver(service.New(dbClient),
+					graphql.WithWorkflowRunner(chain.RerunAlert),
+					graphql.WithWorkflowEvents(events),
+				)
 				serverOpt = append(serverOpt, server.WithResolver(resolver))
 			}
 			if playground {
 				serverOpt = append(serverOpt, server.WithEnableGraphiQL())
 			}
+			if enableConsole {
+				if !graphQL {
+					return goerr.New("console requires graphql", goerr.T(types.ErrTagConfig))
+				}
+				serverOpt = append(serverOpt, server.WithEnableConsole())
+			}
+
+			tlsConfig, err := tlsCfg.New()
+			if err != nil {
+				return err
+			}
+			if tlsConfig != nil {
+				serverOpt = append(serverOpt, server.WithTLSConfig(tlsConfig))
+			}
+
+			readyChecks, err := readyCfg.Checks()
+			if err != nil {
+				return err
+			}
+			serverOpt = append(serverOpt,
+				server.WithReadinessCheck(server.ReadinessCheck{Name: "database", Check: dbClient.Ping}),
+				server.WithReadinessCheck(readyChecks...),
+				server.WithPolicies(chain.Policies()...),
+			)
 
+			serverOpt = append(serverOpt, server.WithGracePeriod(gracePeriod))
 			srv := server.New(chain.HandleAlert, serverOpt...)
 
+			// Build syslog listener
+			syslogOpt := []syslog.Option{
+				syslog.WithAuthzPolicy(authz),
+				syslog.WithGracePeriod(gracePeriod),
+			}
+			if decisionSink != nil {
+				syslogOpt = append(syslogOpt, syslog.WithDecisionLogSink(decisionSink))
+			}
+			if prom != nil {
+				syslogOpt = append(syslogOpt, syslog.WithInstrument(prom))
+			}
+			syslogListener, err := syslogCfg.New(chain.HandleAlert, tlsConfig, syslogOpt...)
+			if err != nil {
+				return err
+			}
+
 			// Starting server
-			ctxutil.Logger(ctx).Info("starting alertchain with serve mode", slog.String("addr", addr))
-			if err := srv.Run(addr); err != nil {
+			ctxutil.Logger(ctx).Info("starting alertchain with serve mode", slog.String("addr", addr), slog.Bool("tls", tlsConfig != nil))
+			sigCtx, stop := signal.NotifyContext(ctx, syscall.SIGTERM, os.Interrupt)
+			defer stop()
+
+			syslogDone := make(chan error, 1)
+			if syslogListener != nil {
+				if err := syslogListener.Listen(); err != nil {
+					return err
+				}
+				ctxutil.Logger(ctx).Info("starting syslog listener",
+					slog.Any("udp", syslogListener.UDPAddr()),
+					slog.Any("tcp", syslogListener.TCPAddr()),
+				)
+				go func() { syslogDone <- syslogListener.Serve(sigCtx) }()
+			} else {
+				close(syslogDone)
+			}
+
+			if err := srv.Run(sigCtx, addr); err != nil {
 				utils.HandleError(ctx, err)
+				stop()
+				<-syslogDone
 				return err
 			}
+			if err := <-syslogDone; err != nil {
+				utils.HandleError(ctx, err)
+				return err
+			}
+
+			// Workflows still running after the grace period can not finish
+			chain.Interrupt(ctx)
 
 			return nil
 		},
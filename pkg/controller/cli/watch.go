@@ -0,0 +1,171 @@
+package cli
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/m-mizutani/goerr/v2"
+	"github.com/secmon-lab/alertchain/pkg/chain"
+	"github.com/secmon-lab/alertchain/pkg/controller/cli/config"
+	"github.com/secmon-lab/alertchain/pkg/controller/watch"
+	"github.com/secmon-lab/alertchain/pkg/ctxutil"
+	"github.com/secmon-lab/alertchain/pkg/domain/types"
+	"github.com/secmon-lab/alertchain/pkg/utils"
+	"github.com/urfave/cli/v3"
+)
+
+func cmdWatch() *cli.Command {
+	var (
+		dir        string
+		schema     types.Schema
+		patterns   []string
+		checkpoint string
+		interval   time.Duration
+		startAtEnd bool
+
+		dbCfg       config.Database
+		policyCfg   config.Policy
+		sentryCfg   config.Sentry
+		decisionCfg config.DecisionLog
+		traceCfg    config.Tracing
+	)
+
+	flags := []cli.Flag{
+		&cli.StringFlag{
+			Name:        "dir",
+			Usage:       "Directory that has NDJSON or JSON files to tail",
+			Category:    "watch",
+			Sources:     cli.EnvVars("ALERTCHAIN_WATCH_DIR"),
+			Required:    true,
+			Destination: &dir,
+		},
+		&cli.StringFlag{
+			Name:        "schema",
+			Aliases:     []string{"s"},
+			Usage:       "Schema of all files in the directory. It is used for files not matched with --pattern",
+			Category:    "watch",
+			Sources:     cli.EnvVars("ALERTCHAIN_SCHEMA"),
+			Destination: (*string)(&schema),
+		},
+		&cli.StringSliceFlag{
+			Name:        "pattern",
+			Usage:       "Schema of files in 'GLOB=SCHEMA' format. GLOB is relative to --dir, e.g. 'falco/*.json=falco'. The first matched pattern is used",
+			Category:    "watch",
+			Sources:     cli.EnvVars("ALERTCHAIN_WATCH_PATTERN"),
+			Destination: &patterns,
+		},
+		&cli.StringFlag{
+			Name:        "checkpoint",
+			Usage:       "File to save read positions of files",
+			Category:    "watch",
+			Sources:     cli.EnvVars("ALERTCHAIN_WATCH_CHECKPOINT"),
+			Value:       "alertchain-checkpoint.json",
+			Destination: &checkpoint,
+		},
+		&cli.DurationFlag{
+			Name:        "interval",
+			Usage:       "Interval to check the files",
+			Category:    "watch",
+			Sources:     cli.EnvVars("ALERTCHAIN_WATCH_INTERVAL"),
+			Value:       time.Second,
+			Destination: &interval,
+		},
+		&cli.BoolFlag{
+			Name:        "start-at-end",
+			Usage:       "Skip existing content of files that are not in the checkpoint at startup",
+			Category:    "watch",
+			Sources:     cli.EnvVars("ALERTCHAIN_WATCH_START_AT_END"),
+			Destination: &startAtEnd,
+		},
+	}
+	flags = append(flags, dbCfg.Flags()...)
+	flags = append(flags, policyCfg.Flags()...)
+	flags = append(flags, sentryCfg.Flags()...)
+	flags = append(flags, decisionCfg.Flags()...)
+	flags = append(flags, traceCfg.Flags()...)
+
+	return &cli.Command{
+		Name:  "watch",
+		Usage: "Tail NDJSON or JSON files in a directory and handle each event",
+		Flags: flags,
+		Action: func(ctx context.Context, cmd *cli.Command) error {
+			var watchOpt []watch.Option
+			for _, p := range patterns {
+				rule, err := watch.ParseRule(p)
+				if err != nil {
+					return err
+				}
+				watchOpt = append(watchOpt, watch.WithRule(rule))
+			}
+			if schema != "" {
+				watchOpt = append(watchOpt, watch.WithRule(watch.Rule{Pattern: "*", Schema: schema}))
+			}
+			if len(watchOpt) == 0 {
+				return goerr.New("either --schema or --pattern is required", goerr.T(types.ErrTagConfig))
+			}
+			watchOpt = append(watchOpt, watch.WithCheckpoint(checkpoint), watch.WithInterval(interval))
+			if startAtEnd {
+				watchOpt = append(watchOpt, watch.WithStartAtEnd())
+			}
+
+			sentryCloser, err := sentryCfg.Configure(ctx)
+			if err != nil {
+				return err
+			}
+			defer sentryCloser()
+
+			traceCloser, err := traceCfg.Configure(ctx)
+			if err != nil {
+				return err
+			}
+			defer traceCloser()
+
+			// Alerts and workflows are stored in the same database as serve mode, so they can be queried via GraphQL
+			dbClient, dbCloser, err := dbCfg.New(ctx)
+			if err != nil {
+				return err
+			}
+			defer dbCloser()
+			dbClient = traceCfg.WrapDatabase(dbClient)
+			chainOpt := []chain.Option{chain.WithDatabase(dbClient)}
+			if client := traceCfg.HTTPClient(); client != nil {
+				chainOpt = append(chainOpt, chain.WithHTTPClient(client))
+			}
+
+			decisionSink, decisionCloser, err := decisionCfg.New(ctx, dbClient)
+			if err != nil {
+				return err
+			}
+			defer decisionCloser()
+			if decisionSink != nil {
+				chainOpt = append(chainOpt, chain.WithDecisionLogSink(decisionSink))
+			}
+
+			chain, err := buildChain(ctx, &policyCfg, chainOpt...)
+			if err != nil {
+				return err
+			}
+
+			ctxutil.Logger(ctx).Info("starting alertchain with watch mode",
+				slog.String("dir", dir),
+				slog.Any("patterns", patterns),
+				slog.String("schema", string(schema)),
+				slog.String("checkpoint", checkpoint),
+			)
+
+			sigCtx, stop := signal.NotifyContext(ctx, syscall.SIGTERM, os.Interrupt)
+			defer stop()
+
+			if err := watch.New(chain.HandleAlert, dir, watchOpt...).Run(sigCtx); err != nil {
+				utils.HandleError(ctx, err)
+				return err
+			}
+
+			return nil
+		},
+	}
+}
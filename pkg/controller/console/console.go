@@ -0,0 +1,56 @@
+package console
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/secmon-lab/alertchain/pkg/utils"
+)
+
+//go:embed static
+var staticFS embed.FS
+
+// contentSecurityPolicy allows only assets and API of the same origin. Pages are rendered by DOM API without inline script.
+const contentSecurityPolicy = "default-src 'self'; connect-src 'self'; img-src 'self' data:; object-src 'none'; base-uri 'none'; frame-ancestors 'none'"
+
+// Handler serves the web console mounted at prefix, e.g. "/console". Pages are routed by URL fragment, so a path without a file is redirected to the top page.
+func Handler(prefix string) http.Handler {
+	assets, err := fs.Sub(staticFS, "static")
+	if err != nil {
+		panic(err) // never happens, the directory is embedded
+	}
+	index, err := fs.ReadFile(assets, "index.html")
+	if err != nil {
+		panic(err)
+	}
+	files := http.FileServer(http.FS(assets))
+
+	return http.StripPrefix(prefix, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Security-Policy", contentSecurityPolicy)
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+
+		name := strings.TrimPrefix(path.Clean("/"+r.URL.Path), "/")
+		if name != "" && name != "index.html" && !exists(assets, name) {
+			http.Redirect(w, r, prefix+"/", http.StatusFound)
+			return
+		}
+		if name == "" || name == "index.html" {
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Header().Set("Cache-Control", "no-cache")
+			w.WriteHeader(http.StatusOK)
+			utils.SafeWrite(r.Context(), w, index)
+			return
+		}
+
+		r.URL.Path = "/" + name
+		files.ServeHTTP(w, r)
+	}))
+}
+
+func exists(assets fs.FS, name string) bool {
+	stat, err := fs.Stat(assets, name)
+	return err == nil && !stat.IsDir()
+}
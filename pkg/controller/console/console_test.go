@@ -0,0 +1,47 @@
+package console_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/m-mizutani/gt"
+	"github.com/secmon-lab/alertchain/pkg/controller/console"
+)
+
+func TestHandler(t *testing.T) {
+	h := console.Handler("/console")
+
+	get := func(path string) *http.Response {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w.Result()
+	}
+
+	t.Run("index", func(t *testing.T) {
+		for _, path := range []string{"/console/", "/console/index.html"} {
+			resp := get(path)
+			gt.N(t, resp.StatusCode).Equal(http.StatusOK)
+			gt.S(t, resp.Header.Get("Content-Type")).Contains("text/html")
+			gt.S(t, resp.Header.Get("Content-Security-Policy")).Contains("default-src 'self'")
+		}
+	})
+
+	t.Run("assets", func(t *testing.T) {
+		resp := get("/console/app.js")
+		gt.N(t, resp.StatusCode).Equal(http.StatusOK)
+		gt.S(t, resp.Header.Get("Content-Type")).Contains("javascript")
+
+		resp = get("/console/style.css")
+		gt.N(t, resp.StatusCode).Equal(http.StatusOK)
+		gt.S(t, resp.Header.Get("Content-Type")).Contains("text/css")
+	})
+
+	t.Run("unknown path is redirected to top", func(t *testing.T) {
+		for _, path := range []string{"/console/workflows/xxx", "/console/../graphql", "/console/static"} {
+			resp := get(path)
+			gt.N(t, resp.StatusCode).Equal(http.StatusFound)
+			gt.V(t, resp.Header.Get("Location")).Equal("/console/")
+		}
+	})
+}
@@ -0,0 +1,495 @@
+// AlertChain web console. Pages are routed by URL fragment and all data is fetched from /graphql.
+// Elements are built by DOM API with textContent to avoid injection of alert data.
+"use strict";
+
+(() => {
+  const pageSize = 20;
+  const tokenKey = "alertchain.token";
+
+  // ----------------------------------------------------------------
+  // Utilities
+
+  const h = (tag, attrs, ...children) => {
+    const el = document.createElement(tag);
+    for (const [k, v] of Object.entries(attrs || {})) {
+      if (v === undefined || v === null || v === false) continue;
+      if (k.startsWith("on")) {
+        el.addEventListener(k.slice(2), v);
+      } else if (k === "class") {
+        el.className = v;
+      } else if (k in el && typeof v !== "string") {
+        el[k] = v;
+      } else {
+        el.setAttribute(k, v === true ? "" : v);
+      }
+    }
+    for (const c of children.flat()) {
+      if (c === undefined || c === null || c === false) continue;
+      el.append(c instanceof Node ? c : String(c));
+    }
+    return el;
+  };
+
+  const fmtTime = (s) => (s ? new Date(s).toLocaleString() : "");
+
+  const duration = (from, to) => {
+    if (!from || !to) return "";
+    const ms = new Date(to) - new Date(from);
+    return ms < 1000 ? `${ms} ms` : `${(ms / 1000).toFixed(2)} s`;
+  };
+
+  // prettyJSON formats a JSON string. The string is returned as is if it is not JSON.
+  const prettyJSON = (s) => {
+    if (s === null || s === undefined) return "";
+    try {
+      return JSON.stringify(JSON.parse(s), null, 2);
+    } catch (e) {
+      return s;
+    }
+  };
+
+  const statusBadge = (status) => h("span", { class: `status ${status || ""}` }, status || "unknown");
+
+  // navigate changes the page. The page is rendered again if the fragment is not changed, e.g. search with the same filter.
+  const navigate = (hash) => {
+    if (location.hash === hash) {
+      route();
+    } else {
+      location.hash = hash;
+    }
+  };
+
+  const notice = (err) => h("div", { class: "notice" }, err instanceof Error ? err.message : String(err));
+
+  const getToken = () => sessionStorage.getItem(tokenKey) || "";
+
+  const authHeaders = () => {
+    const token = getToken();
+    return token ? { Authorization: `Bearer ${token}` } : {};
+  };
+
+  // warn shows the message above the page until the page is changed.
+  const warn = (msg) => document.getElementById("notices").append(notice(msg));
+
+  // gql sends a query to /graphql and returns data. Errors without data are thrown. Errors with data, e.g. fields hidden by deny_fields of authz.graphql policy, are shown as warning.
+  const gql = async (query, variables) => {
+    const resp = await fetch("/graphql", {
+      method: "POST",
+      headers: { "Content-Type": "application/json", ...authHeaders() },
+      body: JSON.stringify({ query, variables }),
+    });
+    if (!resp.ok) {
+      throw new Error(`GraphQL request failed: ${resp.status} ${(await resp.text()).trim()}`);
+    }
+    const body = await resp.json();
+    if (body.errors && body.errors.length > 0) {
+      const msg = body.errors.map((e) => e.message).join(", ");
+      if (!body.data) throw new Error(msg);
+      warn(msg);
+    }
+    return body.data;
+  };
+
+  // ----------------------------------------------------------------
+  // Workflow list
+
+  const filterFields = [
+    { name: "title", label: "Title contains" },
+    { name: "schema", label: "Schema" },
+    { name: "source", label: "Source" },
+    { name: "namespace", label: "Namespace" },
+    { name: "status", label: "Status", options: ["", "running", "completed", "failed", "interrupted"] },
+    { name: "action", label: "Action (uses)" },
+    { name: "attrKey", label: "Attribute key" },
+    { name: "attrValue", label: "Attribute value" },
+    { name: "error", label: "Error contains" },
+    { name: "createdAfter", label: "Created after", type: "datetime-local" },
+    { name: "createdBefore", label: "Created before", type: "datetime-local" },
+  ];
+
+  const queryWorkflows = `query consoleWorkflows($filter: WorkflowFilter!, $offset: Int, $limit: Int) {
+  searchWorkflows(filter: $filter, offset: $offset, limit: $limit) {
+    id createdAt status finishedAt triage error
+    alert { title schema source namespace }
+    actions { id }
+  }
+}`;
+
+  const renderWorkflows = async (app, params) => {
+    const offset = Number(params.get("offset") || 0);
+    const filter = {};
+    for (const f of filterFields) {
+      const v = params.get(f.name);
+      if (!v) continue;
+      filter[f.name] = f.type === "datetime-local" ? new Date(v).toISOString() : v;
+    }
+
+    const form = h("form", { class: "filters" },
+      filterFields.map((f) => h("label", {}, f.label,
+        f.options
+          ? h("select", { name: f.name }, f.options.map((o) => h("option", { value: o, selected: params.get(f.name) === o }, o || "(any)")))
+          : h("input", { name: f.name, type: f.type || "text", value: params.get(f.name) || "" }))),
+      h("div", { class: "buttons" },
+        h("button", { type: "submit" }, "Search"),
+        h("button", { type: "button", onclick: () => { navigate("#/workflows"); } }, "Clear")));
+    form.addEventListener("submit", (ev) => {
+      ev.preventDefault();
+      const q = new URLSearchParams();
+      for (const [k, v] of new FormData(form)) {
+        if (v) q.set(k, v);
+      }
+      navigate(`#/workflows?${q}`);
+    });
+
+    app.replaceChildren(h("h2", {}, "Workflows"), form);
+
+    const data = await gql(queryWorkflows, { filter, offset, limit: pageSize });
+    const workflows = data.searchWorkflows;
+
+    const page = (o) => {
+      const q = new URLSearchParams(params);
+      q.set("offset", String(o));
+      return `#/workflows?${q}`;
+    };
+
+    app.append(
+      h("table", {},
+        h("thead", {}, h("tr", {}, ["Created at", "Status", "Triage", "Title", "Schema", "Source", "Namespace", "Actions"].map((c) => h("th", {}, c)))),
+        h("tbody", {}, workflows.length === 0
+          ? h("tr", {}, h("td", { colspan: "8", class: "muted" }, "No workflow found"))
+          : workflows.map((wf) => h("tr", {},
+            h("td", {}, h("a", { href: `#/workflows/${wf.id}` }, fmtTime(wf.createdAt))),
+            h("td", {}, statusBadge(wf.status)),
+            h("td", {}, wf.triage),
+            h("td", {}, wf.alert.title, wf.error ? h("div", { class: "error" }, wf.error) : null),
+            h("td", {}, wf.alert.schema),
+            h("td", {}, wf.alert.source),
+            h("td", {}, wf.alert.namespace || ""),
+            h("td", {}, String(wf.actions.length)))))),
+      h("div", { class: "pager" },
+        h("button", { disabled: offset === 0, onclick: () => { navigate(page(Math.max(0, offset - pageSize))); } }, "Prev"),
+        h("span", { class: "muted" }, `${offset + 1} - ${offset + workflows.length}`),
+        h("button", { disabled: workflows.length < pageSize, onclick: () => { navigate(page(offset + pageSize)); } }, "Next")));
+  };
+
+  // ----------------------------------------------------------------
+  // Workflow detail
+
+  const queryWorkflow = `query consoleWorkflow($id: String!) {
+  Workflow(id: $id) {
+    id createdAt status finishedAt error triage
+    alert {
+      id schema data createdAt title description source namespace
+      initAttrs { id key value type persist ttl }
+      lastAttrs { id key value type persist ttl }
+      refs { title url }
+    }
+    actions {
+      id seq uses result error startedAt finishedAt
+      args { key value }
+      next { abort attrs { id key value type persist ttl } }
+    }
+    comments { author action body createdAt }
+  }
+}`;
+
+  const attrTable = (attrs) => attrs.length === 0
+    ? h("p", { class: "muted" }, "No attribute")
+    : h("table", {},
+      h("thead", {}, h("tr", {}, ["Key", "Value", "Type", "Persist", "TTL"].map((c) => h("th", {}, c)))),
+      h("tbody", {}, attrs.map((a) => h("tr", {},
+        h("td", { class: "mono" }, a.key),
+        h("td", { class: "mono" }, a.value),
+        h("td", {}, a.type || ""),
+        h("td", {}, a.persist ? "yes" : ""),
+        h("td", {}, a.persist ? String(a.ttl) : "")))));
+
+  // attrDiff returns changes of attributes committed by an action against the current attributes, and applies them.
+  const attrDiff = (current, committed) => committed.map((a) => {
+    const prev = current.get(a.id);
+    current.set(a.id, a);
+    if (!prev) return { op: "added", attr: a };
+    if (prev.value !== a.value || prev.key !== a.key) return { op: "changed", attr: a, prev };
+    return { op: "unchanged", attr: a };
+  });
+
+  const diffTable = (diffs) => h("table", {},
+    h("thead", {}, h("tr", {}, ["", "Key", "Value", "Persist"].map((c) => h("th", {}, c)))),
+    h("tbody", {}, diffs.map((d) => h("tr", { class: `diff-${d.op}` },
+      h("td", {}, d.op === "added" ? "+" : d.op === "changed" ? "~" : "="),
+      h("td", { class: "mono" }, d.attr.key),
+      h("td", { class: "mono" }, d.op === "changed" ? `${d.prev.value} → ${d.attr.value}` : d.attr.value),
+      h("td", {}, d.attr.persist ? `yes (ttl ${d.attr.ttl})` : "")))));
+
+  const actionCard = (action, current) => {
+    const committed = action.next.flatMap((n) => n.attrs);
+    const aborted = action.next.some((n) => n.abort);
+    return h("div", { class: `card${action.error ? " failed" : ""}` },
+      h("div", { class: "title" },
+        h("span", { class: "uses" }, action.uses),
+        h("span", { class: "mono muted" }, action.id),
+        h("span", { class: "muted" }, `${fmtTime(action.startedAt)} (${duration(action.startedAt, action.finishedAt)})`),
+        aborted ? h("span", { class: "error" }, "abort") : null),
+      action.error ? h("p", { class: "error" }, action.error) : null,
+      action.args.length > 0 ? h("details", { open: true },
+        h("summary", {}, "Arguments"),
+        h("table", {}, h("tbody", {}, action.args.map((a) => h("tr", {},
+          h("td", { class: "mono" }, a.key),
+          h("td", {}, h("pre", {}, prettyJSON(a.value))))))))
+        : null,
+      action.result ? h("details", {},
+        h("summary", {}, "Result"),
+        h("pre", {}, prettyJSON(action.result))) : null,
+      committed.length > 0 ? h("details", { open: true },
+        h("summary", {}, "Attribute changes"),
+        diffTable(attrDiff(current, committed))) : null);
+  };
+
+  const renderWorkflow = async (app, id) => {
+    app.replaceChildren(h("p", { class: "muted" }, "Loading..."));
+    const wf = (await gql(queryWorkflow, { id })).Workflow;
+    const alert = wf.alert;
+
+    // Actions are grouped by sequence in order of execution
+    const sequences = new Map();
+    for (const a of [...wf.actions].sort((x, y) => x.seq - y.seq || new Date(x.startedAt) - new Date(y.startedAt))) {
+      if (!sequences.has(a.seq)) sequences.set(a.seq, []);
+      sequences.get(a.seq).push(a);
+    }
+    const current = new Map(alert.initAttrs.map((a) => [a.id, a]));
+
+    app.replaceChildren(
+      h("p", {}, h("a", { href: "#/workflows" }, "← Workflows")),
+      h("h2", {}, alert.title || "(no title)"),
+      h("dl", { class: "props" },
+        h("dt", {}, "Workflow"), h("dd", { class: "mono" }, wf.id),
+        h("dt", {}, "Status"), h("dd", {}, statusBadge(wf.status), wf.error ? h("span", { class: "error" }, ` ${wf.error}`) : null),
+        h("dt", {}, "Triage"), h("dd", {}, wf.triage),
+        h("dt", {}, "Created at"), h("dd", {}, fmtTime(wf.createdAt)),
+        h("dt", {}, "Finished at"), h("dd", {}, wf.finishedAt ? `${fmtTime(wf.finishedAt)} (${duration(wf.createdAt, wf.finishedAt)})` : ""),
+        h("dt", {}, "Alert"), h("dd", { class: "mono" }, alert.id),
+        h("dt", {}, "Schema"), h("dd", {}, alert.schema),
+        h("dt", {}, "Source"), h("dd", {}, alert.source),
+        h("dt", {}, "Namespace"), h("dd", {}, alert.namespace
+          ? h("a", { href: `#/namespaces/${encodeURIComponent(alert.namespace)}` }, alert.namespace) : ""),
+        h("dt", {}, "Description"), h("dd", {}, alert.description)),
+      alert.refs.length > 0 ? [h("h3", {}, "References"), h("ul", {}, alert.refs.map((r) =>
+        h("li", {}, /^https?:\/\//.test(r.url || "") ? h("a", { href: r.url, target: "_blank", rel: "noopener noreferrer" }, r.title || r.url) : `${r.title || ""} ${r.url || ""}`)))] : null,
+      h("details", {}, h("summary", {}, "Alert data"), h("pre", {}, prettyJSON(alert.data))),
+      h("h3", {}, "Initial attributes"),
+      attrTable(alert.initAttrs),
+      h("h3", {}, "Timeline"),
+      sequences.size === 0
+        ? h("p", { class: "muted" }, "No action was run")
+        : h("div", { class: "timeline" }, [...sequences.entries()].map(([seq, actions]) => [
+          h("div", { class: "seq" }, `Sequence ${seq}`),
+          actions.map((a) => actionCard(a, current)),
+        ])),
+      h("h3", {}, "Last attributes"),
+      attrTable(alert.lastAttrs),
+      wf.comments.length > 0 ? [h("h3", {}, "Comments"), h("table", {}, h("tbody", {}, wf.comments.map((c) => h("tr", {},
+        h("td", {}, fmtTime(c.createdAt)),
+        h("td", {}, c.author || "(unknown)"),
+        h("td", {}, c.action),
+        h("td", {}, c.body)))))] : null);
+  };
+
+  // ----------------------------------------------------------------
+  // Namespace and attribute browser
+
+  const queryNamespaces = `query consoleNamespaces($offset: Int, $limit: Int) {
+  namespaces(offset: $offset, limit: $limit) { name updatedAt }
+}`;
+
+  const queryAttributes = `query consoleAttributes($namespace: String!) {
+  attributes(namespace: $namespace) {
+    id key value type ttl expiresAt updatedAt workflowId actor
+  }
+}`;
+
+  const queryHistory = `query consoleHistory($namespace: String!) {
+  attributes(namespace: $namespace) {
+    id
+    history(limit: 20) { value expiresAt workflowId actor createdAt }
+  }
+}`;
+
+  const renderNamespaces = async (app, params) => {
+    const offset = Number(params.get("offset") || 0);
+    app.replaceChildren(h("h2", {}, "Namespaces"));
+    const namespaces = (await gql(queryNamespaces, { offset, limit: pageSize })).namespaces;
+
+    app.append(
+      h("table", {},
+        h("thead", {}, h("tr", {}, h("th", {}, "Name"), h("th", {}, "Updated at"))),
+        h("tbody", {}, namespaces.length === 0
+          ? h("tr", {}, h("td", { colspan: "2", class: "muted" }, "No namespace found"))
+          : namespaces.map((ns) => h("tr", {},
+            h("td", {}, h("a", { href: `#/namespaces/${encodeURIComponent(ns.name)}` }, ns.name)),
+            h("td", {}, fmtTime(ns.updatedAt)))))),
+      h("div", { class: "pager" },
+        h("button", { disabled: offset === 0, onclick: () => { navigate(`#/namespaces?offset=${Math.max(0, offset - pageSize)}`); } }, "Prev"),
+        h("button", { disabled: namespaces.length < pageSize, onclick: () => { navigate(`#/namespaces?offset=${offset + pageSize}`); } }, "Next")));
+  };
+
+  const workflowLink = (id) => (id ? h("a", { class: "mono", href: `#/workflows/${id}` }, id.slice(0, 8)) : "");
+
+  const renderAttributes = async (app, namespace) => {
+    app.replaceChildren(
+      h("p", {}, h("a", { href: "#/namespaces" }, "← Namespaces")),
+      h("h2", {}, `Namespace: ${namespace}`));
+    const attrs = (await gql(queryAttributes, { namespace })).attributes;
+    if (attrs.length === 0) {
+      app.append(h("p", { class: "muted" }, "No unexpired attribute"));
+      return;
+    }
+
+    // History is fetched on demand because it requires a query per attribute
+    let history = null;
+    const loadHistory = async (id, cell) => {
+      try {
+        if (!history) {
+          const data = await gql(queryHistory, { namespace });
+          history = new Map(data.attributes.map((a) => [a.id, a.history]));
+        }
+        const changes = history.get(id) || [];
+        cell.replaceChildren(changes.length === 0 ? h("span", { class: "muted" }, "No history") : h("table", {},
+          h("thead", {}, h("tr", {}, ["Written at", "Value", "Expires at", "Workflow", "Actor"].map((c) => h("th", {}, c)))),
+          h("tbody", {}, changes.map((c) => h("tr", {},
+            h("td", {}, fmtTime(c.createdAt)),
+            h("td", { class: "mono" }, c.value),
+            h("td", {}, fmtTime(c.expiresAt)),
+            h("td", {}, workflowLink(c.workflowId)),
+            h("td", {}, c.actor || ""))))));
+      } catch (e) {
+        cell.replaceChildren(notice(e));
+      }
+    };
+
+    const rows = attrs.flatMap((a) => {
+      const cell = h("td", { colspan: "7" });
+      const historyRow = h("tr", { hidden: true }, cell);
+      const toggle = () => {
+        historyRow.hidden = !historyRow.hidden;
+        if (!historyRow.hidden && !cell.hasChildNodes()) {
+          cell.append(h("span", { class: "muted" }, "Loading..."));
+          loadHistory(a.id, cell);
+        }
+      };
+      return [
+        h("tr", {},
+          h("td", { class: "mono" }, a.key),
+          h("td", { class: "mono" }, a.value),
+          h("td", {}, a.type || ""),
+          h("td", {}, fmtTime(a.expiresAt)),
+          h("td", {}, fmtTime(a.updatedAt)),
+          h("td", {}, a.workflowId ? workflowLink(a.workflowId) : a.actor || ""),
+          h("td", {}, h("button", { onclick: toggle }, "History"))),
+        historyRow,
+      ];
+    });
+
+    app.append(h("table", {},
+      h("thead", {}, h("tr", {}, ["Key", "Value", "Type", "Expires at", "Updated at", "Written by", ""].map((c) => h("th", {}, c)))),
+      h("tbody", {}, rows)));
+  };
+
+  // ----------------------------------------------------------------
+  // Event submit form
+
+  const renderSubmit = (app) => {
+    const schema = h("input", { name: "schema", required: true, placeholder: "e.g. my_alert" });
+    const body = h("textarea", { name: "body", required: true, spellcheck: "false" }, "{\n  \n}");
+    const dryRun = h("input", { type: "checkbox", name: "dry_run", checked: true });
+    const result = h("div", {});
+
+    const form = h("form", { class: "submit" },
+      h("label", {}, "Schema ", schema),
+      h("label", {}, "Event (JSON)"),
+      body,
+      h("label", {}, dryRun, " Dry-run (actions are not executed, requires allow_dry_run of authz.http policy)"),
+      h("div", {}, h("button", { type: "submit" }, "Submit")));
+
+    form.addEventListener("submit", async (ev) => {
+      ev.preventDefault();
+      result.replaceChildren();
+      try {
+        JSON.parse(body.value);
+      } catch (e) {
+        result.append(notice(`Invalid JSON: ${e.message}`));
+        return;
+      }
+
+      const url = `/alert/raw/${encodeURIComponent(schema.value)}${dryRun.checked ? "?dry_run=true" : ""}`;
+      try {
+        const resp = await fetch(url, {
+          method: "POST",
+          headers: { "Content-Type": "application/json", ...authHeaders() },
+          body: body.value,
+        });
+        const text = await resp.text();
+        result.append(
+          h("h3", {}, `Response: ${resp.status} ${resp.statusText}`),
+          h("p", { class: "muted" }, dryRun.checked ? "" : "Workflows are recorded asynchronously. See Workflows page for the result."),
+          h("pre", {}, prettyJSON(text)));
+      } catch (e) {
+        result.append(notice(e));
+      }
+    });
+
+    app.replaceChildren(
+      h("h2", {}, "Submit event"),
+      h("p", { class: "muted" }, "The event is sent to /alert/raw/{schema} and evaluated by alert policy of the schema."),
+      form, result);
+  };
+
+  // ----------------------------------------------------------------
+  // Router
+
+  async function route() {
+    const app = document.getElementById("app");
+    const hash = location.hash.replace(/^#/, "") || "/workflows";
+    const [path, query] = hash.split("?", 2);
+    const params = new URLSearchParams(query || "");
+    const parts = path.split("/").filter((p) => p).map(decodeURIComponent);
+
+    document.getElementById("notices").replaceChildren();
+    for (const a of document.querySelectorAll("header nav a")) {
+      a.classList.toggle("active", a.dataset.page === parts[0]);
+    }
+
+    try {
+      switch (parts[0]) {
+        case "workflows":
+          await (parts[1] ? renderWorkflow(app, parts[1]) : renderWorkflows(app, params));
+          break;
+        case "namespaces":
+          await (parts[1] ? renderAttributes(app, parts[1]) : renderNamespaces(app, params));
+          break;
+        case "submit":
+          renderSubmit(app);
+          break;
+        default:
+          navigate("#/workflows");
+      }
+    } catch (e) {
+      warn(e instanceof Error ? e.message : String(e));
+    }
+  }
+
+  const tokenForm = document.getElementById("token-form");
+  const tokenInput = document.getElementById("token");
+  tokenInput.value = getToken();
+  tokenForm.addEventListener("submit", (ev) => {
+    ev.preventDefault();
+    if (tokenInput.value) {
+      sessionStorage.setItem(tokenKey, tokenInput.value);
+    } else {
+      sessionStorage.removeItem(tokenKey);
+    }
+    route();
+  });
+
+  window.addEventListener("hashchange", route);
+  route();
+})();
@@ -0,0 +1,54 @@
+package graphql
+
+import (
+	"context"
+
+	"github.com/m-mizutani/goerr/v2"
+	"github.com/secmon-lab/alertchain/pkg/domain/model"
+	"github.com/secmon-lab/alertchain/pkg/domain/types"
+	"github.com/secmon-lab/alertchain/pkg/utils"
+)
+
+// audit records the mutation with its result. Failure of recording does not change the result, because the mutation has been already applied.
+func (r *Resolver) audit(ctx context.Context, operation, target string, input map[string]any, opErr error) {
+	if err := r.svc.Audit.Record(ctx, operation, target, input, opErr); err != nil {
+		utils.HandleError(ctx, goerr.Wrap(err, "failed to record audit log", goerr.V("operation", operation), goerr.V("target", target)))
+	}
+}
+
+// rerunWorkflow runs a new workflow with the stored alert and returns record of the new workflow. Failure of the workflow is not an error of the mutation, and it can be seen in status of the returned record.
+func (r *Resolver) rerunWorkflow(ctx context.Context, id types.WorkflowID) (*model.WorkflowRecord, error) {
+	if r.runner == nil {
+		return nil, goerr.New("rerun of workflow is not available", goerr.T(types.ErrTagConfig))
+	}
+
+	alert, err := r.svc.Workflow.LookupAlert(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if alert == nil {
+		return nil, goerr.New("alert of workflow not found", goerr.V("id", id), goerr.T(types.ErrTagBadRequest))
+	}
+
+	// The workflow should not be interrupted by disconnection of the client
+	newID, err := r.runner(context.WithoutCancel(ctx), *alert)
+	if newID == "" {
+		return nil, err
+	}
+	if err != nil {
+		utils.HandleError(ctx, goerr.Wrap(err, "rerun workflow failed", goerr.V("id", id), goerr.V("new_id", newID)))
+	}
+
+	return r.svc.Workflow.Lookup(ctx, newID)
+}
+
+func (r *Resolver) triageWorkflow(ctx context.Context, operation string, id types.WorkflowID, triage string, comment *string) (*model.WorkflowRecord, error) {
+	var body string
+	if comment != nil {
+		body = *comment
+	}
+
+	wf, err := r.svc.Workflow.Triage(ctx, id, triage, body)
+	r.audit(ctx, operation, string(id), map[string]any{"id": id, "comment": body}, err)
+	return wf, err
+}
@@ -1,17 +1,43 @@
 package graphql
 
-import "github.com/secmon-lab/alertchain/pkg/service"
+import (
+	"github.com/secmon-lab/alertchain/pkg/domain/interfaces"
+	"github.com/secmon-lab/alertchain/pkg/infra/broker"
+	"github.com/secmon-lab/alertchain/pkg/service"
+)
 
 // This file will not be regenerated automatically.
 //
 // It serves as dependency injection for your app, add any dependencies you require here.
 
 type Resolver struct {
-	svc *service.Services
+	svc    *service.Services
+	runner interfaces.WorkflowRunner
+	events *broker.Broker
 }
 
-func NewResolver(svc *service.Services) *Resolver {
-	return &Resolver{
+type Option func(r *Resolver)
+
+// WithWorkflowRunner enables rerunWorkflow mutation.
+func WithWorkflowRunner(runner interfaces.WorkflowRunner) Option {
+	return func(r *Resolver) {
+		r.runner = runner
+	}
+}
+
+// WithWorkflowEvents enables workflowUpdated subscription with events published to the broker.
+func WithWorkflowEvents(events *broker.Broker) Option {
+	return func(r *Resolver) {
+		r.events = events
+	}
+}
+
+func NewResolver(svc *service.Services, options ...Option) *Resolver {
+	r := &Resolver{
 		svc: svc,
 	}
+	for _, opt := range options {
+		opt(r)
+	}
+	return r
 }
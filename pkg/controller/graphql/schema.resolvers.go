@@ -6,13 +6,83 @@ package graphql
 
 import (
 	"context"
-	"fmt"
+	"time"
 
 	"github.com/secmon-lab/alertchain/pkg/domain/model"
 	"github.com/secmon-lab/alertchain/pkg/domain/types"
 	"github.com/secmon-lab/alertchain/pkg/utils"
 )
 
+// RerunWorkflow is the resolver for the rerunWorkflow field.
+func (r *mutationResolver) RerunWorkflow(ctx context.Context, id types.WorkflowID) (*model.WorkflowRecord, error) {
+	wf, err := r.rerunWorkflow(ctx, id)
+	input := map[string]any{"id": id}
+	if wf != nil {
+		input["new_workflow_id"] = wf.ID
+	}
+	r.audit(ctx, "rerunWorkflow", string(id), input, err)
+	return wf, err
+}
+
+// AcknowledgeWorkflow is the resolver for the acknowledgeWorkflow field.
+func (r *mutationResolver) AcknowledgeWorkflow(ctx context.Context, id types.WorkflowID, comment *string) (*model.WorkflowRecord, error) {
+	return r.triageWorkflow(ctx, "acknowledgeWorkflow", id, model.WorkflowTriageAcknowledged, comment)
+}
+
+// CloseWorkflow is the resolver for the closeWorkflow field.
+func (r *mutationResolver) CloseWorkflow(ctx context.Context, id types.WorkflowID, comment *string) (*model.WorkflowRecord, error) {
+	return r.triageWorkflow(ctx, "closeWorkflow", id, model.WorkflowTriageClosed, comment)
+}
+
+// PutAttribute is the resolver for the putAttribute field.
+func (r *mutationResolver) PutAttribute(ctx context.Context, namespace string, attribute model.AttributeInput) (*model.AttributeRecord, error) {
+	attr := model.Attribute{
+		Key:   types.AttrKey(attribute.Key),
+		Value: attribute.Value,
+	}
+	if attribute.ID != nil {
+		attr.ID = types.AttrID(*attribute.ID)
+	}
+	if attribute.Type != nil {
+		attr.Type = types.AttrType(*attribute.Type)
+	}
+	if attribute.TTL != nil {
+		attr.TTL = *attribute.TTL
+	}
+
+	record, err := r.svc.Attribute.Put(ctx, types.Namespace(namespace), attr)
+	input := map[string]any{"namespace": namespace, "key": attr.Key, "value": attr.Value, "type": attr.Type, "ttl": attr.TTL}
+	if record != nil {
+		input["id"] = record.ID
+	}
+	r.audit(ctx, "putAttribute", namespace, input, err)
+	return record, err
+}
+
+// DeleteAttribute is the resolver for the deleteAttribute field.
+func (r *mutationResolver) DeleteAttribute(ctx context.Context, namespace string, id string) (bool, error) {
+	deleted, err := r.svc.Attribute.Delete(ctx, types.Namespace(namespace), types.AttrID(id))
+	r.audit(ctx, "deleteAttribute", namespace, map[string]any{"namespace": namespace, "id": id, "deleted": deleted}, err)
+	return deleted, err
+}
+
+// ReleaseLock is the resolver for the releaseLock field.
+func (r *mutationResolver) ReleaseLock(ctx context.Context, namespace string) (bool, error) {
+	err := r.svc.Attribute.ReleaseLock(ctx, types.Namespace(namespace))
+	r.audit(ctx, "releaseLock", namespace, map[string]any{"namespace": namespace}, err)
+	return err == nil, err
+}
+
+// Attributes is the resolver for the attributes field.
+func (r *namespaceRecordResolver) Attributes(ctx context.Context, obj *model.NamespaceRecord) ([]*model.PersistentAttributeRecord, error) {
+	return r.svc.Attribute.List(ctx, types.Namespace(obj.Name))
+}
+
+// History is the resolver for the history field.
+func (r *persistentAttributeRecordResolver) History(ctx context.Context, obj *model.PersistentAttributeRecord, limit *int) ([]*model.AttributeChangeRecord, error) {
+	return r.svc.Attribute.History(ctx, types.Namespace(obj.Namespace), types.AttrID(obj.ID), limit)
+}
+
 // Workflows is the resolver for the workflows field.
 func (r *queryResolver) Workflows(ctx context.Context, offset *int, limit *int) ([]*model.WorkflowRecord, error) {
 	results, err := r.svc.Workflow.Get(ctx, offset, limit)
@@ -23,21 +93,79 @@ func (r *queryResolver) Workflows(ctx context.Context, offset *int, limit *int)
 	return utils.ToPtrSlice(results), nil
 }
 
+// WorkflowConnection is the resolver for the workflowConnection field.
+func (r *queryResolver) WorkflowConnection(ctx context.Context, first *int, after *string) (*model.WorkflowConnection, error) {
+	return r.svc.Workflow.Connection(ctx, first, after)
+}
+
+// SearchWorkflows is the resolver for the searchWorkflows field.
+func (r *queryResolver) SearchWorkflows(ctx context.Context, filter model.WorkflowFilter, offset *int, limit *int) ([]*model.WorkflowRecord, error) {
+	results, err := r.svc.Workflow.Search(ctx, filter, offset, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return utils.ToPtrSlice(results), nil
+}
+
 // Workflow is the resolver for the workflow field.
 func (r *queryResolver) Workflow(ctx context.Context, id string) (*model.WorkflowRecord, error) {
 	return r.svc.Workflow.Lookup(ctx, types.WorkflowID(id))
 }
 
+// Stats is the resolver for the stats field.
+func (r *queryResolver) Stats(ctx context.Context, from time.Time, to time.Time, interval *int) (*model.Stats, error) {
+	return r.svc.Workflow.Stats(ctx, from, to, interval)
+}
+
+// Namespaces is the resolver for the namespaces field.
+func (r *queryResolver) Namespaces(ctx context.Context, offset *int, limit *int) ([]*model.NamespaceRecord, error) {
+	results, err := r.svc.Attribute.Namespaces(ctx, offset, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return utils.ToPtrSlice(results), nil
+}
+
+// Attributes is the resolver for the attributes field.
+func (r *queryResolver) Attributes(ctx context.Context, namespace string) ([]*model.PersistentAttributeRecord, error) {
+	return r.svc.Attribute.List(ctx, types.Namespace(namespace))
+}
+
+// WorkflowUpdated is the resolver for the workflowUpdated field.
+func (r *subscriptionResolver) WorkflowUpdated(ctx context.Context, id *types.WorkflowID, alertID *types.AlertID) (<-chan *model.WorkflowEvent, error) {
+	return r.subscribeWorkflow(ctx, id, alertID)
+}
+
 // Actions is the resolver for the actions field.
 func (r *workflowRecordResolver) Actions(ctx context.Context, obj *model.WorkflowRecord) ([]*model.ActionRecord, error) {
-	panic(fmt.Errorf("not implemented: Actions - actions"))
+	return obj.Actions, nil
+}
+
+// Mutation returns MutationResolver implementation.
+func (r *Resolver) Mutation() MutationResolver { return &mutationResolver{r} }
+
+// NamespaceRecord returns NamespaceRecordResolver implementation.
+func (r *Resolver) NamespaceRecord() NamespaceRecordResolver { return &namespaceRecordResolver{r} }
+
+// PersistentAttributeRecord returns PersistentAttributeRecordResolver implementation.
+func (r *Resolver) PersistentAttributeRecord() PersistentAttributeRecordResolver {
+	return &persistentAttributeRecordResolver{r}
 }
 
 // Query returns QueryResolver implementation.
 func (r *Resolver) Query() QueryResolver { return &queryResolver{r} }
 
+// Subscription returns SubscriptionResolver implementation.
+func (r *Resolver) Subscription() SubscriptionResolver { return &subscriptionResolver{r} }
+
 // WorkflowRecord returns WorkflowRecordResolver implementation.
 func (r *Resolver) WorkflowRecord() WorkflowRecordResolver { return &workflowRecordResolver{r} }
 
+type mutationResolver struct{ *Resolver }
+type namespaceRecordResolver struct{ *Resolver }
+type persistentAttributeRecordResolver struct{ *Resolver }
 type queryResolver struct{ *Resolver }
+type subscriptionResolver struct{ *Resolver }
 type workflowRecordResolver struct{ *Resolver }
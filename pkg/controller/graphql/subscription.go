@@ -0,0 +1,38 @@
+package graphql
+
+import (
+	"context"
+
+	"github.com/m-mizutani/goerr/v2"
+	"github.com/secmon-lab/alertchain/pkg/domain/model"
+	"github.com/secmon-lab/alertchain/pkg/domain/types"
+)
+
+// subscribeWorkflow returns events of workflows matched with id and alertID. Nil condition matches any workflow.
+func (r *Resolver) subscribeWorkflow(ctx context.Context, id *types.WorkflowID, alertID *types.AlertID) (<-chan *model.WorkflowEvent, error) {
+	if r.events == nil {
+		return nil, goerr.New("subscription of workflow is not available", goerr.T(types.ErrTagConfig))
+	}
+
+	events := r.events.Subscribe(ctx)
+	ch := make(chan *model.WorkflowEvent)
+	go func() {
+		defer close(ch)
+		for event := range events {
+			if id != nil && event.WorkflowID != *id {
+				continue
+			}
+			if alertID != nil && event.AlertID != *alertID {
+				continue
+			}
+
+			select {
+			case ch <- event:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return ch, nil
+}
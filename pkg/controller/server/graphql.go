@@ -0,0 +1,220 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	gqlgen "github.com/99designs/gqlgen/graphql"
+	"github.com/secmon-lab/alertchain/pkg/ctxutil"
+	"github.com/secmon-lab/alertchain/pkg/domain/interfaces"
+	"github.com/secmon-lab/alertchain/pkg/domain/model"
+	"github.com/secmon-lab/alertchain/pkg/domain/types"
+	"github.com/secmon-lab/alertchain/pkg/infra/policy"
+	"github.com/secmon-lab/alertchain/pkg/infra/tracing"
+	"github.com/secmon-lab/alertchain/pkg/logging"
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// GraphQLAuthzInput is input of authz.graphql policy. It is evaluated once per GraphQL operation.
+type GraphQLAuthzInput struct {
+	// Operation is name of the operation. It is empty for anonymous operation.
+	Operation string `json:"operation"`
+	// Type is one of "query", "mutation" and "subscription".
+	Type string `json:"type"`
+	// Fields is a list of requested fields in "Type.field" format, e.g. "Query.workflows" and "AlertRecord.data".
+	Fields    []string       `json:"fields"`
+	Variables map[string]any `json:"variables"`
+	// Caller is the HTTP request of the operation without body. It has the same fields as input of authz.http policy.
+	Caller *HTTPAuthzInput `json:"caller,omitempty"`
+	Env    types.EnvVars   `json:"env" masq:"secret"`
+}
+
+// GraphQLAuthzOutput is output of authz.graphql policy.
+type GraphQLAuthzOutput struct {
+	// Deny rejects the whole operation.
+	Deny bool `json:"deny"`
+	// DenyFields hides the fields in "Type.field" format. A denied field is resolved as null or zero value with an error.
+	DenyFields []string `json:"deny_fields"`
+	// AllowMutation permits mutation operation. Mutation is denied unless it is explicitly allowed.
+	AllowMutation bool `json:"allow_mutation"`
+	// Actor is identity of the caller recorded in audit log, e.g. email in header set by authentication proxy. Email or subject of OIDC token, or common name of client certificate is used if it is empty.
+	Actor string `json:"actor"`
+}
+
+type graphqlAuthz struct {
+	authz  *policy.Client
+	getEnv interfaces.Env
+	sink   interfaces.DecisionLogSink
+	inst   interfaces.Instrument
+}
+
+type ctxDeniedFieldsKey struct{}
+
+type deniedFields struct {
+	fields   map[string]struct{}
+	reported sync.Map
+}
+
+func collectFields(set ast.SelectionSet, fields map[string]struct{}) {
+	for _, sel := range set {
+		switch v := sel.(type) {
+		case *ast.Field:
+			if v.ObjectDefinition != nil {
+				fields[v.ObjectDefinition.Name+"."+v.Name] = struct{}{}
+			}
+			collectFields(v.SelectionSet, fields)
+		case *ast.InlineFragment:
+			collectFields(v.SelectionSet, fields)
+		case *ast.FragmentSpread:
+			if v.Definition != nil {
+				collectFields(v.Definition.SelectionSet, fields)
+			}
+		}
+	}
+}
+
+// newActor identifies the caller of the operation for audit log.
+func newActor(caller *HTTPAuthzInput, subject string) *model.Actor {
+	actor := &model.Actor{Subject: subject}
+	if caller == nil {
+		return actor
+	}
+
+	actor.Remote = caller.Remote
+	if actor.Subject == "" {
+		for _, key := range []string{"email", "sub"} {
+			if v, ok := caller.OIDC[key].(string); ok && v != "" {
+				actor.Subject = v
+				break
+			}
+		}
+	}
+	if actor.Subject == "" && caller.ClientCert != nil {
+		actor.Subject = caller.ClientCert.CommonName
+	}
+	return actor
+}
+
+// aroundOperations evaluates authz.graphql policy for the operation. Query is allowed if the package does not exist, but mutation must be allowed explicitly by allow_mutation. Then mutation is always denied without authz policy.
+func (x *graphqlAuthz) aroundOperations(ctx context.Context, next gqlgen.OperationHandler) gqlgen.ResponseHandler {
+	opCtx := gqlgen.GetOperationContext(ctx)
+	if opCtx.Operation == nil {
+		return next(ctx)
+	}
+	isMutation := opCtx.Operation.Operation == ast.Mutation
+
+	if x.authz == nil {
+		if isMutation {
+			x.inst.AuthzDenied(ctx, "/graphql")
+			return gqlgen.OneShot(gqlgen.ErrorResponse(ctx, "mutation is not allowed without authz policy"))
+		}
+		return next(ctx)
+	}
+
+	fieldSet := map[string]struct{}{}
+	collectFields(opCtx.Operation.SelectionSet, fieldSet)
+	fields := make([]string, 0, len(fieldSet))
+	for f := range fieldSet {
+		fields = append(fields, f)
+	}
+	sort.Strings(fields)
+
+	input := &GraphQLAuthzInput{
+		Operation: opCtx.OperationName,
+		Type:      string(opCtx.Operation.Operation),
+		Fields:    fields,
+		Variables: opCtx.Variables,
+		Env:       x.getEnv(),
+	}
+	if caller, ok := ctx.Value(ctxHTTPAuthzInputKey{}).(*HTTPAuthzInput); ok {
+		copied := *caller
+		copied.Body = ""
+		copied.Env = nil
+		input.Caller = &copied
+	}
+
+	var output GraphQLAuthzOutput
+	queryCtx, span := tracing.Start(ctx, "authz.graphql",
+		attribute.String("graphql.operation.name", input.Operation),
+		attribute.String("graphql.operation.type", input.Type),
+	)
+	if err := x.authz.Query(queryCtx, input, &output, authzQueryOptions(ctx, "graphql", x.sink, x.inst)...); err != nil {
+		if !errors.Is(err, types.ErrNoPolicyResult) {
+			tracing.End(span, err)
+			ctxutil.Logger(ctx).Error("Fail to evaluate GraphQL authz policy", logging.ErrAttr(err))
+			return gqlgen.OneShot(gqlgen.ErrorResponse(ctx, "failed to evaluate authorization policy"))
+		}
+	}
+	span.SetAttributes(attribute.Bool("alertchain.authz.deny", output.Deny))
+	tracing.End(span, nil)
+
+	if output.Deny || (isMutation && !output.AllowMutation) {
+		x.inst.AuthzDenied(ctx, "/graphql")
+		return gqlgen.OneShot(gqlgen.ErrorResponse(ctx, "access denied"))
+	}
+	ctx = ctxutil.InjectActor(ctx, newActor(input.Caller, output.Actor))
+
+	if len(output.DenyFields) > 0 {
+		denied := &deniedFields{fields: map[string]struct{}{}}
+		for _, f := range output.DenyFields {
+			denied.fields[f] = struct{}{}
+		}
+		ctx = context.WithValue(ctx, ctxDeniedFieldsKey{}, denied)
+	}
+
+	return next(ctx)
+}
+
+// aroundFields hides fields denied by authz.graphql policy. The field is not resolved, and replaced with zero value of its type instead of returning error, because an error of non-null field nullifies the parent object.
+func (x *graphqlAuthz) aroundFields(ctx context.Context, next gqlgen.Resolver) (any, error) {
+	denied, ok := ctx.Value(ctxDeniedFieldsKey{}).(*deniedFields)
+	if !ok {
+		return next(ctx)
+	}
+
+	fc := gqlgen.GetFieldContext(ctx)
+	name := fc.Object + "." + fc.Field.Name
+	if _, ok := denied.fields[name]; !ok {
+		return next(ctx)
+	}
+
+	// Report only once per field to avoid flood of errors for list
+	if _, reported := denied.reported.LoadOrStore(name, true); !reported {
+		gqlgen.AddError(ctx, &gqlerror.Error{
+			Path:    fc.Path(),
+			Message: "access to " + name + " is denied",
+		})
+	}
+
+	return zeroValue(fc), nil
+}
+
+// zeroValue returns zero value of Go type of the field. The type is looked up from struct of the parent object by json tag or name of the field. It returns nil for nullable field and field without parent struct, such as field of Query, and nil of non-null field is reported as null by gqlgen.
+func zeroValue(fc *gqlgen.FieldContext) any {
+	if !fc.Field.Definition.Type.NonNull || fc.Parent == nil {
+		return nil
+	}
+
+	t := reflect.TypeOf(fc.Parent.Result)
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == fc.Field.Name || (name == "" && strings.EqualFold(f.Name, fc.Field.Name)) {
+			return reflect.Zero(f.Type).Interface()
+		}
+	}
+	return nil
+}
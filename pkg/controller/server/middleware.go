@@ -1,20 +1,35 @@
 package server
 
 import (
+	"bufio"
 	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"io"
+	"net"
 	"net/http"
 	"net/url"
+	"strconv"
+	"strings"
+	"time"
 
 	"log/slog"
 
+	"github.com/m-mizutani/goerr/v2"
 	"github.com/secmon-lab/alertchain/pkg/ctxutil"
 	"github.com/secmon-lab/alertchain/pkg/domain/interfaces"
 	"github.com/secmon-lab/alertchain/pkg/domain/types"
+	"github.com/secmon-lab/alertchain/pkg/infra/oidc"
 	"github.com/secmon-lab/alertchain/pkg/infra/policy"
+	"github.com/secmon-lab/alertchain/pkg/infra/ratelimit"
+	"github.com/secmon-lab/alertchain/pkg/infra/signature"
+	"github.com/secmon-lab/alertchain/pkg/infra/tracing"
 	"github.com/secmon-lab/alertchain/pkg/logging"
 	"github.com/secmon-lab/alertchain/pkg/utils"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 type StatusCodeWriter struct {
@@ -27,6 +42,20 @@ func (x *StatusCodeWriter) WriteHeader(code int) {
 	x.ResponseWriter.WriteHeader(code)
 }
 
+// Hijack is required to upgrade the connection to websocket for GraphQL subscription.
+func (x *StatusCodeWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := x.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, goerr.New("response writer does not support hijack")
+	}
+	x.code = http.StatusSwitchingProtocols
+	return hijacker.Hijack()
+}
+
+func (x *StatusCodeWriter) Unwrap() http.ResponseWriter {
+	return x.ResponseWriter
+}
+
 type HTTPAuthzInput struct {
 	Method string              `json:"method"`
 	Path   string              `json:"path"`
@@ -34,14 +63,120 @@ type HTTPAuthzInput struct {
 	Header map[string][]string `json:"header"`
 	Remote string              `json:"remote"`
 	Body   string              `json:"body"`
-	Env    types.EnvVars       `json:"env"`
+	Env    types.EnvVars       `json:"env" masq:"secret"`
+
+	// OIDC is verified claims of ID token. It is set only for Pub/Sub push request when the token verification is enabled.
+	OIDC map[string]any `json:"oidc,omitempty"`
+
+	// VerifiedSignatures is a list of signature types (e.g. "github") that the request body is successfully verified with.
+	VerifiedSignatures []string `json:"verified_signatures,omitempty"`
+
+	// ClientCert is identity of the client certificate verified by mutual TLS. It is nil if the request has no verified certificate.
+	ClientCert *HTTPAuthzClientCert `json:"client_cert,omitempty"`
+}
+
+// HTTPAuthzClientCert is identity of the verified client certificate (leaf of the verified chain).
+type HTTPAuthzClientCert struct {
+	Subject           string    `json:"subject"`
+	CommonName        string    `json:"common_name"`
+	Organization      []string  `json:"organization,omitempty"`
+	Issuer            string    `json:"issuer"`
+	SerialNumber      string    `json:"serial_number"`
+	DNSNames          []string  `json:"dns_names,omitempty"`
+	EmailAddresses    []string  `json:"email_addresses,omitempty"`
+	URIs              []string  `json:"uris,omitempty"`
+	IPAddresses       []string  `json:"ip_addresses,omitempty"`
+	FingerprintSHA256 string    `json:"fingerprint_sha256"`
+	NotBefore         time.Time `json:"not_before"`
+	NotAfter          time.Time `json:"not_after"`
+}
+
+func newHTTPAuthzClientCert(r *http.Request) *HTTPAuthzClientCert {
+	// PeerCertificates are not trusted unless they are verified by ClientCAs
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return nil
+	}
+	cert := r.TLS.VerifiedChains[0][0]
+	fingerprint := sha256.Sum256(cert.Raw)
+
+	input := &HTTPAuthzClientCert{
+		Subject:           cert.Subject.String(),
+		CommonName:        cert.Subject.CommonName,
+		Organization:      cert.Subject.Organization,
+		Issuer:            cert.Issuer.String(),
+		SerialNumber:      cert.SerialNumber.String(),
+		DNSNames:          cert.DNSNames,
+		EmailAddresses:    cert.EmailAddresses,
+		FingerprintSHA256: hex.EncodeToString(fingerprint[:]),
+		NotBefore:         cert.NotBefore,
+		NotAfter:          cert.NotAfter,
+	}
+	for _, uri := range cert.URIs {
+		input.URIs = append(input.URIs, uri.String())
+	}
+	for _, ip := range cert.IPAddresses {
+		input.IPAddresses = append(input.IPAddresses, ip.String())
+	}
+
+	return input
 }
 
 type HTTPAuthzOutput struct {
 	Deny bool `json:"deny"`
+
+	// RateClass selects a rate class defined by --rate-limit-class. The class limit is applied to the request in addition to the global, schema and remote address limits.
+	RateClass string `json:"rate_class,omitempty"`
+
+	// AllowDryRun permits dry-run mode of /alert/* requested by `dry_run=true` query parameter. Dry-run is rejected unless it is explicitly allowed.
+	AllowDryRun bool `json:"allow_dry_run,omitempty"`
+}
+
+type ctxAllowDryRunKey struct{}
+
+type ctxHTTPAuthzInputKey struct{}
+
+func authzQueryOptions(ctx context.Context, suffix string, sink interfaces.DecisionLogSink, inst interfaces.Instrument) []policy.QueryOption {
+	options := []policy.QueryOption{
+		policy.WithPackageSuffix(suffix),
+		policy.WithInstrument(inst),
+		policy.WithRegoPrint(func(file string, row int, msg string) error {
+			ctxutil.Logger(ctx).Info("rego print",
+				slog.String("file", file),
+				slog.Int("row", row),
+				slog.String("msg", msg),
+				slog.String("package", "authz."+suffix),
+			)
+			return nil
+		}),
+	}
+	if sink != nil {
+		options = append(options, policy.WithDecisionLogSink(sink))
+	}
+	return options
 }
 
-func Authorize(authz *policy.Client, getEnv interfaces.Env) func(next http.Handler) http.Handler {
+// LimitBody limits size of request body. Reading more than maxBytes fails, and it is reported as 413 by middlewares and handlers reading the body. No limit if maxBytes is not positive.
+func LimitBody(maxBytes int64) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if maxBytes > 0 {
+				r.Body = http.MaxBytesReader(w, r.Body, maxBytes)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// bodyErrorStatus returns status code for error of reading request body.
+func bodyErrorStatus(err error) int {
+	var maxErr *http.MaxBytesError
+	if errors.As(err, &maxErr) {
+		return http.StatusRequestEntityTooLarge
+	}
+	return http.StatusBadRequest
+}
+
+func Authorize(authz *policy.Client, getEnv interfaces.Env, sink interfaces.DecisionLogSink, inst interfaces.Instrument) func(next http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			ctx := r.Context()
@@ -50,7 +185,7 @@ func Authorize(authz *policy.Client, getEnv interfaces.Env) func(next http.Handl
 				body, err := io.ReadAll(reader)
 				if err != nil {
 					utils.HandleError(ctx, err)
-					w.WriteHeader(http.StatusBadRequest)
+					w.WriteHeader(bodyErrorStatus(err))
 					utils.SafeWrite(ctx, w, []byte(err.Error()))
 					return
 				}
@@ -66,34 +201,45 @@ func Authorize(authz *policy.Client, getEnv interfaces.Env) func(next http.Handl
 					Body:   string(body),
 					Env:    getEnv(),
 				}
-
-				options := []policy.QueryOption{
-					policy.WithPackageSuffix("http"),
-					policy.WithRegoPrint(func(file string, row int, msg string) error {
-						ctxutil.Logger(ctx).Info("rego print",
-							slog.String("file", file),
-							slog.Int("row", row),
-							slog.String("msg", msg),
-							slog.String("package", "authz.http"),
-						)
-						return nil
-					}),
+				if claims, ok := ctx.Value(ctxOIDCClaimsKey{}).(map[string]any); ok {
+					input.OIDC = claims
+				}
+				if verified, ok := ctx.Value(ctxVerifiedSignaturesKey{}).([]string); ok {
+					input.VerifiedSignatures = verified
 				}
+				input.ClientCert = newHTTPAuthzClientCert(r)
+
+				options := authzQueryOptions(ctx, "http", sink, inst)
 
 				var output HTTPAuthzOutput
-				if err := authz.Query(r.Context(), input, &output, options...); err != nil {
+				queryCtx, span := tracing.Start(ctx, "authz", attribute.String("url.path", r.URL.Path))
+				if err := authz.Query(queryCtx, input, &output, options...); err != nil {
 					if !errors.Is(err, types.ErrNoPolicyResult) {
+						tracing.End(span, err)
 						ctxutil.Logger(ctx).Error("Fail to evaluate authz policy", logging.ErrAttr(err))
 						w.WriteHeader(http.StatusInternalServerError)
 						return
 					}
 				}
+				span.SetAttributes(attribute.Bool("alertchain.authz.deny", output.Deny))
+				tracing.End(span, nil)
 
 				if output.Deny {
+					inst.AuthzDenied(ctx, r.URL.Path)
 					w.WriteHeader(http.StatusForbidden)
 					utils.SafeWrite(ctx, w, []byte("Access denied"))
 					return
 				}
+
+				// Caller identity is also used by authz.graphql policy
+				ctx = context.WithValue(ctx, ctxHTTPAuthzInputKey{}, input)
+				if output.RateClass != "" {
+					ctx = context.WithValue(ctx, ctxRateClassKey{}, output.RateClass)
+				}
+				if output.AllowDryRun {
+					ctx = context.WithValue(ctx, ctxAllowDryRunKey{}, true)
+				}
+				r = r.WithContext(ctx)
 			}
 
 			next.ServeHTTP(w, r)
@@ -101,6 +247,242 @@ func Authorize(authz *policy.Client, getEnv interfaces.Env) func(next http.Handl
 	}
 }
 
+type ctxOIDCClaimsKey struct{}
+
+// VerifyPubSubToken validates ID token in Authorization header of Pub/Sub push request (/alert/pubsub/*). The request is rejected with 401 if the token is missing or invalid. Other paths are not affected.
+func VerifyPubSubToken(verifier *oidc.Verifier) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if verifier == nil || !strings.HasPrefix(r.URL.Path, "/alert/pubsub/") {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			ctx := r.Context()
+			scheme, token, found := strings.Cut(r.Header.Get("Authorization"), " ")
+			if !found || !strings.EqualFold(scheme, "bearer") {
+				ctxutil.Logger(ctx).Warn("no bearer token in Pub/Sub push request", slog.String("path", r.URL.Path))
+				w.WriteHeader(http.StatusUnauthorized)
+				utils.SafeWrite(ctx, w, []byte("Unauthorized"))
+				return
+			}
+
+			claims, err := verifier.Verify(ctx, token)
+			if err != nil {
+				if goerr.HasTag(err, types.ErrTagUnauthorized) {
+					ctxutil.Logger(ctx).Warn("invalid ID token of Pub/Sub push request", logging.ErrAttr(err))
+					w.WriteHeader(http.StatusUnauthorized)
+					utils.SafeWrite(ctx, w, []byte("Unauthorized"))
+				} else {
+					utils.HandleError(ctx, err)
+					w.WriteHeader(http.StatusInternalServerError)
+				}
+				return
+			}
+
+			ctx = context.WithValue(ctx, ctxOIDCClaimsKey{}, claims)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+type ctxVerifiedSignaturesKey struct{}
+
+// VerifySignature checks HMAC signature of request body with verifiers of rules matched with the path. Failure of the verification does not reject the request, but the type of verifier is not listed in verified_signatures of authz input.
+func VerifySignature(rules []signature.Rule) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var verifiers []signature.Verifier
+			for _, rule := range rules {
+				if rule.Match(r.URL.Path) {
+					verifiers = append(verifiers, rule.Verifier)
+				}
+			}
+			if len(verifiers) == 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			ctx := r.Context()
+			reader := r.Body
+			body, err := io.ReadAll(reader)
+			if err != nil {
+				utils.HandleError(ctx, err)
+				w.WriteHeader(bodyErrorStatus(err))
+				utils.SafeWrite(ctx, w, []byte(err.Error()))
+				return
+			}
+			defer utils.SafeClose(ctx, reader)
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			verified := []string{}
+			for _, v := range verifiers {
+				if err := v.Verify(r.Header, body); err != nil {
+					ctxutil.Logger(ctx).Warn("signature verification failed",
+						slog.String("type", v.Type()),
+						slog.String("path", r.URL.Path),
+						logging.ErrAttr(err),
+					)
+					continue
+				}
+				verified = append(verified, v.Type())
+			}
+
+			ctx = context.WithValue(ctx, ctxVerifiedSignaturesKey{}, verified)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+type ctxRateClassKey struct{}
+
+type ctxEventCountKey struct{}
+
+// RateLimit applies global, schema and remote address limits to /alert/* requests. It must be placed before Authorize so that a flood of requests does not reach authz policy. Each event of a batch request consumes a token. A request exceeding the limit is rejected with 429, or processed or dropped by sampling if the limiter enables it.
+func RateLimit(limiter *ratelimit.Limiter, inst interfaces.Instrument) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			schema, ok := rateLimitTarget(r)
+			if limiter == nil || !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			ctx := r.Context()
+			n, err := countEvents(r)
+			if err != nil {
+				utils.HandleError(ctx, err)
+				w.WriteHeader(bodyErrorStatus(err))
+				utils.SafeWrite(ctx, w, []byte(err.Error()))
+				return
+			}
+			r = r.WithContext(context.WithValue(ctx, ctxEventCountKey{}, n))
+
+			remote := remoteHost(r)
+			result := limiter.Allow(schema, remote, n)
+			handleRateLimitResult(w, r, next, inst, result, schema, remote, "")
+		})
+	}
+}
+
+// RateLimitClass applies limit of rate class selected by authz policy to /alert/* requests. It must be placed after Authorize. Number of events counted by RateLimit is consumed.
+func RateLimitClass(limiter *ratelimit.Limiter, inst interfaces.Instrument) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+			schema, ok := rateLimitTarget(r)
+			class, _ := ctx.Value(ctxRateClassKey{}).(string)
+			if limiter == nil || !ok || class == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if !limiter.HasClass(class) {
+				ctxutil.Logger(ctx).Warn("unknown rate class in authz policy output", slog.String("rate_class", class))
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			n, ok := ctx.Value(ctxEventCountKey{}).(int)
+			if !ok {
+				n = 1
+			}
+
+			remote := remoteHost(r)
+			result := limiter.AllowClass(class, remote, n)
+			handleRateLimitResult(w, r, next, inst, result, schema, remote, class)
+		})
+	}
+}
+
+func rateLimitTarget(r *http.Request) (types.Schema, bool) {
+	path, ok := strings.CutPrefix(r.URL.Path, "/alert/")
+	if !ok {
+		return "", false
+	}
+
+	var schema types.Schema
+	if _, s, found := strings.Cut(path, "/"); found {
+		schema = types.Schema(s)
+	}
+	return schema, true
+}
+
+func remoteHost(r *http.Request) string {
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
+
+// countEvents returns number of events in the request. Only batch request of /alert/raw/* has multiple events, and the body is restored after counting. A broken JSON array is counted as one event and rejected by the handler later.
+func countEvents(r *http.Request) (int, error) {
+	batch := r.URL.Query().Get("batch") == "true"
+	if !strings.HasPrefix(r.URL.Path, "/alert/raw/") || (!isNDJSON(r) && !batch) {
+		return 1, nil
+	}
+
+	reader := r.Body
+	body, err := io.ReadAll(reader)
+	if err != nil {
+		return 0, goerr.Wrap(err, "failed to read request body")
+	}
+	utils.SafeClose(r.Context(), reader)
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	if isNDJSON(r) {
+		var n int
+		for _, line := range bytes.Split(body, []byte("\n")) {
+			if len(bytes.TrimSpace(line)) > 0 {
+				n++
+			}
+		}
+		return max(n, 1), nil
+	}
+
+	var events []json.RawMessage
+	if err := json.Unmarshal(body, &events); err != nil {
+		return 1, nil
+	}
+	return max(len(events), 1), nil
+}
+
+func handleRateLimitResult(w http.ResponseWriter, r *http.Request, next http.Handler, inst interfaces.Instrument, result ratelimit.Result, schema types.Schema, remote, class string) {
+	if result.Decision == ratelimit.Pass {
+		next.ServeHTTP(w, r)
+		return
+	}
+
+	ctx := r.Context()
+	inst.RateLimited(ctx, result.Scope, schema, result.Decision.String())
+	ctxutil.Logger(ctx).Warn("rate limit exceeded",
+		slog.String("scope", result.Scope),
+		slog.String("decision", result.Decision.String()),
+		slog.Any("schema", schema),
+		slog.String("remote", remote),
+		slog.String("rate_class", class),
+	)
+
+	switch result.Decision {
+	case ratelimit.Sampled:
+		next.ServeHTTP(w, r)
+
+	case ratelimit.Dropped:
+		// Respond 202 so that the sender does not retry the dropped event
+		w.WriteHeader(http.StatusAccepted)
+		utils.SafeWrite(ctx, w, []byte("Dropped by rate limit"))
+
+	case ratelimit.TooLarge:
+		// The request never fits in the bucket, then Retry-After is not given
+		respondError(ctx, w, goerr.New("too many events for rate limit", goerr.V("scope", result.Scope), goerr.T(types.ErrTagTooLarge)))
+
+	default:
+		w.Header().Set("Retry-After", strconv.Itoa(int(result.RetryAfter.Seconds())))
+		w.WriteHeader(http.StatusTooManyRequests)
+		utils.SafeWrite(ctx, w, []byte("Too many requests"))
+	}
+}
+
 func Logging(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
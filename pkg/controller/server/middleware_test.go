@@ -4,10 +4,14 @@ import (
 	"bytes"
 	"context"
 	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
 	"crypto/sha256"
 	_ "embed"
 	"encoding/base64"
+	"encoding/hex"
 	"fmt"
+	"math/big"
 	"net/http"
 	"net/http/httptest"
 	"os/exec"
@@ -15,12 +19,18 @@ import (
 	"testing"
 	"time"
 
+	"github.com/golang-jwt/jwt/v4"
 	"github.com/m-mizutani/gt"
 	"github.com/secmon-lab/alertchain/pkg/controller/server"
 	"github.com/secmon-lab/alertchain/pkg/domain/model"
 	"github.com/secmon-lab/alertchain/pkg/domain/types"
+	"github.com/secmon-lab/alertchain/pkg/infra/metrics"
+	"github.com/secmon-lab/alertchain/pkg/infra/oidc"
 	"github.com/secmon-lab/alertchain/pkg/infra/policy"
+	"github.com/secmon-lab/alertchain/pkg/infra/ratelimit"
+	"github.com/secmon-lab/alertchain/pkg/infra/signature"
 	"github.com/secmon-lab/alertchain/pkg/utils"
+	"golang.org/x/time/rate"
 )
 
 //go:embed testdata/authz.rego
@@ -136,6 +146,139 @@ func TestVerifyGoogleIDToken(t *testing.T) {
 	}
 }
 
+func TestVerifyPubSubToken(t *testing.T) {
+	key := gt.R1(rsa.GenerateKey(rand.Reader, 2048)).NoError(t)
+	jwks := fmt.Sprintf(`{"keys":[{"kty":"RSA","kid":"key1","n":"%s","e":"%s"}]}`,
+		base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	)
+	verifier := gt.R1(oidc.New(
+		oidc.WithAudience("https://alertchain.example.com"),
+		oidc.WithJWKSFetcher(func(ctx context.Context) ([]byte, error) {
+			return []byte(jwks), nil
+		}),
+	)).NoError(t)
+
+	issue := func(email string) string {
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+			"iss":            "https://accounts.google.com",
+			"aud":            "https://alertchain.example.com",
+			"email":          email,
+			"email_verified": true,
+			"exp":            time.Now().Add(time.Hour).Unix(),
+		})
+		token.Header["kid"] = "key1"
+		return gt.R1(token.SignedString(key)).NoError(t)
+	}
+
+	// authz policy decides only on verified identity
+	srv := newServer(t, `package authz.http
+
+default deny := true
+
+deny := false if {
+	input.oidc.email == "pubsub@my-project.iam.gserviceaccount.com"
+}
+
+deny := false if {
+	input.path == "/alert/raw/test"
+}
+`, server.WithPubSubVerifier(verifier))
+
+	testCases := map[string]struct {
+		path   string
+		token  string
+		expect int
+	}{
+		"valid": {
+			path:   "/alert/pubsub/test",
+			token:  "Bearer " + issue("pubsub@my-project.iam.gserviceaccount.com"),
+			expect: http.StatusOK,
+		},
+		"denied by policy": {
+			path:   "/alert/pubsub/test",
+			token:  "Bearer " + issue("someone@my-project.iam.gserviceaccount.com"),
+			expect: http.StatusForbidden,
+		},
+		"invalid token": {
+			path:   "/alert/pubsub/test",
+			token:  "Bearer invalid-token",
+			expect: http.StatusUnauthorized,
+		},
+		"no token": {
+			path:   "/alert/pubsub/test",
+			expect: http.StatusUnauthorized,
+		},
+		"not pubsub path": {
+			path:   "/alert/raw/test",
+			expect: http.StatusOK,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", tc.path, strings.NewReader(`{"message":{"data":"e30="}}`))
+			if tc.token != "" {
+				req.Header.Set("Authorization", tc.token)
+			}
+			w := httptest.NewRecorder()
+			srv.ServeHTTP(w, req)
+			gt.N(t, w.Result().StatusCode).Equal(tc.expect)
+		})
+	}
+}
+
+func TestVerifySignature(t *testing.T) {
+	const secret = "Caprice_of_the_Leaves"
+	data := []byte(`{"action":"opened"}`)
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(data)
+	validSig := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+
+	srv := newServer(t, `package authz.http
+
+default deny := true
+
+deny := false if "github" in input.verified_signatures
+`, server.WithSignatureRules(signature.Rule{
+		Path:     "/alert/raw/github",
+		Verifier: signature.NewGitHub(secret),
+	}))
+
+	testCases := map[string]struct {
+		path   string
+		sig    string
+		expect int
+	}{
+		"valid": {
+			path:   "/alert/raw/github",
+			sig:    validSig,
+			expect: http.StatusOK,
+		},
+		"invalid signature": {
+			path:   "/alert/raw/github",
+			sig:    "sha256=" + hex.EncodeToString([]byte("invalid")),
+			expect: http.StatusForbidden,
+		},
+		"not configured path": {
+			path:   "/alert/raw/other",
+			sig:    validSig,
+			expect: http.StatusForbidden,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", tc.path, bytes.NewReader(data))
+			req.Header.Set("X-Hub-Signature-256", tc.sig)
+			w := httptest.NewRecorder()
+			srv.ServeHTTP(w, req)
+			gt.N(t, w.Result().StatusCode).Equal(tc.expect)
+		})
+	}
+}
+
 func hmacSign(secretKey, timestamp string, data []byte) string {
 	msg := fmt.Sprintf("%s%s", data, timestamp)
 	fmt.Println("msg=", msg)
@@ -226,3 +369,125 @@ func TestVerifySignedBody(t *testing.T) {
 		})
 	}
 }
+
+func TestRateLimit(t *testing.T) {
+	perMinute := func(n int) ratelimit.Limit {
+		return ratelimit.Limit{Rate: rate.Limit(float64(n) / 60), Burst: n}
+	}
+
+	var evaluated int
+	authz := gt.R1(policy.New(
+		policy.WithPolicyData("authz.rego", `package authz.http
+
+deny := false
+
+rate_class := "restricted" if input.header["X-Source"] == ["restricted"]
+`),
+		policy.WithPackage("authz"),
+	)).NoError(t)
+	srv := server.New(func(ctx context.Context, schema types.Schema, data any) ([]*model.Alert, error) {
+		return nil, nil
+	},
+		server.WithAuthzPolicy(authz),
+		server.WithInstrument(&authzCounter{count: &evaluated}),
+		server.WithRateLimiter(ratelimit.New(
+			ratelimit.WithRemote(perMinute(3)),
+			ratelimit.WithClass("restricted", perMinute(1)),
+		)),
+	)
+
+	send := func(method, path, remote, source, body string) *http.Response {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.RemoteAddr = remote + ":50000"
+		if source != "" {
+			req.Header.Set("X-Source", source)
+		}
+		if strings.HasSuffix(body, "\n") {
+			req.Header.Set("Content-Type", "application/x-ndjson")
+		}
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, req)
+		return w.Result()
+	}
+
+	t.Run("rejected with 429 before authorization", func(t *testing.T) {
+		for i := 0; i < 3; i++ {
+			gt.N(t, send("POST", "/alert/raw/test", "192.0.2.1", "", `{}`).StatusCode).Equal(http.StatusOK)
+		}
+		evaluated = 0
+		resp := send("POST", "/alert/raw/test", "192.0.2.1", "", `{}`)
+		gt.N(t, resp.StatusCode).Equal(http.StatusTooManyRequests)
+		gt.V(t, resp.Header.Get("Retry-After")).Equal("20")
+		gt.N(t, evaluated).Equal(0)
+	})
+
+	t.Run("not limited except /alert/*", func(t *testing.T) {
+		gt.N(t, send("GET", "/health", "192.0.2.1", "", ``).StatusCode).Equal(http.StatusOK)
+	})
+
+	t.Run("rate class by authz policy", func(t *testing.T) {
+		gt.N(t, send("POST", "/alert/raw/test", "192.0.2.2", "restricted", `{}`).StatusCode).Equal(http.StatusOK)
+		gt.N(t, send("POST", "/alert/raw/test", "192.0.2.2", "restricted", `{}`).StatusCode).Equal(http.StatusTooManyRequests)
+	})
+
+	t.Run("batch consumes tokens per event", func(t *testing.T) {
+		gt.N(t, send("POST", "/alert/raw/test?batch=true", "192.0.2.3", "", `[{},{}]`).StatusCode).Equal(http.StatusOK)
+		gt.N(t, send("POST", "/alert/raw/test", "192.0.2.3", "", "{}\n{}\n").StatusCode).Equal(http.StatusTooManyRequests)
+		gt.N(t, send("POST", "/alert/raw/test", "192.0.2.3", "", "{}\n").StatusCode).Equal(http.StatusOK)
+	})
+
+	t.Run("batch larger than burst is rejected with 413", func(t *testing.T) {
+		resp := send("POST", "/alert/raw/test", "192.0.2.4", "", "{}\n{}\n{}\n{}\n")
+		gt.N(t, resp.StatusCode).Equal(http.StatusRequestEntityTooLarge)
+		gt.V(t, resp.Header.Get("Retry-After")).Equal("")
+
+		// Tokens are not consumed
+		gt.N(t, send("POST", "/alert/raw/test", "192.0.2.4", "", "{}\n{}\n{}\n").StatusCode).Equal(http.StatusOK)
+	})
+}
+
+// authzCounter counts evaluation of authz policy.
+type authzCounter struct {
+	metrics.Nop
+	count *int
+}
+
+func (x *authzCounter) PolicyEvaluated(ctx context.Context, pkg string, duration time.Duration, err error) {
+	*x.count++
+}
+
+func TestRateLimitSampling(t *testing.T) {
+	var called int
+	random := 0.0
+	srv := server.New(func(ctx context.Context, schema types.Schema, data any) ([]*model.Alert, error) {
+		called++
+		return nil, nil
+	}, server.WithRateLimiter(ratelimit.New(
+		ratelimit.WithSchema("test", ratelimit.Limit{Rate: 1, Burst: 1}),
+		ratelimit.WithSampling(0.5),
+		ratelimit.WithRandom(func() float64 { return random }),
+	)))
+
+	send := func() int {
+		req := httptest.NewRequest("POST", "/alert/raw/test", strings.NewReader(`{}`))
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, req)
+		return w.Result().StatusCode
+	}
+
+	gt.N(t, send()).Equal(http.StatusOK)
+	random = 0.1
+	gt.N(t, send()).Equal(http.StatusOK)
+	random = 0.9
+	gt.N(t, send()).Equal(http.StatusAccepted)
+	gt.N(t, called).Equal(2)
+
+	// Batch larger than burst is not sampled
+	random = 0.1
+	req := httptest.NewRequest("POST", "/alert/raw/test", strings.NewReader("{}\n{}\n"))
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	gt.N(t, w.Result().StatusCode).Equal(http.StatusRequestEntityTooLarge)
+	gt.N(t, called).Equal(2)
+}
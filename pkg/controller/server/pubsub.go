@@ -0,0 +1,63 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/m-mizutani/goerr/v2"
+	"github.com/secmon-lab/alertchain/pkg/ctxutil"
+	"github.com/secmon-lab/alertchain/pkg/domain/interfaces"
+	"github.com/secmon-lab/alertchain/pkg/domain/model"
+	"github.com/secmon-lab/alertchain/pkg/utils"
+)
+
+// pubsubRunningTimeout is lifetime of the record of message in progress. It is the maximum ack deadline of Pub/Sub, so the message can be processed again after crash of the instance that claimed it.
+const pubsubRunningTimeout = 10 * time.Minute
+
+// pubsubDedup skips Pub/Sub messages that are redelivered after processing. nil means deduplication is disabled.
+type pubsubDedup struct {
+	db  interfaces.Database
+	ttl time.Duration
+	now func() time.Time
+}
+
+func pubsubMessageKey(req *model.PubSubRequest) string {
+	return req.Subscription + "/" + req.Message.MessageID
+}
+
+// claim returns the existing record if the message is already claimed by another delivery.
+func (x *pubsubDedup) claim(ctx context.Context, key string) (*model.PubSubMessageRecord, error) {
+	current, err := x.db.ClaimPubSubMessage(ctx, model.PubSubMessageRecord{
+		Key:       key,
+		Status:    model.PubSubMessageRunning,
+		ExpiresAt: x.now().Add(pubsubRunningTimeout),
+	})
+	if err != nil {
+		return nil, goerr.Wrap(err, "failed to claim pub/sub message", goerr.V("key", key))
+	}
+	return current, nil
+}
+
+// finish records the result of the message. A failed message is released to be processed again by redelivery. ctx should not be cancelled with the request, otherwise the result is lost after the workflow ran.
+func (x *pubsubDedup) finish(ctx context.Context, key string, failed bool) {
+	if failed {
+		if err := x.db.DeletePubSubMessage(ctx, key); err != nil {
+			utils.HandleError(ctx, goerr.Wrap(err, "failed to release pub/sub message", goerr.V("key", key)))
+		}
+		return
+	}
+
+	record := model.PubSubMessageRecord{
+		Key:       key,
+		Status:    model.PubSubMessageCompleted,
+		ExpiresAt: x.now().Add(x.ttl),
+	}
+	if err := x.db.PutPubSubMessage(ctx, record); err != nil {
+		// The running record expires after pubsubRunningTimeout, then a redelivered message is processed again
+		utils.HandleError(ctx, goerr.Wrap(err, "failed to complete pub/sub message", goerr.V("key", key)))
+		return
+	}
+
+	ctxutil.Logger(ctx).Debug("pub/sub message completed", slog.String("key", key))
+}
@@ -0,0 +1,133 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/secmon-lab/alertchain/pkg/ctxutil"
+	"github.com/secmon-lab/alertchain/pkg/infra/policy"
+	"github.com/secmon-lab/alertchain/pkg/logging"
+)
+
+// ReadinessCheck is a dependency checked by /ready endpoint. The endpoint returns 503 if any check fails.
+type ReadinessCheck struct {
+	Name  string
+	Check func(ctx context.Context) error
+	// Interval caches the last result for the duration to avoid calling external services on every request. Zero means no cache.
+	Interval time.Duration
+}
+
+const (
+	readyStatusOK   = "ok"
+	readyStatusFail = "fail"
+
+	readinessTimeout = 5 * time.Second
+)
+
+type readyCheckResult struct {
+	Name      string    `json:"name"`
+	Status    string    `json:"status"`
+	LatencyMS int64     `json:"latency_ms"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+type readyPolicyResult struct {
+	Package  string   `json:"package"`
+	Hash     string   `json:"hash"`
+	Packages []string `json:"packages"`
+}
+
+type readyResponse struct {
+	Status   string               `json:"status"`
+	Checks   []*readyCheckResult  `json:"checks"`
+	Policies []*readyPolicyResult `json:"policies"`
+}
+
+type readinessEntry struct {
+	check ReadinessCheck
+	mutex sync.Mutex
+	last  *readyCheckResult
+}
+
+func (x *readinessEntry) run(ctx context.Context, now time.Time) *readyCheckResult {
+	x.mutex.Lock()
+	defer x.mutex.Unlock()
+
+	if x.last != nil && x.check.Interval > 0 && now.Sub(x.last.CheckedAt) < x.check.Interval {
+		return x.last
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, readinessTimeout)
+	defer cancel()
+
+	result := &readyCheckResult{
+		Name:      x.check.Name,
+		Status:    readyStatusOK,
+		CheckedAt: now,
+	}
+	// Detail of error is only logged because it may have internal information, such as URL and address of dependency
+	if err := x.check.Check(ctx); err != nil {
+		ctxutil.Logger(ctx).Warn("readiness check failed", slog.String("name", x.check.Name), logging.ErrAttr(err))
+		result.Status = readyStatusFail
+	}
+	result.LatencyMS = time.Since(now).Milliseconds()
+
+	x.last = result
+	return result
+}
+
+// handleReady runs all checks concurrently and reports loaded policies. Policies are compiled when the server starts, so they are always reported with hash of the loaded files.
+func handleReady(checks []ReadinessCheck, policies []*policy.Client) http.HandlerFunc {
+	entries := make([]*readinessEntry, len(checks))
+	for i := range checks {
+		entries[i] = &readinessEntry{check: checks[i]}
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		resp := readyResponse{
+			Status:   readyStatusOK,
+			Checks:   make([]*readyCheckResult, len(entries)),
+			Policies: make([]*readyPolicyResult, 0, len(policies)),
+		}
+
+		var wg sync.WaitGroup
+		for i, entry := range entries {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				resp.Checks[i] = entry.run(ctx, time.Now())
+			}()
+		}
+		wg.Wait()
+
+		for _, result := range resp.Checks {
+			if result.Status != readyStatusOK {
+				resp.Status = readyStatusFail
+			}
+		}
+
+		for _, p := range policies {
+			resp.Policies = append(resp.Policies, &readyPolicyResult{
+				Package:  p.Package(),
+				Hash:     p.Hash(),
+				Packages: p.Packages(),
+			})
+		}
+
+		code := http.StatusOK
+		if resp.Status != readyStatusOK {
+			code = http.StatusServiceUnavailable
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			ctxutil.Logger(ctx).Error("failed to encode readiness response", logging.ErrAttr(err))
+		}
+	}
+}
@@ -1,35 +1,76 @@
 package server
 
 import (
+	"bufio"
+	"bytes"
 	"context"
+	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"io"
+	"mime"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"log/slog"
 
 	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/99designs/gqlgen/graphql/handler/extension"
+	"github.com/99designs/gqlgen/graphql/handler/lru"
+	"github.com/99designs/gqlgen/graphql/handler/transport"
 	"github.com/99designs/gqlgen/graphql/playground"
 	"github.com/go-chi/chi/v5"
 	"github.com/m-mizutani/goerr/v2"
+	"github.com/secmon-lab/alertchain/pkg/controller/console"
 	"github.com/secmon-lab/alertchain/pkg/controller/graphql"
 	"github.com/secmon-lab/alertchain/pkg/ctxutil"
 	"github.com/secmon-lab/alertchain/pkg/domain/interfaces"
 	"github.com/secmon-lab/alertchain/pkg/domain/model"
 	"github.com/secmon-lab/alertchain/pkg/domain/types"
+	"github.com/secmon-lab/alertchain/pkg/infra/metrics"
+	"github.com/secmon-lab/alertchain/pkg/infra/oidc"
 	"github.com/secmon-lab/alertchain/pkg/infra/policy"
+	"github.com/secmon-lab/alertchain/pkg/infra/ratelimit"
+	"github.com/secmon-lab/alertchain/pkg/infra/signature"
+	"github.com/secmon-lab/alertchain/pkg/infra/sns"
+	"github.com/secmon-lab/alertchain/pkg/logging"
 	"github.com/secmon-lab/alertchain/pkg/utils"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
 )
 
 type Server struct {
-	mux            *chi.Mux
+	mux            http.Handler
 	authz          *policy.Client
 	env            interfaces.Env
 	resolver       *graphql.Resolver
 	enableGrappiQL bool
+	enableConsole  bool
+	decisionSink   interfaces.DecisionLogSink
+	sns            *sns.Client
+	pubsubVerifier *oidc.Verifier
+	signatureRules []signature.Rule
+	gracePeriod    time.Duration
+	instrument     interfaces.Instrument
+	metrics        http.Handler
+	rateLimiter    *ratelimit.Limiter
+	tlsConfig      *tls.Config
+	readiness      []ReadinessCheck
+	pubsubDedup    *pubsubDedup
+	metaHeaders    []string
+	policies       []*policy.Client
+	maxBodySize    int64
+	maxBatchSize   int
 }
 
+const (
+	defaultMaxBodySize  = 10 * 1024 * 1024
+	defaultMaxBatchSize = 1000
+)
+
 type Option func(cfg *Server)
 
 func WithResolver(resolver *graphql.Resolver) Option {
@@ -44,6 +85,13 @@ func WithEnableGraphiQL() Option {
 	}
 }
 
+// WithEnableConsole serves the web console at /console. The console requires GraphQL enabled by WithResolver.
+func WithEnableConsole() Option {
+	return func(cfg *Server) {
+		cfg.enableConsole = true
+	}
+}
+
 func WithAuthzPolicy(authz *policy.Client) Option {
 	return func(cfg *Server) {
 		cfg.authz = authz
@@ -56,6 +104,111 @@ func WithEnv(env interfaces.Env) Option {
 	}
 }
 
+// WithDecisionLogSink records every evaluation of authz policy into the sink.
+func WithDecisionLogSink(sink interfaces.DecisionLogSink) Option {
+	return func(cfg *Server) {
+		cfg.decisionSink = sink
+	}
+}
+
+// WithSNSClient replaces the client to verify and confirm AWS SNS messages.
+func WithSNSClient(client *sns.Client) Option {
+	return func(cfg *Server) {
+		cfg.sns = client
+	}
+}
+
+// WithPubSubVerifier enables verification of ID token in Pub/Sub push request.
+func WithPubSubVerifier(verifier *oidc.Verifier) Option {
+	return func(cfg *Server) {
+		cfg.pubsubVerifier = verifier
+	}
+}
+
+// WithSignatureRules enables HMAC signature verification of request body for paths of the rules.
+func WithSignatureRules(rules ...signature.Rule) Option {
+	return func(cfg *Server) {
+		cfg.signatureRules = append(cfg.signatureRules, rules...)
+	}
+}
+
+// WithGracePeriod sets duration to wait for in-flight requests on shutdown. Default is 8 seconds.
+func WithGracePeriod(d time.Duration) Option {
+	return func(cfg *Server) {
+		cfg.gracePeriod = d
+	}
+}
+
+// WithInstrument sets a collector of received events and authz denials.
+func WithInstrument(inst interfaces.Instrument) Option {
+	return func(cfg *Server) {
+		cfg.instrument = inst
+	}
+}
+
+// WithMetricsHandler serves the handler at /metrics.
+func WithMetricsHandler(handler http.Handler) Option {
+	return func(cfg *Server) {
+		cfg.metrics = handler
+	}
+}
+
+// WithRateLimiter applies the rate limits to /alert/* requests.
+func WithRateLimiter(limiter *ratelimit.Limiter) Option {
+	return func(cfg *Server) {
+		cfg.rateLimiter = limiter
+	}
+}
+
+// WithTLSConfig serves HTTPS with the config. Client certificate is verified if ClientCAs and ClientAuth are set, and its identity is passed to authz policy.
+func WithTLSConfig(tlsConfig *tls.Config) Option {
+	return func(cfg *Server) {
+		cfg.tlsConfig = tlsConfig
+	}
+}
+
+// WithReadinessCheck adds checks of /ready endpoint, such as reachability of database.
+func WithReadinessCheck(checks ...ReadinessCheck) Option {
+	return func(cfg *Server) {
+		cfg.readiness = append(cfg.readiness, checks...)
+	}
+}
+
+// WithPolicies reports the policies in /ready endpoint with hash of loaded files. Authz policy given by WithAuthzPolicy is reported without this option. Schema label of metrics is also limited to schemas that have alert.<schema> package in the policies, and others are recorded as "unknown".
+func WithPolicies(policies ...*policy.Client) Option {
+	return func(cfg *Server) {
+		cfg.policies = append(cfg.policies, policies...)
+	}
+}
+
+// WithPubSubDeduplication records processed Pub/Sub messages in the database for ttl and skips redelivered ones.
+func WithPubSubDeduplication(db interfaces.Database, ttl time.Duration) Option {
+	return func(cfg *Server) {
+		cfg.pubsubDedup = &pubsubDedup{db: db, ttl: ttl, now: time.Now}
+	}
+}
+
+// WithMetaHeaders selects HTTP headers passed to alert policy as `data.alertchain.meta.headers`.
+func WithMetaHeaders(names ...string) Option {
+	return func(cfg *Server) {
+		cfg.metaHeaders = append(cfg.metaHeaders, names...)
+	}
+}
+
+// WithMaxBodySize limits size of request body in bytes. A larger request is rejected with 413. Default is 10 MiB.
+func WithMaxBodySize(size int64) Option {
+	return func(cfg *Server) {
+		cfg.maxBodySize = size
+	}
+}
+
+// WithMaxBatchSize limits number of events in a batch request. A larger batch is rejected with 413 without handling any event. Default is 1000.
+func WithMaxBatchSize(size int) Option {
+	return func(cfg *Server) {
+		cfg.maxBatchSize = size
+	}
+}
+
 func respondError(ctx context.Context, w http.ResponseWriter, err error) {
 	body := struct {
 		Error string `json:"error"`
@@ -70,6 +223,15 @@ func respondError(ctx context.Context, w http.ResponseWriter, err error) {
 	case goerr.HasTag(err, types.ErrTagBadRequest):
 		code = http.StatusBadRequest
 
+	case goerr.HasTag(err, types.ErrTagUnauthorized):
+		code = http.StatusUnauthorized
+
+	case goerr.HasTag(err, types.ErrTagForbidden):
+		code = http.StatusForbidden
+
+	case goerr.HasTag(err, types.ErrTagTooLarge):
+		code = http.StatusRequestEntityTooLarge
+
 	default:
 		code = http.StatusInternalServerError
 	}
@@ -92,15 +254,42 @@ func getSchema(r *http.Request) (types.Schema, error) {
 
 func New(hdlr interfaces.AlertHandler, options ...Option) *Server {
 	s := &Server{
-		env: utils.Env,
+		env:          utils.Env,
+		sns:          sns.New(),
+		gracePeriod:  8 * time.Second,
+		instrument:   metrics.Nop{},
+		maxBodySize:  defaultMaxBodySize,
+		maxBatchSize: defaultMaxBatchSize,
 	}
 	for _, opt := range options {
 		opt(s)
 	}
+	var packages []string
+	for _, p := range s.policies {
+		packages = append(packages, p.Packages()...)
+	}
+	s.instrument = metrics.WithKnownSchemas(s.instrument, packages)
+
+	wrap := func(endpoint string, handler apiAlertHandler) http.HandlerFunc {
+		route := func(ctx context.Context, schema types.Schema, data any) ([]*model.Alert, error) {
+			s.instrument.EventReceived(ctx, endpoint, schema)
+			return hdlr(ctx, schema, data)
+		}
 
-	wrap := func(handler apiAlertHandler) http.HandlerFunc {
 		return func(w http.ResponseWriter, r *http.Request) {
-			ctx := r.Context()
+			ctx := ctxutil.InjectEventMeta(r.Context(), newEventMeta(r, endpoint, s.metaHeaders))
+
+			dryRun, err := isDryRunRequest(r)
+			if err != nil {
+				respondError(ctx, w, err)
+				return
+			}
+			var timeline *model.Timeline
+			if dryRun {
+				timeline = &model.Timeline{}
+				ctx = ctxutil.InjectTimeline(ctxutil.SetDryRun(ctx, true), timeline)
+			}
+			r = r.WithContext(ctx)
 
 			defer func() {
 				if err := recover(); err != nil {
@@ -108,16 +297,25 @@ func New(hdlr interfaces.AlertHandler, options ...Option) *Server {
 				}
 			}()
 
-			resp, err := handler(r, hdlr)
+			resp, err := handler(r, route)
 			if err != nil {
 				respondError(ctx, w, err)
 				return
 			}
 
 			body := struct {
-				Alerts []*model.Alert `json:"alerts"`
+				Alerts  []*model.Alert    `json:"alerts"`
+				Results []*apiEventResult `json:"results,omitempty"`
+				DryRun  bool              `json:"dry_run,omitempty"`
+				// Timeline is planned actions in dry-run mode. Arguments with "secret_" prefix are redacted.
+				Timeline any `json:"timeline,omitempty"`
 			}{
-				Alerts: resp.Alerts,
+				Alerts:  resp.Alerts,
+				Results: resp.Results,
+				DryRun:  dryRun,
+			}
+			if timeline != nil {
+				body.Timeline = logging.Redact(timeline.Entries())
 			}
 
 			w.WriteHeader(resp.Code)
@@ -130,7 +328,12 @@ func New(hdlr interfaces.AlertHandler, options ...Option) *Server {
 
 	r := chi.NewRouter()
 	r.Use(Logging)
-	r.Use(Authorize(s.authz, s.env))
+	r.Use(LimitBody(s.maxBodySize))
+	r.Use(RateLimit(s.rateLimiter, s.instrument))
+	r.Use(VerifyPubSubToken(s.pubsubVerifier))
+	r.Use(VerifySignature(s.signatureRules))
+	r.Use(Authorize(s.authz, s.env, s.decisionSink, s.instrument))
+	r.Use(RateLimitClass(s.rateLimiter, s.instrument))
 	r.Route("/health", func(r chi.Router) {
 		r.Get("/", func(w http.ResponseWriter, r *http.Request) {
 			w.WriteHeader(http.StatusOK)
@@ -138,23 +341,59 @@ func New(hdlr interfaces.AlertHandler, options ...Option) *Server {
 		})
 	})
 
+	policies := s.policies
+	if s.authz != nil {
+		policies = append(policies, s.authz)
+	}
+	r.Get("/ready", handleReady(s.readiness, policies))
+
+	if s.metrics != nil {
+		r.Handle("/metrics", s.metrics)
+	}
+
 	r.Route("/alert", func(r chi.Router) {
-		r.Post("/raw/{schema}", wrap(handleRawAlert))
-		r.Post("/pubsub/{schema}", wrap(handlePubSubAlert))
+		r.Post("/raw/{schema}", wrap("raw", handleRawAlert(s.maxBatchSize)))
+		r.Post("/pubsub/{schema}", wrap("pubsub", handlePubSubAlert(s.pubsubDedup)))
+		r.Post("/sns/{schema}", wrap("sns", handleSNSAlert(s.sns)))
 	})
 
 	if s.resolver != nil {
-		gql := handler.NewDefaultServer(graphql.NewExecutableSchema(graphql.Config{
+		gql := handler.New(graphql.NewExecutableSchema(graphql.Config{
 			Resolvers: s.resolver,
 		}))
+		// Websocket is for workflowUpdated subscription. Operations over it are authorized by the same aroundOperations as HTTP
+		gql.AddTransport(transport.Websocket{
+			KeepAlivePingInterval: 10 * time.Second,
+		})
+		gql.AddTransport(transport.Options{})
+		gql.AddTransport(transport.GET{})
+		gql.AddTransport(transport.POST{})
+		gql.SetQueryCache(lru.New(1000))
+		gql.Use(extension.Introspection{})
+		gql.Use(extension.AutomaticPersistedQuery{
+			Cache: lru.New(100),
+		})
+		// Installed even without authz policy to deny mutation
+		gqlAuthz := &graphqlAuthz{authz: s.authz, getEnv: s.env, sink: s.decisionSink, inst: s.instrument}
+		gql.AroundOperations(gqlAuthz.aroundOperations)
+		gql.AroundFields(gqlAuthz.aroundFields)
 		r.Handle("/graphql", gql)
 
 		if s.enableGrappiQL {
 			r.Handle("/graphiql", playground.Handler("playground", "/graphql"))
 		}
+		if s.enableConsole {
+			r.Get("/console", http.RedirectHandler("/console/", http.StatusMovedPermanently).ServeHTTP)
+			r.Get("/console/*", console.Handler("/console").ServeHTTP)
+		}
 	}
 
-	s.mux = r
+	// Incoming trace context in HTTP headers is continued by the handler
+	s.mux = otelhttp.NewHandler(r, "alertchain",
+		otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+			return r.Method + " " + r.URL.Path
+		}),
+	)
 
 	return s
 }
@@ -162,76 +401,382 @@ func New(hdlr interfaces.AlertHandler, options ...Option) *Server {
 type apiAlertResponse struct {
 	Code   int
 	Alerts []*model.Alert
+
+	// Results is set only for batch request
+	Results []*apiEventResult
+}
+
+// apiEventResult is a result of each event in batch request. Index is 0-based position of the event in the batch.
+type apiEventResult struct {
+	Index  int            `json:"index"`
+	Alerts []*model.Alert `json:"alerts"`
+	Error  string         `json:"error,omitempty"`
 }
 
 type apiAlertHandler func(r *http.Request, route interfaces.AlertHandler) (*apiAlertResponse, error)
 
-func handleRawAlert(r *http.Request, route interfaces.AlertHandler) (*apiAlertResponse, error) {
-	var data any
-	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
-		return nil, goerr.Wrap(err, "failed to decode request body")
+func handleRawAlert(maxBatchSize int) apiAlertHandler {
+	return func(r *http.Request, route interfaces.AlertHandler) (*apiAlertResponse, error) {
+		schema, err := getSchema(r)
+		if err != nil {
+			return nil, err
+		}
+
+		if isNDJSON(r) {
+			entries, err := decodeNDJSON(r.Body, maxBatchSize)
+			if err != nil {
+				return nil, err
+			}
+			return handleBatch(r, route, schema, entries)
+		}
+
+		var data any
+		if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+			return nil, wrapBodyError(err, "failed to decode request body")
+		}
+
+		if r.URL.Query().Get("batch") == "true" {
+			events, ok := data.([]any)
+			if !ok {
+				return nil, goerr.New("batch request must be JSON array", goerr.T(types.ErrTagBadRequest))
+			}
+			if len(events) > maxBatchSize {
+				return nil, goerr.New("too many events in batch", goerr.V("events", len(events)), goerr.V("max", maxBatchSize), goerr.T(types.ErrTagTooLarge))
+			}
+
+			entries := make([]batchEntry, len(events))
+			for i, ev := range events {
+				entries[i] = batchEntry{data: ev}
+			}
+			return handleBatch(r, route, schema, entries)
+		}
+
+		ctx := r.Context()
+		alerts, err := route(ctx, schema, data)
+		if err != nil {
+			return nil, err
+		}
+
+		return &apiAlertResponse{
+			Code:   http.StatusOK,
+			Alerts: alerts,
+		}, nil
 	}
+}
+
+// wrapBodyError tags error of reading request body. Exceeding the limit of LimitBody is reported as 413, and others as 400.
+func wrapBodyError(err error, msg string) error {
+	var maxErr *http.MaxBytesError
+	if errors.As(err, &maxErr) {
+		return goerr.Wrap(err, msg, goerr.V("limit", maxErr.Limit), goerr.T(types.ErrTagTooLarge))
+	}
+	return goerr.Wrap(err, msg, goerr.T(types.ErrTagBadRequest))
+}
 
-	schema, err := getSchema(r)
+func isNDJSON(r *http.Request) bool {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
 	if err != nil {
-		return nil, err
+		return false
 	}
+	return mediaType == "application/x-ndjson"
+}
 
+// batchEntry is an event in batch request. err is set if the event can not be decoded.
+type batchEntry struct {
+	data any
+	err  error
+}
+
+// decodeNDJSON decodes each non-empty line as an event. A broken line does not stop decoding following lines. All lines are decoded before handling events, and it fails if body can not be read or the number of events exceeds maxEntries.
+func decodeNDJSON(r io.Reader, maxEntries int) ([]batchEntry, error) {
+	var entries []batchEntry
+	reader := bufio.NewReader(r)
+	for lineNo := 1; ; lineNo++ {
+		line, readErr := reader.ReadBytes('\n')
+		if readErr != nil && readErr != io.EOF {
+			return nil, wrapBodyError(readErr, "failed to read NDJSON line")
+		}
+
+		if line = bytes.TrimSpace(line); len(line) > 0 {
+			if len(entries) >= maxEntries {
+				return nil, goerr.New("too many events in batch", goerr.V("max", maxEntries), goerr.T(types.ErrTagTooLarge))
+			}
+
+			var entry batchEntry
+			if err := json.Unmarshal(line, &entry.data); err != nil {
+				entry.err = goerr.Wrap(err, "failed to decode NDJSON line", goerr.V("line", lineNo), goerr.T(types.ErrTagBadRequest))
+			}
+			entries = append(entries, entry)
+		}
+
+		if readErr == io.EOF {
+			break
+		}
+	}
+
+	return entries, nil
+}
+
+// handleBatch processes each event independently. The request succeeds even if some of events fail, and the errors are reported in the results.
+func handleBatch(r *http.Request, route interfaces.AlertHandler, schema types.Schema, entries []batchEntry) (*apiAlertResponse, error) {
 	ctx := r.Context()
-	alerts, err := route(ctx, schema, data)
-	if err != nil {
-		return nil, err
+	resp := &apiAlertResponse{
+		Code:    http.StatusOK,
+		Results: make([]*apiEventResult, len(entries)),
+	}
+
+	for i, entry := range entries {
+		result := &apiEventResult{Index: i}
+		resp.Results[i] = result
+
+		if entry.err != nil {
+			result.Error = entry.err.Error()
+			continue
+		}
+
+		alerts, err := route(ctx, schema, entry.data)
+		if err != nil {
+			utils.HandleError(ctx, goerr.Wrap(err, "failed to handle event in batch", goerr.V("index", i)))
+			result.Error = err.Error()
+			continue
+		}
+
+		result.Alerts = alerts
+		resp.Alerts = append(resp.Alerts, alerts...)
 	}
 
-	return &apiAlertResponse{
-		Code:   http.StatusOK,
-		Alerts: alerts,
-	}, nil
+	return resp, nil
 }
 
-func handlePubSubAlert(r *http.Request, route interfaces.AlertHandler) (*apiAlertResponse, error) {
-	schema, err := getSchema(r)
-	if err != nil {
-		return nil, err
+func handlePubSubAlert(dedup *pubsubDedup) apiAlertHandler {
+	return func(r *http.Request, route interfaces.AlertHandler) (*apiAlertResponse, error) {
+		schema, err := getSchema(r)
+		if err != nil {
+			return nil, err
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			return nil, wrapBodyError(err, "reading pub/sub message")
+		}
+		ctxutil.Logger(r.Context()).Debug("recv pubsub message", slog.String("body", string(body)))
+
+		var req model.PubSubRequest
+		if err := json.Unmarshal(body, &req); err != nil {
+			return nil, goerr.Wrap(err, "parsing pub/sub message", goerr.V("body", string(body)))
+		}
+
+		var data any
+		if err := json.Unmarshal(req.Message.Data, &data); err != nil {
+			return nil, goerr.Wrap(err, "parsing pub/sub data field", goerr.V("data", string(req.Message.Data)))
+		}
+
+		ctx := extractPubSubTraceContext(r.Context(), req.Message.Attributes)
+		if meta := ctxutil.GetEventMeta(ctx); meta != nil {
+			meta.MessageID = req.Message.MessageID
+			meta.Subscription = req.Subscription
+			meta.Attributes = req.Message.Attributes
+			meta.PublishTime = req.Message.PublishTime
+		}
+
+		var key string
+		// Message in dry-run mode is not recorded because it is expected to be delivered again for actual processing
+		if dedup != nil && req.Message.MessageID != "" && !ctxutil.IsDryRun(ctx) {
+			key = pubsubMessageKey(&req)
+			current, err := dedup.claim(ctx, key)
+			if err != nil {
+				return nil, err
+			}
+
+			if current != nil {
+				ctxutil.Logger(ctx).Info("skip redelivered pub/sub message",
+					slog.String("message_id", req.Message.MessageID),
+					slog.String("subscription", req.Subscription),
+					slog.Int64("delivery_attempt", req.DeliveryAttempt),
+					slog.String("status", current.Status),
+				)
+
+				// Pub/Sub redelivers the message later if the response is not 2xx
+				if current.Status == model.PubSubMessageRunning {
+					return &apiAlertResponse{Code: http.StatusConflict}, nil
+				}
+				return &apiAlertResponse{Code: http.StatusOK}, nil
+			}
+		}
+
+		alerts, err := route(ctx, schema, data)
+		if key != "" {
+			// Record the result even if the request is cancelled by client or shutdown after the workflow ran
+			dedup.finish(context.WithoutCancel(ctx), key, err != nil)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		return &apiAlertResponse{
+			Code:   http.StatusOK,
+			Alerts: alerts,
+		}, nil
 	}
+}
 
-	body, err := io.ReadAll(r.Body)
+// isDryRunRequest returns true if the request has `dry_run=true` query parameter. Dry-run must be allowed by allow_dry_run of authz.http policy.
+func isDryRunRequest(r *http.Request) (bool, error) {
+	v := r.URL.Query().Get("dry_run")
+	if v == "" {
+		return false, nil
+	}
+
+	dryRun, err := strconv.ParseBool(v)
 	if err != nil {
-		return nil, goerr.Wrap(err, "reading pub/sub message", goerr.V("body", string(body)))
+		return false, goerr.Wrap(err, "invalid dry_run parameter", goerr.V("dry_run", v), goerr.T(types.ErrTagBadRequest))
+	}
+	if !dryRun {
+		return false, nil
 	}
-	ctxutil.Logger(r.Context()).Debug("recv pubsub message", slog.String("body", string(body)))
 
-	var req model.PubSubRequest
-	if err := json.Unmarshal(body, &req); err != nil {
-		return nil, goerr.Wrap(err, "parsing pub/sub message", goerr.V("body", string(body)))
+	if allowed, _ := r.Context().Value(ctxAllowDryRunKey{}).(bool); !allowed {
+		return false, goerr.New("dry-run is not allowed by authz policy", goerr.T(types.ErrTagForbidden))
 	}
+	return true, nil
+}
 
-	var data any
-	if err := json.Unmarshal(req.Message.Data, &data); err != nil {
-		return nil, goerr.Wrap(err, "parsing pub/sub data field", goerr.V("data", string(req.Message.Data)))
+// newEventMeta creates metadata of the request. Pub/Sub and SNS handlers fill fields of the message envelope.
+func newEventMeta(r *http.Request, transport string, headers []string) *model.EventMeta {
+	meta := &model.EventMeta{
+		Transport:  transport,
+		ReceivedAt: time.Now().UTC(),
 	}
 
-	ctx := r.Context()
-	alerts, err := route(ctx, schema, data)
-	if err != nil {
-		return nil, err
+	for _, name := range headers {
+		if v := r.Header.Get(name); v != "" {
+			if meta.Headers == nil {
+				meta.Headers = make(map[string]string)
+			}
+			meta.Headers[http.CanonicalHeaderKey(name)] = v
+		}
 	}
 
-	return &apiAlertResponse{
-		Code:   http.StatusOK,
-		Alerts: alerts,
-	}, nil
+	return meta
+}
+
+// extractPubSubTraceContext continues trace of the publisher if the message has trace context in attributes. Google Cloud client libraries set it with "googclient_" prefix.
+func extractPubSubTraceContext(ctx context.Context, attrs map[string]string) context.Context {
+	carrier := propagation.MapCarrier{}
+	for k, v := range attrs {
+		carrier[strings.TrimPrefix(k, "googclient_")] = v
+	}
+	return otel.GetTextMapPropagator().Extract(ctx, carrier)
 }
 
-func (x *Server) Run(addr string) error {
+func handleSNSAlert(client *sns.Client) apiAlertHandler {
+	return func(r *http.Request, route interfaces.AlertHandler) (*apiAlertResponse, error) {
+		schema, err := getSchema(r)
+		if err != nil {
+			return nil, err
+		}
+
+		ctx := r.Context()
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			return nil, wrapBodyError(err, "reading SNS message")
+		}
+		ctxutil.Logger(ctx).Debug("recv SNS message", slog.String("body", string(body)))
+
+		var msg model.SNSMessage
+		if err := json.Unmarshal(body, &msg); err != nil {
+			return nil, goerr.Wrap(err, "parsing SNS message", goerr.V("body", string(body)), goerr.T(types.ErrTagBadRequest))
+		}
+
+		// Check topic before verification not to fetch certificate for messages of unknown topics
+		if err := client.CheckTopic(&msg); err != nil {
+			return nil, err
+		}
+		if err := client.Verify(ctx, &msg); err != nil {
+			return nil, err
+		}
+
+		switch msg.Type {
+		case model.SNSTypeSubscriptionConfirmation:
+			if err := client.Confirm(ctx, &msg); err != nil {
+				return nil, err
+			}
+			ctxutil.Logger(ctx).Info("confirmed SNS subscription", slog.String("topic_arn", msg.TopicArn))
+			return &apiAlertResponse{Code: http.StatusOK}, nil
+
+		case model.SNSTypeUnsubscribeConfirmation:
+			ctxutil.Logger(ctx).Info("SNS subscription is unsubscribed", slog.String("topic_arn", msg.TopicArn))
+			return &apiAlertResponse{Code: http.StatusOK}, nil
+
+		case model.SNSTypeNotification:
+			// Message is often JSON encoded event data (e.g. EventBridge event), but it can be plain text
+			var data any = msg.Message
+			if json.Valid([]byte(msg.Message)) {
+				if err := json.Unmarshal([]byte(msg.Message), &data); err != nil {
+					return nil, goerr.Wrap(err, "parsing SNS Message field", goerr.V("message", msg.Message))
+				}
+			}
+
+			if meta := ctxutil.GetEventMeta(ctx); meta != nil {
+				meta.MessageID = msg.MessageID
+				meta.Topic = msg.TopicArn
+				meta.PublishTime = msg.Timestamp
+			}
+
+			alerts, err := route(ctx, schema, data)
+			if err != nil {
+				return nil, err
+			}
+
+			return &apiAlertResponse{
+				Code:   http.StatusOK,
+				Alerts: alerts,
+			}, nil
+
+		default:
+			return nil, goerr.New("unsupported SNS message type", goerr.V("type", msg.Type), goerr.T(types.ErrTagBadRequest))
+		}
+	}
+}
+
+// Run starts HTTP server and blocks until ctx is canceled. Then it stops accepting new requests and waits for in-flight requests up to the grace period.
+func (x *Server) Run(ctx context.Context, addr string) error {
 	server := &http.Server{
 		Addr:              addr,
 		ReadHeaderTimeout: 3 * time.Second,
 		Handler:           x.mux,
 	}
 
-	if err := server.ListenAndServe(); err != nil {
-		return goerr.Wrap(err, "failed to listen")
+	listen := server.ListenAndServe
+	if x.tlsConfig != nil {
+		server.TLSConfig = x.tlsConfig
+		// Certificates are already loaded into TLSConfig
+		listen = func() error { return server.ListenAndServeTLS("", "") }
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		if err := listen(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			errCh <- goerr.Wrap(err, "failed to listen")
+		}
+		close(errCh)
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+	}
+
+	ctxutil.Logger(ctx).Info("shutting down server", slog.Duration("grace_period", x.gracePeriod))
+	shutdownCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), x.gracePeriod)
+	defer cancel()
+
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		if !errors.Is(err, context.DeadlineExceeded) {
+			return goerr.Wrap(err, "failed to shutdown server")
+		}
+		ctxutil.Logger(ctx).Warn("grace period expired before in-flight requests finished")
 	}
 
 	return nil
@@ -3,23 +3,53 @@ package server_test
 import (
 	"bytes"
 	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
 	_ "embed"
+	"encoding/base64"
 	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"io"
+	"math/big"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"sort"
 	"strings"
+	"time"
 
 	"testing"
 
+	"github.com/gorilla/websocket"
+	"github.com/m-mizutani/gots/ptr"
 	"github.com/m-mizutani/gt"
 	"github.com/secmon-lab/alertchain/pkg/chain"
 	"github.com/secmon-lab/alertchain/pkg/controller/graphql"
 	"github.com/secmon-lab/alertchain/pkg/controller/server"
+	"github.com/secmon-lab/alertchain/pkg/ctxutil"
+	"github.com/secmon-lab/alertchain/pkg/domain/interfaces"
 	"github.com/secmon-lab/alertchain/pkg/domain/model"
 	"github.com/secmon-lab/alertchain/pkg/domain/types"
+	"github.com/secmon-lab/alertchain/pkg/infra/broker"
 	"github.com/secmon-lab/alertchain/pkg/infra/memory"
+	"github.com/secmon-lab/alertchain/pkg/infra/metrics"
 	"github.com/secmon-lab/alertchain/pkg/infra/policy"
+	"github.com/secmon-lab/alertchain/pkg/infra/sns"
+	"github.com/secmon-lab/alertchain/pkg/mock"
 	"github.com/secmon-lab/alertchain/pkg/service"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
 )
 
 //go:embed testdata/scc.json
@@ -51,24 +81,544 @@ func TestPubSub(t *testing.T) {
 		alert := gt.Cast[map[string]any](t, data)
 		name := gt.Cast[string](t, alert["color"])
 		gt.V(t, name).Equal("blue")
+
+		meta := ctxutil.GetEventMeta(ctx)
+		gt.V(t, meta.Transport).Equal(model.TransportPubSub)
+		gt.V(t, meta.Subscription).Equal("projects/test/subscriptions/alert")
+		gt.V(t, meta.Attributes["env"]).Equal("prod")
+		gt.V(t, meta.PublishTime).Equal("2024-01-01T00:00:00Z")
+		gt.V(t, meta.Headers).Equal(map[string]string{"User-Agent": "APIs-Google"})
 		return nil, nil
-	})
+	}, server.WithMetaHeaders("user-agent"))
 
 	req := model.PubSubRequest{
+		Subscription: "projects/test/subscriptions/alert",
 		Message: model.PubSubMessage{
-			Data: []byte(`{"color":"blue"}`),
+			Data:        []byte(`{"color":"blue"}`),
+			Attributes:  map[string]string{"env": "prod"},
+			PublishTime: "2024-01-01T00:00:00Z",
 		},
 	}
 
 	body := gt.R1(json.Marshal(req)).NoError(t)
 
 	httpReq := httptest.NewRequest("POST", "/alert/pubsub/scc", bytes.NewReader(body))
+	httpReq.Header.Set("User-Agent", "APIs-Google")
+	httpReq.Header.Set("Authorization", "Bearer secret")
 	w := httptest.NewRecorder()
 	srv.ServeHTTP(w, httpReq)
 	gt.N(t, w.Result().StatusCode).Equal(http.StatusOK)
 	gt.N(t, called).Equal(1)
 }
 
+func TestPubSubDeduplication(t *testing.T) {
+	db := memory.New()
+	var called int
+	srv := server.New(func(ctx context.Context, schema types.Schema, data any) ([]*model.Alert, error) {
+		called++
+		if gt.Cast[map[string]any](t, data)["fail"] == true {
+			return nil, errors.New("failed")
+		}
+		return nil, nil
+	}, server.WithPubSubDeduplication(db, time.Hour))
+
+	send := func(messageID, data string) int {
+		body := gt.R1(json.Marshal(model.PubSubRequest{
+			Subscription: "projects/test/subscriptions/alert",
+			Message: model.PubSubMessage{
+				MessageID: messageID,
+				Data:      []byte(data),
+			},
+		})).NoError(t)
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, httptest.NewRequest("POST", "/alert/pubsub/scc", bytes.NewReader(body)))
+		return w.Result().StatusCode
+	}
+
+	t.Run("redelivered message is skipped", func(t *testing.T) {
+		called = 0
+		gt.N(t, send("m1", `{"color":"blue"}`)).Equal(http.StatusOK)
+		gt.N(t, send("m1", `{"color":"blue"}`)).Equal(http.StatusOK)
+		gt.N(t, called).Equal(1)
+		gt.N(t, send("m2", `{"color":"blue"}`)).Equal(http.StatusOK)
+		gt.N(t, called).Equal(2)
+	})
+
+	t.Run("message in progress is retried later", func(t *testing.T) {
+		called = 0
+		gt.R1(db.ClaimPubSubMessage(context.Background(), model.PubSubMessageRecord{
+			Key:       "projects/test/subscriptions/alert/m3",
+			Status:    model.PubSubMessageRunning,
+			ExpiresAt: time.Now().Add(time.Minute),
+		})).NoError(t)
+		gt.N(t, send("m3", `{"color":"blue"}`)).Equal(http.StatusConflict)
+		gt.N(t, called).Equal(0)
+	})
+
+	t.Run("failed message is processed again", func(t *testing.T) {
+		called = 0
+		gt.N(t, send("m4", `{"fail":true}`)).Equal(http.StatusInternalServerError)
+		gt.N(t, send("m4", `{"fail":true}`)).Equal(http.StatusInternalServerError)
+		gt.N(t, called).Equal(2)
+	})
+}
+
+// ctxCheckDB fails writing Pub/Sub message records with cancelled context like actual databases
+type ctxCheckDB struct {
+	*memory.Client
+}
+
+func (x *ctxCheckDB) PutPubSubMessage(ctx context.Context, record model.PubSubMessageRecord) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return x.Client.PutPubSubMessage(ctx, record)
+}
+
+func (x *ctxCheckDB) DeletePubSubMessage(ctx context.Context, key string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return x.Client.DeletePubSubMessage(ctx, key)
+}
+
+func TestPubSubDeduplicationCancelled(t *testing.T) {
+	db := &ctxCheckDB{Client: memory.New()}
+	var called int
+	var cancel context.CancelFunc
+	srv := server.New(func(ctx context.Context, schema types.Schema, data any) ([]*model.Alert, error) {
+		called++
+		// Request is cancelled while handling the message
+		cancel()
+		if gt.Cast[map[string]any](t, data)["fail"] == true {
+			return nil, errors.New("failed")
+		}
+		return nil, nil
+	}, server.WithPubSubDeduplication(db, time.Hour))
+
+	send := func(messageID, data string) int {
+		body := gt.R1(json.Marshal(model.PubSubRequest{
+			Subscription: "projects/test/subscriptions/alert",
+			Message: model.PubSubMessage{
+				MessageID: messageID,
+				Data:      []byte(data),
+			},
+		})).NoError(t)
+		var ctx context.Context
+		ctx, cancel = context.WithCancel(context.Background())
+		defer cancel()
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, httptest.NewRequest("POST", "/alert/pubsub/scc", bytes.NewReader(body)).WithContext(ctx))
+		return w.Result().StatusCode
+	}
+
+	t.Run("completed message is recorded", func(t *testing.T) {
+		called = 0
+		send("m1", `{"color":"blue"}`)
+		gt.N(t, called).Equal(1)
+
+		// Redelivered message must be skipped without running the workflow again
+		gt.N(t, send("m1", `{"color":"blue"}`)).Equal(http.StatusOK)
+		gt.N(t, called).Equal(1)
+	})
+
+	t.Run("failed message is released", func(t *testing.T) {
+		called = 0
+		send("m2", `{"fail":true}`)
+		gt.N(t, called).Equal(1)
+
+		// Released message must be processed again instead of 409 until the running record expires
+		send("m2", `{"fail":true}`)
+		gt.N(t, called).Equal(2)
+	})
+}
+
+func TestBatch(t *testing.T) {
+	var received []string
+	srv := server.New(func(ctx context.Context, schema types.Schema, data any) ([]*model.Alert, error) {
+		gt.V(t, schema).Equal("my_log")
+		event := gt.Cast[map[string]any](t, data)
+		color := gt.Cast[string](t, event["color"])
+		received = append(received, color)
+		if color == "red" {
+			return nil, errors.New("red is not allowed")
+		}
+
+		alert := model.NewAlert(model.AlertMetaData{Title: color}, schema, data)
+		return []*model.Alert{&alert}, nil
+	})
+
+	type result struct {
+		Index  int            `json:"index"`
+		Alerts []*model.Alert `json:"alerts"`
+		Error  string         `json:"error"`
+	}
+	var output struct {
+		Alerts  []*model.Alert `json:"alerts"`
+		Results []result       `json:"results"`
+	}
+
+	t.Run("NDJSON", func(t *testing.T) {
+		received = nil
+		body := `{"color":"blue"}
+{"color":
+
+{"color":"red"}
+{"color":"green"}
+`
+		req := httptest.NewRequest("POST", "/alert/raw/my_log", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/x-ndjson; charset=utf-8")
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, req)
+		gt.N(t, w.Result().StatusCode).Equal(http.StatusOK)
+		gt.NoError(t, json.Unmarshal(w.Body.Bytes(), &output))
+
+		gt.A(t, received).Equal([]string{"blue", "red", "green"})
+		gt.A(t, output.Alerts).Length(2)
+		gt.A(t, output.Results).Length(4).
+			At(0, func(t testing.TB, v result) {
+				gt.A(t, v.Alerts).Length(1)
+				gt.V(t, v.Error).Equal("")
+			}).
+			At(1, func(t testing.TB, v result) {
+				gt.V(t, v.Index).Equal(1)
+				gt.S(t, v.Error).Contains("failed to decode NDJSON line")
+			}).
+			At(2, func(t testing.TB, v result) {
+				gt.S(t, v.Error).Contains("red is not allowed")
+			}).
+			At(3, func(t testing.TB, v result) {
+				gt.A(t, v.Alerts).Length(1)
+			})
+	})
+
+	t.Run("JSON array with batch", func(t *testing.T) {
+		received = nil
+		req := httptest.NewRequest("POST", "/alert/raw/my_log?batch=true", strings.NewReader(`[{"color":"blue"},{"color":"red"}]`))
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, req)
+		gt.N(t, w.Result().StatusCode).Equal(http.StatusOK)
+		gt.NoError(t, json.Unmarshal(w.Body.Bytes(), &output))
+
+		gt.A(t, received).Equal([]string{"blue", "red"})
+		gt.A(t, output.Results).Length(2).
+			At(1, func(t testing.TB, v result) {
+				gt.V(t, v.Index).Equal(1)
+				gt.S(t, v.Error).Contains("red is not allowed")
+			})
+	})
+
+	t.Run("batch requires JSON array", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/alert/raw/my_log?batch=true", strings.NewReader(`{"color":"blue"}`))
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, req)
+		gt.N(t, w.Result().StatusCode).Equal(http.StatusBadRequest)
+	})
+}
+
+func TestRequestLimit(t *testing.T) {
+	var called int
+	srv := server.New(func(ctx context.Context, schema types.Schema, data any) ([]*model.Alert, error) {
+		called++
+		return nil, nil
+	}, server.WithMaxBodySize(64), server.WithMaxBatchSize(2))
+
+	testCases := map[string]struct {
+		url         string
+		contentType string
+		body        string
+		code        int
+		called      int
+	}{
+		"body within limit": {
+			url:    "/alert/raw/my_log",
+			body:   `{"color":"blue"}`,
+			code:   http.StatusOK,
+			called: 1,
+		},
+		"too large body": {
+			url:  "/alert/raw/my_log",
+			body: `{"color":"` + strings.Repeat("b", 64) + `"}`,
+			code: http.StatusRequestEntityTooLarge,
+		},
+		"too large body of pub/sub": {
+			url:  "/alert/pubsub/my_log",
+			body: `{"message":{"data":"` + strings.Repeat("b", 64) + `"}}`,
+			code: http.StatusRequestEntityTooLarge,
+		},
+		"NDJSON within limit": {
+			url:         "/alert/raw/my_log",
+			contentType: "application/x-ndjson",
+			body:        "{\"n\":1}\n{\"n\":2}\n",
+			code:        http.StatusOK,
+			called:      2,
+		},
+		"too many events in NDJSON": {
+			url:         "/alert/raw/my_log",
+			contentType: "application/x-ndjson",
+			body:        "{\"n\":1}\n{\"n\":2}\n{\"n\":3}\n",
+			code:        http.StatusRequestEntityTooLarge,
+		},
+		"too many events in JSON array": {
+			url:  "/alert/raw/my_log?batch=true",
+			body: `[{"n":1},{"n":2},{"n":3}]`,
+			code: http.StatusRequestEntityTooLarge,
+		},
+	}
+
+	for title, tc := range testCases {
+		t.Run(title, func(t *testing.T) {
+			called = 0
+			req := httptest.NewRequest("POST", tc.url, strings.NewReader(tc.body))
+			if tc.contentType != "" {
+				req.Header.Set("Content-Type", tc.contentType)
+			}
+			w := httptest.NewRecorder()
+			srv.ServeHTTP(w, req)
+			gt.N(t, w.Result().StatusCode).Equal(tc.code)
+			gt.N(t, called).Equal(tc.called)
+		})
+	}
+}
+
+func TestSNS(t *testing.T) {
+	key := gt.R1(rsa.GenerateKey(rand.Reader, 2048)).NoError(t)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "sns.amazonaws.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der := gt.R1(x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)).NoError(t)
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+
+	var confirmed int
+	subscribeSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		confirmed++
+	}))
+	defer subscribeSrv.Close()
+
+	snsClient := sns.New(
+		sns.WithCertFetcher(func(ctx context.Context, certURL string) ([]byte, error) {
+			return certPEM, nil
+		}),
+		sns.WithHTTPClient(subscribeSrv.Client()),
+		sns.WithTopicARN("arn:aws:sns:us-east-1:123456789012:guardduty"),
+	)
+
+	var called int
+	srv := server.New(func(ctx context.Context, schema types.Schema, data any) ([]*model.Alert, error) {
+		called++
+		gt.V(t, schema).Equal("guardduty")
+		event := gt.Cast[map[string]any](t, data)
+		gt.V(t, event["detail-type"]).Equal("GuardDuty Finding")
+		return nil, nil
+	}, server.WithSNSClient(snsClient))
+
+	send := func(t *testing.T, msg model.SNSMessage) *http.Response {
+		msg.SignatureVersion = "2"
+		msg.SigningCertURL = "https://sns.us-east-1.amazonaws.com/SimpleNotificationService-test.pem"
+		digest := sha256.Sum256(gt.R1(sns.StringToSign(&msg)).NoError(t))
+		sig := gt.R1(rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])).NoError(t)
+		msg.Signature = base64.StdEncoding.EncodeToString(sig)
+
+		body := gt.R1(json.Marshal(msg)).NoError(t)
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, httptest.NewRequest("POST", "/alert/sns/guardduty", bytes.NewReader(body)))
+		return w.Result()
+	}
+
+	t.Run("confirm subscription", func(t *testing.T) {
+		resp := send(t, model.SNSMessage{
+			Type:         model.SNSTypeSubscriptionConfirmation,
+			MessageID:    "msg-1",
+			Token:        "token",
+			TopicArn:     "arn:aws:sns:us-east-1:123456789012:guardduty",
+			Message:      "You have chosen to subscribe to the topic",
+			SubscribeURL: subscribeSrv.URL + "/?Action=ConfirmSubscription",
+			Timestamp:    "2024-01-01T00:00:00.000Z",
+		})
+		gt.N(t, resp.StatusCode).Equal(http.StatusOK)
+		gt.N(t, confirmed).Equal(1)
+		gt.N(t, called).Equal(0)
+	})
+
+	t.Run("unwrap notification message", func(t *testing.T) {
+		resp := send(t, model.SNSMessage{
+			Type:      model.SNSTypeNotification,
+			MessageID: "msg-2",
+			TopicArn:  "arn:aws:sns:us-east-1:123456789012:guardduty",
+			Message:   `{"detail-type":"GuardDuty Finding"}`,
+			Timestamp: "2024-01-01T00:00:00.000Z",
+		})
+		gt.N(t, resp.StatusCode).Equal(http.StatusOK)
+		gt.N(t, called).Equal(1)
+	})
+
+	t.Run("reject invalid signature", func(t *testing.T) {
+		msg := model.SNSMessage{
+			Type:             model.SNSTypeNotification,
+			MessageID:        "msg-3",
+			TopicArn:         "arn:aws:sns:us-east-1:123456789012:guardduty",
+			Message:          `{"detail-type":"GuardDuty Finding"}`,
+			SignatureVersion: "2",
+			Signature:        base64.StdEncoding.EncodeToString([]byte("invalid")),
+			SigningCertURL:   "https://sns.us-east-1.amazonaws.com/SimpleNotificationService-test.pem",
+		}
+		body := gt.R1(json.Marshal(msg)).NoError(t)
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, httptest.NewRequest("POST", "/alert/sns/guardduty", bytes.NewReader(body)))
+		gt.N(t, w.Result().StatusCode).Equal(http.StatusUnauthorized)
+		gt.N(t, called).Equal(1)
+	})
+
+	t.Run("reject subscription of unknown topic", func(t *testing.T) {
+		resp := send(t, model.SNSMessage{
+			Type:         model.SNSTypeSubscriptionConfirmation,
+			MessageID:    "msg-4",
+			Token:        "token",
+			TopicArn:     "arn:aws:sns:us-east-1:999999999999:attacker",
+			Message:      "You have chosen to subscribe to the topic",
+			SubscribeURL: subscribeSrv.URL + "/?Action=ConfirmSubscription",
+			Timestamp:    "2024-01-01T00:00:00.000Z",
+		})
+		gt.N(t, resp.StatusCode).Equal(http.StatusForbidden)
+		gt.N(t, confirmed).Equal(1)
+	})
+
+	t.Run("reject notification of unknown topic", func(t *testing.T) {
+		resp := send(t, model.SNSMessage{
+			Type:      model.SNSTypeNotification,
+			MessageID: "msg-5",
+			TopicArn:  "arn:aws:sns:us-east-1:999999999999:attacker",
+			Message:   `{"detail-type":"GuardDuty Finding"}`,
+			Timestamp: "2024-01-01T00:00:00.000Z",
+		})
+		gt.N(t, resp.StatusCode).Equal(http.StatusForbidden)
+		gt.N(t, called).Equal(1)
+	})
+}
+
+func TestGracefulShutdown(t *testing.T) {
+	l := gt.R1(net.Listen("tcp", "127.0.0.1:0")).NoError(t)
+	addr := l.Addr().String()
+	gt.NoError(t, l.Close())
+
+	received := make(chan struct{})
+	srv := server.New(func(ctx context.Context, schema types.Schema, data any) ([]*model.Alert, error) {
+		close(received)
+		time.Sleep(200 * time.Millisecond)
+		return nil, nil
+	}, server.WithGracePeriod(5*time.Second))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	runErr := make(chan error, 1)
+	go func() {
+		runErr <- srv.Run(ctx, addr)
+	}()
+
+	var resp *http.Response
+	var reqErr error
+	reqDone := make(chan struct{})
+	go func() {
+		defer close(reqDone)
+		for i := 0; i < 50; i++ {
+			resp, reqErr = http.Post("http://"+addr+"/alert/raw/test", "application/json", strings.NewReader(`{}`))
+			if reqErr == nil {
+				return
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+	}()
+
+	// Shutdown while the alert is being handled. The request must be completed
+	<-received
+	cancel()
+	<-reqDone
+	gt.NoError(t, reqErr)
+	gt.N(t, resp.StatusCode).Equal(http.StatusOK)
+	gt.NoError(t, resp.Body.Close())
+	gt.NoError(t, <-runErr)
+}
+
+func TestMetrics(t *testing.T) {
+	alertPolicy := gt.R1(policy.New(
+		policy.WithPolicyData("alert.rego", "package alert.scc\n\nalert contains {\"title\": \"test\"} if false\n"),
+		policy.WithPackage("alert"),
+	)).NoError(t)
+
+	prom := metrics.NewPrometheus()
+	srv := newServer(t, `package authz.http
+
+default deny := false
+
+deny if input.path == "/admin"
+`,
+		server.WithInstrument(prom),
+		server.WithMetricsHandler(prom.Handler()),
+		server.WithPolicies(alertPolicy),
+	)
+
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest("POST", "/alert/raw/scc", bytes.NewReader(sccData)))
+	gt.N(t, w.Result().StatusCode).Equal(http.StatusOK)
+
+	for _, schema := range []string{"random_1", "random_2"} {
+		w = httptest.NewRecorder()
+		srv.ServeHTTP(w, httptest.NewRequest("POST", "/alert/raw/"+schema, bytes.NewReader(sccData)))
+		gt.N(t, w.Result().StatusCode).Equal(http.StatusOK)
+	}
+
+	w = httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest("GET", "/admin", nil))
+	gt.N(t, w.Result().StatusCode).Equal(http.StatusForbidden)
+
+	w = httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	gt.N(t, w.Result().StatusCode).Equal(http.StatusOK)
+
+	body := w.Body.String()
+	gt.S(t, body).Contains(`alertchain_events_received_total{endpoint="raw",schema="scc"} 1`)
+	gt.S(t, body).Contains(`alertchain_events_received_total{endpoint="raw",schema="unknown"} 2`)
+	gt.S(t, body).NotContains(`random_1`)
+	gt.S(t, body).Contains(`alertchain_authz_denied_total 1`)
+	gt.S(t, body).Contains(`alertchain_policy_evaluation_duration_seconds_count{package="authz.http"} 5`)
+}
+
+func TestMetricsUnknownSchema(t *testing.T) {
+	prom := metrics.NewPrometheus()
+	alertPolicy := gt.R1(policy.New(
+		policy.WithPolicyData("alert.rego", "package alert.scc\n\nalert contains {\"title\": \"test\"} if false\n"),
+		policy.WithPackage("alert"),
+	)).NoError(t)
+	chain := gt.R1(chain.New(
+		chain.WithPolicyAlert(alertPolicy),
+		chain.WithInstrument(prom),
+	)).NoError(t)
+
+	srv := server.New(chain.HandleAlert,
+		server.WithInstrument(prom),
+		server.WithMetricsHandler(prom.Handler()),
+		server.WithPolicies(alertPolicy),
+	)
+
+	for _, schema := range []string{"random_1", "random_2"} {
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, httptest.NewRequest("POST", "/alert/raw/"+schema, bytes.NewReader(sccData)))
+		gt.N(t, w.Result().StatusCode).Equal(http.StatusOK)
+	}
+
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	gt.N(t, w.Result().StatusCode).Equal(http.StatusOK)
+
+	body := w.Body.String()
+	gt.S(t, body).Contains(`alertchain_events_received_total{endpoint="raw",schema="unknown"} 2`)
+	gt.S(t, body).Contains(`alertchain_alerts_detected_total{schema="unknown"} 0`)
+	gt.S(t, body).Contains(`alertchain_policy_evaluation_duration_seconds_count{package="alert.unknown"} 2`)
+	gt.S(t, body).NotContains(`random_`)
+}
+
 //go:embed testdata/alert.rego
 var alertRego string
 
@@ -183,3 +733,1097 @@ func TestGraphQL(t *testing.T) {
 			})
 	})
 }
+
+func TestTraceContext(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(noop.NewTracerProvider())
+		otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator())
+	})
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	const traceparent = "00-" + traceID + "-00f067aa0ba902b7-01"
+
+	var received string
+	srv := server.New(func(ctx context.Context, schema types.Schema, data any) ([]*model.Alert, error) {
+		received = trace.SpanContextFromContext(ctx).TraceID().String()
+		return nil, nil
+	})
+
+	t.Run("traceparent header", func(t *testing.T) {
+		received = ""
+		req := httptest.NewRequest("POST", "/alert/raw/test", strings.NewReader(`{"color":"blue"}`))
+		req.Header.Set("traceparent", traceparent)
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, req)
+		gt.N(t, w.Result().StatusCode).Equal(http.StatusOK)
+		gt.V(t, received).Equal(traceID)
+	})
+
+	t.Run("Pub/Sub message attribute", func(t *testing.T) {
+		received = ""
+		body := gt.R1(json.Marshal(model.PubSubRequest{
+			Message: model.PubSubMessage{
+				Data:       []byte(`{"color":"blue"}`),
+				Attributes: map[string]string{"googclient_traceparent": traceparent},
+			},
+		})).NoError(t)
+
+		req := httptest.NewRequest("POST", "/alert/pubsub/test", bytes.NewReader(body))
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, req)
+		gt.N(t, w.Result().StatusCode).Equal(http.StatusOK)
+		gt.V(t, received).Equal(traceID)
+	})
+
+	gt.A(t, recorder.Ended()).Length(2)
+}
+
+func issueCert(t *testing.T, template *x509.Certificate, parent *x509.Certificate, parentKey *rsa.PrivateKey) (*x509.Certificate, *rsa.PrivateKey) {
+	key := gt.R1(rsa.GenerateKey(rand.Reader, 2048)).NoError(t)
+	template.NotBefore = time.Now().Add(-time.Hour)
+	template.NotAfter = time.Now().Add(time.Hour)
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+	raw := gt.R1(x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)).NoError(t)
+	return gt.R1(x509.ParseCertificate(raw)).NoError(t), key
+}
+
+func TestMutualTLS(t *testing.T) {
+	ca, caKey := issueCert(t, &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}, nil, nil)
+	serverCert, serverKey := issueCert(t, &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "alertchain"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}, ca, caKey)
+	newClientCert := func(cn string) tls.Certificate {
+		cert, key := issueCert(t, &x509.Certificate{
+			SerialNumber: big.NewInt(3),
+			Subject:      pkix.Name{CommonName: cn, Organization: []string{"Blue Team"}},
+			URIs:         []*url.URL{{Scheme: "spiffe", Host: "example.com", Path: "/" + cn}},
+			ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		}, ca, caKey)
+		return tls.Certificate{Certificate: [][]byte{cert.Raw}, PrivateKey: key}
+	}
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ca)
+
+	l := gt.R1(net.Listen("tcp", "127.0.0.1:0")).NoError(t)
+	addr := l.Addr().String()
+	gt.NoError(t, l.Close())
+
+	srv := newServer(t, `package authz.http
+
+default deny := true
+
+deny := false if {
+	input.client_cert.common_name == "scanner"
+	"spiffe://example.com/scanner" in input.client_cert.uris
+}
+`, server.WithTLSConfig(&tls.Config{
+		Certificates: []tls.Certificate{{Certificate: [][]byte{serverCert.Raw}, PrivateKey: serverKey}},
+		ClientCAs:    pool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		MinVersion:   tls.VersionTLS12,
+	}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	runErr := make(chan error, 1)
+	go func() {
+		runErr <- srv.Run(ctx, addr)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		gt.NoError(t, <-runErr)
+	})
+
+	post := func(certs ...tls.Certificate) (*http.Response, error) {
+		client := &http.Client{Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{RootCAs: pool, Certificates: certs, MinVersion: tls.VersionTLS12},
+		}}
+
+		var resp *http.Response
+		var err error
+		for i := 0; i < 50; i++ {
+			resp, err = client.Post("https://"+addr+"/alert/raw/test", "application/json", strings.NewReader(`{}`))
+			if err == nil || !strings.Contains(err.Error(), "connection refused") {
+				break
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+		return resp, err
+	}
+
+	t.Run("allowed client", func(t *testing.T) {
+		resp := gt.R1(post(newClientCert("scanner"))).NoError(t)
+		gt.N(t, resp.StatusCode).Equal(http.StatusOK)
+		gt.NoError(t, resp.Body.Close())
+	})
+
+	t.Run("denied client by authz policy", func(t *testing.T) {
+		resp := gt.R1(post(newClientCert("intruder"))).NoError(t)
+		gt.N(t, resp.StatusCode).Equal(http.StatusForbidden)
+		gt.NoError(t, resp.Body.Close())
+	})
+
+	t.Run("no client certificate", func(t *testing.T) {
+		_, err := post()
+		gt.Error(t, err)
+	})
+}
+
+func TestGraphQLAuthz(t *testing.T) {
+	dbClient := memory.New()
+	svc := service.New(dbClient)
+	alert := model.NewAlert(model.AlertMetaData{Title: "blue"}, "test", map[string]any{"password": "xxx"})
+	gt.R1(svc.Workflow.Create(context.Background(), alert)).NoError(t)
+
+	authz := gt.R1(policy.New(
+		policy.WithPackage("authz"),
+		policy.WithPolicyData("http.rego", `package authz.http
+
+deny := false
+`),
+		policy.WithPolicyData("graphql.rego", `package authz.graphql
+
+default deny := false
+
+deny if input.caller.header["X-Role"] == ["guest"]
+
+deny_fields contains "AlertRecord.data" if input.caller.header["X-Role"] != ["admin"]
+`),
+	)).NoError(t)
+
+	srv := server.New(func(ctx context.Context, schema types.Schema, data any) ([]*model.Alert, error) {
+		return nil, nil
+	}, server.WithAuthzPolicy(authz), server.WithResolver(graphql.NewResolver(svc)))
+
+	type response struct {
+		Data struct {
+			Workflows []*model.WorkflowRecord `json:"workflows"`
+		} `json:"data"`
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+
+	query := func(t *testing.T, role string) response {
+		body := gt.R1(json.Marshal(map[string]string{
+			"query": `query list { workflows(limit: 10) { id alert { title data } } }`,
+		})).NoError(t)
+		req := httptest.NewRequest("POST", "/graphql", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Role", role)
+
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, req)
+		gt.N(t, w.Result().StatusCode).Equal(http.StatusOK)
+
+		var resp response
+		gt.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		return resp
+	}
+
+	t.Run("admin can read data", func(t *testing.T) {
+		resp := query(t, "admin")
+		gt.A(t, resp.Errors).Length(0)
+		gt.A(t, resp.Data.Workflows).Length(1)
+		gt.S(t, resp.Data.Workflows[0].Alert.Data).Contains("password")
+	})
+
+	t.Run("data is hidden for analyst", func(t *testing.T) {
+		resp := query(t, "analyst")
+		gt.A(t, resp.Data.Workflows).Length(1)
+		gt.V(t, resp.Data.Workflows[0].Alert.Title).Equal("blue")
+		gt.V(t, resp.Data.Workflows[0].Alert.Data).Equal("")
+		gt.A(t, resp.Errors).Length(1)
+		gt.S(t, resp.Errors[0].Message).Contains("AlertRecord.data")
+	})
+
+	t.Run("guest is denied", func(t *testing.T) {
+		resp := query(t, "guest")
+		gt.A(t, resp.Data.Workflows).Length(0)
+		gt.A(t, resp.Errors).Length(1)
+		gt.V(t, resp.Errors[0].Message).Equal("access denied")
+	})
+}
+
+// namespaceDB returns a fixed namespace and counts reading its attributes.
+type namespaceDB struct {
+	interfaces.Database
+	attrsRead int
+}
+
+func (x *namespaceDB) GetNamespaces(ctx context.Context, offset, limit int) ([]model.NamespaceRecord, error) {
+	return []model.NamespaceRecord{{Name: "host:web-1"}}, nil
+}
+
+func (x *namespaceDB) GetPersistentAttrs(ctx context.Context, ns types.Namespace) ([]model.PersistentAttribute, error) {
+	x.attrsRead++
+	return nil, nil
+}
+
+func TestGraphQLAuthzDenyFieldsNotResolved(t *testing.T) {
+	dbClient := &namespaceDB{Database: memory.New()}
+	authz := gt.R1(policy.New(
+		policy.WithPackage("authz"),
+		policy.WithPolicyData("http.rego", `package authz.http
+
+deny := false
+`),
+		policy.WithPolicyData("graphql.rego", `package authz.graphql
+
+deny_fields contains "NamespaceRecord.attributes"
+`),
+	)).NoError(t)
+
+	srv := server.New(func(ctx context.Context, schema types.Schema, data any) ([]*model.Alert, error) {
+		return nil, nil
+	}, server.WithAuthzPolicy(authz), server.WithResolver(graphql.NewResolver(service.New(dbClient))))
+
+	type namespace struct {
+		Name       string `json:"name"`
+		Attributes []any  `json:"attributes"`
+	}
+	var resp struct {
+		Data struct {
+			Namespaces []namespace `json:"namespaces"`
+		} `json:"data"`
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	body := gt.R1(json.Marshal(map[string]string{
+		"query": `query { namespaces { name attributes { key } } }`,
+	})).NoError(t)
+	req := httptest.NewRequest("POST", "/graphql", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	gt.N(t, w.Result().StatusCode).Equal(http.StatusOK)
+	gt.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+
+	gt.N(t, dbClient.attrsRead).Equal(0)
+	gt.A(t, resp.Data.Namespaces).Length(1).At(0, func(t testing.TB, v namespace) {
+		gt.V(t, v.Name).Equal("host:web-1")
+		gt.A(t, v.Attributes).Length(0)
+	})
+	gt.A(t, resp.Errors).Length(1)
+	gt.S(t, resp.Errors[0].Message).Contains("NamespaceRecord.attributes")
+}
+
+func TestGraphQLMutation(t *testing.T) {
+	dbClient := memory.New()
+	var called int
+	chain := gt.R1(chain.New(
+		chain.WithPolicyAlert(gt.R1(policy.New(
+			policy.WithPackage("alert"),
+			policy.WithPolicyData("alert.rego", `package alert.test
+
+alert contains {"title": "mutation test", "namespace": "ns1"}
+`),
+		)).NoError(t)),
+		chain.WithPolicyAction(gt.R1(policy.New(
+			policy.WithPackage("action"),
+			policy.WithPolicyData("action.rego", `package action
+
+run contains {
+	"id": "mock",
+	"uses": "mock",
+	"commit": [{"key": "color", "value": "red", "persist": true}],
+} if input.seq == 0
+`),
+		)).NoError(t)),
+		chain.WithExtraAction("mock", func(ctx context.Context, alert model.Alert, args model.ActionArgs) (any, error) {
+			called++
+			return nil, nil
+		}),
+		chain.WithDatabase(dbClient),
+	)).NoError(t)
+
+	authz := gt.R1(policy.New(
+		policy.WithPackage("authz"),
+		policy.WithPolicyData("graphql.rego", `package authz.graphql
+
+allow_mutation if input.caller.header["X-Role"] == ["admin"]
+
+actor := input.caller.header["X-User"][0]
+`),
+	)).NoError(t)
+
+	resolver := graphql.NewResolver(service.New(dbClient), graphql.WithWorkflowRunner(chain.RerunAlert))
+	srv := server.New(chain.HandleAlert, server.WithAuthzPolicy(authz), server.WithResolver(resolver))
+
+	type response struct {
+		Data   map[string]json.RawMessage `json:"data"`
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	mutate := func(t *testing.T, srv *server.Server, role, query string) response {
+		body := gt.R1(json.Marshal(map[string]string{"query": query})).NoError(t)
+		req := httptest.NewRequest("POST", "/graphql", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Role", role)
+		req.Header.Set("X-User", "alice")
+
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, req)
+		gt.N(t, w.Result().StatusCode).Equal(http.StatusOK)
+
+		var resp response
+		gt.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		return resp
+	}
+
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest("POST", "/alert/raw/test", strings.NewReader(`{"foo":"bar"}`)))
+	gt.N(t, w.Result().StatusCode).Equal(http.StatusOK)
+	workflows := gt.R1(dbClient.GetWorkflows(context.Background(), 0, 10)).NoError(t)
+	gt.A(t, workflows).Length(1)
+	wfID := workflows[0].ID
+
+	t.Run("mutation is denied without allow_mutation", func(t *testing.T) {
+		resp := mutate(t, srv, "analyst", `mutation { releaseLock(namespace: "ns1") }`)
+		gt.A(t, resp.Errors).Length(1)
+		gt.V(t, resp.Errors[0].Message).Equal("access denied")
+	})
+
+	t.Run("mutation is denied without authz policy", func(t *testing.T) {
+		noAuthz := server.New(chain.HandleAlert, server.WithResolver(resolver))
+		resp := mutate(t, noAuthz, "admin", `mutation { releaseLock(namespace: "ns1") }`)
+		gt.A(t, resp.Errors).Length(1)
+		gt.S(t, resp.Errors[0].Message).Contains("not allowed")
+	})
+
+	t.Run("acknowledge and close workflow", func(t *testing.T) {
+		resp := mutate(t, srv, "admin", `mutation { acknowledgeWorkflow(id: "`+string(wfID)+`", comment: "checking") { triage comments { author action body } } }`)
+		gt.A(t, resp.Errors).Length(0)
+		var wf model.WorkflowRecord
+		gt.NoError(t, json.Unmarshal(resp.Data["acknowledgeWorkflow"], &wf))
+		gt.V(t, wf.Triage).Equal(model.WorkflowTriageAcknowledged)
+		gt.A(t, wf.Comments).Length(1)
+		gt.V(t, *wf.Comments[0]).Equal(model.CommentRecord{Author: "alice", Action: "acknowledged", Body: "checking"})
+
+		resp = mutate(t, srv, "admin", `mutation { closeWorkflow(id: "`+string(wfID)+`") { triage comments { body } } }`)
+		gt.A(t, resp.Errors).Length(0)
+		gt.NoError(t, json.Unmarshal(resp.Data["closeWorkflow"], &wf))
+		gt.V(t, wf.Triage).Equal(model.WorkflowTriageClosed)
+		gt.A(t, wf.Comments).Length(1)
+
+		resp = mutate(t, srv, "admin", `mutation { closeWorkflow(id: "not-found") { triage } }`)
+		gt.A(t, resp.Errors).Length(1)
+	})
+
+	t.Run("put and delete attribute", func(t *testing.T) {
+		ctx := context.Background()
+		attrs := gt.R1(dbClient.GetAttrs(ctx, "ns1")).NoError(t)
+		gt.A(t, attrs).Length(1)
+		colorID := attrs[0].ID
+
+		resp := mutate(t, srv, "admin", `mutation { putAttribute(namespace: "ns1", attribute: {id: "`+string(colorID)+`", key: "color", value: "blue"}) { id value persist } }`)
+		gt.A(t, resp.Errors).Length(0)
+		resp = mutate(t, srv, "admin", `mutation { putAttribute(namespace: "ns1", attribute: {key: "owner", value: "bob", ttl: 60}) { id key persist ttl } }`)
+		gt.A(t, resp.Errors).Length(0)
+		var added model.AttributeRecord
+		gt.NoError(t, json.Unmarshal(resp.Data["putAttribute"], &added))
+		gt.V(t, added.Key).Equal("owner")
+		gt.B(t, added.Persist).True()
+		gt.N(t, added.TTL).Equal(60)
+
+		values := map[types.AttrKey]any{}
+		for _, attr := range gt.R1(dbClient.GetAttrs(ctx, "ns1")).NoError(t) {
+			values[attr.Key] = attr.Value
+		}
+		gt.V(t, values).Equal(map[types.AttrKey]any{"color": "blue", "owner": "bob"})
+
+		resp = mutate(t, srv, "admin", `mutation { deleteAttribute(namespace: "ns1", id: "`+added.ID+`") }`)
+		gt.V(t, string(resp.Data["deleteAttribute"])).Equal("true")
+		resp = mutate(t, srv, "admin", `mutation { deleteAttribute(namespace: "ns1", id: "`+added.ID+`") }`)
+		gt.V(t, string(resp.Data["deleteAttribute"])).Equal("false")
+		gt.A(t, gt.R1(dbClient.GetAttrs(ctx, "ns1")).NoError(t)).Length(1)
+	})
+
+	t.Run("release lock", func(t *testing.T) {
+		ctx := context.Background()
+		gt.NoError(t, dbClient.Lock(ctx, "ns1", time.Now().Add(time.Hour)))
+
+		resp := mutate(t, srv, "admin", `mutation { releaseLock(namespace: "ns1") }`)
+		gt.A(t, resp.Errors).Length(0)
+		gt.V(t, string(resp.Data["releaseLock"])).Equal("true")
+
+		locked := make(chan struct{})
+		go func() {
+			gt.NoError(t, dbClient.Lock(ctx, "ns1", time.Now().Add(time.Hour)))
+			close(locked)
+		}()
+		select {
+		case <-locked:
+		case <-time.After(3 * time.Second):
+			t.Fatal("lock is not released")
+		}
+		gt.NoError(t, dbClient.Unlock(ctx, "ns1"))
+	})
+
+	t.Run("rerun workflow", func(t *testing.T) {
+		resp := mutate(t, srv, "admin", `mutation { rerunWorkflow(id: "`+string(wfID)+`") { id status triage alert { id } } }`)
+		gt.A(t, resp.Errors).Length(0)
+		var wf model.WorkflowRecord
+		gt.NoError(t, json.Unmarshal(resp.Data["rerunWorkflow"], &wf))
+		gt.V(t, wf.ID).NotEqual(wfID)
+		gt.V(t, wf.Status).Equal(model.WorkflowStatusCompleted)
+		gt.V(t, wf.Triage).Equal(model.WorkflowTriageOpen)
+		gt.V(t, wf.Alert.ID).Equal(workflows[0].Alert.ID)
+		gt.N(t, called).Equal(2)
+	})
+
+	t.Run("mutations are recorded in audit log", func(t *testing.T) {
+		logs := dbClient.AuditLogs()
+		var operations []string
+		for _, log := range logs {
+			operations = append(operations, log.Operation)
+			gt.V(t, log.Actor.Subject).Equal("alice")
+		}
+		gt.V(t, operations).Equal([]string{
+			"acknowledgeWorkflow", "closeWorkflow", "closeWorkflow",
+			"putAttribute", "putAttribute", "deleteAttribute", "deleteAttribute",
+			"releaseLock",
+			"rerunWorkflow",
+		})
+		gt.V(t, logs[2].Error).NotEqual("")
+		gt.V(t, logs[8].Target).Equal(string(wfID))
+	})
+}
+
+func TestGraphQLSearchWorkflows(t *testing.T) {
+	dbClient := memory.New()
+	chain := gt.R1(chain.New(
+		chain.WithPolicyAlert(gt.R1(policy.New(
+			policy.WithPackage("alert"),
+			policy.WithPolicyData("alert.rego", `package alert.test
+
+alert contains {
+	"title": input.title,
+	"attrs": [{"key": "src_ip", "value": input.ip}],
+}
+`),
+		)).NoError(t)),
+		chain.WithPolicyAction(gt.R1(policy.New(
+			policy.WithPackage("action"),
+			policy.WithPolicyData("action.rego", `package action
+
+run contains {
+	"id": "notify",
+	"uses": "mock",
+	"args": {"channel": "#alert", "secret_token": "xxx"},
+} if {
+	input.seq == 0
+	startswith(input.alert.title, "SSH")
+}
+`),
+		)).NoError(t)),
+		chain.WithExtraAction("mock", func(ctx context.Context, alert model.Alert, args model.ActionArgs) (any, error) {
+			return map[string]any{"ok": true}, nil
+		}),
+		chain.WithDatabase(dbClient),
+	)).NoError(t)
+
+	srv := server.New(chain.HandleAlert, server.WithResolver(graphql.NewResolver(service.New(dbClient))))
+	for _, body := range []string{
+		`{"title": "SSH brute force", "ip": "192.0.2.1"}`,
+		`{"title": "Port scan", "ip": "192.0.2.1"}`,
+		`{"title": "SSH login", "ip": "198.51.100.1"}`,
+	} {
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, httptest.NewRequest("POST", "/alert/raw/test", strings.NewReader(body)))
+		gt.N(t, w.Result().StatusCode).Equal(http.StatusOK)
+	}
+
+	search := func(t *testing.T, filter string) []*model.WorkflowRecord {
+		var output struct {
+			Data struct {
+				SearchWorkflows []*model.WorkflowRecord `json:"searchWorkflows"`
+			} `json:"data"`
+		}
+		sendGraphQLRequest(t, srv, `query { searchWorkflows(filter: `+filter+`) {
+			id
+			alert { title }
+			actions { uses args { key value } result }
+		} }`, &output)
+		return output.Data.SearchWorkflows
+	}
+	titles := func(workflows []*model.WorkflowRecord) []string {
+		var ret []string
+		for _, wf := range workflows {
+			ret = append(ret, wf.Alert.Title)
+		}
+		sort.Strings(ret)
+		return ret
+	}
+
+	t.Run("attribute value", func(t *testing.T) {
+		got := search(t, `{attrKey: "src_ip", attrValue: "192.0.2.1"}`)
+		gt.V(t, titles(got)).Equal([]string{"Port scan", "SSH brute force"})
+	})
+
+	t.Run("executed action", func(t *testing.T) {
+		got := search(t, `{action: "mock"}`)
+		gt.V(t, titles(got)).Equal([]string{"SSH brute force", "SSH login"})
+
+		gt.A(t, got[0].Actions).Length(1)
+		gt.V(t, got[0].Actions[0].Uses).Equal("mock")
+		gt.V(t, *got[0].Actions[0].Result).Equal(`{"ok":true}`)
+		gt.V(t, got[0].Actions[0].Args).Equal([]*model.ArgumentRecord{
+			{Key: "channel", Value: `"#alert"`},
+			{Key: "secret_token", Value: "null"},
+		})
+	})
+
+	t.Run("title, attribute and action", func(t *testing.T) {
+		got := search(t, `{title: "ssh", attrValue: "192.0.2.1", action: "mock"}`)
+		gt.V(t, titles(got)).Equal([]string{"SSH brute force"})
+	})
+
+	t.Run("status and time range", func(t *testing.T) {
+		gt.A(t, search(t, `{status: "completed", createdAfter: "2000-01-01T00:00:00Z"}`)).Length(3)
+		gt.A(t, search(t, `{status: "failed"}`)).Length(0)
+		gt.A(t, search(t, `{createdBefore: "2000-01-01T00:00:00Z"}`)).Length(0)
+	})
+}
+
+func TestReady(t *testing.T) {
+	var pingErr error
+	db := &mock.DatabaseMock{
+		PingFunc: func(ctx context.Context) error { return pingErr },
+	}
+	alertPolicy := gt.R1(policy.New(
+		policy.WithPolicyData("alert.rego", "package alert.my_alert\nalert contains {} if false"),
+		policy.WithPackage("alert"),
+	)).NoError(t)
+
+	var probed int
+	srv := server.New(func(ctx context.Context, schema types.Schema, data any) ([]*model.Alert, error) {
+		return nil, nil
+	},
+		server.WithReadinessCheck(
+			server.ReadinessCheck{Name: "database", Check: db.Ping},
+			server.ReadinessCheck{Name: "probe:slack", Interval: time.Hour, Check: func(ctx context.Context) error {
+				probed++
+				return nil
+			}},
+		),
+		server.WithPolicies(alertPolicy),
+	)
+
+	var resp struct {
+		Status string `json:"status"`
+		Checks []struct {
+			Name   string `json:"name"`
+			Status string `json:"status"`
+		} `json:"checks"`
+		Policies []struct {
+			Package  string   `json:"package"`
+			Hash     string   `json:"hash"`
+			Packages []string `json:"packages"`
+		} `json:"policies"`
+	}
+	var body string
+	get := func() int {
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, httptest.NewRequest("GET", "/ready", nil))
+		body = w.Body.String()
+		gt.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		return w.Result().StatusCode
+	}
+
+	t.Run("ready", func(t *testing.T) {
+		gt.N(t, get()).Equal(http.StatusOK)
+		gt.V(t, resp.Status).Equal("ok")
+		gt.A(t, resp.Checks).Length(2)
+		gt.V(t, resp.Checks[0].Name).Equal("database")
+		gt.V(t, resp.Checks[0].Status).Equal("ok")
+		gt.A(t, resp.Policies).Length(1)
+		gt.V(t, resp.Policies[0].Package).Equal("alert")
+		gt.V(t, resp.Policies[0].Hash).Equal(alertPolicy.Hash())
+		gt.V(t, resp.Policies[0].Packages).Equal([]string{"alert.my_alert"})
+	})
+
+	t.Run("database is not reachable", func(t *testing.T) {
+		pingErr = errors.New("connection refused")
+		gt.N(t, get()).Equal(http.StatusServiceUnavailable)
+		gt.V(t, resp.Status).Equal("fail")
+		gt.V(t, resp.Checks[0].Status).Equal("fail")
+		gt.S(t, body).NotContains("connection refused")
+		gt.S(t, body).NotContains(`"error"`)
+		gt.V(t, resp.Checks[1].Status).Equal("ok")
+	})
+
+	t.Run("probe result is cached", func(t *testing.T) {
+		gt.N(t, probed).Equal(1)
+	})
+}
+
+func TestDryRun(t *testing.T) {
+	dbClient := memory.New()
+	chain := gt.R1(chain.New(
+		chain.WithPolicyAlert(gt.R1(policy.New(
+			policy.WithPackage("alert"),
+			policy.WithPolicyData("alert.rego", alertRego),
+		)).NoError(t)),
+		chain.WithPolicyAction(gt.R1(policy.New(
+			policy.WithPackage("action"),
+			policy.WithPolicyData("action.rego", `package action
+
+run contains {
+	"id": "notify",
+	"uses": "http.fetch",
+	"args": {
+		"method": "POST",
+		"url": "https://example.com/notify",
+		"secret_token": "xxx",
+	},
+}
+`),
+		)).NoError(t)),
+		chain.WithDatabase(dbClient),
+	)).NoError(t)
+
+	authz := gt.R1(policy.New(
+		policy.WithPackage("authz"),
+		policy.WithPolicyData("authz.rego", `package authz.http
+
+default deny := false
+
+allow_dry_run if input.header["X-Test-User"] == ["admin"]
+`),
+	)).NoError(t)
+	srv := server.New(chain.HandleAlert, server.WithAuthzPolicy(authz))
+
+	send := func(query, user string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/alert/raw/test_service"+query, strings.NewReader(`{"foo":"bar"}`))
+		if user != "" {
+			req.Header.Set("X-Test-User", user)
+		}
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("planned actions are returned", func(t *testing.T) {
+		w := send("?dry_run=true", "admin")
+		gt.N(t, w.Result().StatusCode).Equal(http.StatusOK)
+
+		var output struct {
+			Alerts   []*model.Alert `json:"alerts"`
+			DryRun   bool           `json:"dry_run"`
+			Timeline []struct {
+				ID     string         `json:"id"`
+				Uses   string         `json:"uses"`
+				Args   map[string]any `json:"args"`
+				Result struct {
+					DryRun  bool           `json:"dry_run"`
+					Request map[string]any `json:"request"`
+				} `json:"result"`
+			} `json:"timeline"`
+		}
+		gt.NoError(t, json.Unmarshal(w.Body.Bytes(), &output))
+		gt.B(t, output.DryRun).True()
+		gt.A(t, output.Alerts).Length(1)
+		gt.A(t, output.Timeline).Length(1)
+		gt.V(t, output.Timeline[0].ID).Equal("notify")
+		gt.V(t, output.Timeline[0].Uses).Equal("http.fetch")
+		gt.V(t, output.Timeline[0].Args["secret_token"]).Nil()
+		gt.B(t, output.Timeline[0].Result.DryRun).True()
+		gt.V(t, output.Timeline[0].Result.Request["url"]).Equal("https://example.com/notify")
+
+		// Workflow and alert are not recorded in dry-run mode
+		workflows := gt.R1(dbClient.GetWorkflows(context.Background(), 0, 10)).NoError(t)
+		gt.A(t, workflows).Length(0)
+		alert := gt.R1(dbClient.GetAlert(context.Background(), output.Alerts[0].ID)).NoError(t)
+		gt.V(t, alert).Nil()
+	})
+
+	t.Run("dry-run is not allowed by authz policy", func(t *testing.T) {
+		w := send("?dry_run=true", "")
+		gt.N(t, w.Result().StatusCode).Equal(http.StatusForbidden)
+	})
+
+	t.Run("invalid dry_run value", func(t *testing.T) {
+		w := send("?dry_run=maybe", "admin")
+		gt.N(t, w.Result().StatusCode).Equal(http.StatusBadRequest)
+	})
+}
+
+func TestGraphQLAttributes(t *testing.T) {
+	dbClient := memory.New()
+	chain := gt.R1(chain.New(
+		chain.WithPolicyAlert(gt.R1(policy.New(
+			policy.WithPackage("alert"),
+			policy.WithPolicyData("alert.rego", `package alert.test
+
+alert contains {"title": "suppress test", "namespace": "host:web-1"}
+`),
+		)).NoError(t)),
+		chain.WithPolicyAction(gt.R1(policy.New(
+			policy.WithPackage("action"),
+			policy.WithPolicyData("action.rego", `package action
+
+run contains {
+	"id": "suppress",
+	"commit": [{"id": "suppressed", "key": "suppressed", "value": "yes", "persist": true, "ttl": 600}],
+} if input.seq == 0
+`),
+		)).NoError(t)),
+		chain.WithDatabase(dbClient),
+	)).NoError(t)
+
+	srv := server.New(chain.HandleAlert, server.WithResolver(graphql.NewResolver(service.New(dbClient))))
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest("POST", "/alert/raw/test", strings.NewReader(`{}`)))
+	gt.N(t, w.Result().StatusCode).Equal(http.StatusOK)
+
+	workflows := gt.R1(dbClient.GetWorkflows(context.Background(), 0, 10)).NoError(t)
+	gt.A(t, workflows).Length(1)
+
+	var output struct {
+		Data struct {
+			Namespaces []struct {
+				Name       string `json:"name"`
+				Attributes []struct {
+					Key        string    `json:"key"`
+					Value      string    `json:"value"`
+					TTL        int       `json:"ttl"`
+					ExpiresAt  time.Time `json:"expiresAt"`
+					WorkflowID string    `json:"workflowId"`
+					History    []struct {
+						Value      string `json:"value"`
+						WorkflowID string `json:"workflowId"`
+					} `json:"history"`
+				} `json:"attributes"`
+			} `json:"namespaces"`
+		} `json:"data"`
+	}
+	sendGraphQLRequest(t, srv, `query { namespaces {
+		name
+		attributes { key value ttl expiresAt workflowId history(limit: 5) { value workflowId } }
+	} }`, &output)
+
+	gt.A(t, output.Data.Namespaces).Length(1)
+	ns := output.Data.Namespaces[0]
+	gt.V(t, ns.Name).Equal("host:web-1")
+	gt.A(t, ns.Attributes).Length(1)
+	attr := ns.Attributes[0]
+	gt.V(t, attr.Key).Equal("suppressed")
+	gt.V(t, attr.Value).Equal("yes")
+	gt.V(t, attr.TTL).Equal(600)
+	gt.B(t, attr.ExpiresAt.After(time.Now())).True()
+	gt.V(t, attr.WorkflowID).Equal(workflows[0].ID.String())
+	gt.A(t, attr.History).Length(1)
+	gt.V(t, attr.History[0].Value).Equal("yes")
+	gt.V(t, attr.History[0].WorkflowID).Equal(workflows[0].ID.String())
+}
+
+func TestGraphQLStats(t *testing.T) {
+	ctx := context.Background()
+	dbClient := memory.New()
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, schema := range []string{"guardduty", "guardduty", "scc"} {
+		createdAt := from.Add(time.Duration(i) * 40 * time.Minute)
+		gt.NoError(t, dbClient.PutWorkflow(ctx, model.WorkflowRecord{
+			ID:        types.NewWorkflowID(),
+			CreatedAt: createdAt,
+			Alert:     &model.AlertRecord{Schema: schema, Title: "test"},
+			Actions: []*model.ActionRecord{
+				{Uses: "slack.post", StartedAt: createdAt, FinishedAt: createdAt.Add(time.Second)},
+			},
+		}))
+	}
+
+	srv := server.New(func(ctx context.Context, schema types.Schema, data any) ([]*model.Alert, error) {
+		return nil, nil
+	}, server.WithResolver(graphql.NewResolver(service.New(dbClient))))
+
+	type response struct {
+		Data struct {
+			Stats *model.Stats `json:"stats"`
+		} `json:"data"`
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+
+	t.Run("aggregate alerts and actions", func(t *testing.T) {
+		var resp response
+		sendGraphQLRequest(t, srv, `query { stats(from: "2024-01-01T00:00:00Z", to: "2024-01-01T02:00:00Z") {
+			interval
+			alertsBySchema { key total buckets }
+			actions { uses executions failureRate meanLatencyMs }
+		} }`, &resp)
+		gt.A(t, resp.Errors).Length(0)
+		gt.V(t, resp.Data.Stats.Interval).Equal(3600)
+		gt.A(t, resp.Data.Stats.AlertsBySchema).Length(2).At(0, func(t testing.TB, v *model.AlertCount) {
+			gt.V(t, v.Key).Equal("guardduty")
+			gt.V(t, v.Buckets).Equal([]int{2, 0})
+		}).At(1, func(t testing.TB, v *model.AlertCount) {
+			gt.V(t, v.Key).Equal("scc")
+			gt.V(t, v.Buckets).Equal([]int{0, 1})
+		})
+		gt.A(t, resp.Data.Stats.Actions).Length(1).At(0, func(t testing.TB, v *model.ActionStats) {
+			gt.V(t, v.Executions).Equal(3)
+			gt.V(t, v.FailureRate).Equal(0.0)
+			gt.V(t, v.MeanLatencyMs).Equal(1000.0)
+		})
+	})
+
+	t.Run("too many buckets", func(t *testing.T) {
+		var resp response
+		sendGraphQLRequest(t, srv, `query { stats(from: "2024-01-01T00:00:00Z", to: "2024-02-01T00:00:00Z", interval: 60) { interval } }`, &resp)
+		gt.A(t, resp.Errors).Length(1)
+	})
+}
+
+func TestGraphQLSubscription(t *testing.T) {
+	authz := gt.R1(policy.New(
+		policy.WithPackage("authz"),
+		policy.WithPolicyData("graphql.rego", `package authz.graphql
+
+deny if {
+	input.type == "subscription"
+	input.caller.header["X-Role"] != ["viewer"]
+}
+`),
+	)).NoError(t)
+
+	events := broker.New()
+	resolver := graphql.NewResolver(service.New(memory.New()), graphql.WithWorkflowEvents(events))
+	srv := server.New(func(ctx context.Context, schema types.Schema, data any) ([]*model.Alert, error) {
+		return nil, nil
+	}, server.WithAuthzPolicy(authz), server.WithResolver(resolver))
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	type message struct {
+		ID      string          `json:"id,omitempty"`
+		Type    string          `json:"type"`
+		Payload json.RawMessage `json:"payload,omitempty"`
+	}
+
+	wfID := types.NewWorkflowID()
+	subscribe := func(t *testing.T, role string) *websocket.Conn {
+		dialer := websocket.Dialer{Subprotocols: []string{"graphql-transport-ws"}}
+		header := http.Header{"X-Role": []string{role}}
+		conn, resp := gt.R2(dialer.Dial("ws"+strings.TrimPrefix(ts.URL, "http")+"/graphql", header)).NoError(t)
+		gt.N(t, resp.StatusCode).Equal(http.StatusSwitchingProtocols)
+
+		gt.NoError(t, conn.WriteJSON(message{Type: "connection_init"}))
+		var ack message
+		gt.NoError(t, conn.ReadJSON(&ack))
+		gt.V(t, ack.Type).Equal("connection_ack")
+
+		query := `subscription ($id: WorkflowID) { workflowUpdated(id: $id) { type workflowId seq } }`
+		payload := gt.R1(json.Marshal(map[string]any{
+			"query":     query,
+			"variables": map[string]any{"id": wfID},
+		})).NoError(t)
+		gt.NoError(t, conn.WriteJSON(message{ID: "1", Type: "subscribe", Payload: payload}))
+		return conn
+	}
+
+	t.Run("receive events of the workflow", func(t *testing.T) {
+		conn := subscribe(t, "viewer")
+		defer conn.Close()
+
+		// Subscription starts asynchronously, so keep publishing until the client receives
+		done := make(chan struct{})
+		defer close(done)
+		go func() {
+			ticker := time.NewTicker(10 * time.Millisecond)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-done:
+					return
+				case <-ticker.C:
+					ctx := context.Background()
+					events.Publish(ctx, &model.WorkflowEvent{Type: model.WorkflowEventCreated, WorkflowID: types.NewWorkflowID()})
+					events.Publish(ctx, &model.WorkflowEvent{Type: model.WorkflowEventSequenceEvaluated, WorkflowID: wfID, Seq: ptr.To(2)})
+				}
+			}
+		}()
+
+		gt.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+		var msg message
+		gt.NoError(t, conn.ReadJSON(&msg))
+		gt.V(t, msg.Type).Equal("next")
+		gt.V(t, msg.ID).Equal("1")
+
+		var payload struct {
+			Data struct {
+				WorkflowUpdated struct {
+					Type       string `json:"type"`
+					WorkflowID string `json:"workflowId"`
+					Seq        int    `json:"seq"`
+				} `json:"workflowUpdated"`
+			} `json:"data"`
+		}
+		gt.NoError(t, json.Unmarshal(msg.Payload, &payload))
+		gt.V(t, payload.Data.WorkflowUpdated.Type).Equal(model.WorkflowEventSequenceEvaluated)
+		gt.V(t, payload.Data.WorkflowUpdated.WorkflowID).Equal(wfID.String())
+		gt.V(t, payload.Data.WorkflowUpdated.Seq).Equal(2)
+	})
+
+	t.Run("denied by authz policy", func(t *testing.T) {
+		conn := subscribe(t, "guest")
+		defer conn.Close()
+
+		gt.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+		// Denied operation is delivered as a result with error, and then the subscription is completed
+		var msg message
+		gt.NoError(t, conn.ReadJSON(&msg))
+		gt.V(t, msg.Type).Equal("next")
+		gt.S(t, string(msg.Payload)).Contains("access denied")
+		gt.NoError(t, conn.ReadJSON(&msg))
+		gt.V(t, msg.Type).Equal("complete")
+	})
+}
+
+func TestConsole(t *testing.T) {
+	authz := gt.R1(policy.New(
+		policy.WithPackage("authz"),
+		policy.WithPolicyData("http.rego", `package authz.http
+
+default deny := false
+
+deny if {
+	startswith(input.path, "/console")
+	input.header["X-Role"] != ["analyst"]
+}
+`),
+	)).NoError(t)
+	resolver := graphql.NewResolver(service.New(memory.New()))
+	handleAlert := func(ctx context.Context, schema types.Schema, data any) ([]*model.Alert, error) {
+		return nil, nil
+	}
+
+	get := func(srv *server.Server, path, role string) *http.Response {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("X-Role", role)
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, req)
+		return w.Result()
+	}
+
+	t.Run("served if enabled", func(t *testing.T) {
+		srv := server.New(handleAlert, server.WithResolver(resolver), server.WithAuthzPolicy(authz), server.WithEnableConsole())
+
+		resp := get(srv, "/console/", "analyst")
+		gt.N(t, resp.StatusCode).Equal(http.StatusOK)
+		gt.S(t, string(gt.R1(io.ReadAll(resp.Body)).NoError(t))).Contains("app.js")
+
+		resp = get(srv, "/console", "analyst")
+		gt.N(t, resp.StatusCode).Equal(http.StatusMovedPermanently)
+		gt.V(t, resp.Header.Get("Location")).Equal("/console/")
+	})
+
+	t.Run("authorized by authz.http policy", func(t *testing.T) {
+		srv := server.New(handleAlert, server.WithResolver(resolver), server.WithAuthzPolicy(authz), server.WithEnableConsole())
+		gt.N(t, get(srv, "/console/", "guest").StatusCode).Equal(http.StatusForbidden)
+	})
+
+	t.Run("not served by default", func(t *testing.T) {
+		srv := server.New(handleAlert, server.WithResolver(resolver))
+		gt.N(t, get(srv, "/console/", "analyst").StatusCode).Equal(http.StatusNotFound)
+	})
+
+	t.Run("not served without GraphQL", func(t *testing.T) {
+		srv := server.New(handleAlert, server.WithEnableConsole())
+		gt.N(t, get(srv, "/console/", "analyst").StatusCode).Equal(http.StatusNotFound)
+	})
+}
+
+func TestGraphQLWorkflowsConnection(t *testing.T) {
+	ctx := context.Background()
+	dbClient := memory.New()
+	now := time.Now()
+	var ids []types.WorkflowID
+	for i := 0; i < 5; i++ {
+		wf := model.WorkflowRecord{
+			ID:        types.NewWorkflowID(),
+			CreatedAt: now.Add(-time.Duration(i) * time.Second),
+			Alert:     &model.AlertRecord{ID: types.NewAlertID()},
+		}
+		gt.NoError(t, dbClient.PutWorkflow(ctx, wf))
+		ids = append(ids, wf.ID)
+	}
+
+	srv := server.New(func(ctx context.Context, schema types.Schema, data any) ([]*model.Alert, error) {
+		return nil, nil
+	}, server.WithResolver(graphql.NewResolver(service.New(dbClient))))
+
+	type response struct {
+		Data struct {
+			Workflows *model.WorkflowConnection `json:"workflowConnection"`
+		} `json:"data"`
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	query := func(t *testing.T, args string) response {
+		var resp response
+		sendGraphQLRequest(t, srv, `query { workflowConnection(`+args+`) {
+			edges { cursor node { id } }
+			nodes { id }
+			pageInfo { hasNextPage hasPreviousPage startCursor endCursor }
+		} }`, &resp)
+		return resp
+	}
+
+	t.Run("paginate with cursor", func(t *testing.T) {
+		var got []types.WorkflowID
+		args := "first: 2"
+		for page := 0; ; page++ {
+			resp := query(t, args)
+			gt.A(t, resp.Errors).Length(0)
+			conn := resp.Data.Workflows
+			gt.V(t, conn.PageInfo.HasPreviousPage).Equal(page > 0)
+			gt.A(t, conn.Nodes).Length(len(conn.Edges))
+			for i, edge := range conn.Edges {
+				gt.V(t, edge.Node.ID).Equal(conn.Nodes[i].ID)
+				got = append(got, edge.Node.ID)
+			}
+			if !conn.PageInfo.HasNextPage {
+				break
+			}
+			gt.V(t, *conn.PageInfo.EndCursor).Equal(conn.Edges[len(conn.Edges)-1].Cursor)
+			args = `first: 2, after: "` + *conn.PageInfo.EndCursor + `"`
+		}
+		gt.V(t, got).Equal(ids)
+	})
+
+	t.Run("empty page", func(t *testing.T) {
+		resp := query(t, "first: 0")
+		gt.A(t, resp.Errors).Length(0)
+		gt.A(t, resp.Data.Workflows.Edges).Length(0)
+		gt.B(t, resp.Data.Workflows.PageInfo.HasNextPage).True()
+		gt.V(t, resp.Data.Workflows.PageInfo.EndCursor).Nil()
+	})
+
+	t.Run("invalid arguments", func(t *testing.T) {
+		for _, args := range []string{`after: "invalid"`, `first: -1`} {
+			resp := query(t, args)
+			gt.A(t, resp.Errors).Length(1)
+		}
+	})
+}
@@ -0,0 +1,170 @@
+package syslog
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	cefPrefix  = "CEF:"
+	leefPrefix = "LEEF:"
+)
+
+// CEF is ArcSight Common Event Format payload in syslog message.
+type CEF struct {
+	Version       string            `json:"version"`
+	DeviceVendor  string            `json:"device_vendor"`
+	DeviceProduct string            `json:"device_product"`
+	DeviceVersion string            `json:"device_version"`
+	SignatureID   string            `json:"signature_id"`
+	Name          string            `json:"name"`
+	Severity      string            `json:"severity"`
+	Extensions    map[string]string `json:"extensions,omitempty"`
+}
+
+// LEEF is IBM QRadar Log Event Extended Format payload in syslog message.
+type LEEF struct {
+	Version        string            `json:"version"`
+	Vendor         string            `json:"vendor"`
+	Product        string            `json:"product"`
+	ProductVersion string            `json:"product_version"`
+	EventID        string            `json:"event_id"`
+	Attributes     map[string]string `json:"attributes,omitempty"`
+}
+
+// ParsePayload parses CEF or LEEF payload in Message. The message is not changed if the payload is neither CEF nor LEEF, or malformed.
+func (x *Message) ParsePayload() {
+	payload := x.Message
+	switch {
+	case strings.HasPrefix(payload, cefPrefix):
+		if cef := parseCEF(payload); cef != nil {
+			x.CEF = cef
+		}
+	case strings.HasPrefix(payload, leefPrefix):
+		if leef := parseLEEF(payload); leef != nil {
+			x.LEEF = leef
+		}
+	}
+}
+
+// splitCEFHeader splits header by unescaped "|". The last element is the rest after n-1 separators.
+func splitCEFHeader(s string, n int) []string {
+	var fields []string
+	var field strings.Builder
+	for i := 0; i < len(s); i++ {
+		if len(fields) == n-1 {
+			return append(fields, s[i:])
+		}
+
+		c := s[i]
+		switch {
+		case c == '\\' && i+1 < len(s) && (s[i+1] == '|' || s[i+1] == '\\'):
+			field.WriteByte(s[i+1])
+			i++
+		case c == '|':
+			fields = append(fields, field.String())
+			field.Reset()
+		default:
+			field.WriteByte(c)
+		}
+	}
+	return append(fields, field.String())
+}
+
+func parseCEF(payload string) *CEF {
+	fields := splitCEFHeader(strings.TrimPrefix(payload, cefPrefix), 8)
+	if len(fields) < 7 {
+		return nil
+	}
+
+	cef := &CEF{
+		Version:       fields[0],
+		DeviceVendor:  fields[1],
+		DeviceProduct: fields[2],
+		DeviceVersion: fields[3],
+		SignatureID:   fields[4],
+		Name:          fields[5],
+		Severity:      fields[6],
+	}
+	if len(fields) == 8 {
+		cef.Extensions = parseCEFExtension(fields[7])
+	}
+	return cef
+}
+
+// cefExtKey matches key of CEF extension. A value can contain spaces, so the value continues until the next key.
+var cefExtKey = regexp.MustCompile(`(?:^|\s)([A-Za-z0-9_.\-\[\]]+)=`)
+
+var cefExtUnescaper = strings.NewReplacer(`\=`, `=`, `\\`, `\`, `\n`, "\n", `\r`, "\r")
+
+func parseCEFExtension(s string) map[string]string {
+	matches := cefExtKey.FindAllStringSubmatchIndex(s, -1)
+	if len(matches) == 0 {
+		return nil
+	}
+
+	ext := make(map[string]string, len(matches))
+	for i, m := range matches {
+		end := len(s)
+		if i+1 < len(matches) {
+			end = matches[i+1][0]
+		}
+		key := s[m[2]:m[3]]
+		ext[key] = cefExtUnescaper.Replace(strings.TrimSpace(s[m[1]:end]))
+	}
+	return ext
+}
+
+func parseLEEF(payload string) *LEEF {
+	// LEEF 1.0 has 5 header fields and LEEF 2.0 has the delimiter field additionally
+	fields := strings.SplitN(strings.TrimPrefix(payload, leefPrefix), "|", 7)
+	if len(fields) < 6 {
+		return nil
+	}
+
+	leef := &LEEF{
+		Version:        fields[0],
+		Vendor:         fields[1],
+		Product:        fields[2],
+		ProductVersion: fields[3],
+		EventID:        fields[4],
+	}
+
+	delimiter := "\t"
+	attrs := fields[5]
+	if strings.HasPrefix(leef.Version, "2") && len(fields) == 7 {
+		if d := parseLEEFDelimiter(fields[5]); d != "" {
+			delimiter = d
+		}
+		attrs = fields[6]
+	} else if len(fields) == 7 {
+		attrs = fields[5] + "|" + fields[6]
+	}
+
+	leef.Attributes = map[string]string{}
+	for _, attr := range strings.Split(attrs, delimiter) {
+		if key, value, found := strings.Cut(attr, "="); found && key != "" {
+			leef.Attributes[strings.TrimSpace(key)] = value
+		}
+	}
+
+	return leef
+}
+
+// parseLEEFDelimiter parses delimiter of LEEF 2.0. It is a character or hex like "x5E" or "0x5E".
+func parseLEEFDelimiter(s string) string {
+	if len(s) == 1 {
+		return s
+	}
+
+	hex, found := strings.CutPrefix(strings.TrimPrefix(s, "0"), "x")
+	if !found {
+		return ""
+	}
+	code, err := strconv.ParseUint(hex, 16, 8)
+	if err != nil {
+		return ""
+	}
+	return string(rune(code))
+}
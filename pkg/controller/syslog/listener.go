@@ -0,0 +1,525 @@
+package syslog
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/m-mizutani/goerr/v2"
+	"github.com/secmon-lab/alertchain/pkg/ctxutil"
+	"github.com/secmon-lab/alertchain/pkg/domain/interfaces"
+	"github.com/secmon-lab/alertchain/pkg/domain/model"
+	"github.com/secmon-lab/alertchain/pkg/domain/types"
+	"github.com/secmon-lab/alertchain/pkg/infra/metrics"
+	"github.com/secmon-lab/alertchain/pkg/infra/policy"
+	"github.com/secmon-lab/alertchain/pkg/logging"
+	"github.com/secmon-lab/alertchain/pkg/utils"
+)
+
+const (
+	ProtocolUDP = "udp"
+	ProtocolTCP = "tcp"
+
+	// maxMessageSize is the maximum size of UDP datagram. It is also applied to a TCP frame.
+	maxMessageSize = 64 * 1024
+	queueSize      = 1024
+)
+
+// Listener receives syslog messages over UDP and TCP and passes them to the alert handler.
+type Listener struct {
+	handler      interfaces.AlertHandler
+	udpAddr      string
+	tcpAddr      string
+	tlsConfig    *tls.Config
+	rules        []SchemaRule
+	parsePayload bool
+	authz        *policy.Client
+	env          interfaces.Env
+	decisionSink interfaces.DecisionLogSink
+	instrument   interfaces.Instrument
+	workers      int
+	gracePeriod  time.Duration
+
+	udpConn     net.PacketConn
+	tcpListener net.Listener
+}
+
+type Option func(x *Listener)
+
+// WithUDP receives messages over UDP at the address.
+func WithUDP(addr string) Option {
+	return func(x *Listener) {
+		x.udpAddr = addr
+	}
+}
+
+// WithTCP receives messages over TCP at the address. Both octet counting and LF delimited framing of RFC6587 are accepted.
+func WithTCP(addr string) Option {
+	return func(x *Listener) {
+		x.tcpAddr = addr
+	}
+}
+
+// WithTLSConfig receives TCP messages over TLS (RFC5425).
+func WithTLSConfig(tlsConfig *tls.Config) Option {
+	return func(x *Listener) {
+		x.tlsConfig = tlsConfig
+	}
+}
+
+// WithSchemaRule adds rules to decide schema of the message. The first matched rule is used.
+func WithSchemaRule(rules ...SchemaRule) Option {
+	return func(x *Listener) {
+		x.rules = append(x.rules, rules...)
+	}
+}
+
+// WithParsePayload parses CEF and LEEF payload in the message.
+func WithParsePayload() Option {
+	return func(x *Listener) {
+		x.parsePayload = true
+	}
+}
+
+// WithAuthzPolicy filters messages by authz.syslog policy. The policy can also choose schema of the message.
+func WithAuthzPolicy(authz *policy.Client) Option {
+	return func(x *Listener) {
+		x.authz = authz
+	}
+}
+
+func WithEnv(env interfaces.Env) Option {
+	return func(x *Listener) {
+		x.env = env
+	}
+}
+
+func WithDecisionLogSink(sink interfaces.DecisionLogSink) Option {
+	return func(x *Listener) {
+		x.decisionSink = sink
+	}
+}
+
+func WithInstrument(inst interfaces.Instrument) Option {
+	return func(x *Listener) {
+		x.instrument = inst
+	}
+}
+
+// WithWorkers sets number of goroutines that handle messages concurrently.
+func WithWorkers(n int) Option {
+	return func(x *Listener) {
+		x.workers = n
+	}
+}
+
+// WithGracePeriod sets duration to wait for queued messages on shutdown.
+func WithGracePeriod(d time.Duration) Option {
+	return func(x *Listener) {
+		x.gracePeriod = d
+	}
+}
+
+func New(hdlr interfaces.AlertHandler, options ...Option) *Listener {
+	x := &Listener{
+		handler:     hdlr,
+		env:         utils.Env,
+		instrument:  metrics.Nop{},
+		workers:     4,
+		gracePeriod: 8 * time.Second,
+	}
+	for _, opt := range options {
+		opt(x)
+	}
+	return x
+}
+
+// Listen binds the addresses. It is separated from Serve to fail before starting other servers.
+func (x *Listener) Listen() error {
+	if x.udpAddr != "" {
+		conn, err := net.ListenPacket("udp", x.udpAddr)
+		if err != nil {
+			return goerr.Wrap(err, "failed to listen syslog UDP", goerr.V("addr", x.udpAddr), goerr.T(types.ErrTagConfig))
+		}
+		x.udpConn = conn
+	}
+
+	if x.tcpAddr != "" {
+		listener, err := net.Listen("tcp", x.tcpAddr)
+		if err != nil {
+			x.Close()
+			return goerr.Wrap(err, "failed to listen syslog TCP", goerr.V("addr", x.tcpAddr), goerr.T(types.ErrTagConfig))
+		}
+		if x.tlsConfig != nil {
+			listener = tls.NewListener(listener, x.tlsConfig)
+		}
+		x.tcpListener = listener
+	}
+
+	return nil
+}
+
+// UDPAddr returns bound UDP address. It is nil if UDP is not enabled.
+func (x *Listener) UDPAddr() net.Addr {
+	if x.udpConn == nil {
+		return nil
+	}
+	return x.udpConn.LocalAddr()
+}
+
+// TCPAddr returns bound TCP address. It is nil if TCP is not enabled.
+func (x *Listener) TCPAddr() net.Addr {
+	if x.tcpListener == nil {
+		return nil
+	}
+	return x.tcpListener.Addr()
+}
+
+func (x *Listener) Close() {
+	if x.udpConn != nil {
+		_ = x.udpConn.Close()
+	}
+	if x.tcpListener != nil {
+		_ = x.tcpListener.Close()
+	}
+}
+
+type event struct {
+	raw        string
+	remote     net.Addr
+	protocol   string
+	receivedAt time.Time
+}
+
+// Serve handles received messages until ctx is canceled. Queued messages are handled within the grace period after that.
+func (x *Listener) Serve(ctx context.Context) error {
+	queue := make(chan *event, queueSize)
+
+	var readers sync.WaitGroup
+	conns := &connSet{conns: map[net.Conn]struct{}{}}
+	if x.udpConn != nil {
+		readers.Add(1)
+		go func() {
+			defer readers.Done()
+			x.readUDP(ctx, queue)
+		}()
+	}
+	if x.tcpListener != nil {
+		readers.Add(1)
+		go func() {
+			defer readers.Done()
+			x.acceptTCP(ctx, queue, conns, &readers)
+		}()
+	}
+
+	var workers sync.WaitGroup
+	for range x.workers {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for ev := range queue {
+				// Accepted message should be handled even while shutting down
+				x.handle(context.WithoutCancel(ctx), ev)
+			}
+		}()
+	}
+
+	<-ctx.Done()
+	ctxutil.Logger(ctx).Info("shutting down syslog listener", slog.Duration("grace_period", x.gracePeriod))
+	x.Close()
+	conns.closeAll()
+	readers.Wait()
+	close(queue)
+
+	done := make(chan struct{})
+	go func() {
+		workers.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(x.gracePeriod):
+		ctxutil.Logger(ctx).Warn("grace period expired before queued syslog messages are handled", slog.Int("queued", len(queue)))
+	}
+
+	return nil
+}
+
+func (x *Listener) readUDP(ctx context.Context, queue chan<- *event) {
+	buf := make([]byte, maxMessageSize)
+	for {
+		n, addr, err := x.udpConn.ReadFrom(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			utils.HandleError(ctx, goerr.Wrap(err, "failed to read syslog UDP message"))
+			continue
+		}
+
+		ev := &event{
+			raw:        string(buf[:n]),
+			remote:     addr,
+			protocol:   ProtocolUDP,
+			receivedAt: time.Now(),
+		}
+
+		// UDP has no backpressure, so the message is dropped instead of blocking the reader
+		select {
+		case queue <- ev:
+		default:
+			ctxutil.Logger(ctx).Warn("syslog queue is full, message is dropped", slog.String("remote", addr.String()))
+		}
+	}
+}
+
+type connSet struct {
+	mutex  sync.Mutex
+	conns  map[net.Conn]struct{}
+	closed bool
+}
+
+func (x *connSet) add(conn net.Conn) bool {
+	x.mutex.Lock()
+	defer x.mutex.Unlock()
+	if x.closed {
+		return false
+	}
+	x.conns[conn] = struct{}{}
+	return true
+}
+
+func (x *connSet) remove(conn net.Conn) {
+	x.mutex.Lock()
+	defer x.mutex.Unlock()
+	delete(x.conns, conn)
+}
+
+func (x *connSet) closeAll() {
+	x.mutex.Lock()
+	defer x.mutex.Unlock()
+	x.closed = true
+	for conn := range x.conns {
+		_ = conn.Close()
+	}
+}
+
+func (x *Listener) acceptTCP(ctx context.Context, queue chan<- *event, conns *connSet, readers *sync.WaitGroup) {
+	for {
+		conn, err := x.tcpListener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			utils.HandleError(ctx, goerr.Wrap(err, "failed to accept syslog TCP connection"))
+			continue
+		}
+
+		if !conns.add(conn) {
+			_ = conn.Close()
+			return
+		}
+
+		readers.Add(1)
+		go func() {
+			defer readers.Done()
+			defer conns.remove(conn)
+			defer utils.SafeClose(ctx, conn)
+			x.readTCP(ctx, conn, queue)
+		}()
+	}
+}
+
+func (x *Listener) readTCP(ctx context.Context, conn net.Conn, queue chan<- *event) {
+	r := bufio.NewReaderSize(conn, maxMessageSize)
+	for {
+		raw, err := readFrame(r)
+		if err != nil {
+			if !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) {
+				ctxutil.Logger(ctx).Warn("failed to read syslog TCP message, connection is closed",
+					slog.String("remote", conn.RemoteAddr().String()),
+					logging.ErrAttr(err),
+				)
+			}
+			return
+		}
+		if raw == "" {
+			continue
+		}
+
+		// TCP sender waits when the queue is full
+		queue <- &event{
+			raw:        raw,
+			remote:     conn.RemoteAddr(),
+			protocol:   ProtocolTCP,
+			receivedAt: time.Now(),
+		}
+	}
+}
+
+// readFrame reads a message framed by octet counting ("LEN MSG") or LF of RFC6587.
+func readFrame(r *bufio.Reader) (string, error) {
+	head, err := r.Peek(1)
+	if err != nil {
+		return "", err
+	}
+
+	if head[0] >= '1' && head[0] <= '9' {
+		length, err := r.ReadSlice(' ')
+		if err != nil {
+			return "", goerr.Wrap(err, "failed to read length of syslog frame")
+		}
+		n, err := strconv.Atoi(string(length[:len(length)-1]))
+		if err != nil || n > maxMessageSize {
+			return "", goerr.New("invalid length of syslog frame", goerr.V("length", string(length)), goerr.T(types.ErrTagBadRequest))
+		}
+
+		buf := make([]byte, n)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return "", goerr.Wrap(err, "failed to read syslog frame")
+		}
+		return string(buf), nil
+	}
+
+	line, err := r.ReadSlice('\n')
+	if err != nil {
+		if errors.Is(err, io.EOF) && len(line) > 0 {
+			return string(line), nil
+		}
+		if errors.Is(err, bufio.ErrBufferFull) {
+			return "", goerr.Wrap(err, "too large syslog message", goerr.T(types.ErrTagBadRequest))
+		}
+		return "", err
+	}
+	return string(line), nil
+}
+
+// AuthzInput is input of authz.syslog policy.
+type AuthzInput struct {
+	Remote   string        `json:"remote"`
+	RemoteIP string        `json:"remote_ip"`
+	Protocol string        `json:"protocol"`
+	Message  *Message      `json:"message"`
+	Env      types.EnvVars `json:"env" masq:"secret"`
+}
+
+// AuthzOutput is output of authz.syslog policy.
+type AuthzOutput struct {
+	Deny bool `json:"deny"`
+	// Schema overrides schema rules for the message.
+	Schema types.Schema `json:"schema,omitempty"`
+}
+
+func (x *Listener) handle(ctx context.Context, ev *event) {
+	logger := ctxutil.Logger(ctx).With(slog.String("remote", ev.remote.String()), slog.String("protocol", ev.protocol))
+
+	msg, err := Parse(ev.raw, ev.receivedAt)
+	if err != nil {
+		logger.Warn("failed to parse syslog message", logging.ErrAttr(err))
+		return
+	}
+	if x.parsePayload {
+		msg.ParsePayload()
+	}
+
+	var output AuthzOutput
+	if x.authz != nil {
+		input := &AuthzInput{
+			Remote:   ev.remote.String(),
+			RemoteIP: remoteIP(ev.remote),
+			Protocol: ev.protocol,
+			Message:  msg,
+			Env:      x.env(),
+		}
+		if err := x.authz.Query(ctx, input, &output, x.authzQueryOptions(ctx)...); err != nil && !errors.Is(err, types.ErrNoPolicyResult) {
+			utils.HandleError(ctx, goerr.Wrap(err, "failed to evaluate authz.syslog policy"))
+			return
+		}
+		if output.Deny {
+			x.instrument.AuthzDenied(ctx, "syslog")
+			logger.Debug("syslog message is denied by authz policy")
+			return
+		}
+	}
+
+	schema := output.Schema
+	if schema == "" {
+		for _, rule := range x.rules {
+			if rule.Match(msg) {
+				schema = rule.Schema
+				break
+			}
+		}
+	}
+	if schema == "" {
+		logger.Debug("no schema for syslog message, dropped", slog.String("app_name", msg.AppName))
+		return
+	}
+
+	// Alert policy receives the message as a plain JSON object in the same way as HTTP endpoints
+	raw, err := json.Marshal(msg)
+	if err != nil {
+		utils.HandleError(ctx, goerr.Wrap(err, "failed to marshal syslog message"))
+		return
+	}
+	var data any
+	if err := json.Unmarshal(raw, &data); err != nil {
+		utils.HandleError(ctx, goerr.Wrap(err, "failed to unmarshal syslog message"))
+		return
+	}
+
+	ctx = ctxutil.InjectEventMeta(ctx, &model.EventMeta{
+		Transport:  model.TransportSyslog,
+		Attributes: map[string]string{"protocol": ev.protocol},
+		ReceivedAt: ev.receivedAt,
+		RemoteAddr: ev.remote.String(),
+	})
+	x.instrument.EventReceived(ctx, model.TransportSyslog, schema)
+
+	if _, err := x.handler(ctx, schema, data); err != nil {
+		utils.HandleError(ctx, goerr.Wrap(err, "failed to handle syslog message", goerr.V("schema", schema)))
+	}
+}
+
+func (x *Listener) authzQueryOptions(ctx context.Context) []policy.QueryOption {
+	options := []policy.QueryOption{
+		policy.WithPackageSuffix("syslog"),
+		policy.WithInstrument(x.instrument),
+		policy.WithRegoPrint(func(file string, row int, msg string) error {
+			ctxutil.Logger(ctx).Info("rego print",
+				slog.String("file", file),
+				slog.Int("row", row),
+				slog.String("msg", msg),
+				slog.String("package", "authz.syslog"),
+			)
+			return nil
+		}),
+	}
+	if x.decisionSink != nil {
+		options = append(options, policy.WithDecisionLogSink(x.decisionSink))
+	}
+	return options
+}
+
+func remoteIP(addr net.Addr) string {
+	switch v := addr.(type) {
+	case *net.UDPAddr:
+		return v.IP.String()
+	case *net.TCPAddr:
+		return v.IP.String()
+	}
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return addr.String()
+	}
+	return host
+}
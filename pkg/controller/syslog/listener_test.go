@@ -0,0 +1,144 @@
+package syslog_test
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/m-mizutani/gt"
+	"github.com/secmon-lab/alertchain/pkg/controller/syslog"
+	"github.com/secmon-lab/alertchain/pkg/ctxutil"
+	"github.com/secmon-lab/alertchain/pkg/domain/model"
+	"github.com/secmon-lab/alertchain/pkg/domain/types"
+	"github.com/secmon-lab/alertchain/pkg/infra/policy"
+)
+
+type received struct {
+	schema types.Schema
+	data   map[string]any
+	meta   *model.EventMeta
+}
+
+func startListener(t *testing.T, options ...syslog.Option) (*syslog.Listener, chan *received) {
+	ch := make(chan *received, 16)
+	hdlr := func(ctx context.Context, schema types.Schema, data any) ([]*model.Alert, error) {
+		ch <- &received{
+			schema: schema,
+			data:   data.(map[string]any),
+			meta:   ctxutil.GetEventMeta(ctx),
+		}
+		return nil, nil
+	}
+
+	options = append(options, syslog.WithUDP("127.0.0.1:0"), syslog.WithTCP("127.0.0.1:0"))
+	listener := syslog.New(hdlr, options...)
+	gt.NoError(t, listener.Listen())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		gt.NoError(t, listener.Serve(ctx))
+	}()
+	t.Cleanup(func() {
+		cancel()
+		wg.Wait()
+	})
+
+	return listener, ch
+}
+
+func waitReceived(t *testing.T, ch chan *received) *received {
+	select {
+	case r := <-ch:
+		return r
+	case <-time.After(3 * time.Second):
+		t.Fatal("message is not received")
+		return nil
+	}
+}
+
+func TestListener(t *testing.T) {
+	authz := gt.R1(policy.New(
+		policy.WithPackage("authz"),
+		policy.WithPolicyData("syslog.rego", `package authz.syslog
+
+deny if input.message.hostname == "untrusted"
+
+schema := "firewall" if input.message.cef.device_vendor == "Palo Alto Networks"
+`),
+	)).NoError(t)
+
+	listener, ch := startListener(t,
+		syslog.WithAuthzPolicy(authz),
+		syslog.WithParsePayload(),
+		// Keep order of messages
+		syslog.WithWorkers(1),
+		syslog.WithSchemaRule(
+			gt.R1(syslog.ParseSchemaRule("app:sshd=linux_auth")).NoError(t),
+			gt.R1(syslog.ParseSchemaRule("facility:local7=network")).NoError(t),
+		),
+	)
+
+	t.Run("UDP", func(t *testing.T) {
+		conn := gt.R1(net.Dial("udp", listener.UDPAddr().String())).NoError(t)
+		defer conn.Close()
+
+		// Denied by authz policy and no matched schema are dropped
+		gt.R1(conn.Write([]byte("<38>Feb 29 22:14:15 untrusted sshd[1]: ignored"))).NoError(t)
+		gt.R1(conn.Write([]byte("<38>Feb 29 22:14:15 host cron[1]: ignored"))).NoError(t)
+		gt.R1(conn.Write([]byte("<38>Feb 29 22:14:15 host sshd[1]: Failed password for root"))).NoError(t)
+
+		r := waitReceived(t, ch)
+		gt.V(t, r.schema).Equal("linux_auth")
+		gt.V(t, r.data["app_name"]).Equal("sshd")
+		gt.V(t, r.data["message"]).Equal("Failed password for root")
+		gt.V(t, r.meta.Transport).Equal(model.TransportSyslog)
+		gt.V(t, r.meta.Attributes["protocol"]).Equal(syslog.ProtocolUDP)
+		gt.S(t, r.meta.RemoteAddr).Contains("127.0.0.1:")
+	})
+
+	t.Run("TCP with octet counting and LF framing", func(t *testing.T) {
+		conn := gt.R1(net.Dial("tcp", listener.TCPAddr().String())).NoError(t)
+		defer conn.Close()
+
+		cef := "<190>1 2024-02-29T22:14:15Z pa01 - - - - CEF:0|Palo Alto Networks|PAN-OS|10.0|threat|virus|8|src=192.0.2.1"
+		gt.R1(fmt.Fprintf(conn, "%d %s", len(cef), cef)).NoError(t)
+		gt.R1(fmt.Fprint(conn, "<190>1 2024-02-29T22:14:15Z sw01 - - - - port down\n")).NoError(t)
+
+		r := waitReceived(t, ch)
+		gt.V(t, r.schema).Equal("firewall")
+		cefData := r.data["cef"].(map[string]any)
+		gt.V(t, cefData["name"]).Equal("virus")
+		gt.V(t, r.meta.Attributes["protocol"]).Equal(syslog.ProtocolTCP)
+
+		r = waitReceived(t, ch)
+		gt.V(t, r.schema).Equal("network")
+		gt.V(t, r.data["hostname"]).Equal("sw01")
+		gt.V(t, r.data["message"]).Equal("port down")
+	})
+
+	select {
+	case r := <-ch:
+		t.Errorf("unexpected message: %+v", r.data)
+	default:
+	}
+}
+
+func TestParseSchemaRule(t *testing.T) {
+	rule := gt.R1(syslog.ParseSchemaRule("*=default")).NoError(t)
+	gt.V(t, rule).Equal(syslog.SchemaRule{Schema: "default"})
+
+	rule = gt.R1(syslog.ParseSchemaRule("facility:4=auth")).NoError(t)
+	gt.B(t, rule.Match(&syslog.Message{Facility: 4, FacilityName: "auth"})).True()
+	gt.B(t, rule.Match(&syslog.Message{Facility: 1, FacilityName: "user"})).False()
+
+	for _, s := range []string{"app=x", "unknown:x=y", "app:sshd=", "=schema"} {
+		_, err := syslog.ParseSchemaRule(s)
+		gt.Error(t, err)
+	}
+}
@@ -0,0 +1,270 @@
+package syslog
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/m-mizutani/goerr/v2"
+	"github.com/secmon-lab/alertchain/pkg/domain/types"
+)
+
+const (
+	FormatRFC3164 = "rfc3164"
+	FormatRFC5424 = "rfc5424"
+
+	// defaultPriority is user.notice. RFC3164 says a relay must use it for a message without PRI.
+	defaultPriority = 13
+)
+
+var facilityNames = []string{
+	"kern", "user", "mail", "daemon", "auth", "syslog", "lpr", "news",
+	"uucp", "cron", "authpriv", "ftp", "ntp", "security", "console", "solaris-cron",
+	"local0", "local1", "local2", "local3", "local4", "local5", "local6", "local7",
+}
+
+var severityNames = []string{
+	"emerg", "alert", "crit", "err", "warning", "notice", "info", "debug",
+}
+
+// Message is a parsed syslog message. It is passed to alert policy as input in JSON form.
+type Message struct {
+	Format       string `json:"format"`
+	Priority     int    `json:"priority"`
+	Facility     int    `json:"facility"`
+	FacilityName string `json:"facility_name"`
+	Severity     int    `json:"severity"`
+	SeverityName string `json:"severity_name"`
+	// Timestamp is in RFC3339. Year of RFC3164 timestamp is complemented by the received time.
+	Timestamp string `json:"timestamp,omitempty"`
+	Hostname  string `json:"hostname,omitempty"`
+	// AppName is APP-NAME of RFC5424 or TAG of RFC3164.
+	AppName        string                       `json:"app_name,omitempty"`
+	ProcID         string                       `json:"proc_id,omitempty"`
+	MsgID          string                       `json:"msg_id,omitempty"`
+	StructuredData map[string]map[string]string `json:"structured_data,omitempty"`
+	Message        string                       `json:"message"`
+
+	CEF  *CEF  `json:"cef,omitempty"`
+	LEEF *LEEF `json:"leef,omitempty"`
+}
+
+// Parse parses RFC5424 or RFC3164 message. RFC3164 is parsed leniently because many devices do not follow it strictly, and the part that can not be parsed is kept in Message.
+func Parse(raw string, now time.Time) (*Message, error) {
+	raw = strings.TrimRight(raw, "\r\n\x00")
+
+	msg := &Message{Format: FormatRFC3164}
+	pri, rest, err := parsePriority(raw)
+	if err != nil {
+		return nil, err
+	}
+	msg.setPriority(pri)
+
+	if strings.HasPrefix(rest, "1 ") {
+		msg.Format = FormatRFC5424
+		parseRFC5424(msg, rest[2:])
+	} else {
+		parseRFC3164(msg, rest, now)
+	}
+
+	return msg, nil
+}
+
+func (x *Message) setPriority(pri int) {
+	x.Priority = pri
+	x.Facility = pri / 8
+	x.Severity = pri % 8
+	if x.Facility < len(facilityNames) {
+		x.FacilityName = facilityNames[x.Facility]
+	}
+	x.SeverityName = severityNames[x.Severity]
+}
+
+func parsePriority(raw string) (int, string, error) {
+	if !strings.HasPrefix(raw, "<") {
+		return defaultPriority, raw, nil
+	}
+
+	end := strings.IndexByte(raw, '>')
+	if end < 2 || end > 4 {
+		return 0, "", goerr.New("invalid PRI of syslog message", goerr.V("raw", truncate(raw)), goerr.T(types.ErrTagBadRequest))
+	}
+
+	pri, err := strconv.Atoi(raw[1:end])
+	if err != nil || pri < 0 || pri > 191 {
+		return 0, "", goerr.New("invalid PRI of syslog message", goerr.V("raw", truncate(raw)), goerr.T(types.ErrTagBadRequest))
+	}
+
+	return pri, raw[end+1:], nil
+}
+
+func truncate(s string) string {
+	if len(s) > 64 {
+		return s[:64]
+	}
+	return s
+}
+
+// nextField returns a space separated field and the rest.
+func nextField(s string) (string, string) {
+	field, rest, _ := strings.Cut(s, " ")
+	return field, rest
+}
+
+// nilValue converts NILVALUE "-" of RFC5424 to empty.
+func nilValue(s string) string {
+	if s == "-" {
+		return ""
+	}
+	return s
+}
+
+func parseRFC5424(msg *Message, s string) {
+	var timestamp, hostname, appName, procID, msgID string
+	timestamp, s = nextField(s)
+	hostname, s = nextField(s)
+	appName, s = nextField(s)
+	procID, s = nextField(s)
+	msgID, s = nextField(s)
+
+	if ts, err := time.Parse(time.RFC3339Nano, timestamp); err == nil {
+		msg.Timestamp = ts.Format(time.RFC3339Nano)
+	}
+	msg.Hostname = nilValue(hostname)
+	msg.AppName = nilValue(appName)
+	msg.ProcID = nilValue(procID)
+	msg.MsgID = nilValue(msgID)
+
+	sd, rest, ok := parseStructuredData(s)
+	if !ok {
+		// Keep the broken structured data in message not to lose it
+		msg.Message = s
+		return
+	}
+	msg.StructuredData = sd
+
+	rest = strings.TrimPrefix(rest, " ")
+	msg.Message = strings.TrimPrefix(rest, "\ufeff")
+}
+
+// parseStructuredData parses STRUCTURED-DATA of RFC5424 and returns the rest. ok is false if it is malformed.
+func parseStructuredData(s string) (map[string]map[string]string, string, bool) {
+	if strings.HasPrefix(s, "-") {
+		return nil, s[1:], true
+	}
+
+	sd := map[string]map[string]string{}
+	for strings.HasPrefix(s, "[") {
+		end := strings.IndexAny(s, " ]")
+		if end < 0 {
+			return nil, "", false
+		}
+		id := s[1:end]
+		params := map[string]string{}
+		s = s[end:]
+
+		for {
+			s = strings.TrimLeft(s, " ")
+			if strings.HasPrefix(s, "]") {
+				s = s[1:]
+				break
+			}
+
+			name, rest, found := strings.Cut(s, "=\"")
+			if !found || name == "" || strings.ContainsAny(name, " ]") {
+				return nil, "", false
+			}
+
+			var value strings.Builder
+			closed := false
+			for i := 0; i < len(rest); i++ {
+				c := rest[i]
+				if c == '\\' && i+1 < len(rest) && strings.IndexByte(`"\]`, rest[i+1]) >= 0 {
+					value.WriteByte(rest[i+1])
+					i++
+					continue
+				}
+				if c == '"' {
+					s = rest[i+1:]
+					closed = true
+					break
+				}
+				value.WriteByte(c)
+			}
+			if !closed {
+				return nil, "", false
+			}
+			params[name] = value.String()
+		}
+
+		sd[id] = params
+	}
+
+	if len(sd) == 0 {
+		return nil, "", false
+	}
+	return sd, s, true
+}
+
+func parseRFC3164(msg *Message, s string, now time.Time) {
+	if ts, rest, ok := parseRFC3164Timestamp(s, now); ok {
+		msg.Timestamp = ts.Format(time.RFC3339Nano)
+		s = rest
+
+		// HOSTNAME is often omitted by local senders, e.g. "sshd[123]: ..."
+		if field, rest := nextField(s); field != "" && rest != "" && !isTag(field) && !isEventPayload(field) {
+			msg.Hostname = field
+			s = rest
+		}
+	}
+
+	if field, rest := nextField(s); isTag(field) {
+		tag := strings.TrimSuffix(field, ":")
+		if name, pid, found := strings.Cut(tag, "["); found {
+			msg.AppName = name
+			msg.ProcID = strings.TrimSuffix(pid, "]")
+		} else {
+			msg.AppName = tag
+		}
+		s = rest
+	}
+
+	msg.Message = s
+}
+
+// parseRFC3164Timestamp parses "Mmm dd hh:mm:ss" of RFC3164. RFC3339 timestamp is also accepted because some senders use it in RFC3164 format.
+func parseRFC3164Timestamp(s string, now time.Time) (time.Time, string, bool) {
+	if len(s) >= len(time.Stamp) {
+		if ts, err := time.Parse(time.Stamp, s[:len(time.Stamp)]); err == nil {
+			ts = ts.AddDate(now.Year(), 0, 0)
+			// The message sent at the end of the year may be received in the next year
+			if ts.After(now.Add(24 * time.Hour)) {
+				ts = ts.AddDate(-1, 0, 0)
+			}
+			return ts, strings.TrimPrefix(s[len(time.Stamp):], " "), true
+		}
+	}
+
+	field, rest := nextField(s)
+	if ts, err := time.Parse(time.RFC3339Nano, field); err == nil {
+		return ts, rest, true
+	}
+
+	return time.Time{}, s, false
+}
+
+// isTag returns true if the field is TAG of RFC3164, e.g. "sshd:" or "sshd[123]:".
+func isTag(field string) bool {
+	if !strings.HasSuffix(field, ":") || len(field) < 2 || isEventPayload(field) {
+		return false
+	}
+	tag := strings.TrimSuffix(field, ":")
+	if i := strings.IndexByte(tag, '['); i >= 0 {
+		return i > 0 && strings.HasSuffix(tag, "]") && !strings.ContainsAny(tag[:i], ":[]")
+	}
+	return !strings.ContainsAny(tag, ":[]")
+}
+
+func isEventPayload(field string) bool {
+	return strings.HasPrefix(field, cefPrefix) || strings.HasPrefix(field, leefPrefix)
+}
@@ -0,0 +1,177 @@
+package syslog_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/m-mizutani/gt"
+	"github.com/secmon-lab/alertchain/pkg/controller/syslog"
+)
+
+func TestParse(t *testing.T) {
+	now := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	testCases := map[string]struct {
+		raw    string
+		expect syslog.Message
+	}{
+		"RFC5424 with structured data": {
+			raw: `<165>1 2024-02-29T22:14:15.003Z mymachine.example.com evntslog - ID47 [exampleSDID@32473 iut="3" eventSource="App\"lication"][origin ip="192.0.2.1"] ` + "\ufeff" + `An application event`,
+			expect: syslog.Message{
+				Format:       syslog.FormatRFC5424,
+				Priority:     165,
+				Facility:     20,
+				FacilityName: "local4",
+				Severity:     5,
+				SeverityName: "notice",
+				Timestamp:    "2024-02-29T22:14:15.003Z",
+				Hostname:     "mymachine.example.com",
+				AppName:      "evntslog",
+				MsgID:        "ID47",
+				StructuredData: map[string]map[string]string{
+					"exampleSDID@32473": {"iut": "3", "eventSource": `App"lication`},
+					"origin":            {"ip": "192.0.2.1"},
+				},
+				Message: "An application event",
+			},
+		},
+		"RFC5424 without structured data": {
+			raw: "<34>1 2024-02-29T22:14:15Z host su 123 - - 'su root' failed\n",
+			expect: syslog.Message{
+				Format:       syslog.FormatRFC5424,
+				Priority:     34,
+				Facility:     4,
+				FacilityName: "auth",
+				Severity:     2,
+				SeverityName: "crit",
+				Timestamp:    "2024-02-29T22:14:15Z",
+				Hostname:     "host",
+				AppName:      "su",
+				ProcID:       "123",
+				Message:      "'su root' failed",
+			},
+		},
+		"RFC3164": {
+			raw: "<38>Feb 29 22:14:15 fw01 sshd[4123]: Failed password for root",
+			expect: syslog.Message{
+				Format:       syslog.FormatRFC3164,
+				Priority:     38,
+				Facility:     4,
+				FacilityName: "auth",
+				Severity:     6,
+				SeverityName: "info",
+				Timestamp:    "2024-02-29T22:14:15Z",
+				Hostname:     "fw01",
+				AppName:      "sshd",
+				ProcID:       "4123",
+				Message:      "Failed password for root",
+			},
+		},
+		"RFC3164 without hostname": {
+			raw: "<13>Feb  3 01:02:03 kernel: link down",
+			expect: syslog.Message{
+				Format:       syslog.FormatRFC3164,
+				Priority:     13,
+				Facility:     1,
+				FacilityName: "user",
+				Severity:     5,
+				SeverityName: "notice",
+				Timestamp:    "2024-02-03T01:02:03Z",
+				AppName:      "kernel",
+				Message:      "link down",
+			},
+		},
+		"RFC3164 sent in the last year": {
+			raw: "<13>Dec 31 23:59:59 host app: bye",
+			expect: syslog.Message{
+				Format:       syslog.FormatRFC3164,
+				Priority:     13,
+				Facility:     1,
+				FacilityName: "user",
+				Severity:     5,
+				SeverityName: "notice",
+				Timestamp:    "2023-12-31T23:59:59Z",
+				Hostname:     "host",
+				AppName:      "app",
+				Message:      "bye",
+			},
+		},
+		"no PRI and timestamp": {
+			raw: "something happened",
+			expect: syslog.Message{
+				Format:       syslog.FormatRFC3164,
+				Priority:     13,
+				Facility:     1,
+				FacilityName: "user",
+				Severity:     5,
+				SeverityName: "notice",
+				Message:      "something happened",
+			},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			msg := gt.R1(syslog.Parse(tc.raw, now)).NoError(t)
+			gt.V(t, *msg).Equal(tc.expect)
+		})
+	}
+
+	t.Run("invalid PRI", func(t *testing.T) {
+		_, err := syslog.Parse("<192>Feb 29 22:14:15 host app: msg", now)
+		gt.Error(t, err)
+	})
+}
+
+func TestParsePayload(t *testing.T) {
+	now := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	t.Run("CEF", func(t *testing.T) {
+		msg := gt.R1(syslog.Parse(`<134>Feb 29 22:14:15 host CEF:0|Security|threat\|manager|1.0|100|worm successfully stopped|10|src=10.0.0.1 dst=2.1.2.2 msg=Detected a threat. No action needed cs1Label=path\=x cs1=C:\\Windows`, now)).NoError(t)
+		msg.ParsePayload()
+
+		gt.V(t, msg.Hostname).Equal("host")
+		gt.V(t, msg.CEF).NotNil()
+		gt.V(t, *msg.CEF).Equal(syslog.CEF{
+			Version:       "0",
+			DeviceVendor:  "Security",
+			DeviceProduct: "threat|manager",
+			DeviceVersion: "1.0",
+			SignatureID:   "100",
+			Name:          "worm successfully stopped",
+			Severity:      "10",
+			Extensions: map[string]string{
+				"src":      "10.0.0.1",
+				"dst":      "2.1.2.2",
+				"msg":      "Detected a threat. No action needed",
+				"cs1Label": "path=x",
+				"cs1":      `C:\Windows`,
+			},
+		})
+	})
+
+	t.Run("LEEF 1.0", func(t *testing.T) {
+		msg := gt.R1(syslog.Parse("<134>Feb 29 22:14:15 host LEEF:1.0|Microsoft|MSExchange|4.0 SP1|15345|src=192.0.2.1\tdst=172.50.123.1\tusrName=joe", now)).NoError(t)
+		msg.ParsePayload()
+
+		gt.V(t, msg.LEEF).NotNil()
+		gt.V(t, msg.LEEF.Vendor).Equal("Microsoft")
+		gt.V(t, msg.LEEF.EventID).Equal("15345")
+		gt.V(t, msg.LEEF.Attributes).Equal(map[string]string{"src": "192.0.2.1", "dst": "172.50.123.1", "usrName": "joe"})
+	})
+
+	t.Run("LEEF 2.0 with delimiter", func(t *testing.T) {
+		msg := gt.R1(syslog.Parse("<134>Feb 29 22:14:15 host LEEF:2.0|Lancope|StealthWatch|1.0|41|x5E|src=10.0.1.8^dst=10.0.0.5", now)).NoError(t)
+		msg.ParsePayload()
+
+		gt.V(t, msg.LEEF).NotNil()
+		gt.V(t, msg.LEEF.Attributes).Equal(map[string]string{"src": "10.0.1.8", "dst": "10.0.0.5"})
+	})
+
+	t.Run("not CEF", func(t *testing.T) {
+		msg := gt.R1(syslog.Parse("<134>Feb 29 22:14:15 host app: CEF is not here", now)).NoError(t)
+		msg.ParsePayload()
+		gt.V(t, msg.CEF).Nil()
+		gt.V(t, msg.LEEF).Nil()
+	})
+}
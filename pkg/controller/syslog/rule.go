@@ -0,0 +1,74 @@
+package syslog
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/m-mizutani/goerr/v2"
+	"github.com/secmon-lab/alertchain/pkg/domain/types"
+)
+
+const (
+	RuleFieldApp      = "app"
+	RuleFieldFacility = "facility"
+	RuleFieldHostname = "hostname"
+	RuleFieldVendor   = "vendor"
+)
+
+// SchemaRule maps syslog message to schema of alert policy. Empty Field matches any message.
+type SchemaRule struct {
+	Field  string
+	Value  string
+	Schema types.Schema
+}
+
+// ParseSchemaRule parses rule in "FIELD:VALUE=SCHEMA" or "*=SCHEMA" format. FIELD is app, facility, hostname or vendor (device vendor of CEF or vendor of LEEF).
+func ParseSchemaRule(s string) (SchemaRule, error) {
+	cond, schema, found := cutLast(s, "=")
+	if !found || cond == "" || schema == "" {
+		return SchemaRule{}, goerr.New("invalid syslog schema rule, expected 'FIELD:VALUE=SCHEMA' or '*=SCHEMA'", goerr.V("rule", s), goerr.T(types.ErrTagConfig))
+	}
+
+	if cond == "*" {
+		return SchemaRule{Schema: types.Schema(schema)}, nil
+	}
+
+	field, value, found := strings.Cut(cond, ":")
+	if !found || value == "" {
+		return SchemaRule{}, goerr.New("invalid syslog schema rule, expected 'FIELD:VALUE=SCHEMA' or '*=SCHEMA'", goerr.V("rule", s), goerr.T(types.ErrTagConfig))
+	}
+
+	switch field {
+	case RuleFieldApp, RuleFieldFacility, RuleFieldHostname, RuleFieldVendor:
+	default:
+		return SchemaRule{}, goerr.New("unsupported field of syslog schema rule", goerr.V("field", field), goerr.T(types.ErrTagConfig))
+	}
+
+	return SchemaRule{Field: field, Value: value, Schema: types.Schema(schema)}, nil
+}
+
+func cutLast(s, sep string) (string, string, bool) {
+	i := strings.LastIndex(s, sep)
+	if i < 0 {
+		return s, "", false
+	}
+	return s[:i], s[i+len(sep):], true
+}
+
+// Match returns true if the message has the value in the field. Facility matches with both name (e.g. "auth") and number.
+func (x SchemaRule) Match(msg *Message) bool {
+	switch x.Field {
+	case "":
+		return true
+	case RuleFieldApp:
+		return msg.AppName == x.Value
+	case RuleFieldFacility:
+		return msg.FacilityName == x.Value || strconv.Itoa(msg.Facility) == x.Value
+	case RuleFieldHostname:
+		return msg.Hostname == x.Value
+	case RuleFieldVendor:
+		return (msg.CEF != nil && msg.CEF.DeviceVendor == x.Value) ||
+			(msg.LEEF != nil && msg.LEEF.Vendor == x.Value)
+	}
+	return false
+}
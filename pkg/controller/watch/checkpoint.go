@@ -0,0 +1,60 @@
+package watch
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+
+	"github.com/m-mizutani/goerr/v2"
+	"github.com/secmon-lab/alertchain/pkg/domain/types"
+)
+
+// Position is read position of a file. Path and Schema are the last seen ones, and they are used to read the rest of the file after rotation.
+type Position struct {
+	Path   string       `json:"path"`
+	Schema types.Schema `json:"schema"`
+	Offset int64        `json:"offset"`
+}
+
+// Checkpoint is read positions of files by key of fileKey. It is saved on disk to resume after restart.
+type Checkpoint struct {
+	Files map[string]*Position `json:"files"`
+}
+
+func loadCheckpoint(path string) (*Checkpoint, error) {
+	cp := &Checkpoint{Files: map[string]*Position{}}
+
+	raw, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return cp, nil
+		}
+		return nil, goerr.Wrap(err, "failed to read checkpoint", goerr.V("path", path), goerr.T(types.ErrTagSystem))
+	}
+
+	if err := json.Unmarshal(raw, cp); err != nil {
+		return nil, goerr.Wrap(err, "failed to parse checkpoint", goerr.V("path", path), goerr.T(types.ErrTagConfig))
+	}
+	if cp.Files == nil {
+		cp.Files = map[string]*Position{}
+	}
+	return cp, nil
+}
+
+// save writes the checkpoint atomically by renaming a temporary file not to break it by crash.
+func (x *Checkpoint) save(path string) error {
+	raw, err := json.MarshalIndent(x, "", "  ")
+	if err != nil {
+		return goerr.Wrap(err, "failed to marshal checkpoint")
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, raw, 0600); err != nil {
+		return goerr.Wrap(err, "failed to write checkpoint", goerr.V("path", tmp), goerr.T(types.ErrTagSystem))
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return goerr.Wrap(err, "failed to replace checkpoint", goerr.V("path", path), goerr.T(types.ErrTagSystem))
+	}
+	return nil
+}
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package watch
+
+import "os"
+
+// fileKey identifies the file by path because inode is not available on the platform. Rotation is detected only by truncation.
+func fileKey(path string, _ os.FileInfo) string {
+	return "path:" + path
+}
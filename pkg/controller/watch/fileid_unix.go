@@ -0,0 +1,18 @@
+//go:build unix
+
+package watch
+
+import (
+	"os"
+	"strconv"
+	"syscall"
+)
+
+// fileKey identifies the file by device and inode, so that read position follows the file renamed by rotation.
+func fileKey(path string, info os.FileInfo) string {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return "path:" + path
+	}
+	return "inode:" + strconv.FormatUint(uint64(stat.Dev), 10) + ":" + strconv.FormatUint(stat.Ino, 10)
+}
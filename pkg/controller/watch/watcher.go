@@ -0,0 +1,355 @@
+package watch
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/m-mizutani/goerr/v2"
+	"github.com/secmon-lab/alertchain/pkg/ctxutil"
+	"github.com/secmon-lab/alertchain/pkg/domain/interfaces"
+	"github.com/secmon-lab/alertchain/pkg/domain/model"
+	"github.com/secmon-lab/alertchain/pkg/domain/types"
+	"github.com/secmon-lab/alertchain/pkg/infra/metrics"
+	"github.com/secmon-lab/alertchain/pkg/logging"
+	"github.com/secmon-lab/alertchain/pkg/utils"
+)
+
+// Rule maps files matched with Pattern to Schema. Pattern is a glob relative to the watched directory, e.g. "falco/*.json".
+type Rule struct {
+	Pattern string
+	Schema  types.Schema
+}
+
+// ParseRule parses rule in "GLOB=SCHEMA" format.
+func ParseRule(s string) (Rule, error) {
+	i := strings.LastIndex(s, "=")
+	if i <= 0 || i == len(s)-1 {
+		return Rule{}, goerr.New("invalid watch pattern, expected 'GLOB=SCHEMA'", goerr.V("pattern", s), goerr.T(types.ErrTagConfig))
+	}
+
+	rule := Rule{Pattern: s[:i], Schema: types.Schema(s[i+1:])}
+	if _, err := filepath.Match(rule.Pattern, ""); err != nil {
+		return Rule{}, goerr.Wrap(err, "invalid glob of watch pattern", goerr.V("pattern", s), goerr.T(types.ErrTagConfig))
+	}
+	return rule, nil
+}
+
+// Watcher tails JSON files in a directory and passes each event to the alert handler. Read positions are saved in the checkpoint file.
+type Watcher struct {
+	handler        interfaces.AlertHandler
+	dir            string
+	rules          []Rule
+	checkpointPath string
+	interval       time.Duration
+	startAtEnd     bool
+	instrument     interfaces.Instrument
+
+	cp          *Checkpoint
+	dirty       bool
+	initialized bool
+}
+
+type Option func(x *Watcher)
+
+// WithRule adds rules of file pattern and schema. The first matched rule is used for a file.
+func WithRule(rules ...Rule) Option {
+	return func(x *Watcher) {
+		x.rules = append(x.rules, rules...)
+	}
+}
+
+func WithCheckpoint(path string) Option {
+	return func(x *Watcher) {
+		x.checkpointPath = path
+	}
+}
+
+// WithInterval sets interval to check the files.
+func WithInterval(d time.Duration) Option {
+	return func(x *Watcher) {
+		x.interval = d
+	}
+}
+
+// WithStartAtEnd skips existing content of files found at the first check without checkpoint.
+func WithStartAtEnd() Option {
+	return func(x *Watcher) {
+		x.startAtEnd = true
+	}
+}
+
+func WithInstrument(inst interfaces.Instrument) Option {
+	return func(x *Watcher) {
+		x.instrument = inst
+	}
+}
+
+func New(hdlr interfaces.AlertHandler, dir string, options ...Option) *Watcher {
+	x := &Watcher{
+		handler:        hdlr,
+		dir:            dir,
+		checkpointPath: "alertchain-checkpoint.json",
+		interval:       time.Second,
+		instrument:     metrics.Nop{},
+	}
+	for _, opt := range options {
+		opt(x)
+	}
+	return x
+}
+
+// Run checks the files every interval until ctx is canceled.
+func (x *Watcher) Run(ctx context.Context) error {
+	ticker := time.NewTicker(x.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := x.Poll(ctx); err != nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+type watchedFile struct {
+	path   string
+	schema types.Schema
+	info   os.FileInfo
+}
+
+func (x *Watcher) match() ([]*watchedFile, error) {
+	checkpoint, err := filepath.Abs(x.checkpointPath)
+	if err != nil {
+		return nil, goerr.Wrap(err, "failed to get absolute path of checkpoint", goerr.V("path", x.checkpointPath))
+	}
+
+	seen := map[string]bool{}
+	var files []*watchedFile
+	for _, rule := range x.rules {
+		paths, err := filepath.Glob(filepath.Join(x.dir, rule.Pattern))
+		if err != nil {
+			return nil, goerr.Wrap(err, "failed to match files", goerr.V("pattern", rule.Pattern))
+		}
+
+		for _, path := range paths {
+			if seen[path] {
+				continue
+			}
+			seen[path] = true
+
+			if abs, err := filepath.Abs(path); err == nil && (abs == checkpoint || abs == checkpoint+".tmp") {
+				continue
+			}
+
+			info, err := os.Stat(path)
+			if err != nil || !info.Mode().IsRegular() {
+				continue
+			}
+			files = append(files, &watchedFile{path: path, schema: rule.Schema, info: info})
+		}
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].path < files[j].path })
+	return files, nil
+}
+
+// Poll reads appended events of all matched files once and saves the checkpoint.
+func (x *Watcher) Poll(ctx context.Context) error {
+	if x.cp == nil {
+		cp, err := loadCheckpoint(x.checkpointPath)
+		if err != nil {
+			return err
+		}
+		x.cp = cp
+	}
+
+	files, err := x.match()
+	if err != nil {
+		return err
+	}
+
+	active := map[string]*watchedFile{}
+	for _, f := range files {
+		active[fileKey(f.path, f.info)] = f
+	}
+
+	// Files rotated out of the patterns are read to the end before new files to keep order of events
+	for key, pos := range x.cp.Files {
+		if _, ok := active[key]; ok {
+			continue
+		}
+		if path := findFile(filepath.Dir(pos.Path), key); path != "" {
+			ctxutil.Logger(ctx).Info("reading rest of rotated file", slog.String("path", path), slog.String("last_path", pos.Path))
+			x.read(ctx, path, pos)
+			if ctx.Err() != nil {
+				// Keep the position to read the rest after restart
+				break
+			}
+		}
+		delete(x.cp.Files, key)
+		x.dirty = true
+	}
+
+	for _, f := range files {
+		key := fileKey(f.path, f.info)
+		pos, ok := x.cp.Files[key]
+		if !ok {
+			pos = &Position{Path: f.path, Schema: f.schema}
+			if !x.initialized && x.startAtEnd {
+				pos.Offset = f.info.Size()
+			}
+			x.cp.Files[key] = pos
+			x.dirty = true
+		}
+		if pos.Path != f.path || pos.Schema != f.schema {
+			pos.Path, pos.Schema = f.path, f.schema
+			x.dirty = true
+		}
+
+		if f.info.Size() < pos.Offset {
+			ctxutil.Logger(ctx).Warn("file is truncated, read from the beginning", slog.String("path", f.path))
+			pos.Offset = 0
+			x.dirty = true
+		}
+		if f.info.Size() > pos.Offset {
+			x.read(ctx, f.path, pos)
+		}
+	}
+	x.initialized = true
+
+	if x.dirty {
+		if err := x.cp.save(x.checkpointPath); err != nil {
+			return err
+		}
+		x.dirty = false
+	}
+	return nil
+}
+
+// findFile looks for the file that has the key in the directory, e.g. "alerts.json.1" renamed from "alerts.json".
+func findFile(dir, key string) string {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return ""
+	}
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		info, err := os.Stat(path)
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		if fileKey(path, info) == key {
+			return path
+		}
+	}
+	return ""
+}
+
+// read decodes JSON values from the offset and updates it. NDJSON and concatenated JSON are accepted, and a JSON array is handled as multiple events. An incomplete value at the end of the file is read in the next check.
+func (x *Watcher) read(ctx context.Context, path string, pos *Position) {
+	logger := ctxutil.Logger(ctx).With(slog.String("path", path))
+
+	f, err := os.Open(filepath.Clean(path))
+	if err != nil {
+		logger.Warn("failed to open file", logging.ErrAttr(err))
+		return
+	}
+	defer utils.SafeClose(ctx, f)
+
+	for ctx.Err() == nil {
+		if _, err := f.Seek(pos.Offset, io.SeekStart); err != nil {
+			logger.Warn("failed to seek file", logging.ErrAttr(err))
+			return
+		}
+		base := pos.Offset
+		decoder := json.NewDecoder(f)
+
+		for ctx.Err() == nil {
+			var data any
+			err := decoder.Decode(&data)
+			if errors.Is(err, io.EOF) {
+				// Trailing spaces, e.g. the last newline of NDJSON, are consumed not to open the file again
+				rest, _ := io.Copy(io.Discard, decoder.Buffered())
+				if end := base + decoder.InputOffset() + rest; end != pos.Offset {
+					pos.Offset = end
+					x.dirty = true
+				}
+				return
+			}
+			if errors.Is(err, io.ErrUnexpectedEOF) {
+				return
+			}
+
+			var syntaxErr *json.SyntaxError
+			if errors.As(err, &syntaxErr) {
+				break
+			}
+			if err != nil {
+				logger.Warn("failed to read file", logging.ErrAttr(err))
+				return
+			}
+
+			pos.Offset = base + decoder.InputOffset()
+			x.dirty = true
+
+			if events, ok := data.([]any); ok {
+				for _, event := range events {
+					x.dispatch(ctx, path, pos.Schema, event)
+				}
+			} else {
+				x.dispatch(ctx, path, pos.Schema, data)
+			}
+		}
+
+		// Skip the broken line and continue from the next line
+		if _, err := f.Seek(pos.Offset, io.SeekStart); err != nil {
+			logger.Warn("failed to seek file", logging.ErrAttr(err))
+			return
+		}
+		r := bufio.NewReader(f)
+		for {
+			line, err := r.ReadBytes('\n')
+			if err != nil {
+				// The line may be still being written
+				return
+			}
+			offset := pos.Offset
+			pos.Offset += int64(len(line))
+			x.dirty = true
+
+			if len(bytes.TrimSpace(line)) > 0 {
+				logger.Warn("skip invalid JSON line", slog.Int64("offset", offset), slog.Int("length", len(line)))
+				break
+			}
+		}
+	}
+}
+
+func (x *Watcher) dispatch(ctx context.Context, path string, schema types.Schema, data any) {
+	ctx = ctxutil.InjectEventMeta(ctx, &model.EventMeta{
+		Transport:  model.TransportFile,
+		Attributes: map[string]string{"path": path},
+		ReceivedAt: time.Now(),
+	})
+	x.instrument.EventReceived(ctx, model.TransportFile, schema)
+
+	// The event already read should be handled even while shutting down
+	if _, err := x.handler(context.WithoutCancel(ctx), schema, data); err != nil {
+		utils.HandleError(ctx, goerr.Wrap(err, "failed to handle event of file", goerr.V("path", path), goerr.V("schema", schema)))
+	}
+}
@@ -0,0 +1,157 @@
+package watch_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/m-mizutani/gt"
+	"github.com/secmon-lab/alertchain/pkg/controller/watch"
+	"github.com/secmon-lab/alertchain/pkg/ctxutil"
+	"github.com/secmon-lab/alertchain/pkg/domain/model"
+	"github.com/secmon-lab/alertchain/pkg/domain/types"
+)
+
+type event struct {
+	schema types.Schema
+	data   any
+	path   string
+}
+
+type recorder struct {
+	events []event
+}
+
+func (x *recorder) handle(ctx context.Context, schema types.Schema, data any) ([]*model.Alert, error) {
+	x.events = append(x.events, event{
+		schema: schema,
+		data:   data,
+		path:   ctxutil.GetEventMeta(ctx).Attributes["path"],
+	})
+	return nil, nil
+}
+
+func (x *recorder) names() []any {
+	var names []any
+	for _, ev := range x.events {
+		names = append(names, ev.data.(map[string]any)["name"])
+	}
+	x.events = nil
+	return names
+}
+
+func appendFile(t *testing.T, path, data string) {
+	f := gt.R1(os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)).NoError(t)
+	gt.R1(f.WriteString(data)).NoError(t)
+	gt.NoError(t, f.Close())
+}
+
+func TestWatcher(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	checkpoint := filepath.Join(dir, "checkpoint.json")
+	logPath := filepath.Join(dir, "alerts.json")
+
+	rec := &recorder{}
+	newWatcher := func() *watch.Watcher {
+		return watch.New(rec.handle, dir,
+			watch.WithCheckpoint(checkpoint),
+			watch.WithRule(watch.Rule{Pattern: "*.json", Schema: "falco"}),
+		)
+	}
+
+	w := newWatcher()
+
+	t.Run("read appended events and wait for incomplete line", func(t *testing.T) {
+		appendFile(t, logPath, `{"name":"a1"}`+"\n"+`{"name":"a2"}`+"\n"+`{"name":`)
+		gt.NoError(t, w.Poll(ctx))
+		gt.V(t, rec.events[0].schema).Equal("falco")
+		gt.V(t, rec.events[0].path).Equal(logPath)
+		gt.V(t, rec.names()).Equal([]any{"a1", "a2"})
+
+		appendFile(t, logPath, `"a3"}`+"\n")
+		gt.NoError(t, w.Poll(ctx))
+		gt.V(t, rec.names()).Equal([]any{"a3"})
+
+		gt.NoError(t, w.Poll(ctx))
+		gt.A(t, rec.events).Length(0)
+	})
+
+	t.Run("resume from checkpoint", func(t *testing.T) {
+		appendFile(t, logPath, `{"name":"a4"}`+"\n")
+		w = newWatcher()
+		gt.NoError(t, w.Poll(ctx))
+		gt.V(t, rec.names()).Equal([]any{"a4"})
+	})
+
+	t.Run("rotation by rename", func(t *testing.T) {
+		appendFile(t, logPath, `{"name":"a5"}`+"\n")
+		gt.NoError(t, os.Rename(logPath, logPath+".1"))
+		appendFile(t, logPath, `{"name":"b1"}`+"\n")
+
+		gt.NoError(t, w.Poll(ctx))
+		gt.V(t, rec.names()).Equal([]any{"a5", "b1"})
+	})
+
+	t.Run("truncation", func(t *testing.T) {
+		gt.NoError(t, os.Truncate(logPath, 0))
+		appendFile(t, logPath, `{"name":"c"}`+"\n")
+
+		gt.NoError(t, w.Poll(ctx))
+		gt.V(t, rec.names()).Equal([]any{"c"})
+	})
+}
+
+func TestWatcherFormat(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	gt.NoError(t, os.Mkdir(filepath.Join(dir, "falco"), 0700))
+
+	rec := &recorder{}
+	w := watch.New(rec.handle, dir,
+		watch.WithCheckpoint(filepath.Join(dir, "checkpoint.json")),
+		watch.WithRule(
+			gt.R1(watch.ParseRule("falco/*.json=falco")).NoError(t),
+			gt.R1(watch.ParseRule("*.json=generic")).NoError(t),
+		),
+	)
+
+	appendFile(t, filepath.Join(dir, "falco", "events.json"), "{\n  \"name\": \"f1\"\n}\n{\"name\": \"f2\"}")
+	appendFile(t, filepath.Join(dir, "other.json"), `[{"name":"g1"},{"name":"g2"}]`+"\nbroken line\n"+`{"name":"g3"}`+"\n")
+	gt.NoError(t, w.Poll(ctx))
+
+	gt.A(t, rec.events).Length(5)
+	schemas := map[any]types.Schema{}
+	for _, ev := range rec.events {
+		schemas[ev.data.(map[string]any)["name"]] = ev.schema
+	}
+	gt.V(t, schemas).Equal(map[any]types.Schema{
+		"f1": "falco",
+		"f2": "falco",
+		"g1": "generic",
+		"g2": "generic",
+		"g3": "generic",
+	})
+}
+
+func TestWatcherStartAtEnd(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	logPath := filepath.Join(dir, "alerts.json")
+	appendFile(t, logPath, `{"name":"old"}`+"\n")
+
+	rec := &recorder{}
+	w := watch.New(rec.handle, dir,
+		watch.WithCheckpoint(filepath.Join(dir, "checkpoint.json")),
+		watch.WithRule(watch.Rule{Pattern: "*.json", Schema: "falco"}),
+		watch.WithStartAtEnd(),
+	)
+
+	gt.NoError(t, w.Poll(ctx))
+	gt.A(t, rec.events).Length(0)
+
+	appendFile(t, logPath, `{"name":"new"}`+"\n")
+	gt.NoError(t, w.Poll(ctx))
+	gt.V(t, rec.names()).Equal([]any{"new"})
+}
@@ -3,9 +3,11 @@ package ctxutil
 import (
 	"context"
 	"log/slog"
+	"net/http"
 	"time"
 
 	"github.com/secmon-lab/alertchain/pkg/domain/model"
+	"github.com/secmon-lab/alertchain/pkg/domain/types"
 	"github.com/secmon-lab/alertchain/pkg/logging"
 )
 
@@ -37,6 +39,49 @@ func GetAlert(ctx context.Context) *model.Alert {
 	return v.(*model.Alert)
 }
 
+type ctxWorkflowIDKey struct{}
+
+func InjectWorkflowID(ctx context.Context, id types.WorkflowID) context.Context {
+	return context.WithValue(ctx, ctxWorkflowIDKey{}, id)
+}
+
+func GetWorkflowID(ctx context.Context) types.WorkflowID {
+	v := ctx.Value(ctxWorkflowIDKey{})
+	if v == nil {
+		return ""
+	}
+	return v.(types.WorkflowID)
+}
+
+type ctxEventMetaKey struct{}
+
+func InjectEventMeta(ctx context.Context, meta *model.EventMeta) context.Context {
+	return context.WithValue(ctx, ctxEventMetaKey{}, meta)
+}
+
+func GetEventMeta(ctx context.Context) *model.EventMeta {
+	v := ctx.Value(ctxEventMetaKey{})
+	if v == nil {
+		return nil
+	}
+	return v.(*model.EventMeta)
+}
+
+type ctxActorKey struct{}
+
+// InjectActor sets identity of the caller. It is recorded in audit log of GraphQL mutation.
+func InjectActor(ctx context.Context, actor *model.Actor) context.Context {
+	return context.WithValue(ctx, ctxActorKey{}, actor)
+}
+
+func GetActor(ctx context.Context) *model.Actor {
+	v := ctx.Value(ctxActorKey{})
+	if v == nil {
+		return nil
+	}
+	return v.(*model.Actor)
+}
+
 type ctxDryRunKey struct{}
 
 func SetDryRun(ctx context.Context, dryRun bool) context.Context {
@@ -51,6 +96,21 @@ func IsDryRun(ctx context.Context) bool {
 	return v.(bool)
 }
 
+type ctxTimelineKey struct{}
+
+// InjectTimeline sets a collector of actions run by workflows of the context.
+func InjectTimeline(ctx context.Context, timeline *model.Timeline) context.Context {
+	return context.WithValue(ctx, ctxTimelineKey{}, timeline)
+}
+
+func GetTimeline(ctx context.Context) *model.Timeline {
+	v := ctx.Value(ctxTimelineKey{})
+	if v == nil {
+		return nil
+	}
+	return v.(*model.Timeline)
+}
+
 type ctxClockKey struct{}
 
 func InjectClock(ctx context.Context, clock model.Clock) context.Context {
@@ -92,3 +152,19 @@ func Logger(ctx context.Context) *slog.Logger {
 	}
 	return v.(*slog.Logger)
 }
+
+type ctxHTTPClientKey struct{}
+
+// InjectHTTPClient sets HTTP client used by actions to call external services, e.g. client that records outbound requests as spans.
+func InjectHTTPClient(ctx context.Context, client *http.Client) context.Context {
+	return context.WithValue(ctx, ctxHTTPClientKey{}, client)
+}
+
+// HTTPClient returns HTTP client set by InjectHTTPClient. It returns http.DefaultClient if not set.
+func HTTPClient(ctx context.Context) *http.Client {
+	v := ctx.Value(ctxHTTPClientKey{})
+	if v == nil {
+		return http.DefaultClient
+	}
+	return v.(*http.Client)
+}
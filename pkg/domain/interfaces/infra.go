@@ -14,13 +14,36 @@ type GenAI interface {
 
 type Database interface {
 	GetAttrs(ctx context.Context, ns types.Namespace) (model.Attributes, error)
+	// PutAttrs stores the attributes. Workflow ID and actor in ctx are recorded as writer of the attributes.
 	PutAttrs(ctx context.Context, ns types.Namespace, attrs model.Attributes) error
+	// GetNamespaces returns namespaces that have persistent attributes in ascending order of name.
+	GetNamespaces(ctx context.Context, offset, limit int) ([]model.NamespaceRecord, error)
+	// GetPersistentAttrs returns unexpired attributes of the namespace with their expiration and last writer.
+	GetPersistentAttrs(ctx context.Context, ns types.Namespace) ([]model.PersistentAttribute, error)
+	// GetAttrHistory returns writes of the attribute in descending order of time.
+	GetAttrHistory(ctx context.Context, ns types.Namespace, id types.AttrID, limit int) ([]model.AttributeChange, error)
+	// DeleteAttrs removes attributes of the namespace. IDs that do not exist are ignored.
+	DeleteAttrs(ctx context.Context, ns types.Namespace, ids []types.AttrID) error
 	PutWorkflow(ctx context.Context, workflow model.WorkflowRecord) error
 	GetWorkflows(ctx context.Context, offset, limit int) ([]model.WorkflowRecord, error)
+	// GetWorkflowsAfter returns workflows after the cursor in descending order of CreatedAt and ID. Workflows from the newest are returned if cursor is nil.
+	GetWorkflowsAfter(ctx context.Context, cursor *model.WorkflowCursor, limit int) ([]model.WorkflowRecord, error)
 	GetWorkflow(ctx context.Context, id types.WorkflowID) (*model.WorkflowRecord, error)
+	// SearchWorkflows returns workflows matched with the filter in descending order of CreatedAt.
+	SearchWorkflows(ctx context.Context, filter model.WorkflowFilter, offset, limit int) ([]model.WorkflowRecord, error)
+	// GetWorkflowStats aggregates workflows created in [from, to) into buckets of the interval.
+	GetWorkflowStats(ctx context.Context, from, to time.Time, interval time.Duration) (*model.Stats, error)
 	PutAlert(ctx context.Context, alert model.Alert) error
 	GetAlert(ctx context.Context, id types.AlertID) (*model.Alert, error)
 	Lock(ctx context.Context, ns types.Namespace, timeout time.Time) error
 	Unlock(ctx context.Context, ns types.Namespace) error
+	PutDecisionLog(ctx context.Context, log model.DecisionLog) error
+	PutAuditLog(ctx context.Context, log model.AuditLog) error
+	// ClaimPubSubMessage stores the record if no unexpired record has the same key. It returns nil if the record is stored, or the existing record without storing.
+	ClaimPubSubMessage(ctx context.Context, record model.PubSubMessageRecord) (*model.PubSubMessageRecord, error)
+	PutPubSubMessage(ctx context.Context, record model.PubSubMessageRecord) error
+	DeletePubSubMessage(ctx context.Context, key string) error
+	// Ping checks the database is reachable. It is used by readiness check.
+	Ping(ctx context.Context) error
 	Close() error
 }
@@ -2,6 +2,7 @@ package interfaces
 
 import (
 	"context"
+	"time"
 
 	"github.com/secmon-lab/alertchain/pkg/domain/model"
 	"github.com/secmon-lab/alertchain/pkg/domain/types"
@@ -16,6 +17,7 @@ type ActionMock interface {
 type ScenarioRecorder interface {
 	NewAlertRecorder(alert *model.Alert) AlertRecorder
 	LogError(err error)
+	LogTrace(trace *model.PolicyTrace)
 	Flush() error
 }
 
@@ -32,4 +34,32 @@ type ActionRecorder interface {
 // AlertHandler is a function to handle the alert from data source. The handler is registered as an option within the chain.Chain.
 type AlertHandler func(ctx context.Context, schema types.Schema, data any) ([]*model.Alert, error)
 
+// WorkflowRunner runs a new workflow for the alert that has been already detected. It is implemented by chain.Chain.RerunAlert.
+type WorkflowRunner func(ctx context.Context, alert model.Alert) (types.WorkflowID, error)
+
 type Env func() types.EnvVars
+
+// DecisionLogSink receives a record of every policy evaluation for auditing. The sink is passed to policy.Client.Query as a query option.
+type DecisionLogSink interface {
+	Write(ctx context.Context, log *model.DecisionLog) error
+}
+
+// WorkflowEventPublisher receives progress of workflows, e.g. to deliver them to GraphQL subscription. It is called synchronously in the workflow, then the implementation must not block.
+type WorkflowEventPublisher interface {
+	Publish(ctx context.Context, event *model.WorkflowEvent)
+}
+
+// Instrument receives measurements of AlertChain runtime, e.g. to expose metrics. It is called synchronously in the processing path, then the implementation must not block.
+type Instrument interface {
+	// EventReceived is called for each event passed to alert policy. endpoint is a kind of the receiver, e.g. "raw", "pubsub", "sns", "syslog" or "file".
+	EventReceived(ctx context.Context, endpoint string, schema types.Schema)
+	AlertDetected(ctx context.Context, schema types.Schema, count int)
+	ActionExecuted(ctx context.Context, uses types.ActionName, duration time.Duration, err error)
+	PolicyEvaluated(ctx context.Context, pkg string, duration time.Duration, err error)
+	// WorkflowFinished is called with number of evaluated sequences of the workflow.
+	WorkflowFinished(ctx context.Context, sequences int, err error)
+	LockWaited(ctx context.Context, ns types.Namespace, duration time.Duration)
+	AuthzDenied(ctx context.Context, path string)
+	// RateLimited is called for each request exceeding a rate limit. scope is the exceeded limit ("global", "schema", "remote" or "class") and decision is "rejected", "sampled" or "dropped".
+	RateLimited(ctx context.Context, scope string, schema types.Schema, decision string)
+}
@@ -65,3 +65,13 @@ func (x ActionArgs) Parse(psr ...ArgParser) error {
 	}
 	return nil
 }
+
+// DryRunResult is returned by a built-in action instead of its result in dry-run mode. Request is the request that the action would have sent. It does not include credentials.
+type DryRunResult struct {
+	DryRun  bool `json:"dry_run"`
+	Request any  `json:"request"`
+}
+
+func NewDryRunResult(req any) *DryRunResult {
+	return &DryRunResult{DryRun: true, Request: req}
+}
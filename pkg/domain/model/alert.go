@@ -36,6 +36,8 @@ type Alert struct {
 	Schema    types.Schema  `json:"schema"`
 	Data      any           `json:"data,omitempty"`
 	CreatedAt time.Time     `json:"created_at"`
+	// Meta is metadata of the envelope that delivered Data. It is nil if the alert is not received via HTTP endpoint.
+	Meta *EventMeta `json:"meta,omitempty"`
 
 	// Raw is a JSON string of Data. The field will be redacted by masq because of verbosity.
 	Raw string `json:"raw,omitempty" masq:"quiet"`
@@ -49,6 +51,7 @@ func (x Alert) Copy() Alert {
 		Schema:    x.Schema,
 		Data:      x.Data,
 		CreatedAt: x.CreatedAt,
+		Meta:      x.Meta.Copy(),
 
 		Raw: x.Raw,
 	}
@@ -1,6 +1,8 @@
 package model
 
 import (
+	"time"
+
 	"github.com/secmon-lab/alertchain/pkg/domain/types"
 )
 
@@ -13,6 +15,24 @@ type Attribute struct {
 	TTL     int             `json:"ttl" firestore:"ttl"`
 }
 
+// PersistentAttribute is an attribute stored in a namespace. WorkflowID and Actor are the last writer of the attribute, and empty for the attribute stored by older version.
+type PersistentAttribute struct {
+	Attribute
+	ExpiresAt  time.Time        `json:"expires_at" firestore:"expires_at"`
+	UpdatedAt  time.Time        `json:"updated_at" firestore:"updated_at"`
+	WorkflowID types.WorkflowID `json:"workflow_id,omitempty" firestore:"workflow_id"`
+	Actor      string           `json:"actor,omitempty" firestore:"actor"`
+}
+
+// AttributeChange is a write of persistent attribute.
+type AttributeChange struct {
+	Value      types.AttrValue  `json:"value" firestore:"value"`
+	ExpiresAt  time.Time        `json:"expires_at" firestore:"expires_at"`
+	WorkflowID types.WorkflowID `json:"workflow_id,omitempty" firestore:"workflow_id"`
+	Actor      string           `json:"actor,omitempty" firestore:"actor"`
+	CreatedAt  time.Time        `json:"created_at" firestore:"created_at"`
+}
+
 func (x Attribute) Copy() Attribute {
 	copied := x
 	return copied
@@ -0,0 +1,26 @@
+package model
+
+import (
+	"time"
+
+	"github.com/secmon-lab/alertchain/pkg/domain/types"
+)
+
+// Actor is identity of the caller that changes state of AlertChain, e.g. by GraphQL mutation.
+type Actor struct {
+	// Subject is identity of the caller, such as email of OIDC token or common name of client certificate. It is empty if the caller is not identified.
+	Subject string `json:"subject,omitempty" firestore:"subject"`
+	Remote  string `json:"remote,omitempty" firestore:"remote"`
+}
+
+// AuditLog is a record of an operation that changes state of AlertChain. Input is already redacted.
+type AuditLog struct {
+	ID        types.AuditID `json:"id" firestore:"id"`
+	Timestamp time.Time     `json:"timestamp" firestore:"timestamp"`
+	Operation string        `json:"operation" firestore:"operation"`
+	// Target is ID of the changed resource, e.g. workflow ID or namespace.
+	Target string         `json:"target" firestore:"target"`
+	Input  map[string]any `json:"input,omitempty" firestore:"input"`
+	Actor  Actor          `json:"actor" firestore:"actor"`
+	Error  string         `json:"error,omitempty" firestore:"error"`
+}
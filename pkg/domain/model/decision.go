@@ -0,0 +1,21 @@
+package model
+
+import (
+	"time"
+
+	"github.com/secmon-lab/alertchain/pkg/domain/types"
+)
+
+// DecisionLog is a record of a single policy evaluation. Input and Output are already redacted and converted to JSON compatible values.
+type DecisionLog struct {
+	ID         types.DecisionID `json:"id" firestore:"id"`
+	Timestamp  time.Time        `json:"timestamp" firestore:"timestamp"`
+	Package    string           `json:"package" firestore:"package"`
+	PolicyHash string           `json:"policy_hash" firestore:"policy_hash"`
+	Input      any              `json:"input" firestore:"input"`
+	Output     any              `json:"output,omitempty" firestore:"output"`
+	Error      string           `json:"error,omitempty" firestore:"error"`
+	Latency    time.Duration    `json:"latency" firestore:"latency"`
+	AlertID    types.AlertID    `json:"alert_id,omitempty" firestore:"alert_id"`
+	WorkflowID types.WorkflowID `json:"workflow_id,omitempty" firestore:"workflow_id"`
+}
@@ -0,0 +1,49 @@
+package model
+
+import "time"
+
+const (
+	TransportRaw    = "raw"
+	TransportPubSub = "pubsub"
+	TransportSNS    = "sns"
+	TransportSyslog = "syslog"
+	TransportFile   = "file"
+)
+
+// EventMeta is metadata of the envelope that delivered the event. It is available as `data.alertchain.meta` in alert policy and stored with the alert, while `input` of alert policy is still the event payload.
+type EventMeta struct {
+	// Transport is endpoint that received the event, "raw", "pubsub", "sns", "syslog" or "file".
+	Transport    string            `json:"transport"`
+	MessageID    string            `json:"message_id,omitempty"`
+	Subscription string            `json:"subscription,omitempty"`
+	Topic        string            `json:"topic,omitempty"`
+	Attributes   map[string]string `json:"attributes,omitempty"`
+	// PublishTime is publish time of the message given by Pub/Sub or SNS as it is.
+	PublishTime string    `json:"publish_time,omitempty"`
+	ReceivedAt  time.Time `json:"received_at"`
+	// Headers has only HTTP headers selected by --meta-header option. Name of header is canonicalized, e.g. "User-Agent".
+	Headers map[string]string `json:"headers,omitempty"`
+	// RemoteAddr is address of the syslog sender.
+	RemoteAddr string `json:"remote_addr,omitempty"`
+}
+
+func (x *EventMeta) Copy() *EventMeta {
+	if x == nil {
+		return nil
+	}
+
+	newMeta := *x
+	if x.Attributes != nil {
+		newMeta.Attributes = make(map[string]string, len(x.Attributes))
+		for k, v := range x.Attributes {
+			newMeta.Attributes[k] = v
+		}
+	}
+	if x.Headers != nil {
+		newMeta.Headers = make(map[string]string, len(x.Headers))
+		for k, v := range x.Headers {
+			newMeta.Headers[k] = v
+		}
+	}
+	return &newMeta
+}
@@ -20,6 +20,20 @@ type ActionRecord struct {
 	FinishedAt time.Time         `json:"finishedAt"`
 }
 
+type ActionStats struct {
+	Uses          string  `json:"uses"`
+	Executions    int     `json:"executions"`
+	Failures      int     `json:"failures"`
+	FailureRate   float64 `json:"failureRate"`
+	MeanLatencyMs float64 `json:"meanLatencyMs"`
+}
+
+type AlertCount struct {
+	Key     string `json:"key"`
+	Total   int    `json:"total"`
+	Buckets []int  `json:"buckets"`
+}
+
 type AlertRecord struct {
 	ID          types.AlertID      `json:"id"`
 	Schema      string             `json:"schema"`
@@ -39,6 +53,22 @@ type ArgumentRecord struct {
 	Value string `json:"value"`
 }
 
+type AttributeChangeRecord struct {
+	Value      string            `json:"value"`
+	ExpiresAt  time.Time         `json:"expiresAt"`
+	WorkflowID *types.WorkflowID `json:"workflowId,omitempty"`
+	Actor      *string           `json:"actor,omitempty"`
+	CreatedAt  time.Time         `json:"createdAt"`
+}
+
+type AttributeInput struct {
+	ID    *string `json:"id,omitempty"`
+	Key   string  `json:"key"`
+	Value string  `json:"value"`
+	Type  *string `json:"type,omitempty"`
+	TTL   *int    `json:"ttl,omitempty"`
+}
+
 type AttributeRecord struct {
 	ID      string  `json:"id"`
 	Key     string  `json:"key"`
@@ -48,11 +78,48 @@ type AttributeRecord struct {
 	TTL     int     `json:"ttl"`
 }
 
+type CommentRecord struct {
+	Author    string    `json:"author"`
+	Action    string    `json:"action"`
+	Body      string    `json:"body"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type Mutation struct {
+}
+
+type NamespaceRecord struct {
+	Name       string                       `json:"name"`
+	UpdatedAt  time.Time                    `json:"updatedAt"`
+	Attributes []*PersistentAttributeRecord `json:"attributes"`
+}
+
 type NextRecord struct {
 	Abort bool               `json:"abort"`
 	Attrs []*AttributeRecord `json:"attrs"`
 }
 
+type PageInfo struct {
+	HasNextPage     bool    `json:"hasNextPage"`
+	HasPreviousPage bool    `json:"hasPreviousPage"`
+	StartCursor     *string `json:"startCursor,omitempty"`
+	EndCursor       *string `json:"endCursor,omitempty"`
+}
+
+type PersistentAttributeRecord struct {
+	Namespace  string                   `json:"namespace"`
+	ID         string                   `json:"id"`
+	Key        string                   `json:"key"`
+	Value      string                   `json:"value"`
+	Type       *string                  `json:"type,omitempty"`
+	TTL        int                      `json:"ttl"`
+	ExpiresAt  time.Time                `json:"expiresAt"`
+	UpdatedAt  time.Time                `json:"updatedAt"`
+	WorkflowID *types.WorkflowID        `json:"workflowId,omitempty"`
+	Actor      *string                  `json:"actor,omitempty"`
+	History    []*AttributeChangeRecord `json:"history"`
+}
+
 type Query struct {
 }
 
@@ -61,9 +128,65 @@ type ReferenceRecord struct {
 	URL   *string `json:"url,omitempty"`
 }
 
+type Stats struct {
+	From           time.Time      `json:"from"`
+	To             time.Time      `json:"to"`
+	Interval       int            `json:"interval"`
+	AlertsBySchema []*AlertCount  `json:"alertsBySchema"`
+	AlertsBySource []*AlertCount  `json:"alertsBySource"`
+	AlertsByTitle  []*AlertCount  `json:"alertsByTitle"`
+	Actions        []*ActionStats `json:"actions"`
+}
+
+type Subscription struct {
+}
+
+type WorkflowConnection struct {
+	Edges    []*WorkflowEdge   `json:"edges"`
+	Nodes    []*WorkflowRecord `json:"nodes"`
+	PageInfo *PageInfo         `json:"pageInfo"`
+}
+
+type WorkflowEdge struct {
+	Cursor string          `json:"cursor"`
+	Node   *WorkflowRecord `json:"node"`
+}
+
+type WorkflowEvent struct {
+	Type       string           `json:"type"`
+	WorkflowID types.WorkflowID `json:"workflowId"`
+	AlertID    types.AlertID    `json:"alertId"`
+	Timestamp  time.Time        `json:"timestamp"`
+	Seq        *int             `json:"seq,omitempty"`
+	Actions    *int             `json:"actions,omitempty"`
+	ActionID   *string          `json:"actionId,omitempty"`
+	Uses       *string          `json:"uses,omitempty"`
+	Status     *string          `json:"status,omitempty"`
+	Error      *string          `json:"error,omitempty"`
+}
+
+type WorkflowFilter struct {
+	Schema        *string    `json:"schema,omitempty"`
+	CreatedAfter  *time.Time `json:"createdAfter,omitempty"`
+	CreatedBefore *time.Time `json:"createdBefore,omitempty"`
+	Title         *string    `json:"title,omitempty"`
+	Source        *string    `json:"source,omitempty"`
+	Namespace     *string    `json:"namespace,omitempty"`
+	AttrKey       *string    `json:"attrKey,omitempty"`
+	AttrValue     *string    `json:"attrValue,omitempty"`
+	Action        *string    `json:"action,omitempty"`
+	Status        *string    `json:"status,omitempty"`
+	Error         *string    `json:"error,omitempty"`
+}
+
 type WorkflowRecord struct {
-	ID        types.WorkflowID `json:"id"`
-	CreatedAt time.Time        `json:"createdAt"`
-	Alert     *AlertRecord     `json:"alert"`
-	Actions   []*ActionRecord  `json:"actions"`
+	ID         types.WorkflowID `json:"id"`
+	CreatedAt  time.Time        `json:"createdAt"`
+	Status     string           `json:"status"`
+	FinishedAt *time.Time       `json:"finishedAt,omitempty"`
+	Error      *string          `json:"error,omitempty"`
+	Alert      *AlertRecord     `json:"alert"`
+	Actions    []*ActionRecord  `json:"actions"`
+	Triage     string           `json:"triage"`
+	Comments   []*CommentRecord `json:"comments"`
 }
@@ -6,8 +6,9 @@ type ScenarioLog struct {
 	ID    types.ScenarioID    `json:"id"`
 	Title types.ScenarioTitle `json:"title"`
 
-	Results []*PlayLog `json:"results,omitempty"`
-	Error   any        `json:"error,omitempty"`
+	Results []*PlayLog     `json:"results,omitempty"`
+	Error   any            `json:"error,omitempty"`
+	Traces  []*PolicyTrace `json:"traces,omitempty"`
 }
 
 type PlayLog struct {
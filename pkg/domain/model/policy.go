@@ -2,6 +2,7 @@ package model
 
 import (
 	"errors"
+	"time"
 
 	"github.com/PaesslerAG/gval"
 	"github.com/PaesslerAG/jsonpath"
@@ -85,4 +86,10 @@ func (x *Commit) ToAttr(data any) (*Attribute, error) {
 type ActionResult struct {
 	Action
 	Result any `json:"result,omitempty"`
+
+	// Fields below are recorded in WorkflowRecord and not passed to action policy.
+	StartedAt  time.Time `json:"-"`
+	FinishedAt time.Time `json:"-"`
+	// Error is error message of forced action that is ignored in the workflow.
+	Error string `json:"-"`
 }
@@ -1,5 +1,7 @@
 package model
 
+import "time"
+
 type PubSubRequest struct {
 	DeliveryAttempt int64         `json:"deliveryAttempt"`
 	Message         PubSubMessage `json:"message"`
@@ -12,3 +14,15 @@ type PubSubMessage struct {
 	MessageID   string            `json:"message_id"`
 	PublishTime string            `json:"publish_time"`
 }
+
+const (
+	PubSubMessageRunning   = "running"
+	PubSubMessageCompleted = "completed"
+)
+
+// PubSubMessageRecord is a processing record of Pub/Sub message to skip redelivered messages. Key is unique per subscription and message ID, because the same message is delivered to each subscription of the topic.
+type PubSubMessageRecord struct {
+	Key       string    `json:"key" firestore:"key"`
+	Status    string    `json:"status" firestore:"status"`
+	ExpiresAt time.Time `json:"expires_at" firestore:"expires_at"`
+}
@@ -0,0 +1,126 @@
+package model
+
+import (
+	"sort"
+	"time"
+)
+
+// StatsAggregator counts workflows into Stats. Database that can not group workflows in query feeds workflows created in the time range to it.
+type StatsAggregator struct {
+	from     time.Time
+	to       time.Time
+	interval time.Duration
+	buckets  int
+
+	schemas map[string]*AlertCount
+	sources map[string]*AlertCount
+	titles  map[string]*AlertCount
+	actions map[string]*actionStats
+}
+
+type actionStats struct {
+	executions int
+	failures   int
+	latency    time.Duration
+}
+
+// NewStatsAggregator creates an aggregator for workflows created in [from, to). interval must be positive.
+func NewStatsAggregator(from, to time.Time, interval time.Duration) *StatsAggregator {
+	buckets := int((to.Sub(from) + interval - 1) / interval)
+	if buckets < 0 {
+		buckets = 0
+	}
+
+	return &StatsAggregator{
+		from:     from,
+		to:       to,
+		interval: interval,
+		buckets:  buckets,
+		schemas:  map[string]*AlertCount{},
+		sources:  map[string]*AlertCount{},
+		titles:   map[string]*AlertCount{},
+		actions:  map[string]*actionStats{},
+	}
+}
+
+// Add counts the workflow. It is ignored if it was created out of the time range.
+func (x *StatsAggregator) Add(wf *WorkflowRecord) {
+	if wf.CreatedAt.Before(x.from) || !wf.CreatedAt.Before(x.to) {
+		return
+	}
+	idx := int(wf.CreatedAt.Sub(x.from) / x.interval)
+
+	if wf.Alert != nil {
+		x.count(x.schemas, wf.Alert.Schema, idx)
+		x.count(x.sources, wf.Alert.Source, idx)
+		x.count(x.titles, wf.Alert.Title, idx)
+	}
+
+	for _, action := range wf.Actions {
+		s, ok := x.actions[action.Uses]
+		if !ok {
+			s = &actionStats{}
+			x.actions[action.Uses] = s
+		}
+		s.executions++
+		if action.Error != nil {
+			s.failures++
+		}
+		s.latency += action.FinishedAt.Sub(action.StartedAt)
+	}
+}
+
+func (x *StatsAggregator) count(groups map[string]*AlertCount, key string, idx int) {
+	c, ok := groups[key]
+	if !ok {
+		c = &AlertCount{Key: key, Buckets: make([]int, x.buckets)}
+		groups[key] = c
+	}
+	c.Total++
+	c.Buckets[idx]++
+}
+
+// Stats returns the aggregated result. Groups are sorted in descending order of total, and then by key.
+func (x *StatsAggregator) Stats() *Stats {
+	stats := &Stats{
+		From:           x.from,
+		To:             x.to,
+		Interval:       int(x.interval / time.Second),
+		AlertsBySchema: sortAlertCounts(x.schemas),
+		AlertsBySource: sortAlertCounts(x.sources),
+		AlertsByTitle:  sortAlertCounts(x.titles),
+		Actions:        []*ActionStats{},
+	}
+
+	for uses, s := range x.actions {
+		stats.Actions = append(stats.Actions, &ActionStats{
+			Uses:          uses,
+			Executions:    s.executions,
+			Failures:      s.failures,
+			FailureRate:   float64(s.failures) / float64(s.executions),
+			MeanLatencyMs: float64(s.latency.Milliseconds()) / float64(s.executions),
+		})
+	}
+	sort.Slice(stats.Actions, func(i, j int) bool {
+		if stats.Actions[i].Executions != stats.Actions[j].Executions {
+			return stats.Actions[i].Executions > stats.Actions[j].Executions
+		}
+		return stats.Actions[i].Uses < stats.Actions[j].Uses
+	})
+
+	return stats
+}
+
+func sortAlertCounts(groups map[string]*AlertCount) []*AlertCount {
+	counts := make([]*AlertCount, 0, len(groups))
+	for _, c := range groups {
+		counts = append(counts, c)
+	}
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].Total != counts[j].Total {
+			return counts[i].Total > counts[j].Total
+		}
+		return counts[i].Key < counts[j].Key
+	})
+	return counts
+}
@@ -0,0 +1,67 @@
+package model_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/m-mizutani/gots/ptr"
+	"github.com/m-mizutani/gt"
+	"github.com/secmon-lab/alertchain/pkg/domain/model"
+)
+
+func TestStatsAggregator(t *testing.T) {
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := from.Add(150 * time.Minute)
+	agg := model.NewStatsAggregator(from, to, time.Hour)
+
+	newWorkflow := func(createdAt time.Time, schema, title string, actions ...*model.ActionRecord) *model.WorkflowRecord {
+		return &model.WorkflowRecord{
+			CreatedAt: createdAt,
+			Alert:     &model.AlertRecord{Schema: schema, Source: "aws", Title: title},
+			Actions:   actions,
+		}
+	}
+	newAction := func(uses string, latency time.Duration, err *string) *model.ActionRecord {
+		return &model.ActionRecord{Uses: uses, StartedAt: from, FinishedAt: from.Add(latency), Error: err}
+	}
+
+	agg.Add(newWorkflow(from, "guardduty", "SSH brute force", newAction("slack.post", 100*time.Millisecond, nil)))
+	agg.Add(newWorkflow(from.Add(30*time.Minute), "guardduty", "Port scan"))
+	agg.Add(newWorkflow(from.Add(2*time.Hour), "scc", "SSH brute force",
+		newAction("slack.post", 300*time.Millisecond, ptr.To("timeout")),
+		newAction("jira.create", time.Second, nil),
+	))
+	// Out of range
+	agg.Add(newWorkflow(from.Add(-time.Second), "guardduty", "Port scan"))
+	agg.Add(newWorkflow(to, "guardduty", "Port scan"))
+
+	stats := agg.Stats()
+	gt.V(t, stats.Interval).Equal(3600)
+
+	gt.A(t, stats.AlertsBySchema).Length(2).At(0, func(t testing.TB, v *model.AlertCount) {
+		gt.V(t, v.Key).Equal("guardduty")
+		gt.V(t, v.Total).Equal(2)
+		gt.V(t, v.Buckets).Equal([]int{2, 0, 0})
+	}).At(1, func(t testing.TB, v *model.AlertCount) {
+		gt.V(t, v.Key).Equal("scc")
+		gt.V(t, v.Buckets).Equal([]int{0, 0, 1})
+	})
+	gt.A(t, stats.AlertsBySource).Length(1).At(0, func(t testing.TB, v *model.AlertCount) {
+		gt.V(t, v.Total).Equal(3)
+	})
+	gt.A(t, stats.AlertsByTitle).Length(2).At(0, func(t testing.TB, v *model.AlertCount) {
+		gt.V(t, v.Key).Equal("SSH brute force")
+		gt.V(t, v.Buckets).Equal([]int{1, 0, 1})
+	})
+
+	gt.A(t, stats.Actions).Length(2).At(0, func(t testing.TB, v *model.ActionStats) {
+		gt.V(t, v.Uses).Equal("slack.post")
+		gt.V(t, v.Executions).Equal(2)
+		gt.V(t, v.Failures).Equal(1)
+		gt.V(t, v.FailureRate).Equal(0.5)
+		gt.V(t, v.MeanLatencyMs).Equal(200.0)
+	}).At(1, func(t testing.TB, v *model.ActionStats) {
+		gt.V(t, v.Uses).Equal("jira.create")
+		gt.V(t, v.MeanLatencyMs).Equal(1000.0)
+	})
+}
@@ -0,0 +1,50 @@
+package model
+
+import (
+	"sync"
+
+	"github.com/secmon-lab/alertchain/pkg/domain/types"
+)
+
+// TimelineEntry is an action run by a workflow. Result is DryRunResult of built-in action in dry-run mode.
+type TimelineEntry struct {
+	AlertID types.AlertID    `json:"alert_id"`
+	Seq     int              `json:"seq"`
+	ID      types.ActionID   `json:"id"`
+	Uses    types.ActionName `json:"uses"`
+	Args    ActionArgs       `json:"args"`
+	Force   bool             `json:"force,omitempty"`
+	Commit  []Commit         `json:"commit,omitempty"`
+	Result  any              `json:"result,omitempty"`
+}
+
+// Timeline collects actions run by workflows in order. It is used to show the planned actions in dry-run mode.
+type Timeline struct {
+	mutex   sync.Mutex
+	entries []*TimelineEntry
+}
+
+func (x *Timeline) Add(alertID types.AlertID, seq int, result *ActionResult) {
+	x.mutex.Lock()
+	defer x.mutex.Unlock()
+
+	x.entries = append(x.entries, &TimelineEntry{
+		AlertID: alertID,
+		Seq:     seq,
+		ID:      result.ID,
+		Uses:    result.Uses,
+		Args:    result.Args,
+		Force:   result.Force,
+		Commit:  result.Commit,
+		Result:  result.Result,
+	})
+}
+
+func (x *Timeline) Entries() []*TimelineEntry {
+	x.mutex.Lock()
+	defer x.mutex.Unlock()
+
+	entries := make([]*TimelineEntry, len(x.entries))
+	copy(entries, x.entries)
+	return entries
+}
@@ -0,0 +1,21 @@
+package model
+
+// PolicyTrace is a condensed explanation of a policy evaluation. It is built from OPA trace events when explain mode is enabled.
+type PolicyTrace struct {
+	Package string      `json:"package"`
+	Rules   []RuleTrace `json:"rules"`
+}
+
+// RuleTrace shows result of a rule evaluation. Fails has expressions that evaluated to false while the rule was not satisfied. Evaluated is false if the rule body was skipped by rule indexing of OPA because an indexed condition (e.g. `input.role == "guest"`) did not match.
+type RuleTrace struct {
+	Name      string      `json:"name"`
+	Location  string      `json:"location"`
+	Evaluated bool        `json:"evaluated"`
+	Result    bool        `json:"result"`
+	Fails     []ExprTrace `json:"fails,omitempty"`
+}
+
+type ExprTrace struct {
+	Expr     string `json:"expr"`
+	Location string `json:"location"`
+}
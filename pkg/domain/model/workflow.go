@@ -0,0 +1,141 @@
+package model
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/m-mizutani/goerr/v2"
+	"github.com/secmon-lab/alertchain/pkg/domain/types"
+)
+
+// Status of WorkflowRecord
+const (
+	WorkflowStatusRunning     = "running"
+	WorkflowStatusCompleted   = "completed"
+	WorkflowStatusFailed      = "failed"
+	WorkflowStatusInterrupted = "interrupted"
+)
+
+// Triage status of WorkflowRecord. It is changed by analyst via GraphQL mutation.
+const (
+	WorkflowTriageOpen         = "open"
+	WorkflowTriageAcknowledged = "acknowledged"
+	WorkflowTriageClosed       = "closed"
+)
+
+// Type of WorkflowEvent
+const (
+	WorkflowEventCreated           = "created"
+	WorkflowEventSequenceEvaluated = "sequence_evaluated"
+	WorkflowEventActionStarted     = "action_started"
+	WorkflowEventActionFinished    = "action_finished"
+	WorkflowEventFinished          = "finished"
+)
+
+func matchString(cond *string, v string) bool {
+	return cond == nil || *cond == v
+}
+
+// Match returns true if the workflow satisfies all conditions of the filter. Database that can not evaluate some conditions in query uses it to filter results.
+func (x *WorkflowFilter) Match(wf *WorkflowRecord) bool {
+	if x.CreatedAfter != nil && wf.CreatedAt.Before(*x.CreatedAfter) {
+		return false
+	}
+	if x.CreatedBefore != nil && !wf.CreatedAt.Before(*x.CreatedBefore) {
+		return false
+	}
+	if !matchString(x.Status, wf.Status) {
+		return false
+	}
+	if x.Error != nil && (wf.Error == nil || !strings.Contains(*wf.Error, *x.Error)) {
+		return false
+	}
+
+	if x.Action != nil {
+		found := false
+		for _, action := range wf.Actions {
+			if action != nil && action.Uses == *x.Action {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	alert := wf.Alert
+	if alert == nil {
+		alert = &AlertRecord{}
+	}
+	if !matchString(x.Schema, alert.Schema) || !matchString(x.Source, alert.Source) {
+		return false
+	}
+	if x.Namespace != nil && (alert.Namespace == nil || *alert.Namespace != *x.Namespace) {
+		return false
+	}
+	if x.Title != nil && !strings.Contains(strings.ToLower(alert.Title), strings.ToLower(*x.Title)) {
+		return false
+	}
+
+	if x.AttrKey != nil || x.AttrValue != nil {
+		found := false
+		for _, attrs := range [][]*AttributeRecord{alert.InitAttrs, alert.LastAttrs} {
+			for _, attr := range attrs {
+				if attr != nil && matchString(x.AttrKey, attr.Key) && matchString(x.AttrValue, attr.Value) {
+					found = true
+					break
+				}
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+// WorkflowCursor is a position in workflows sorted in descending order of CreatedAt and ID. ID breaks a tie of CreatedAt.
+type WorkflowCursor struct {
+	CreatedAt time.Time        `json:"t"`
+	ID        types.WorkflowID `json:"id"`
+}
+
+// NewWorkflowCursor returns the cursor pointing the workflow.
+func NewWorkflowCursor(wf *WorkflowRecord) *WorkflowCursor {
+	return &WorkflowCursor{CreatedAt: wf.CreatedAt, ID: wf.ID}
+}
+
+// ParseWorkflowCursor decodes the cursor encoded by Encode.
+func ParseWorkflowCursor(s string) (*WorkflowCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, goerr.Wrap(err, "invalid cursor", goerr.V("cursor", s), goerr.T(types.ErrTagBadRequest))
+	}
+
+	var cursor WorkflowCursor
+	if err := json.Unmarshal(raw, &cursor); err != nil {
+		return nil, goerr.Wrap(err, "invalid cursor", goerr.V("cursor", s), goerr.T(types.ErrTagBadRequest))
+	}
+	if cursor.ID == "" {
+		return nil, goerr.New("invalid cursor, ID is empty", goerr.V("cursor", s), goerr.T(types.ErrTagBadRequest))
+	}
+	return &cursor, nil
+}
+
+// Encode returns opaque string of the cursor for client.
+func (x *WorkflowCursor) Encode() string {
+	raw, _ := json.Marshal(x) // never fails
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+// Follows returns true if wf is placed after the cursor.
+func (x *WorkflowCursor) Follows(wf *WorkflowRecord) bool {
+	if !wf.CreatedAt.Equal(x.CreatedAt) {
+		return wf.CreatedAt.Before(x.CreatedAt)
+	}
+	return wf.ID < x.ID
+}
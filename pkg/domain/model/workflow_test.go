@@ -0,0 +1,86 @@
+package model_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/m-mizutani/goerr/v2"
+	"github.com/m-mizutani/gots/ptr"
+	"github.com/m-mizutani/gt"
+	"github.com/secmon-lab/alertchain/pkg/domain/model"
+	"github.com/secmon-lab/alertchain/pkg/domain/types"
+)
+
+func TestWorkflowFilterMatch(t *testing.T) {
+	now := time.Now()
+	wf := &model.WorkflowRecord{
+		CreatedAt: now,
+		Status:    model.WorkflowStatusFailed,
+		Error:     ptr.To("failed to run action: timeout"),
+		Alert: &model.AlertRecord{
+			Schema:    "guardduty",
+			Title:     "Suspicious SSH Login",
+			Source:    "aws",
+			Namespace: ptr.To("host-1"),
+			InitAttrs: []*model.AttributeRecord{{Key: "src_ip", Value: "192.0.2.1"}},
+			LastAttrs: []*model.AttributeRecord{{Key: "severity", Value: "high"}},
+		},
+		Actions: []*model.ActionRecord{{Uses: "slack.post"}},
+	}
+
+	testCases := map[string]struct {
+		filter model.WorkflowFilter
+		expect bool
+	}{
+		"empty filter":                          {filter: model.WorkflowFilter{}, expect: true},
+		"all conditions":                        {filter: model.WorkflowFilter{Schema: ptr.To("guardduty"), Source: ptr.To("aws"), Namespace: ptr.To("host-1"), Status: ptr.To("failed"), Action: ptr.To("slack.post")}, expect: true},
+		"title ignores case":                    {filter: model.WorkflowFilter{Title: ptr.To("ssh login")}, expect: true},
+		"title not matched":                     {filter: model.WorkflowFilter{Title: ptr.To("rdp")}, expect: false},
+		"time range":                            {filter: model.WorkflowFilter{CreatedAfter: ptr.To(now), CreatedBefore: ptr.To(now.Add(time.Second))}, expect: true},
+		"created before is open":                {filter: model.WorkflowFilter{CreatedBefore: ptr.To(now)}, expect: false},
+		"init attribute":                        {filter: model.WorkflowFilter{AttrKey: ptr.To("src_ip"), AttrValue: ptr.To("192.0.2.1")}, expect: true},
+		"last attribute value":                  {filter: model.WorkflowFilter{AttrValue: ptr.To("high")}, expect: true},
+		"key and value of different attributes": {filter: model.WorkflowFilter{AttrKey: ptr.To("src_ip"), AttrValue: ptr.To("high")}, expect: false},
+		"action not executed":                   {filter: model.WorkflowFilter{Action: ptr.To("jira.create")}, expect: false},
+		"error substring":                       {filter: model.WorkflowFilter{Error: ptr.To("timeout")}, expect: true},
+		"other namespace":                       {filter: model.WorkflowFilter{Namespace: ptr.To("host-2")}, expect: false},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			gt.V(t, tc.filter.Match(wf)).Equal(tc.expect)
+		})
+	}
+
+	t.Run("error of succeeded workflow", func(t *testing.T) {
+		filter := model.WorkflowFilter{Error: ptr.To("")}
+		gt.B(t, filter.Match(&model.WorkflowRecord{})).False()
+	})
+}
+
+func TestWorkflowCursor(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 123456000, time.UTC)
+	cursor := model.NewWorkflowCursor(&model.WorkflowRecord{ID: "b", CreatedAt: now})
+
+	t.Run("encode and parse", func(t *testing.T) {
+		parsed := gt.R1(model.ParseWorkflowCursor(cursor.Encode())).NoError(t)
+		gt.V(t, parsed.ID).Equal(cursor.ID)
+		gt.B(t, parsed.CreatedAt.Equal(now)).True()
+	})
+
+	t.Run("invalid cursor", func(t *testing.T) {
+		for _, s := range []string{"%%%", "bm90IGpzb24", "e30"} {
+			_, err := model.ParseWorkflowCursor(s)
+			gt.Error(t, err)
+			gt.B(t, goerr.HasTag(err, types.ErrTagBadRequest)).True()
+		}
+	})
+
+	t.Run("follows", func(t *testing.T) {
+		gt.B(t, cursor.Follows(&model.WorkflowRecord{ID: "z", CreatedAt: now.Add(-time.Second)})).True()
+		gt.B(t, cursor.Follows(&model.WorkflowRecord{ID: "a", CreatedAt: now})).True()
+		gt.B(t, cursor.Follows(&model.WorkflowRecord{ID: "b", CreatedAt: now})).False()
+		gt.B(t, cursor.Follows(&model.WorkflowRecord{ID: "c", CreatedAt: now})).False()
+		gt.B(t, cursor.Follows(&model.WorkflowRecord{ID: "a", CreatedAt: now.Add(time.Second)})).False()
+	})
+}
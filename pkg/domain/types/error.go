@@ -32,6 +32,15 @@ var (
 	// ErrTagBadRequest is a tag for bad request to AlertChain server or runtime.
 	ErrTagBadRequest = goerr.NewTag("bad_request")
 
+	// ErrTagUnauthorized is a tag for request that failed authentication, e.g. invalid signature of the message.
+	ErrTagUnauthorized = goerr.NewTag("unauthorized")
+
+	// ErrTagTooLarge is a tag for request that exceeds size limit, e.g. request body or number of events in batch.
+	ErrTagTooLarge = goerr.NewTag("too_large")
+
+	// ErrTagForbidden is a tag for request that is authenticated but not permitted, e.g. dry-run not allowed by authz policy.
+	ErrTagForbidden = goerr.NewTag("forbidden")
+
 	// ErrTagSystem is a tag for unexpected system behavior. E.g. I/O error, system call failure, database error, error from integrated system, connection error, etc.
 	ErrTagSystem = goerr.NewTag("system")
 )
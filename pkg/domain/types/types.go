@@ -25,6 +25,10 @@ type (
 	Namespace string
 
 	WorkflowID string
+
+	DecisionID string
+
+	AuditID string
 )
 
 // EnvVars is a set of environment variables
@@ -36,7 +40,11 @@ func NewActionID() ActionID   { return ActionID(uuid.New().String()) }
 func NewWorkflowID() WorkflowID {
 	return WorkflowID(uuid.NewString())
 }
+func NewDecisionID() DecisionID { return DecisionID(uuid.NewString()) }
+func NewAuditID() AuditID       { return AuditID(uuid.NewString()) }
 
 func (x RequestID) String() string  { return string(x) }
 func (x AlertID) String() string    { return string(x) }
 func (x WorkflowID) String() string { return string(x) }
+func (x DecisionID) String() string { return string(x) }
+func (x AuditID) String() string    { return string(x) }
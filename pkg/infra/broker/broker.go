@@ -0,0 +1,80 @@
+package broker
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+
+	"github.com/secmon-lab/alertchain/pkg/ctxutil"
+	"github.com/secmon-lab/alertchain/pkg/domain/model"
+)
+
+// defaultBufferSize is number of events that a subscriber can hold before they are dropped.
+const defaultBufferSize = 256
+
+// Broker delivers workflow events to subscribers in the same process. It does not share events between server instances.
+type Broker struct {
+	mutex       sync.RWMutex
+	subscribers map[*subscriber]struct{}
+	bufferSize  int
+}
+
+type subscriber struct {
+	ch chan *model.WorkflowEvent
+}
+
+type Option func(b *Broker)
+
+// WithBufferSize sets number of events buffered for each subscriber. Events are dropped for a subscriber that does not receive them fast enough.
+func WithBufferSize(size int) Option {
+	return func(b *Broker) {
+		b.bufferSize = size
+	}
+}
+
+func New(options ...Option) *Broker {
+	b := &Broker{
+		subscribers: make(map[*subscriber]struct{}),
+		bufferSize:  defaultBufferSize,
+	}
+	for _, opt := range options {
+		opt(b)
+	}
+	return b
+}
+
+// Publish sends the event to all subscribers without blocking.
+func (x *Broker) Publish(ctx context.Context, event *model.WorkflowEvent) {
+	x.mutex.RLock()
+	defer x.mutex.RUnlock()
+
+	for sub := range x.subscribers {
+		select {
+		case sub.ch <- event:
+		default:
+			ctxutil.Logger(ctx).Warn("workflow event is dropped for slow subscriber",
+				slog.Any("workflow_id", event.WorkflowID),
+				slog.String("type", event.Type),
+			)
+		}
+	}
+}
+
+// Subscribe returns a channel of events published after the call. The channel is closed when ctx is done.
+func (x *Broker) Subscribe(ctx context.Context) <-chan *model.WorkflowEvent {
+	sub := &subscriber{ch: make(chan *model.WorkflowEvent, x.bufferSize)}
+
+	x.mutex.Lock()
+	x.subscribers[sub] = struct{}{}
+	x.mutex.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		x.mutex.Lock()
+		delete(x.subscribers, sub)
+		x.mutex.Unlock()
+		close(sub.ch)
+	}()
+
+	return sub.ch
+}
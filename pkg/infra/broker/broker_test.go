@@ -0,0 +1,52 @@
+package broker_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/m-mizutani/gt"
+	"github.com/secmon-lab/alertchain/pkg/domain/model"
+	"github.com/secmon-lab/alertchain/pkg/domain/types"
+	"github.com/secmon-lab/alertchain/pkg/infra/broker"
+)
+
+func TestBroker(t *testing.T) {
+	ctx := context.Background()
+	b := broker.New(broker.WithBufferSize(2))
+
+	ctx1, cancel1 := context.WithCancel(ctx)
+	ch1 := b.Subscribe(ctx1)
+	ctx2, cancel2 := context.WithCancel(ctx)
+	defer cancel2()
+	ch2 := b.Subscribe(ctx2)
+
+	wfID := types.NewWorkflowID()
+	for _, typ := range []string{model.WorkflowEventCreated, model.WorkflowEventSequenceEvaluated, model.WorkflowEventFinished} {
+		b.Publish(ctx, &model.WorkflowEvent{Type: typ, WorkflowID: wfID})
+	}
+
+	t.Run("deliver to all subscribers", func(t *testing.T) {
+		gt.V(t, (<-ch1).Type).Equal(model.WorkflowEventCreated)
+		gt.V(t, (<-ch2).Type).Equal(model.WorkflowEventCreated)
+	})
+
+	t.Run("drop events exceeding buffer", func(t *testing.T) {
+		gt.V(t, (<-ch2).Type).Equal(model.WorkflowEventSequenceEvaluated)
+		select {
+		case ev := <-ch2:
+			t.Errorf("unexpected event: %v", ev)
+		default:
+		}
+	})
+
+	t.Run("close channel after unsubscribe", func(t *testing.T) {
+		cancel1()
+		gt.V(t, (<-ch1).Type).Equal(model.WorkflowEventSequenceEvaluated)
+		_, ok := <-ch1
+		gt.B(t, ok).False()
+
+		// Publish after unsubscribe does not panic
+		b.Publish(ctx, &model.WorkflowEvent{Type: model.WorkflowEventCreated, WorkflowID: wfID})
+		gt.V(t, (<-ch2).Type).Equal(model.WorkflowEventCreated)
+	})
+}
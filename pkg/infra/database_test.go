@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/m-mizutani/gots/ptr"
 	"github.com/m-mizutani/gt"
 	"github.com/secmon-lab/alertchain/pkg/ctxutil"
 	"github.com/secmon-lab/alertchain/pkg/domain/interfaces"
@@ -42,15 +43,36 @@ func TestFirestore(t *testing.T) {
 }
 
 func testClient(t *testing.T, client interfaces.Database) {
+	t.Run("Ping", func(t *testing.T) {
+		gt.NoError(t, client.Ping(context.Background()))
+	})
 	t.Run("PutGet", func(t *testing.T) {
 		testPutGet(t, client)
 	})
+	t.Run("PubSubMessage", func(t *testing.T) {
+		testPubSubMessage(t, client)
+	})
 	t.Run("Lock", func(t *testing.T) {
 		testLock(t, client)
 	})
 	t.Run("LockExpire", func(t *testing.T) {
 		testLockExpires(t, client)
 	})
+	t.Run("DeleteAttrs", func(t *testing.T) {
+		testDeleteAttrs(t, client)
+	})
+	t.Run("AttrExplorer", func(t *testing.T) {
+		testAttrExplorer(t, client)
+	})
+	t.Run("WorkflowStats", func(t *testing.T) {
+		testWorkflowStats(t, client)
+	})
+	t.Run("WorkflowsAfter", func(t *testing.T) {
+		testWorkflowsAfter(t, client)
+	})
+	t.Run("SearchWorkflows", func(t *testing.T) {
+		testSearchWorkflows(t, client)
+	})
 	t.Run("Workflow", func(t *testing.T) {
 		testWorkflow(t, client)
 	})
@@ -195,6 +217,95 @@ func testLockExpires(t *testing.T, client interfaces.Database) {
 	gt.NoError(t, client.Lock(ctx, ns, time.Now().Add(100*time.Millisecond)))
 }
 
+func testDeleteAttrs(t *testing.T, client interfaces.Database) {
+	ctx := context.Background()
+	ns := types.Namespace("test-delete-" + uuid.NewString())
+
+	attrs := model.Attributes{
+		{ID: types.NewAttrID(), Key: "color", Value: "blue", Persist: true},
+		{ID: types.NewAttrID(), Key: "owner", Value: "alice", Persist: true},
+	}
+	gt.NoError(t, client.PutAttrs(ctx, ns, attrs))
+
+	// Unknown ID is ignored
+	gt.NoError(t, client.DeleteAttrs(ctx, ns, []types.AttrID{attrs[0].ID, types.NewAttrID()}))
+
+	got := gt.R1(client.GetAttrs(ctx, ns)).NoError(t)
+	gt.A(t, got).Length(1).At(0, func(t testing.TB, v model.Attribute) {
+		gt.V(t, v.ID).Equal(attrs[1].ID)
+	})
+
+	// Namespace that has no attribute
+	gt.NoError(t, client.DeleteAttrs(ctx, types.Namespace(uuid.NewString()), []types.AttrID{attrs[0].ID}))
+
+	t.Run("Unlock without lock", func(t *testing.T) {
+		gt.NoError(t, client.Lock(ctx, ns, time.Now().Add(time.Minute)))
+		gt.NoError(t, client.Unlock(ctx, ns))
+		// Lock may be already released by administrator
+		gt.NoError(t, client.Unlock(ctx, ns))
+	})
+}
+
+func testAttrExplorer(t *testing.T, client interfaces.Database) {
+	ctx := context.Background()
+	ns := types.Namespace("test-explorer-" + uuid.NewString())
+	wfID := types.NewWorkflowID()
+
+	attr := model.Attribute{ID: types.NewAttrID(), Key: "suppress", Value: "true", Persist: true, TTL: 3600}
+	gt.NoError(t, client.PutAttrs(ctxutil.InjectWorkflowID(ctx, wfID), ns, model.Attributes{attr}))
+
+	attr.Value = "false"
+	gt.NoError(t, client.PutAttrs(ctxutil.InjectActor(ctx, &model.Actor{Subject: "alice"}), ns, model.Attributes{attr}))
+
+	t.Run("namespace is listed with plain name", func(t *testing.T) {
+		var found *model.NamespaceRecord
+		for offset := 0; found == nil; offset += 100 {
+			namespaces := gt.R1(client.GetNamespaces(ctx, offset, 100)).NoError(t)
+			if len(namespaces) == 0 {
+				break
+			}
+			for i := range namespaces {
+				if namespaces[i].Name == string(ns) {
+					found = &namespaces[i]
+				}
+			}
+		}
+		gt.V(t, found).Must().NotNil()
+		gt.B(t, found.UpdatedAt.IsZero()).False()
+	})
+
+	t.Run("attribute has expiration and last writer", func(t *testing.T) {
+		attrs := gt.R1(client.GetPersistentAttrs(ctx, ns)).NoError(t)
+		gt.A(t, attrs).Length(1).At(0, func(t testing.TB, v model.PersistentAttribute) {
+			gt.V(t, v.ID).Equal(attr.ID)
+			gt.V(t, v.Value).Equal("false")
+			gt.V(t, v.WorkflowID).Equal("")
+			gt.V(t, v.Actor).Equal("alice")
+			gt.B(t, v.ExpiresAt.After(time.Now().Add(50*time.Minute))).True()
+		})
+	})
+
+	t.Run("history is in descending order", func(t *testing.T) {
+		history := gt.R1(client.GetAttrHistory(ctx, ns, attr.ID, 10)).NoError(t)
+		gt.A(t, history).Length(2).At(0, func(t testing.TB, v model.AttributeChange) {
+			gt.V(t, v.Value).Equal("false")
+			gt.V(t, v.Actor).Equal("alice")
+		}).At(1, func(t testing.TB, v model.AttributeChange) {
+			gt.V(t, v.Value).Equal("true")
+			gt.V(t, v.WorkflowID).Equal(wfID)
+		})
+
+		history = gt.R1(client.GetAttrHistory(ctx, ns, attr.ID, 1)).NoError(t)
+		gt.A(t, history).Length(1)
+	})
+
+	t.Run("history is kept after deletion", func(t *testing.T) {
+		gt.NoError(t, client.DeleteAttrs(ctx, ns, []types.AttrID{attr.ID}))
+		gt.A(t, gt.R1(client.GetPersistentAttrs(ctx, ns)).NoError(t)).Length(0)
+		gt.A(t, gt.R1(client.GetAttrHistory(ctx, ns, attr.ID, 10)).NoError(t)).Length(2)
+	})
+}
+
 func testWorkflow(t *testing.T, client interfaces.Database) {
 	now := time.Now()
 	workflows := []model.WorkflowRecord{
@@ -285,3 +396,203 @@ func testAlert(t *testing.T, client interfaces.Database) {
 		gt.V(t, resp.ID).Equal(alerts[1].ID)
 	})
 }
+
+func testPubSubMessage(t *testing.T, client interfaces.Database) {
+	ctx := context.Background()
+	key := "projects/test/subscriptions/test/" + uuid.NewString()
+	running := model.PubSubMessageRecord{
+		Key:       key,
+		Status:    model.PubSubMessageRunning,
+		ExpiresAt: time.Now().Add(time.Minute),
+	}
+
+	gt.V(t, gt.R1(client.ClaimPubSubMessage(ctx, running)).NoError(t)).Nil()
+
+	current := gt.R1(client.ClaimPubSubMessage(ctx, running)).NoError(t)
+	gt.V(t, current).NotNil()
+	gt.V(t, current.Status).Equal(model.PubSubMessageRunning)
+
+	completed := running
+	completed.Status = model.PubSubMessageCompleted
+	gt.NoError(t, client.PutPubSubMessage(ctx, completed))
+	current = gt.R1(client.ClaimPubSubMessage(ctx, running)).NoError(t)
+	gt.V(t, current.Status).Equal(model.PubSubMessageCompleted)
+
+	// Released message can be claimed again
+	gt.NoError(t, client.DeletePubSubMessage(ctx, key))
+	gt.V(t, gt.R1(client.ClaimPubSubMessage(ctx, running)).NoError(t)).Nil()
+
+	// Expired record does not block claim
+	expired := model.PubSubMessageRecord{
+		Key:       "projects/test/subscriptions/test/" + uuid.NewString(),
+		Status:    model.PubSubMessageCompleted,
+		ExpiresAt: time.Now().Add(-time.Second),
+	}
+	gt.NoError(t, client.PutPubSubMessage(ctx, expired))
+	expired.Status = model.PubSubMessageRunning
+	expired.ExpiresAt = time.Now().Add(time.Minute)
+	gt.V(t, gt.R1(client.ClaimPubSubMessage(ctx, expired)).NoError(t)).Nil()
+}
+
+func testSearchWorkflows(t *testing.T, client interfaces.Database) {
+	ctx := context.Background()
+	now := time.Now()
+
+	// Unique schema to avoid conflict with other records
+	schema := "test-search-" + types.NewWorkflowID().String()
+	newWorkflow := func(createdAt time.Time, title, ip, uses string) model.WorkflowRecord {
+		return model.WorkflowRecord{
+			ID:        types.NewWorkflowID(),
+			CreatedAt: createdAt,
+			Status:    model.WorkflowStatusCompleted,
+			Alert: &model.AlertRecord{
+				ID:        types.NewAlertID(),
+				Schema:    schema,
+				Title:     title,
+				CreatedAt: createdAt,
+				InitAttrs: []*model.AttributeRecord{{ID: "a1", Key: "src_ip", Value: ip}},
+			},
+			Actions: []*model.ActionRecord{{ID: "x1", Uses: uses, StartedAt: createdAt, FinishedAt: createdAt}},
+		}
+	}
+	wf0 := newWorkflow(now.Add(-2*time.Hour), "SSH brute force", "192.0.2.1", "slack.post")
+	wf1 := newWorkflow(now.Add(-time.Hour), "Port scan", "192.0.2.1", "jira.create")
+	wf2 := newWorkflow(now, "SSH login", "198.51.100.1", "slack.post")
+	for _, wf := range []model.WorkflowRecord{wf0, wf1, wf2} {
+		gt.NoError(t, client.PutWorkflow(ctx, wf))
+	}
+
+	ids := func(workflows []model.WorkflowRecord) []types.WorkflowID {
+		var ret []types.WorkflowID
+		for _, wf := range workflows {
+			ret = append(ret, wf.ID)
+		}
+		return ret
+	}
+
+	t.Run("attribute value", func(t *testing.T) {
+		filter := model.WorkflowFilter{Schema: &schema, AttrValue: ptr.To("192.0.2.1")}
+		got := gt.R1(client.SearchWorkflows(ctx, filter, 0, 10)).NoError(t)
+		gt.V(t, ids(got)).Equal([]types.WorkflowID{wf1.ID, wf0.ID})
+	})
+
+	t.Run("attribute and action with post filter", func(t *testing.T) {
+		filter := model.WorkflowFilter{Schema: &schema, AttrKey: ptr.To("src_ip"), AttrValue: ptr.To("192.0.2.1"), Action: ptr.To("slack.post")}
+		got := gt.R1(client.SearchWorkflows(ctx, filter, 0, 10)).NoError(t)
+		gt.V(t, ids(got)).Equal([]types.WorkflowID{wf0.ID})
+	})
+
+	t.Run("title and offset", func(t *testing.T) {
+		filter := model.WorkflowFilter{Schema: &schema, Title: ptr.To("ssh")}
+		got := gt.R1(client.SearchWorkflows(ctx, filter, 1, 10)).NoError(t)
+		gt.V(t, ids(got)).Equal([]types.WorkflowID{wf0.ID})
+	})
+
+	t.Run("time range", func(t *testing.T) {
+		filter := model.WorkflowFilter{Schema: &schema, CreatedAfter: ptr.To(now.Add(-90 * time.Minute))}
+		got := gt.R1(client.SearchWorkflows(ctx, filter, 0, 10)).NoError(t)
+		gt.V(t, ids(got)).Equal([]types.WorkflowID{wf2.ID, wf1.ID})
+	})
+}
+
+func testWorkflowsAfter(t *testing.T, client interfaces.Database) {
+	ctx := context.Background()
+	// Before time range of testWorkflowStats not to be mixed with other records
+	base := time.Date(1980, 1, 1, 0, 0, 0, 0, time.UTC).Add(time.Duration(rand.Intn(24*365*20)) * time.Hour)
+
+	newWorkflow := func(createdAt time.Time) model.WorkflowRecord {
+		return model.WorkflowRecord{
+			ID:        types.NewWorkflowID(),
+			CreatedAt: createdAt,
+			Alert:     &model.AlertRecord{ID: types.NewAlertID(), CreatedAt: createdAt},
+		}
+	}
+	workflows := []model.WorkflowRecord{
+		newWorkflow(base.Add(4 * time.Second)),
+		newWorkflow(base.Add(3 * time.Second)),
+		newWorkflow(base.Add(3 * time.Second)),
+		newWorkflow(base.Add(2 * time.Second)),
+		newWorkflow(base.Add(time.Second)),
+	}
+	// ID breaks the tie of CreatedAt in descending order
+	if workflows[1].ID < workflows[2].ID {
+		workflows[1], workflows[2] = workflows[2], workflows[1]
+	}
+	for _, wf := range workflows {
+		gt.NoError(t, client.PutWorkflow(ctx, wf))
+	}
+
+	t.Run("paginate with cursor", func(t *testing.T) {
+		cursor := &model.WorkflowCursor{CreatedAt: base.Add(5 * time.Second)}
+		var got []types.WorkflowID
+		for len(got) < len(workflows) {
+			resp := gt.R1(client.GetWorkflowsAfter(ctx, cursor, 2)).NoError(t)
+			if len(resp) == 0 {
+				break
+			}
+			gt.N(t, len(resp)).LessOrEqual(2)
+			for _, wf := range resp {
+				got = append(got, wf.ID)
+			}
+			cursor = model.NewWorkflowCursor(&resp[len(resp)-1])
+		}
+
+		gt.A(t, got).Length(len(workflows))
+		for i, wf := range workflows {
+			gt.V(t, got[i]).Equal(wf.ID)
+		}
+	})
+
+	t.Run("from the newest without cursor", func(t *testing.T) {
+		resp := gt.R1(client.GetWorkflowsAfter(ctx, nil, 3)).NoError(t)
+		gt.A(t, resp).Length(3)
+		for i := 1; i < len(resp); i++ {
+			gt.B(t, resp[i].CreatedAt.After(resp[i-1].CreatedAt)).False()
+		}
+	})
+}
+
+func testWorkflowStats(t *testing.T, client interfaces.Database) {
+	ctx := context.Background()
+	// Time range in the past to avoid conflict with other records
+	from := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC).Add(time.Duration(rand.Intn(24*365*20)) * time.Hour)
+	to := from.Add(2 * time.Hour)
+
+	schema := "test-stats-" + uuid.NewString()
+	newWorkflow := func(createdAt time.Time, err *string) model.WorkflowRecord {
+		return model.WorkflowRecord{
+			ID:        types.NewWorkflowID(),
+			CreatedAt: createdAt,
+			Alert: &model.AlertRecord{
+				ID:        types.NewAlertID(),
+				Schema:    schema,
+				Source:    "test",
+				Title:     "stats test",
+				CreatedAt: createdAt,
+			},
+			Actions: []*model.ActionRecord{
+				{ID: "a", Uses: "mock", StartedAt: createdAt, FinishedAt: createdAt.Add(10 * time.Millisecond), Error: err},
+			},
+		}
+	}
+	for _, wf := range []model.WorkflowRecord{
+		newWorkflow(from, nil),
+		newWorkflow(from.Add(90*time.Minute), ptr.To("failed")),
+		newWorkflow(to, nil), // out of range
+	} {
+		gt.NoError(t, client.PutWorkflow(ctx, wf))
+	}
+
+	stats := gt.R1(client.GetWorkflowStats(ctx, from, to, time.Hour)).NoError(t)
+	gt.A(t, stats.AlertsBySchema).Length(1).At(0, func(t testing.TB, v *model.AlertCount) {
+		gt.V(t, v.Key).Equal(schema)
+		gt.V(t, v.Total).Equal(2)
+		gt.V(t, v.Buckets).Equal([]int{1, 1})
+	})
+	gt.A(t, stats.Actions).Length(1).At(0, func(t testing.TB, v *model.ActionStats) {
+		gt.V(t, v.Uses).Equal("mock")
+		gt.V(t, v.Executions).Equal(2)
+		gt.V(t, v.Failures).Equal(1)
+		gt.V(t, v.MeanLatencyMs).Equal(10.0)
+	})
+}
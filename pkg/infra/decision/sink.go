@@ -0,0 +1,57 @@
+package decision
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"sync"
+
+	"github.com/m-mizutani/goerr/v2"
+	"github.com/secmon-lab/alertchain/pkg/domain/interfaces"
+	"github.com/secmon-lab/alertchain/pkg/domain/model"
+	"github.com/secmon-lab/alertchain/pkg/domain/types"
+)
+
+// JSONL writes decision logs as JSON Lines. It can be used for both of file and stdout.
+type JSONL struct {
+	w     io.Writer
+	mutex sync.Mutex
+}
+
+var _ interfaces.DecisionLogSink = &JSONL{}
+
+func NewJSONL(w io.Writer) *JSONL {
+	return &JSONL{w: w}
+}
+
+// Write implements interfaces.DecisionLogSink.
+func (x *JSONL) Write(ctx context.Context, log *model.DecisionLog) error {
+	raw, err := json.Marshal(log)
+	if err != nil {
+		return goerr.Wrap(err, "failed to marshal decision log", goerr.V("id", log.ID))
+	}
+
+	x.mutex.Lock()
+	defer x.mutex.Unlock()
+
+	if _, err := x.w.Write(append(raw, '\n')); err != nil {
+		return goerr.Wrap(err, "failed to write decision log", goerr.V("id", log.ID), goerr.T(types.ErrTagSystem))
+	}
+	return nil
+}
+
+// Database stores decision logs into interfaces.Database.
+type Database struct {
+	db interfaces.Database
+}
+
+var _ interfaces.DecisionLogSink = &Database{}
+
+func NewDatabase(db interfaces.Database) *Database {
+	return &Database{db: db}
+}
+
+// Write implements interfaces.DecisionLogSink.
+func (x *Database) Write(ctx context.Context, log *model.DecisionLog) error {
+	return x.db.PutDecisionLog(ctx, *log)
+}
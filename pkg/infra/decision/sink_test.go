@@ -0,0 +1,50 @@
+package decision_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/m-mizutani/gt"
+	"github.com/secmon-lab/alertchain/pkg/domain/model"
+	"github.com/secmon-lab/alertchain/pkg/domain/types"
+	"github.com/secmon-lab/alertchain/pkg/infra/decision"
+	"github.com/secmon-lab/alertchain/pkg/infra/policy"
+)
+
+func TestJSONL(t *testing.T) {
+	var buf bytes.Buffer
+	sink := decision.NewJSONL(&buf)
+
+	client := gt.R1(policy.New(
+		policy.WithPolicyData("test.rego", `package test
+allow if { input.user == "blue" }`),
+		policy.WithPackage("test"),
+	)).NoError(t)
+
+	ctx := context.Background()
+	input := map[string]any{
+		"user":       "blue",
+		"secret_key": "xxx",
+	}
+
+	var out struct {
+		Allow bool `json:"allow"`
+	}
+	gt.NoError(t, client.Query(ctx, input, &out, policy.WithDecisionLogSink(sink)))
+	gt.B(t, out.Allow).True()
+	gt.NoError(t, client.Query(ctx, input, &out, policy.WithDecisionLogSink(sink)))
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	gt.A(t, lines).Length(2)
+
+	var log model.DecisionLog
+	gt.NoError(t, json.Unmarshal([]byte(lines[0]), &log))
+	gt.V(t, log.Package).Equal("test")
+	gt.V(t, log.PolicyHash).Equal(client.Hash())
+	gt.S(t, lines[0]).NotContains("xxx")
+	gt.V(t, log.Output).Equal(any(map[string]any{"allow": true}))
+	gt.V(t, log.AlertID).Equal(types.AlertID(""))
+}
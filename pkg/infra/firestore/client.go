@@ -5,11 +5,14 @@ import (
 	"crypto/sha512"
 	"encoding/hex"
 	"errors"
+	"log/slog"
 	"math"
 	"math/rand"
+	"sort"
 	"time"
 
 	"cloud.google.com/go/firestore"
+	"cloud.google.com/go/firestore/apiv1/firestorepb"
 
 	"github.com/m-mizutani/goerr/v2"
 	"github.com/secmon-lab/alertchain/pkg/ctxutil"
@@ -28,6 +31,9 @@ type Client struct {
 	attrCollection     string
 	workflowCollection string
 	alertCollection    string
+	decisionCollection string
+	auditCollection    string
+	messageCollection  string
 }
 
 const (
@@ -35,9 +41,16 @@ const (
 	lockKeyPrefix     = "lock:"
 	workflowKeyPrefix = "workflow:"
 	alertKeyPrefix    = "alert:"
+	decisionKeyPrefix = "decision:"
+	auditKeyPrefix    = "audit:"
+	messageKeyPrefix  = "message:"
 )
 
 func hashNamespace(input types.Namespace) string {
+	return hashKey(string(input))
+}
+
+func hashKey(input string) string {
 	hash := sha512.New()
 	hash.Write([]byte(input))
 	hashed := hash.Sum(nil)
@@ -59,7 +72,7 @@ func (x *Client) GetAttrs(ctx context.Context, ns types.Namespace) (model.Attrib
 			continue
 		}
 
-		var attr attribute
+		var attr model.PersistentAttribute
 		if err := doc.DataTo(&attr); err != nil {
 			return nil, goerr.Wrap(err, "failed to unmarshal attribute from firestore", goerr.T(types.ErrTagSystem))
 		}
@@ -72,11 +85,114 @@ func (x *Client) GetAttrs(ctx context.Context, ns types.Namespace) (model.Attrib
 	return attrs, nil
 }
 
+// namespace is stored as parent document of attributes because the document key is hashed.
+type namespace struct {
+	Name      string    `firestore:"namespace"`
+	UpdatedAt time.Time `firestore:"updated_at"`
+}
+
+// GetNamespaces implements interfaces.Database. Namespaces whose attributes were stored only by older version are not returned because they do not have the parent document.
+func (x *Client) GetNamespaces(ctx context.Context, offset, limit int) ([]model.NamespaceRecord, error) {
+	// Lock documents in the same collection do not have namespace field, then they are excluded by OrderBy
+	iter := x.client.Collection(x.attrCollection).
+		OrderBy("namespace", firestore.Asc).
+		Offset(offset).
+		Limit(limit).
+		Documents(ctx)
+	defer iter.Stop()
+
+	var namespaces []model.NamespaceRecord
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, goerr.Wrap(err, "failed to get namespaces from firestore", goerr.T(types.ErrTagSystem))
+		}
+
+		var ns namespace
+		if err := doc.DataTo(&ns); err != nil {
+			return nil, goerr.Wrap(err, "failed to unmarshal namespace from firestore", goerr.V("id", doc.Ref.ID), goerr.T(types.ErrTagSystem))
+		}
+		namespaces = append(namespaces, model.NamespaceRecord{Name: ns.Name, UpdatedAt: ns.UpdatedAt})
+	}
+
+	return namespaces, nil
+}
+
+// GetPersistentAttrs implements interfaces.Database.
+func (x *Client) GetPersistentAttrs(ctx context.Context, ns types.Namespace) ([]model.PersistentAttribute, error) {
+	key := attrKeyPrefix + hashNamespace(ns)
+	docs, err := x.client.Collection(x.attrCollection).Doc(key).Collection("attributes").Documents(ctx).GetAll()
+	if err != nil {
+		return nil, goerr.Wrap(err, "failed to get attributes from firestore", goerr.T(types.ErrTagSystem))
+	}
+
+	now := time.Now().UTC()
+	var attrs []model.PersistentAttribute
+	for _, doc := range docs {
+		var attr model.PersistentAttribute
+		if err := doc.DataTo(&attr); err != nil {
+			return nil, goerr.Wrap(err, "failed to unmarshal attribute from firestore", goerr.T(types.ErrTagSystem))
+		}
+		if attr.ExpiresAt.Before(now) {
+			continue
+		}
+		attrs = append(attrs, attr)
+	}
+	sort.Slice(attrs, func(i, j int) bool {
+		return attrs[i].Key < attrs[j].Key
+	})
+
+	return attrs, nil
+}
+
+// GetAttrHistory implements interfaces.Database. History is kept after the attribute is deleted.
+func (x *Client) GetAttrHistory(ctx context.Context, ns types.Namespace, id types.AttrID, limit int) ([]model.AttributeChange, error) {
+	key := attrKeyPrefix + hashNamespace(ns)
+	iter := x.client.Collection(x.attrCollection).Doc(key).Collection("attributes").Doc(string(id)).Collection("history").
+		OrderBy("created_at", firestore.Desc).
+		Limit(limit).
+		Documents(ctx)
+	defer iter.Stop()
+
+	var history []model.AttributeChange
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, goerr.Wrap(err, "failed to get attribute history from firestore", goerr.V("id", id), goerr.T(types.ErrTagSystem))
+		}
+
+		var change model.AttributeChange
+		if err := doc.DataTo(&change); err != nil {
+			return nil, goerr.Wrap(err, "failed to unmarshal attribute history from firestore", goerr.T(types.ErrTagSystem))
+		}
+		history = append(history, change)
+	}
+
+	return history, nil
+}
+
 // PutAttrs implements interfaces.Database.
 func (x *Client) PutAttrs(ctx context.Context, ns types.Namespace, attrs model.Attributes) error {
+	if len(attrs) == 0 {
+		return nil
+	}
+
+	workflowID := ctxutil.GetWorkflowID(ctx)
+	var actor string
+	if a := ctxutil.GetActor(ctx); a != nil {
+		actor = a.Subject
+	}
+
 	err := x.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
 		key := attrKeyPrefix + hashNamespace(ns)
-		collection := x.client.Collection(x.attrCollection).Doc(key).Collection("attributes")
+		nsRef := x.client.Collection(x.attrCollection).Doc(key)
+		collection := nsRef.Collection("attributes")
 
 		attrRefMap := map[types.AttrID]*firestore.DocumentRef{}
 		for _, attr := range attrs {
@@ -92,29 +208,52 @@ func (x *Client) PutAttrs(ctx context.Context, ns types.Namespace, attrs model.A
 
 		now := time.Now().UTC()
 
+		// Plain name of the namespace is stored for GetNamespaces
+		if err := tx.Set(nsRef, namespace{Name: string(ns), UpdatedAt: now}); err != nil {
+			return goerr.Wrap(err, "failed to update namespace", goerr.T(types.ErrTagSystem))
+		}
+
 		for _, base := range attrs {
 			ttl := base.TTL
 			if ttl == 0 {
 				ttl = types.DefaultAttributeTTL
 			}
-			attr := attribute{
-				Attribute: base,
-				ExpiresAt: now.Add(time.Duration(ttl) * time.Second),
+			attr := model.PersistentAttribute{
+				Attribute:  base,
+				ExpiresAt:  now.Add(time.Duration(ttl) * time.Second),
+				UpdatedAt:  now,
+				WorkflowID: workflowID,
+				Actor:      actor,
 			}
 
-			if ref, ok := attrRefMap[attr.ID]; ok {
+			ref, ok := attrRefMap[attr.ID]
+			if ok {
 				if err := tx.Set(ref, map[string]any{
-					"value":      attr.Value,
-					"expires_at": attr.ExpiresAt,
+					"value":       attr.Value,
+					"expires_at":  attr.ExpiresAt,
+					"updated_at":  attr.UpdatedAt,
+					"workflow_id": attr.WorkflowID,
+					"actor":       attr.Actor,
 				}, firestore.MergeAll); err != nil {
 					return goerr.Wrap(err, "failed to update attribute", goerr.T(types.ErrTagSystem))
 				}
 			} else {
-				ref := collection.Doc(string(attr.ID))
+				ref = collection.Doc(string(attr.ID))
 				if err := tx.Create(ref, attr); err != nil {
 					return goerr.Wrap(err, "failed to create attribute", goerr.T(types.ErrTagSystem))
 				}
 			}
+
+			change := model.AttributeChange{
+				Value:      attr.Value,
+				ExpiresAt:  attr.ExpiresAt,
+				WorkflowID: workflowID,
+				Actor:      actor,
+				CreatedAt:  now,
+			}
+			if err := tx.Create(ref.Collection("history").NewDoc(), change); err != nil {
+				return goerr.Wrap(err, "failed to create attribute history", goerr.T(types.ErrTagSystem))
+			}
 		}
 
 		return nil
@@ -126,10 +265,67 @@ func (x *Client) PutAttrs(ctx context.Context, ns types.Namespace, attrs model.A
 	return nil
 }
 
+// DeleteAttrs implements interfaces.Database.
+func (x *Client) DeleteAttrs(ctx context.Context, ns types.Namespace, ids []types.AttrID) error {
+	err := x.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		key := attrKeyPrefix + hashNamespace(ns)
+		collection := x.client.Collection(x.attrCollection).Doc(key).Collection("attributes")
+
+		// Deleting a document that does not exist is not an error in Firestore
+		for _, id := range ids {
+			if err := tx.Delete(collection.Doc(string(id))); err != nil {
+				return goerr.Wrap(err, "failed to delete attribute", goerr.V("id", id), goerr.T(types.ErrTagSystem))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return goerr.Wrap(err, "failed firestore transaction", goerr.T(types.ErrTagSystem))
+	}
+
+	return nil
+}
+
+// workflowDoc is a workflow record with terms for SearchWorkflows. Fields of WorkflowRecord are stored at top level of the document.
+type workflowDoc struct {
+	model.WorkflowRecord
+	SearchTerms []string `firestore:"search_terms"`
+}
+
+// Search terms are hashed because length of an indexed value is limited.
+func attrKeyTerm(key string) string     { return hashKey("attr_key:" + key) }
+func attrValueTerm(value string) string { return hashKey("attr_value:" + value) }
+func attrTerm(key, value string) string { return hashKey("attr:" + key + "=" + value) }
+func actionTerm(uses string) string     { return hashKey("action:" + uses) }
+
+func searchTerms(wf *model.WorkflowRecord) []string {
+	set := map[string]struct{}{}
+	if wf.Alert != nil {
+		for _, attrs := range [][]*model.AttributeRecord{wf.Alert.InitAttrs, wf.Alert.LastAttrs} {
+			for _, attr := range attrs {
+				set[attrKeyTerm(attr.Key)] = struct{}{}
+				set[attrValueTerm(attr.Value)] = struct{}{}
+				set[attrTerm(attr.Key, attr.Value)] = struct{}{}
+			}
+		}
+	}
+	for _, action := range wf.Actions {
+		set[actionTerm(action.Uses)] = struct{}{}
+	}
+
+	terms := make([]string, 0, len(set))
+	for term := range set {
+		terms = append(terms, term)
+	}
+	sort.Strings(terms)
+	return terms
+}
+
 func (x *Client) PutWorkflow(ctx context.Context, workflow model.WorkflowRecord) error {
 	key := workflowKeyPrefix + workflow.ID
 
-	if _, err := x.client.Collection(x.workflowCollection).Doc(string(key)).Set(ctx, workflow); err != nil {
+	doc := workflowDoc{WorkflowRecord: workflow, SearchTerms: searchTerms(&workflow)}
+	if _, err := x.client.Collection(x.workflowCollection).Doc(string(key)).Set(ctx, doc); err != nil {
 		return goerr.Wrap(err, "failed to put workflow", goerr.T(types.ErrTagSystem))
 	}
 	return nil
@@ -160,6 +356,164 @@ func (x *Client) GetWorkflows(ctx context.Context, offset, limit int) ([]model.W
 	}
 }
 
+// GetWorkflowsAfter implements interfaces.Database. It requires composite index of CreatedAt and ID in descending order.
+func (x *Client) GetWorkflowsAfter(ctx context.Context, cursor *model.WorkflowCursor, limit int) ([]model.WorkflowRecord, error) {
+	q := x.client.Collection(x.workflowCollection).
+		OrderBy("CreatedAt", firestore.Desc).
+		OrderBy("ID", firestore.Desc)
+	if cursor != nil {
+		q = q.StartAfter(cursor.CreatedAt, string(cursor.ID))
+	}
+
+	var workflows []model.WorkflowRecord
+	iter := q.Limit(limit).Documents(ctx)
+	for {
+		doc, err := iter.Next()
+		if err != nil {
+			if errors.Is(err, iterator.Done) {
+				return workflows, nil
+			}
+			return nil, goerr.Wrap(err, "failed to get workflow", goerr.V("cursor", cursor), goerr.T(types.ErrTagSystem))
+		}
+
+		var workflow model.WorkflowRecord
+		if err := doc.DataTo(&workflow); err != nil {
+			return nil, goerr.Wrap(err, "failed to unmarshal workflow", goerr.T(types.ErrTagSystem))
+		}
+		workflows = append(workflows, workflow)
+	}
+}
+
+// maxStatsScan is the maximum number of workflows aggregated by GetWorkflowStats.
+const maxStatsScan = 100000
+
+// GetWorkflowStats implements interfaces.Database. Firestore can not group documents in query, then workflows in the time range are read with only fields required for aggregation after checking the number of them by count aggregation query.
+func (x *Client) GetWorkflowStats(ctx context.Context, from, to time.Time, interval time.Duration) (*model.Stats, error) {
+	q := x.client.Collection(x.workflowCollection).
+		Where("CreatedAt", ">=", from).
+		Where("CreatedAt", "<", to)
+
+	resp, err := q.NewAggregationQuery().WithCount("count").Get(ctx)
+	if err != nil {
+		return nil, goerr.Wrap(err, "failed to count workflows", goerr.T(types.ErrTagSystem))
+	}
+	count, ok := resp["count"].(*firestorepb.Value)
+	if !ok {
+		return nil, goerr.New("unexpected result of count aggregation", goerr.V("result", resp), goerr.T(types.ErrTagSystem))
+	}
+	if count.GetIntegerValue() > maxStatsScan {
+		return nil, goerr.New("too many workflows in the time range, narrow down the range",
+			goerr.V("count", count.GetIntegerValue()),
+			goerr.V("max", maxStatsScan),
+			goerr.T(types.ErrTagBadRequest))
+	}
+
+	agg := model.NewStatsAggregator(from, to, interval)
+	iter := q.Select("CreatedAt", "Alert.Schema", "Alert.Source", "Alert.Title", "Actions").Documents(ctx)
+	defer iter.Stop()
+	for {
+		doc, err := iter.Next()
+		if err != nil {
+			if errors.Is(err, iterator.Done) {
+				break
+			}
+			return nil, goerr.Wrap(err, "failed to get workflows for stats", goerr.T(types.ErrTagSystem))
+		}
+
+		var workflow model.WorkflowRecord
+		if err := doc.DataTo(&workflow); err != nil {
+			return nil, goerr.Wrap(err, "failed to unmarshal workflow", goerr.T(types.ErrTagSystem))
+		}
+		agg.Add(&workflow)
+	}
+
+	return agg.Stats(), nil
+}
+
+// maxSearchScan is the maximum number of documents read by SearchWorkflows when some conditions are evaluated after query.
+const maxSearchScan = 10000
+
+// SearchWorkflows implements interfaces.Database. Schema, source, namespace, status and time range are evaluated by query, and one of attribute and action conditions is evaluated by array-contains of search terms. Other conditions (title, error and the rest of attribute and action) are evaluated after query. Composite indexes of each equality field and search_terms with CreatedAt are required.
+func (x *Client) SearchWorkflows(ctx context.Context, filter model.WorkflowFilter, offset, limit int) ([]model.WorkflowRecord, error) {
+	q := x.client.Collection(x.workflowCollection).Query
+	if filter.Schema != nil {
+		q = q.Where("Alert.Schema", "==", *filter.Schema)
+	}
+	if filter.Source != nil {
+		q = q.Where("Alert.Source", "==", *filter.Source)
+	}
+	if filter.Namespace != nil {
+		q = q.Where("Alert.Namespace", "==", *filter.Namespace)
+	}
+	if filter.Status != nil {
+		q = q.Where("Status", "==", *filter.Status)
+	}
+	if filter.CreatedAfter != nil {
+		q = q.Where("CreatedAt", ">=", *filter.CreatedAfter)
+	}
+	if filter.CreatedBefore != nil {
+		q = q.Where("CreatedAt", "<", *filter.CreatedBefore)
+	}
+
+	// Only one array-contains is allowed in a query. The most selective term is used.
+	var terms []string
+	switch {
+	case filter.AttrKey != nil && filter.AttrValue != nil:
+		terms = append(terms, attrTerm(*filter.AttrKey, *filter.AttrValue))
+	case filter.AttrValue != nil:
+		terms = append(terms, attrValueTerm(*filter.AttrValue))
+	case filter.AttrKey != nil:
+		terms = append(terms, attrKeyTerm(*filter.AttrKey))
+	}
+	if filter.Action != nil {
+		terms = append(terms, actionTerm(*filter.Action))
+	}
+	if len(terms) > 0 {
+		q = q.Where("search_terms", "array-contains", terms[0])
+	}
+	q = q.OrderBy("CreatedAt", firestore.Desc)
+
+	postFilter := len(terms) > 1 || filter.Title != nil || filter.Error != nil
+	if !postFilter {
+		q = q.Offset(offset).Limit(limit)
+	}
+
+	var workflows []model.WorkflowRecord
+	iter := q.Documents(ctx)
+	defer iter.Stop()
+	for scanned := 0; len(workflows) < limit; scanned++ {
+		if postFilter && scanned >= maxSearchScan {
+			ctxutil.Logger(ctx).Warn("search of workflows reached max scan, narrow down the condition", slog.Int("max_scan", maxSearchScan))
+			break
+		}
+
+		doc, err := iter.Next()
+		if err != nil {
+			if errors.Is(err, iterator.Done) {
+				break
+			}
+			return nil, goerr.Wrap(err, "failed to search workflows", goerr.T(types.ErrTagSystem))
+		}
+
+		var workflow model.WorkflowRecord
+		if err := doc.DataTo(&workflow); err != nil {
+			return nil, goerr.Wrap(err, "failed to unmarshal workflow", goerr.T(types.ErrTagSystem))
+		}
+		if postFilter {
+			if !filter.Match(&workflow) {
+				continue
+			}
+			if offset > 0 {
+				offset--
+				continue
+			}
+		}
+		workflows = append(workflows, workflow)
+	}
+
+	return workflows, nil
+}
+
 func (x *Client) GetWorkflow(ctx context.Context, id types.WorkflowID) (*model.WorkflowRecord, error) {
 	key := workflowKeyPrefix + id.String()
 	doc, err := x.client.Collection(x.workflowCollection).Doc(key).Get(ctx)
@@ -206,9 +560,26 @@ func (x *Client) GetAlert(ctx context.Context, id types.AlertID) (*model.Alert,
 	return &alert, nil
 }
 
-type attribute struct {
-	model.Attribute
-	ExpiresAt time.Time `firestore:"expires_at"`
+// PutDecisionLog implements interfaces.Database.
+func (x *Client) PutDecisionLog(ctx context.Context, log model.DecisionLog) error {
+	key := decisionKeyPrefix + log.ID.String()
+
+	if _, err := x.client.Collection(x.decisionCollection).Doc(key).Set(ctx, log); err != nil {
+		return goerr.Wrap(err, "failed to put decision log", goerr.T(types.ErrTagSystem))
+	}
+
+	return nil
+}
+
+// PutAuditLog implements interfaces.Database.
+func (x *Client) PutAuditLog(ctx context.Context, log model.AuditLog) error {
+	key := auditKeyPrefix + log.ID.String()
+
+	if _, err := x.client.Collection(x.auditCollection).Doc(key).Set(ctx, log); err != nil {
+		return goerr.Wrap(err, "failed to put audit log", goerr.T(types.ErrTagSystem))
+	}
+
+	return nil
 }
 
 type lock struct {
@@ -341,6 +712,9 @@ func New(ctx context.Context, projectID string, databaseID string) (*Client, err
 		attrCollection:     "attrs",
 		workflowCollection: "workflows",
 		alertCollection:    "alerts",
+		decisionCollection: "decisions",
+		auditCollection:    "audits",
+		messageCollection:  "messages",
 	}, nil
 }
 
@@ -351,4 +725,74 @@ func (x *Client) Close() error {
 	return nil
 }
 
+// ClaimPubSubMessage implements interfaces.Database. The record is created in a transaction, so only one of concurrent redeliveries can claim the message.
+func (x *Client) ClaimPubSubMessage(ctx context.Context, record model.PubSubMessageRecord) (*model.PubSubMessageRecord, error) {
+	ref := x.client.Collection(x.messageCollection).Doc(messageKeyPrefix + hashKey(record.Key))
+	now := time.Now().UTC()
+	record.ExpiresAt = record.ExpiresAt.UTC()
+
+	var claimed *model.PubSubMessageRecord
+	err := x.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		claimed = nil
+
+		doc, err := tx.Get(ref)
+		if err != nil {
+			if status.Code(err) != codes.NotFound {
+				return goerr.Wrap(err, "failed to get message record", goerr.T(types.ErrTagSystem))
+			}
+		} else {
+			var current model.PubSubMessageRecord
+			if err := doc.DataTo(&current); err != nil {
+				return goerr.Wrap(err, "failed to unmarshal message record", goerr.T(types.ErrTagSystem))
+			}
+			if current.ExpiresAt.After(now) {
+				claimed = &current
+				return nil
+			}
+		}
+
+		if err := tx.Set(ref, record); err != nil {
+			return goerr.Wrap(err, "failed to put message record", goerr.T(types.ErrTagSystem))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, goerr.Wrap(err, "failed firestore transaction", goerr.V("key", record.Key), goerr.T(types.ErrTagSystem))
+	}
+
+	return claimed, nil
+}
+
+// PutPubSubMessage implements interfaces.Database.
+func (x *Client) PutPubSubMessage(ctx context.Context, record model.PubSubMessageRecord) error {
+	record.ExpiresAt = record.ExpiresAt.UTC()
+	ref := x.client.Collection(x.messageCollection).Doc(messageKeyPrefix + hashKey(record.Key))
+	if _, err := ref.Set(ctx, record); err != nil {
+		return goerr.Wrap(err, "failed to put message record", goerr.V("key", record.Key), goerr.T(types.ErrTagSystem))
+	}
+	return nil
+}
+
+// DeletePubSubMessage implements interfaces.Database.
+func (x *Client) DeletePubSubMessage(ctx context.Context, key string) error {
+	ref := x.client.Collection(x.messageCollection).Doc(messageKeyPrefix + hashKey(key))
+	if _, err := ref.Delete(ctx); err != nil {
+		return goerr.Wrap(err, "failed to delete message record", goerr.V("key", key), goerr.T(types.ErrTagSystem))
+	}
+	return nil
+}
+
+// Ping implements interfaces.Database. It reads a document that does not need to exist, because NotFound also proves the database is reachable and the credential is valid.
+func (x *Client) Ping(ctx context.Context) error {
+	_, err := x.client.Collection(x.attrCollection).Doc("_ping").Get(ctx)
+	if err != nil && status.Code(err) != codes.NotFound {
+		return goerr.Wrap(err, "failed to ping firestore",
+			goerr.V("project_id", x.projectID),
+			goerr.V("database_id", x.databaseID),
+			goerr.T(types.ErrTagSystem),
+		)
+	}
+	return nil
+}
+
 var _ interfaces.Database = &Client{}
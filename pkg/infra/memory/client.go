@@ -6,6 +6,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/secmon-lab/alertchain/pkg/ctxutil"
 	"github.com/secmon-lab/alertchain/pkg/domain/interfaces"
 	"github.com/secmon-lab/alertchain/pkg/domain/model"
 	"github.com/secmon-lab/alertchain/pkg/domain/types"
@@ -17,23 +18,34 @@ type lock struct {
 }
 
 type Client struct {
-	attrs     map[types.Namespace]map[types.AttrID]*model.Attribute
-	locks     map[types.Namespace]*lock
-	workflows map[types.WorkflowID]model.WorkflowRecord
-	alerts    map[types.AlertID]*model.Alert
+	attrs       map[types.Namespace]map[types.AttrID]*model.PersistentAttribute
+	attrHistory map[types.Namespace]map[types.AttrID][]model.AttributeChange
+	namespaces  map[types.Namespace]time.Time
+	locks       map[types.Namespace]*lock
+	workflows   map[types.WorkflowID]model.WorkflowRecord
+	alerts      map[types.AlertID]*model.Alert
+	decisions   []model.DecisionLog
+	audits      []model.AuditLog
+	messages    map[string]model.PubSubMessageRecord
 
 	attrMutex     sync.RWMutex
 	lockMutex     sync.Mutex
 	workflowMutex sync.RWMutex
 	alertMutex    sync.RWMutex
+	decisionMutex sync.Mutex
+	auditMutex    sync.Mutex
+	messageMutex  sync.Mutex
 }
 
 func New() *Client {
 	return &Client{
-		attrs:     map[types.Namespace]map[types.AttrID]*model.Attribute{},
-		locks:     map[types.Namespace]*lock{},
-		workflows: map[types.WorkflowID]model.WorkflowRecord{},
-		alerts:    map[types.AlertID]*model.Alert{},
+		attrs:       map[types.Namespace]map[types.AttrID]*model.PersistentAttribute{},
+		attrHistory: map[types.Namespace]map[types.AttrID][]model.AttributeChange{},
+		namespaces:  map[types.Namespace]time.Time{},
+		locks:       map[types.Namespace]*lock{},
+		workflows:   map[types.WorkflowID]model.WorkflowRecord{},
+		alerts:      map[types.AlertID]*model.Alert{},
+		messages:    map[string]model.PubSubMessageRecord{},
 	}
 }
 
@@ -42,6 +54,49 @@ func (x *Client) Close() error {
 	return nil
 }
 
+// ClaimPubSubMessage implements interfaces.Database. Expired records are removed when claiming to bound memory usage.
+func (x *Client) ClaimPubSubMessage(ctx context.Context, record model.PubSubMessageRecord) (*model.PubSubMessageRecord, error) {
+	x.messageMutex.Lock()
+	defer x.messageMutex.Unlock()
+
+	now := time.Now()
+	for key, msg := range x.messages {
+		if !msg.ExpiresAt.After(now) {
+			delete(x.messages, key)
+		}
+	}
+
+	if current, ok := x.messages[record.Key]; ok {
+		return &current, nil
+	}
+
+	x.messages[record.Key] = record
+	return nil, nil
+}
+
+// PutPubSubMessage implements interfaces.Database.
+func (x *Client) PutPubSubMessage(ctx context.Context, record model.PubSubMessageRecord) error {
+	x.messageMutex.Lock()
+	defer x.messageMutex.Unlock()
+
+	x.messages[record.Key] = record
+	return nil
+}
+
+// DeletePubSubMessage implements interfaces.Database.
+func (x *Client) DeletePubSubMessage(ctx context.Context, key string) error {
+	x.messageMutex.Lock()
+	defer x.messageMutex.Unlock()
+
+	delete(x.messages, key)
+	return nil
+}
+
+// Ping implements interfaces.Database. Memory database is always reachable.
+func (x *Client) Ping(ctx context.Context) error {
+	return nil
+}
+
 // GetAttrs implements interfaces.Database.
 func (x *Client) GetAttrs(ctx context.Context, ns types.Namespace) (model.Attributes, error) {
 	x.attrMutex.RLock()
@@ -54,7 +109,7 @@ func (x *Client) GetAttrs(ctx context.Context, ns types.Namespace) (model.Attrib
 
 	var ret model.Attributes
 	for _, a := range attrs {
-		ret = append(ret, *a)
+		ret = append(ret, a.Attribute)
 	}
 
 	return ret, nil
@@ -62,23 +117,127 @@ func (x *Client) GetAttrs(ctx context.Context, ns types.Namespace) (model.Attrib
 
 // PutAttrs implements interfaces.Database.
 func (x *Client) PutAttrs(ctx context.Context, ns types.Namespace, attrs model.Attributes) error {
+	if len(attrs) == 0 {
+		return nil
+	}
+
 	x.attrMutex.Lock()
+	defer x.attrMutex.Unlock()
+
 	if _, ok := x.attrs[ns]; !ok {
-		x.attrs[ns] = map[types.AttrID]*model.Attribute{}
+		x.attrs[ns] = map[types.AttrID]*model.PersistentAttribute{}
+	}
+	if _, ok := x.attrHistory[ns]; !ok {
+		x.attrHistory[ns] = map[types.AttrID][]model.AttributeChange{}
+	}
+
+	now := time.Now().UTC()
+	workflowID := ctxutil.GetWorkflowID(ctx)
+	var actor string
+	if a := ctxutil.GetActor(ctx); a != nil {
+		actor = a.Subject
 	}
-	x.attrMutex.Unlock()
+	x.namespaces[ns] = now
 
-	for i, src := range attrs {
-		if dst, ok := x.attrs[ns][src.ID]; ok {
+	for _, src := range attrs {
+		ttl := src.TTL
+		if ttl == 0 {
+			ttl = types.DefaultAttributeTTL
+		}
+		expiresAt := now.Add(time.Duration(ttl) * time.Second)
+
+		dst, ok := x.attrs[ns][src.ID]
+		if ok {
 			dst.Value = src.Value
 		} else {
-			x.attrs[ns][src.ID] = &attrs[i]
+			dst = &model.PersistentAttribute{Attribute: src}
+			x.attrs[ns][src.ID] = dst
 		}
+		dst.ExpiresAt = expiresAt
+		dst.UpdatedAt = now
+		dst.WorkflowID = workflowID
+		dst.Actor = actor
+
+		x.attrHistory[ns][src.ID] = append(x.attrHistory[ns][src.ID], model.AttributeChange{
+			Value:      src.Value,
+			ExpiresAt:  expiresAt,
+			WorkflowID: workflowID,
+			Actor:      actor,
+			CreatedAt:  now,
+		})
 	}
 
 	return nil
 }
 
+// GetNamespaces implements interfaces.Database.
+func (x *Client) GetNamespaces(ctx context.Context, offset, limit int) ([]model.NamespaceRecord, error) {
+	x.attrMutex.RLock()
+	defer x.attrMutex.RUnlock()
+
+	namespaces := make([]model.NamespaceRecord, 0, len(x.namespaces))
+	for ns, updatedAt := range x.namespaces {
+		namespaces = append(namespaces, model.NamespaceRecord{Name: string(ns), UpdatedAt: updatedAt})
+	}
+	sort.Slice(namespaces, func(i, j int) bool {
+		return namespaces[i].Name < namespaces[j].Name
+	})
+
+	if offset >= len(namespaces) {
+		return nil, nil
+	}
+	end := offset + limit
+	if end > len(namespaces) {
+		end = len(namespaces)
+	}
+
+	return namespaces[offset:end], nil
+}
+
+// GetPersistentAttrs implements interfaces.Database.
+func (x *Client) GetPersistentAttrs(ctx context.Context, ns types.Namespace) ([]model.PersistentAttribute, error) {
+	x.attrMutex.RLock()
+	defer x.attrMutex.RUnlock()
+
+	now := time.Now()
+	var ret []model.PersistentAttribute
+	for _, a := range x.attrs[ns] {
+		if a.ExpiresAt.Before(now) {
+			continue
+		}
+		ret = append(ret, *a)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].Key < ret[j].Key
+	})
+
+	return ret, nil
+}
+
+// GetAttrHistory implements interfaces.Database.
+func (x *Client) GetAttrHistory(ctx context.Context, ns types.Namespace, id types.AttrID, limit int) ([]model.AttributeChange, error) {
+	x.attrMutex.RLock()
+	defer x.attrMutex.RUnlock()
+
+	history := x.attrHistory[ns][id]
+	var ret []model.AttributeChange
+	for i := len(history) - 1; i >= 0 && len(ret) < limit; i-- {
+		ret = append(ret, history[i])
+	}
+	return ret, nil
+}
+
+// DeleteAttrs implements interfaces.Database.
+func (x *Client) DeleteAttrs(ctx context.Context, ns types.Namespace, ids []types.AttrID) error {
+	x.attrMutex.Lock()
+	defer x.attrMutex.Unlock()
+
+	for _, id := range ids {
+		delete(x.attrs[ns], id)
+	}
+	return nil
+}
+
 func (x *Client) PutWorkflow(ctx context.Context, workflow model.WorkflowRecord) error {
 	x.workflowMutex.Lock()
 	defer x.workflowMutex.Unlock()
@@ -112,6 +271,68 @@ func (x *Client) GetWorkflows(ctx context.Context, offset, limit int) ([]model.W
 	return workflows[offset:end], nil
 }
 
+// GetWorkflowsAfter implements interfaces.Database.
+func (x *Client) GetWorkflowsAfter(ctx context.Context, cursor *model.WorkflowCursor, limit int) ([]model.WorkflowRecord, error) {
+	x.workflowMutex.RLock()
+	defer x.workflowMutex.RUnlock()
+
+	workflows := make([]model.WorkflowRecord, 0, len(x.workflows))
+	for _, wf := range x.workflows {
+		if cursor == nil || cursor.Follows(&wf) {
+			workflows = append(workflows, wf)
+		}
+	}
+	sort.Slice(workflows, func(i, j int) bool {
+		if !workflows[i].CreatedAt.Equal(workflows[j].CreatedAt) {
+			return workflows[i].CreatedAt.After(workflows[j].CreatedAt)
+		}
+		return workflows[i].ID > workflows[j].ID
+	})
+
+	if len(workflows) > limit {
+		workflows = workflows[:limit]
+	}
+	return workflows, nil
+}
+
+// GetWorkflowStats implements interfaces.Database. All workflows are scanned.
+func (x *Client) GetWorkflowStats(ctx context.Context, from, to time.Time, interval time.Duration) (*model.Stats, error) {
+	x.workflowMutex.RLock()
+	defer x.workflowMutex.RUnlock()
+
+	agg := model.NewStatsAggregator(from, to, interval)
+	for _, wf := range x.workflows {
+		agg.Add(&wf)
+	}
+	return agg.Stats(), nil
+}
+
+// SearchWorkflows implements interfaces.Database. All workflows are scanned.
+func (x *Client) SearchWorkflows(ctx context.Context, filter model.WorkflowFilter, offset, limit int) ([]model.WorkflowRecord, error) {
+	x.workflowMutex.RLock()
+	defer x.workflowMutex.RUnlock()
+
+	var workflows []model.WorkflowRecord
+	for _, wf := range x.workflows {
+		if filter.Match(&wf) {
+			workflows = append(workflows, wf)
+		}
+	}
+	sort.Slice(workflows, func(i, j int) bool {
+		return workflows[i].CreatedAt.After(workflows[j].CreatedAt)
+	})
+
+	if offset >= len(workflows) {
+		return nil, nil
+	}
+	end := offset + limit
+	if end > len(workflows) {
+		end = len(workflows)
+	}
+
+	return workflows[offset:end], nil
+}
+
 func (x *Client) GetWorkflow(ctx context.Context, id types.WorkflowID) (*model.WorkflowRecord, error) {
 	for _, wf := range x.workflows {
 		if wf.ID == id {
@@ -172,14 +393,45 @@ func (x *Client) Lock(ctx context.Context, ns types.Namespace, timeout time.Time
 	return nil
 }
 
-// Unlock implements interfaces.Database.
+// Unlock implements interfaces.Database. Unlocking a namespace that is not locked is no-op, because the lock may be already released by administrator.
 func (x *Client) Unlock(ctx context.Context, ns types.Namespace) error {
-	if _, ok := x.locks[ns]; !ok {
+	x.lockMutex.Lock()
+	l, ok := x.locks[ns]
+	x.lockMutex.Unlock()
+	if !ok {
 		return nil
 	}
 
-	x.locks[ns].mutex.Unlock()
+	// Make sure the mutex is locked before unlocking it, because unlocking an unlocked mutex panics
+	l.mutex.TryLock()
+	l.mutex.Unlock()
+	return nil
+}
+
+// PutDecisionLog implements interfaces.Database.
+func (x *Client) PutDecisionLog(ctx context.Context, log model.DecisionLog) error {
+	x.decisionMutex.Lock()
+	defer x.decisionMutex.Unlock()
+
+	x.decisions = append(x.decisions, log)
 	return nil
 }
 
+// PutAuditLog implements interfaces.Database.
+func (x *Client) PutAuditLog(ctx context.Context, log model.AuditLog) error {
+	x.auditMutex.Lock()
+	defer x.auditMutex.Unlock()
+
+	x.audits = append(x.audits, log)
+	return nil
+}
+
+// AuditLogs returns stored audit logs. It is for testing.
+func (x *Client) AuditLogs() []model.AuditLog {
+	x.auditMutex.Lock()
+	defer x.auditMutex.Unlock()
+
+	return append([]model.AuditLog{}, x.audits...)
+}
+
 var _ interfaces.Database = (*Client)(nil)
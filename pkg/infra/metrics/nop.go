@@ -0,0 +1,23 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/secmon-lab/alertchain/pkg/domain/interfaces"
+	"github.com/secmon-lab/alertchain/pkg/domain/types"
+)
+
+// Nop is an instrument that discards all measurements. It is default instrument of chain and server.
+type Nop struct{}
+
+var _ interfaces.Instrument = Nop{}
+
+func (Nop) EventReceived(context.Context, string, types.Schema)                    {}
+func (Nop) AlertDetected(context.Context, types.Schema, int)                       {}
+func (Nop) ActionExecuted(context.Context, types.ActionName, time.Duration, error) {}
+func (Nop) PolicyEvaluated(context.Context, string, time.Duration, error)          {}
+func (Nop) WorkflowFinished(context.Context, int, error)                           {}
+func (Nop) LockWaited(context.Context, types.Namespace, time.Duration)             {}
+func (Nop) AuthzDenied(context.Context, string)                                    {}
+func (Nop) RateLimited(context.Context, string, types.Schema, string)              {}
@@ -0,0 +1,149 @@
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/secmon-lab/alertchain/pkg/domain/interfaces"
+	"github.com/secmon-lab/alertchain/pkg/domain/types"
+)
+
+const namespace = "alertchain"
+
+// Prometheus is an instrument that exposes measurements as Prometheus metrics. Metrics are registered into its own registry and served by Handler.
+type Prometheus struct {
+	registry *prometheus.Registry
+
+	eventsReceived  *prometheus.CounterVec
+	alertsDetected  *prometheus.CounterVec
+	actionsExecuted *prometheus.CounterVec
+	actionDuration  *prometheus.HistogramVec
+	policyDuration  *prometheus.HistogramVec
+	sequences       *prometheus.HistogramVec
+	lockWait        prometheus.Histogram
+	authzDenied     prometheus.Counter
+	rateLimited     *prometheus.CounterVec
+}
+
+var _ interfaces.Instrument = &Prometheus{}
+
+func NewPrometheus() *Prometheus {
+	x := &Prometheus{
+		registry: prometheus.NewRegistry(),
+
+		eventsReceived: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "events_received_total",
+			Help:      "Number of events received per endpoint and schema",
+		}, []string{"endpoint", "schema"}),
+		alertsDetected: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "alerts_detected_total",
+			Help:      "Number of alerts produced by alert policy per schema",
+		}, []string{"schema"}),
+		actionsExecuted: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "actions_executed_total",
+			Help:      "Number of executed actions per uses and result (success or failure)",
+		}, []string{"uses", "result"}),
+		actionDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "action_duration_seconds",
+			Help:      "Latency of action execution per uses",
+			Buckets:   prometheus.ExponentialBuckets(0.01, 2, 12),
+		}, []string{"uses"}),
+		policyDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "policy_evaluation_duration_seconds",
+			Help:      "Latency of policy evaluation per package",
+			Buckets:   prometheus.ExponentialBuckets(0.0005, 2, 12),
+		}, []string{"package"}),
+		sequences: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "workflow_sequences",
+			Help:      "Number of evaluated sequences per workflow",
+			Buckets:   []float64{1, 2, 3, 4, 6, 8, 12, 16, 24, 32},
+		}, []string{"result"}),
+		lockWait: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "namespace_lock_wait_seconds",
+			Help:      "Wait time to acquire namespace lock",
+			Buckets:   prometheus.ExponentialBuckets(0.001, 4, 10),
+		}),
+		authzDenied: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "authz_denied_total",
+			Help:      "Number of HTTP requests denied by authz policy",
+		}),
+		rateLimited: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "rate_limited_total",
+			Help:      "Number of requests exceeding rate limit per scope, schema and decision (rejected, sampled or dropped)",
+		}, []string{"scope", "schema", "decision"}),
+	}
+
+	x.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		x.eventsReceived,
+		x.alertsDetected,
+		x.actionsExecuted,
+		x.actionDuration,
+		x.policyDuration,
+		x.sequences,
+		x.lockWait,
+		x.authzDenied,
+		x.rateLimited,
+	)
+
+	return x
+}
+
+// Handler returns HTTP handler to serve metrics in Prometheus exposition format.
+func (x *Prometheus) Handler() http.Handler {
+	return promhttp.HandlerFor(x.registry, promhttp.HandlerOpts{})
+}
+
+func result(err error) string {
+	if err != nil {
+		return "failure"
+	}
+	return "success"
+}
+
+func (x *Prometheus) EventReceived(ctx context.Context, endpoint string, schema types.Schema) {
+	x.eventsReceived.WithLabelValues(endpoint, string(schema)).Inc()
+}
+
+func (x *Prometheus) AlertDetected(ctx context.Context, schema types.Schema, count int) {
+	x.alertsDetected.WithLabelValues(string(schema)).Add(float64(count))
+}
+
+func (x *Prometheus) ActionExecuted(ctx context.Context, uses types.ActionName, duration time.Duration, err error) {
+	x.actionsExecuted.WithLabelValues(string(uses), result(err)).Inc()
+	x.actionDuration.WithLabelValues(string(uses)).Observe(duration.Seconds())
+}
+
+func (x *Prometheus) PolicyEvaluated(ctx context.Context, pkg string, duration time.Duration, err error) {
+	x.policyDuration.WithLabelValues(pkg).Observe(duration.Seconds())
+}
+
+func (x *Prometheus) WorkflowFinished(ctx context.Context, sequences int, err error) {
+	x.sequences.WithLabelValues(result(err)).Observe(float64(sequences))
+}
+
+func (x *Prometheus) LockWaited(ctx context.Context, ns types.Namespace, duration time.Duration) {
+	x.lockWait.Observe(duration.Seconds())
+}
+
+func (x *Prometheus) AuthzDenied(ctx context.Context, path string) {
+	x.authzDenied.Inc()
+}
+
+func (x *Prometheus) RateLimited(ctx context.Context, scope string, schema types.Schema, decision string) {
+	x.rateLimited.WithLabelValues(scope, string(schema), decision).Inc()
+}
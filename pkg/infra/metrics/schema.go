@@ -0,0 +1,62 @@
+package metrics
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/secmon-lab/alertchain/pkg/domain/interfaces"
+	"github.com/secmon-lab/alertchain/pkg/domain/types"
+)
+
+// UnknownSchema is recorded as schema of metrics instead of schema that has no alert policy.
+const UnknownSchema types.Schema = "unknown"
+
+const alertPackagePrefix = "alert."
+
+// schemaFilter replaces schema without loaded alert.<schema> package with UnknownSchema. Schema in URL path is given by client, and recording it as is makes cardinality of metrics label unbounded.
+type schemaFilter struct {
+	interfaces.Instrument
+	schemas map[types.Schema]struct{}
+}
+
+// WithKnownSchemas wraps the instrument so that schema labels are bounded by alert policy packages. Schema that has no alert.<schema> package in packages is recorded as UnknownSchema, and also evaluation of its package is recorded as alert.unknown.
+func WithKnownSchemas(inst interfaces.Instrument, packages []string) interfaces.Instrument {
+	schemas := map[types.Schema]struct{}{}
+	for _, pkg := range packages {
+		if schema, ok := strings.CutPrefix(pkg, alertPackagePrefix); ok {
+			schemas[types.Schema(schema)] = struct{}{}
+		}
+	}
+
+	return &schemaFilter{
+		Instrument: inst,
+		schemas:    schemas,
+	}
+}
+
+func (x *schemaFilter) label(schema types.Schema) types.Schema {
+	if _, ok := x.schemas[schema]; ok {
+		return schema
+	}
+	return UnknownSchema
+}
+
+func (x *schemaFilter) EventReceived(ctx context.Context, endpoint string, schema types.Schema) {
+	x.Instrument.EventReceived(ctx, endpoint, x.label(schema))
+}
+
+func (x *schemaFilter) AlertDetected(ctx context.Context, schema types.Schema, count int) {
+	x.Instrument.AlertDetected(ctx, x.label(schema), count)
+}
+
+func (x *schemaFilter) PolicyEvaluated(ctx context.Context, pkg string, duration time.Duration, err error) {
+	if schema, ok := strings.CutPrefix(pkg, alertPackagePrefix); ok {
+		pkg = alertPackagePrefix + string(x.label(types.Schema(schema)))
+	}
+	x.Instrument.PolicyEvaluated(ctx, pkg, duration, err)
+}
+
+func (x *schemaFilter) RateLimited(ctx context.Context, scope string, schema types.Schema, decision string) {
+	x.Instrument.RateLimited(ctx, scope, x.label(schema), decision)
+}
@@ -0,0 +1,250 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/m-mizutani/goerr/v2"
+	"github.com/secmon-lab/alertchain/pkg/domain/types"
+	"github.com/secmon-lab/alertchain/pkg/utils"
+)
+
+const (
+	// GoogleJWKSURL is JWKS endpoint of Google that signs ID token of Pub/Sub push subscription.
+	GoogleJWKSURL = "https://www.googleapis.com/oauth2/v3/certs"
+
+	// jwksTTL is duration to cache JWKS. Unknown key ID also triggers refresh, but not more than once per jwksMinRefresh.
+	jwksTTL        = time.Hour
+	jwksMinRefresh = time.Minute
+)
+
+// JWKSFetcher retrieves JSON Web Key Set.
+type JWKSFetcher func(ctx context.Context) ([]byte, error)
+
+// Verifier validates ID token issued by Google, such as Authorization header of Pub/Sub push request.
+type Verifier struct {
+	audiences []string
+	issuers   []string
+	emails    []string
+	fetchJWKS JWKSFetcher
+	now       func() time.Time
+
+	keys      map[string]*rsa.PublicKey
+	fetchedAt time.Time
+	keyMutex  sync.Mutex
+}
+
+type Option func(*Verifier)
+
+// WithAudience sets acceptable audiences of the token. It is required.
+func WithAudience(aud ...string) Option {
+	return func(x *Verifier) {
+		x.audiences = append(x.audiences, aud...)
+	}
+}
+
+// WithIssuer replaces acceptable issuers. Default is "https://accounts.google.com" and "accounts.google.com".
+func WithIssuer(iss ...string) Option {
+	return func(x *Verifier) {
+		x.issuers = iss
+	}
+}
+
+// WithEmail sets acceptable service account emails. Any verified email is accepted if not set.
+func WithEmail(email ...string) Option {
+	return func(x *Verifier) {
+		x.emails = append(x.emails, email...)
+	}
+}
+
+// WithJWKSFetcher replaces the default fetcher that downloads JWKS from GoogleJWKSURL.
+func WithJWKSFetcher(fetcher JWKSFetcher) Option {
+	return func(x *Verifier) {
+		x.fetchJWKS = fetcher
+	}
+}
+
+// WithJWKSFile loads JWKS from local file instead of GoogleJWKSURL.
+func WithJWKSFile(path string) Option {
+	return WithJWKSFetcher(func(ctx context.Context) ([]byte, error) {
+		data, err := os.ReadFile(filepath.Clean(path))
+		if err != nil {
+			return nil, goerr.Wrap(err, "failed to read JWKS file", goerr.V("path", path), goerr.T(types.ErrTagConfig))
+		}
+		return data, nil
+	})
+}
+
+// WithNow replaces clock to validate expiration of the token. It is for testing.
+func WithNow(now func() time.Time) Option {
+	return func(x *Verifier) {
+		x.now = now
+	}
+}
+
+func New(options ...Option) (*Verifier, error) {
+	v := &Verifier{
+		issuers:   []string{"https://accounts.google.com", "accounts.google.com"},
+		fetchJWKS: downloadJWKS(GoogleJWKSURL),
+		now:       time.Now,
+	}
+
+	for _, opt := range options {
+		opt(v)
+	}
+
+	if len(v.audiences) == 0 {
+		return nil, goerr.New("audience is required for ID token verification", goerr.T(types.ErrTagConfig))
+	}
+
+	return v, nil
+}
+
+func downloadJWKS(url string) JWKSFetcher {
+	client := &http.Client{Timeout: 10 * time.Second}
+
+	return func(ctx context.Context) ([]byte, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return nil, goerr.Wrap(err, "failed to create JWKS request", goerr.V("url", url))
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, goerr.Wrap(err, "failed to fetch JWKS", goerr.V("url", url), goerr.T(types.ErrTagSystem))
+		}
+		defer utils.SafeClose(ctx, resp.Body)
+
+		if resp.StatusCode != http.StatusOK {
+			return nil, goerr.New("unexpected status code of JWKS", goerr.V("url", url), goerr.V("code", resp.StatusCode), goerr.T(types.ErrTagSystem))
+		}
+
+		data, err := io.ReadAll(io.LimitReader(resp.Body, 1024*1024))
+		if err != nil {
+			return nil, goerr.Wrap(err, "failed to read JWKS", goerr.V("url", url), goerr.T(types.ErrTagSystem))
+		}
+		return data, nil
+	}
+}
+
+type jwks struct {
+	Keys []struct {
+		Kty string `json:"kty"`
+		Kid string `json:"kid"`
+		N   string `json:"n"`
+		E   string `json:"e"`
+	} `json:"keys"`
+}
+
+func parseJWKS(data []byte) (map[string]*rsa.PublicKey, error) {
+	var set jwks
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, goerr.Wrap(err, "failed to parse JWKS", goerr.T(types.ErrTagSystem))
+	}
+
+	keys := make(map[string]*rsa.PublicKey)
+	for _, key := range set.Keys {
+		if key.Kty != "RSA" {
+			continue
+		}
+
+		n, err := base64.RawURLEncoding.DecodeString(key.N)
+		if err != nil {
+			return nil, goerr.Wrap(err, "failed to decode modulus of JWK", goerr.V("kid", key.Kid), goerr.T(types.ErrTagSystem))
+		}
+		e, err := base64.RawURLEncoding.DecodeString(key.E)
+		if err != nil {
+			return nil, goerr.Wrap(err, "failed to decode exponent of JWK", goerr.V("kid", key.Kid), goerr.T(types.ErrTagSystem))
+		}
+
+		keys[key.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+
+	return keys, nil
+}
+
+func (x *Verifier) getKey(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	x.keyMutex.Lock()
+	defer x.keyMutex.Unlock()
+
+	now := x.now()
+	elapsed := now.Sub(x.fetchedAt)
+	if key, ok := x.keys[kid]; ok && elapsed <= jwksTTL {
+		return key, nil
+	}
+
+	if elapsed > jwksMinRefresh {
+		data, err := x.fetchJWKS(ctx)
+		if err != nil {
+			return nil, err
+		}
+		keys, err := parseJWKS(data)
+		if err != nil {
+			return nil, err
+		}
+		x.keys, x.fetchedAt = keys, now
+	}
+
+	if key, ok := x.keys[kid]; ok {
+		return key, nil
+	}
+	return nil, goerr.New("signing key of ID token is not found in JWKS", goerr.V("kid", kid), goerr.T(types.ErrTagUnauthorized))
+}
+
+// Verify validates signature, expiration, audience, issuer and email of the token. It returns claims of the verified token. Failure of retrieving JWKS is tagged as ErrTagSystem, not ErrTagUnauthorized, because it is not a problem of the token.
+func (x *Verifier) Verify(ctx context.Context, token string) (map[string]any, error) {
+	claims := jwt.MapClaims{}
+	parser := jwt.NewParser(jwt.WithValidMethods([]string{"RS256"}), jwt.WithoutClaimsValidation())
+
+	var keyErr error
+	if _, err := parser.ParseWithClaims(token, claims, func(t *jwt.Token) (any, error) {
+		kid, _ := t.Header["kid"].(string)
+		key, err := x.getKey(ctx, kid)
+		if err != nil {
+			keyErr = err
+		}
+		return key, err
+	}); err != nil {
+		if keyErr != nil && !goerr.HasTag(keyErr, types.ErrTagUnauthorized) {
+			return nil, goerr.Wrap(keyErr, "failed to get signing key of ID token", goerr.T(types.ErrTagSystem))
+		}
+		return nil, goerr.Wrap(err, "invalid ID token", goerr.T(types.ErrTagUnauthorized))
+	}
+
+	now := x.now().Unix()
+	if !claims.VerifyExpiresAt(now, true) || !claims.VerifyIssuedAt(now, false) || !claims.VerifyNotBefore(now, false) {
+		return nil, goerr.New("ID token is expired or not valid yet", goerr.V("exp", claims["exp"]), goerr.T(types.ErrTagUnauthorized))
+	}
+
+	if !slices.ContainsFunc(x.audiences, func(aud string) bool { return claims.VerifyAudience(aud, true) }) {
+		return nil, goerr.New("audience of ID token is not allowed", goerr.V("aud", claims["aud"]), goerr.T(types.ErrTagUnauthorized))
+	}
+
+	if !slices.ContainsFunc(x.issuers, func(iss string) bool { return claims.VerifyIssuer(iss, true) }) {
+		return nil, goerr.New("issuer of ID token is not allowed", goerr.V("iss", claims["iss"]), goerr.T(types.ErrTagUnauthorized))
+	}
+
+	email, _ := claims["email"].(string)
+	if verified, _ := claims["email_verified"].(bool); email != "" && !verified {
+		return nil, goerr.New("email of ID token is not verified", goerr.V("email", email), goerr.T(types.ErrTagUnauthorized))
+	}
+	if len(x.emails) > 0 && !slices.Contains(x.emails, email) {
+		return nil, goerr.New("email of ID token is not allowed", goerr.V("email", email), goerr.T(types.ErrTagUnauthorized))
+	}
+
+	return claims, nil
+}
@@ -0,0 +1,116 @@
+package oidc_test
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/m-mizutani/goerr/v2"
+	"github.com/m-mizutani/gt"
+	"github.com/secmon-lab/alertchain/pkg/domain/types"
+	"github.com/secmon-lab/alertchain/pkg/infra/oidc"
+)
+
+func writeJWKS(t *testing.T, kid string, key *rsa.PublicKey) string {
+	jwks := map[string]any{
+		"keys": []map[string]any{
+			{
+				"kty": "RSA",
+				"alg": "RS256",
+				"use": "sig",
+				"kid": kid,
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			},
+		},
+	}
+
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	gt.NoError(t, os.WriteFile(path, gt.R1(json.Marshal(jwks)).NoError(t), 0600))
+	return path
+}
+
+func TestVerify(t *testing.T) {
+	key := gt.R1(rsa.GenerateKey(rand.Reader, 2048)).NoError(t)
+	jwksPath := writeJWKS(t, "key1", &key.PublicKey)
+	now := time.Now()
+
+	issue := func(t *testing.T, kid string, claims jwt.MapClaims) string {
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+		token.Header["kid"] = kid
+		return gt.R1(token.SignedString(key)).NoError(t)
+	}
+	validClaims := func() jwt.MapClaims {
+		return jwt.MapClaims{
+			"iss":            "https://accounts.google.com",
+			"aud":            "https://alertchain.example.com/alert/pubsub/scc",
+			"email":          "pubsub@my-project.iam.gserviceaccount.com",
+			"email_verified": true,
+			"iat":            now.Add(-time.Minute).Unix(),
+			"exp":            now.Add(time.Hour).Unix(),
+		}
+	}
+
+	verifier := gt.R1(oidc.New(
+		oidc.WithAudience("https://alertchain.example.com/alert/pubsub/scc"),
+		oidc.WithEmail("pubsub@my-project.iam.gserviceaccount.com"),
+		oidc.WithJWKSFile(jwksPath),
+		oidc.WithNow(func() time.Time { return now }),
+	)).NoError(t)
+
+	ctx := context.Background()
+	t.Run("valid token", func(t *testing.T) {
+		claims := gt.R1(verifier.Verify(ctx, issue(t, "key1", validClaims()))).NoError(t)
+		gt.V(t, claims["email"]).Equal("pubsub@my-project.iam.gserviceaccount.com")
+	})
+
+	testCases := map[string]struct {
+		kid    string
+		modify func(c jwt.MapClaims)
+	}{
+		"unknown key":    {kid: "key2", modify: func(c jwt.MapClaims) {}},
+		"expired":        {kid: "key1", modify: func(c jwt.MapClaims) { c["exp"] = now.Add(-time.Second).Unix() }},
+		"wrong audience": {kid: "key1", modify: func(c jwt.MapClaims) { c["aud"] = "https://other.example.com" }},
+		"wrong issuer":   {kid: "key1", modify: func(c jwt.MapClaims) { c["iss"] = "https://evil.example.com" }},
+		"wrong email":    {kid: "key1", modify: func(c jwt.MapClaims) { c["email"] = "other@my-project.iam.gserviceaccount.com" }},
+		"not verified":   {kid: "key1", modify: func(c jwt.MapClaims) { c["email_verified"] = false }},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			claims := validClaims()
+			tc.modify(claims)
+			_, err := verifier.Verify(ctx, issue(t, tc.kid, claims))
+			gt.Error(t, err)
+			gt.B(t, goerr.HasTag(err, types.ErrTagUnauthorized)).True()
+		})
+	}
+
+	t.Run("JWKS is not available", func(t *testing.T) {
+		verifier := gt.R1(oidc.New(
+			oidc.WithAudience("https://alertchain.example.com/alert/pubsub/scc"),
+			oidc.WithJWKSFetcher(func(ctx context.Context) ([]byte, error) {
+				return nil, errors.New("connection refused")
+			}),
+			oidc.WithNow(func() time.Time { return now }),
+		)).NoError(t)
+
+		_, err := verifier.Verify(ctx, issue(t, "key1", validClaims()))
+		gt.Error(t, err)
+		gt.B(t, goerr.HasTag(err, types.ErrTagSystem)).True()
+		gt.B(t, goerr.HasTag(err, types.ErrTagUnauthorized)).False()
+	})
+
+	t.Run("audience is required", func(t *testing.T) {
+		_, err := oidc.New(oidc.WithJWKSFile(jwksPath))
+		gt.Error(t, err)
+	})
+}
@@ -2,16 +2,28 @@ package policy
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"io/fs"
+	"log/slog"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/m-mizutani/goerr/v2"
 	"github.com/open-policy-agent/opa/v1/ast"
 	"github.com/open-policy-agent/opa/v1/rego"
+	"github.com/open-policy-agent/opa/v1/storage/inmem"
+	"github.com/open-policy-agent/opa/v1/topdown"
+	"github.com/secmon-lab/alertchain/pkg/ctxutil"
+	"github.com/secmon-lab/alertchain/pkg/domain/interfaces"
+	"github.com/secmon-lab/alertchain/pkg/domain/model"
 	"github.com/secmon-lab/alertchain/pkg/domain/types"
+	"github.com/secmon-lab/alertchain/pkg/logging"
+	"github.com/secmon-lab/alertchain/pkg/utils"
 )
 
 // Client is a policy engine client
@@ -24,6 +36,7 @@ type Client struct {
 
 	compiler *ast.Compiler
 	query    string
+	hash     string
 }
 
 type RegoPrint func(file string, row int, msg string) error
@@ -125,13 +138,62 @@ func New(options ...Option) (*Client, error) {
 		return nil, goerr.Wrap(err, "Failed to compile policy", goerr.V("policies", client.policies))
 	}
 	client.compiler = compiler
+	client.hash = hashPolicies(client.policies)
 
 	return client, nil
 }
 
+func hashPolicies(policies map[string]string) string {
+	names := make([]string, 0, len(policies))
+	for name := range policies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	h := sha256.New()
+	for _, name := range names {
+		h.Write([]byte(name))
+		h.Write([]byte{0})
+		h.Write([]byte(policies[name]))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Hash returns SHA256 hash of all loaded policy files. It changes when any policy file is modified, added or removed.
+func (x *Client) Hash() string { return x.hash }
+
+// Package returns the package name specified by WithPackage. It is empty if the client queries all packages.
+func (x *Client) Package() string {
+	return strings.TrimPrefix(strings.TrimPrefix(x.query, "data"), ".")
+}
+
+// Packages returns sorted names of compiled packages under Package(), e.g. "alert.my_alert" for package "alert".
+func (x *Client) Packages() []string {
+	root := x.Package()
+	set := map[string]struct{}{}
+	for _, module := range x.compiler.Modules {
+		name := strings.TrimPrefix(module.Package.Path.String(), "data.")
+		if root == "" || name == root || strings.HasPrefix(name, root+".") {
+			set[name] = struct{}{}
+		}
+	}
+
+	packages := make([]string, 0, len(set))
+	for name := range set {
+		packages = append(packages, name)
+	}
+	sort.Strings(packages)
+	return packages
+}
+
 type queryConfig struct {
-	pkgSuffix []string
-	regoPrint RegoPrint
+	pkgSuffix    []string
+	regoPrint    RegoPrint
+	decisionSink interfaces.DecisionLogSink
+	trace        Trace
+	instrument   interfaces.Instrument
+	data         map[string]any
 }
 
 func newQueryConfig(options ...QueryOption) *queryConfig {
@@ -157,6 +219,34 @@ func WithRegoPrint(callback RegoPrint) QueryOption {
 	}
 }
 
+// WithDecisionLogSink specifies a sink to record the evaluation. Input and output are redacted by the same rule as logging before written to the sink.
+func WithDecisionLogSink(sink interfaces.DecisionLogSink) QueryOption {
+	return func(cfg *queryConfig) {
+		cfg.decisionSink = sink
+	}
+}
+
+// WithTrace enables OPA tracing for the query. The callback receives a condensed explanation after the evaluation.
+func WithTrace(callback Trace) QueryOption {
+	return func(cfg *queryConfig) {
+		cfg.trace = callback
+	}
+}
+
+// WithInstrument reports latency of the evaluation to the instrument.
+func WithInstrument(inst interfaces.Instrument) QueryOption {
+	return func(cfg *queryConfig) {
+		cfg.instrument = inst
+	}
+}
+
+// WithData provides the document under `data` for the query, e.g. {"alertchain": {"meta": ...}} is available as `data.alertchain.meta`. Values must be JSON compatible.
+func WithData(data map[string]any) QueryOption {
+	return func(cfg *queryConfig) {
+		cfg.data = data
+	}
+}
+
 // Query evaluates policy with `input` data. The result will be written to `out`. `out` must be pointer of instance.
 func (x *Client) Query(ctx context.Context, input interface{}, output interface{}, options ...QueryOption) error {
 	cfg := newQueryConfig(options...)
@@ -167,12 +257,37 @@ func (x *Client) Query(ctx context.Context, input interface{}, output interface{
 		rego.Compiler(x.compiler),
 		rego.Input(input),
 	}
+	if cfg.data != nil {
+		regoOpt = append(regoOpt, rego.Store(inmem.NewFromObject(cfg.data)))
+	}
 	if cfg.regoPrint != nil {
 		regoOpt = append(regoOpt, rego.PrintHook(&regoPrintHook{
 			callback: cfg.regoPrint,
 		}))
 	}
 
+	var tracer *topdown.BufferTracer
+	if cfg.trace != nil {
+		tracer = topdown.NewBufferTracer()
+		regoOpt = append(regoOpt, rego.QueryTracer(tracer))
+	}
+
+	startedAt := time.Now()
+	err := x.eval(ctx, query, input, output, regoOpt)
+	if tracer != nil {
+		cfg.trace(summarizeTrace(x.compiler, query, *tracer))
+	}
+	if cfg.instrument != nil {
+		cfg.instrument.PolicyEvaluated(ctx, strings.TrimPrefix(query, "data."), time.Since(startedAt), err)
+	}
+	if cfg.decisionSink != nil {
+		x.writeDecisionLog(ctx, cfg.decisionSink, query, input, output, startedAt, err)
+	}
+
+	return err
+}
+
+func (x *Client) eval(ctx context.Context, query string, input, output any, regoOpt []func(r *rego.Rego)) error {
 	rs, err := rego.New(regoOpt...).Eval(ctx)
 	eb := goerr.NewBuilder(goerr.V("query", query), goerr.V("input", input), goerr.V("rs", rs))
 
@@ -193,3 +308,40 @@ func (x *Client) Query(ctx context.Context, input interface{}, output interface{
 
 	return nil
 }
+
+// writeDecisionLog sends a record of the evaluation to the sink. Failure of the sink is logged and does not affect the result of Query.
+func (x *Client) writeDecisionLog(ctx context.Context, sink interfaces.DecisionLogSink, query string, input, output any, startedAt time.Time, evalErr error) {
+	logger := ctxutil.Logger(ctx)
+
+	log := &model.DecisionLog{
+		ID:         types.NewDecisionID(),
+		Timestamp:  startedAt,
+		Package:    strings.TrimPrefix(query, "data."),
+		PolicyHash: x.hash,
+		Latency:    time.Since(startedAt),
+		WorkflowID: ctxutil.GetWorkflowID(ctx),
+	}
+	if alert := ctxutil.GetAlert(ctx); alert != nil {
+		log.AlertID = alert.ID
+	}
+
+	in, err := utils.ToAny(logging.Redact(input))
+	if err != nil {
+		logger.Warn("failed to convert decision log input", logging.ErrAttr(err))
+	}
+	log.Input = in
+
+	if evalErr != nil {
+		log.Error = evalErr.Error()
+	} else {
+		out, err := utils.ToAny(logging.Redact(output))
+		if err != nil {
+			logger.Warn("failed to convert decision log output", logging.ErrAttr(err))
+		}
+		log.Output = out
+	}
+
+	if err := sink.Write(ctx, log); err != nil {
+		logger.Error("failed to write decision log", logging.ErrAttr(err), slog.String("package", log.Package))
+	}
+}
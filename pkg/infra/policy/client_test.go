@@ -6,6 +6,7 @@ import (
 	"testing"
 
 	"github.com/m-mizutani/gt"
+	"github.com/secmon-lab/alertchain/pkg/domain/model"
 	"github.com/secmon-lab/alertchain/pkg/infra/policy"
 )
 
@@ -103,6 +104,41 @@ func TestClient_New_NoPolicy(t *testing.T) {
 	gt.Error(t, err)
 }
 
+func TestClient_Packages(t *testing.T) {
+	client, err := policy.New(
+		policy.WithPolicyData("a.rego", "package alert.a\nallow := true"),
+		policy.WithPolicyData("b.rego", "package alert.b\nallow := true"),
+		policy.WithPolicyData("c.rego", "package action.main\nallow := true"),
+		policy.WithPackage("alert"),
+	)
+	gt.NoError(t, err)
+	gt.V(t, client.Package()).Equal("alert")
+	gt.V(t, client.Packages()).Equal([]string{"alert.a", "alert.b"})
+
+	all, err := policy.New(policy.WithPolicyData("c.rego", "package action.main\nallow := true"))
+	gt.NoError(t, err)
+	gt.V(t, all.Package()).Equal("")
+	gt.V(t, all.Packages()).Equal([]string{"action.main"})
+}
+
+func TestClient_QueryWithData(t *testing.T) {
+	client, err := policy.New(policy.WithPolicyData("test.rego", `package test
+
+allow if data.alertchain.meta.transport == "pubsub"
+`), policy.WithPackage("test"))
+	gt.NoError(t, err)
+
+	var output examplePolicyResult
+	gt.NoError(t, client.Query(context.Background(), map[string]any{}, &output,
+		policy.WithData(map[string]any{"alertchain": map[string]any{"meta": map[string]any{"transport": "pubsub"}}}),
+	))
+	gt.B(t, output.Allow).True()
+
+	output = examplePolicyResult{}
+	gt.NoError(t, client.Query(context.Background(), map[string]any{}, &output))
+	gt.B(t, output.Allow).False()
+}
+
 func TestClient_Query_NoResult(t *testing.T) {
 	client, err := policy.New(policy.WithPolicyData("test.rego", examplePolicy), policy.WithPackage("test"))
 	gt.NoError(t, err)
@@ -114,3 +150,55 @@ func TestClient_Query_NoResult(t *testing.T) {
 	err = client.Query(ctx, input, &output)
 	gt.Error(t, err)
 }
+
+func TestClient_Trace(t *testing.T) {
+	const tracePolicy = `package test
+
+allow if {
+	input.role == "admin"
+}
+
+deny if {
+	startswith(input.role, "gu")
+	not input.trusted
+}
+
+block if {
+	input.role == "guest"
+}
+`
+	client := gt.R1(policy.New(
+		policy.WithPolicyData("trace.rego", tracePolicy),
+		policy.WithPackage("test"),
+	)).NoError(t)
+
+	var trace *model.PolicyTrace
+	var out examplePolicyResult
+	gt.NoError(t, client.Query(context.Background(), map[string]any{"role": "admin"}, &out,
+		policy.WithTrace(func(v *model.PolicyTrace) { trace = v }),
+	))
+	gt.V(t, out.Allow).Equal(true)
+
+	gt.V(t, trace).NotEqual(nil)
+	gt.V(t, trace.Package).Equal("test")
+	rules := map[string]model.RuleTrace{}
+	for _, r := range trace.Rules {
+		rules[r.Name] = r
+	}
+	gt.M(t, rules).Length(3)
+
+	gt.V(t, rules["allow"].Location).Equal("trace.rego:3")
+	gt.B(t, rules["allow"].Evaluated).True()
+	gt.B(t, rules["allow"].Result).True()
+	gt.A(t, rules["allow"].Fails).Length(0)
+
+	gt.B(t, rules["deny"].Evaluated).True()
+	gt.B(t, rules["deny"].Result).False()
+	gt.A(t, rules["deny"].Fails).Length(1).At(0, func(t testing.TB, v model.ExprTrace) {
+		gt.V(t, v.Expr).Equal(`startswith(input.role, "gu")`)
+		gt.V(t, v.Location).Equal("trace.rego:8")
+	})
+
+	gt.B(t, rules["block"].Evaluated).False()
+	gt.B(t, rules["block"].Result).False()
+}
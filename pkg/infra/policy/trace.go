@@ -0,0 +1,113 @@
+package policy
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/open-policy-agent/opa/v1/ast"
+	"github.com/open-policy-agent/opa/v1/topdown"
+	"github.com/secmon-lab/alertchain/pkg/domain/model"
+)
+
+// Trace is a callback to receive explanation of a policy evaluation.
+type Trace func(trace *model.PolicyTrace)
+
+type ruleState struct {
+	trace    model.RuleTrace
+	failKeys map[string]struct{}
+}
+
+// summarizeTrace converts OPA trace events to a condensed explanation. All rules in the queried package are listed even if they were not evaluated. A rule is true if its body exited at least once. Failed expressions are kept only for rules that never became true, because failures in other iterations of a satisfied rule are not useful for debugging.
+func summarizeTrace(compiler *ast.Compiler, query string, events []*topdown.Event) *model.PolicyTrace {
+	var order []string
+	rules := map[string]*ruleState{}
+	queryToRule := map[uint64]string{}
+
+	getState := func(rule *ast.Rule) *ruleState {
+		key := formatLocation(rule.Location)
+		state, ok := rules[key]
+		if !ok {
+			state = &ruleState{
+				trace: model.RuleTrace{
+					Name:     rule.Head.Ref().String(),
+					Location: key,
+				},
+				failKeys: map[string]struct{}{},
+			}
+			rules[key] = state
+			order = append(order, key)
+		}
+		return state
+	}
+
+	if ref, err := ast.ParseRef(query); err == nil {
+		for _, rule := range compiler.GetRulesWithPrefix(ref) {
+			getState(rule)
+		}
+	}
+
+	for _, ev := range events {
+		switch node := ev.Node.(type) {
+		case *ast.Rule:
+			state := getState(node)
+			key := state.trace.Location
+
+			switch ev.Op {
+			case topdown.EnterOp:
+				state.trace.Evaluated = true
+				queryToRule[ev.QueryID] = key
+			case topdown.ExitOp:
+				state.trace.Result = true
+			}
+
+		case *ast.Expr:
+			if ev.Op != topdown.FailOp {
+				continue
+			}
+			key, ok := queryToRule[ev.QueryID]
+			if !ok {
+				continue
+			}
+			state := rules[key]
+
+			expr := model.ExprTrace{
+				Expr:     exprText(node),
+				Location: formatLocation(node.Location),
+			}
+			failKey := expr.Location + ":" + expr.Expr
+			if _, ok := state.failKeys[failKey]; ok {
+				continue
+			}
+			state.failKeys[failKey] = struct{}{}
+			state.trace.Fails = append(state.trace.Fails, expr)
+		}
+	}
+
+	trace := &model.PolicyTrace{
+		Package: strings.TrimPrefix(query, "data."),
+	}
+	for _, key := range order {
+		state := rules[key]
+		if state.trace.Result {
+			state.trace.Fails = nil
+		}
+		trace.Rules = append(trace.Rules, state.trace)
+	}
+
+	return trace
+}
+
+// exprText returns the original text of the expression in the policy file because the compiled expression has rewritten local variables such as `__local0__`.
+func exprText(expr *ast.Expr) string {
+	if expr.Location != nil && len(expr.Location.Text) > 0 {
+		return string(expr.Location.Text)
+	}
+	return expr.String()
+}
+
+func formatLocation(loc *ast.Location) string {
+	if loc == nil {
+		return ""
+	}
+	return fmt.Sprintf("%s:%d", loc.File, loc.Row)
+}
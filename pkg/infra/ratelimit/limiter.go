@@ -0,0 +1,307 @@
+package ratelimit
+
+import (
+	"math"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/m-mizutani/goerr/v2"
+	"github.com/secmon-lab/alertchain/pkg/domain/types"
+	"golang.org/x/time/rate"
+)
+
+// Limit is a token bucket configuration. Tokens are refilled at Rate per second up to Burst.
+type Limit struct {
+	Rate  rate.Limit
+	Burst int
+}
+
+// ParseLimit parses "N/s", "N/m" or "N/h" with optional ":BURST", e.g. "600/m:50". Burst is N if omitted.
+func ParseLimit(s string) (Limit, error) {
+	spec, burstStr, hasBurst := strings.Cut(s, ":")
+	countStr, unit, found := strings.Cut(spec, "/")
+	if !found {
+		return Limit{}, goerr.New("rate limit must be N/UNIT[:BURST]", goerr.V("limit", s))
+	}
+
+	count, err := strconv.Atoi(countStr)
+	if err != nil || count <= 0 {
+		return Limit{}, goerr.New("count of rate limit must be positive integer", goerr.V("limit", s))
+	}
+
+	var per time.Duration
+	switch unit {
+	case "s":
+		per = time.Second
+	case "m":
+		per = time.Minute
+	case "h":
+		per = time.Hour
+	default:
+		return Limit{}, goerr.New("unit of rate limit must be s, m or h", goerr.V("limit", s))
+	}
+
+	limit := Limit{
+		Rate:  rate.Limit(float64(count) / per.Seconds()),
+		Burst: count,
+	}
+	if hasBurst {
+		burst, err := strconv.Atoi(burstStr)
+		if err != nil || burst <= 0 {
+			return Limit{}, goerr.New("burst of rate limit must be positive integer", goerr.V("limit", s))
+		}
+		limit.Burst = burst
+	}
+
+	return limit, nil
+}
+
+// Scope of the bucket that limited the request.
+const (
+	ScopeGlobal = "global"
+	ScopeSchema = "schema"
+	ScopeRemote = "remote"
+	ScopeClass  = "class"
+)
+
+// Decision is a result of Limiter.Allow.
+type Decision int
+
+const (
+	// Pass means the request is within all limits.
+	Pass Decision = iota
+	// Sampled means the request exceeded a limit, but it is picked by sampling and should be processed.
+	Sampled
+	// Dropped means the request exceeded a limit and is discarded by sampling.
+	Dropped
+	// Rejected means the request exceeded a limit and should be rejected with 429.
+	Rejected
+	// TooLarge means the request requires more tokens than burst size of a limit. It can not pass even if retried, then it should be rejected with 413 and is never sampled.
+	TooLarge
+)
+
+func (x Decision) String() string {
+	switch x {
+	case Pass:
+		return "pass"
+	case Sampled:
+		return "sampled"
+	case Dropped:
+		return "dropped"
+	case Rejected:
+		return "rejected"
+	case TooLarge:
+		return "too_large"
+	default:
+		return "unknown"
+	}
+}
+
+// Result is a decision of the limiter. Scope is set only if the request exceeded a limit, and RetryAfter is set only if it may pass later.
+type Result struct {
+	Decision   Decision
+	Scope      string
+	RetryAfter time.Duration
+}
+
+// Limiter applies token bucket limits globally, per schema and per remote address. A rate class given by authz policy is applied separately by AllowClass, because it is known only after authorization.
+type Limiter struct {
+	global        *Limit
+	schemas       map[types.Schema]Limit
+	defaultSchema *Limit
+	remote        *Limit
+	classes       map[string]Limit
+	sampleRate    float64
+
+	now    func() time.Time
+	random func() float64
+
+	mutex     sync.Mutex
+	buckets   map[string]*rate.Limiter
+	lastSweep time.Time
+}
+
+type Option func(*Limiter)
+
+// WithGlobal sets a limit shared by all requests.
+func WithGlobal(limit Limit) Option {
+	return func(x *Limiter) {
+		x.global = &limit
+	}
+}
+
+// WithSchema sets a limit of requests for the schema. Schema "*" is applied to schemas without their own limit.
+func WithSchema(schema types.Schema, limit Limit) Option {
+	return func(x *Limiter) {
+		if schema == "*" {
+			x.defaultSchema = &limit
+			return
+		}
+		x.schemas[schema] = limit
+	}
+}
+
+// WithRemote sets a limit per remote address.
+func WithRemote(limit Limit) Option {
+	return func(x *Limiter) {
+		x.remote = &limit
+	}
+}
+
+// WithClass defines a rate class that can be selected by rate_class of authz policy output.
+func WithClass(name string, limit Limit) Option {
+	return func(x *Limiter) {
+		x.classes[name] = limit
+	}
+}
+
+// WithSampling processes the ratio (0.0 - 1.0) of requests exceeding a limit instead of rejecting them.
+func WithSampling(ratio float64) Option {
+	return func(x *Limiter) {
+		x.sampleRate = ratio
+	}
+}
+
+// WithNow replaces clock. It is for testing.
+func WithNow(now func() time.Time) Option {
+	return func(x *Limiter) {
+		x.now = now
+	}
+}
+
+// WithRandom replaces random number generator for sampling. It is for testing.
+func WithRandom(random func() float64) Option {
+	return func(x *Limiter) {
+		x.random = random
+	}
+}
+
+func New(options ...Option) *Limiter {
+	x := &Limiter{
+		schemas: make(map[types.Schema]Limit),
+		classes: make(map[string]Limit),
+		buckets: make(map[string]*rate.Limiter),
+		now:     time.Now,
+		random:  rand.Float64,
+	}
+	for _, opt := range options {
+		opt(x)
+	}
+	return x
+}
+
+// HasClass returns true if the rate class is defined.
+func (x *Limiter) HasClass(name string) bool {
+	_, ok := x.classes[name]
+	return ok
+}
+
+type target struct {
+	scope string
+	key   string
+	limit Limit
+}
+
+func (x *Limiter) targets(schema types.Schema, remote string) []target {
+	var targets []target
+
+	if x.remote != nil {
+		targets = append(targets, target{scope: ScopeRemote, key: "remote:" + remote, limit: *x.remote})
+	}
+
+	if limit, ok := x.schemas[schema]; ok {
+		targets = append(targets, target{scope: ScopeSchema, key: "schema:" + string(schema), limit: limit})
+	} else if x.defaultSchema != nil {
+		targets = append(targets, target{scope: ScopeSchema, key: "schema:" + string(schema), limit: *x.defaultSchema})
+	}
+
+	if x.global != nil {
+		targets = append(targets, target{scope: ScopeGlobal, key: "global", limit: *x.global})
+	}
+
+	return targets
+}
+
+// Allow consumes n tokens from every global, schema and remote address bucket applied to the request, e.g. n is number of events in a batch request. If any bucket does not have enough tokens, no token is consumed and the request is rejected, or sampled if sampling is enabled. A request with more events than burst size results in TooLarge regardless of sampling.
+func (x *Limiter) Allow(schema types.Schema, remote string, n int) Result {
+	return x.reserve(x.targets(schema, remote), n)
+}
+
+// AllowClass consumes n tokens from bucket of the rate class per remote address. It always passes if the class is not defined.
+func (x *Limiter) AllowClass(class, remote string, n int) Result {
+	limit, ok := x.classes[class]
+	if !ok {
+		return Result{Decision: Pass}
+	}
+	return x.reserve([]target{{scope: ScopeClass, key: "class:" + class + ":" + remote, limit: limit}}, n)
+}
+
+func (x *Limiter) reserve(targets []target, n int) Result {
+	x.mutex.Lock()
+	defer x.mutex.Unlock()
+
+	now := x.now()
+	x.sweep(now)
+
+	var reservations []*rate.Reservation
+	for _, t := range targets {
+		b, ok := x.buckets[t.key]
+		if !ok {
+			b = rate.NewLimiter(t.limit.Rate, t.limit.Burst)
+			x.buckets[t.key] = b
+		}
+
+		if n > t.limit.Burst {
+			for _, prev := range reservations {
+				prev.CancelAt(now)
+			}
+			return Result{Decision: TooLarge, Scope: t.scope}
+		}
+
+		r := b.ReserveN(now, n)
+		if delay := r.DelayFrom(now); !r.OK() || delay > 0 {
+			r.CancelAt(now)
+			for _, prev := range reservations {
+				prev.CancelAt(now)
+			}
+			return x.exceeded(t.scope, delay)
+		}
+		reservations = append(reservations, r)
+	}
+
+	return Result{Decision: Pass}
+}
+
+func (x *Limiter) exceeded(scope string, delay time.Duration) Result {
+	result := Result{Scope: scope, Decision: Rejected}
+	if delay == rate.InfDuration {
+		delay = time.Second
+	}
+	result.RetryAfter = time.Duration(math.Ceil(delay.Seconds())) * time.Second
+
+	if x.sampleRate > 0 {
+		if x.random() < x.sampleRate {
+			result.Decision = Sampled
+		} else {
+			result.Decision = Dropped
+		}
+	}
+	return result
+}
+
+// sweep removes buckets that are refilled to the burst size, because they are equivalent to new ones. It bounds memory usage by number of remote addresses.
+func (x *Limiter) sweep(now time.Time) {
+	if now.Sub(x.lastSweep) < time.Minute {
+		return
+	}
+	x.lastSweep = now
+
+	for key, b := range x.buckets {
+		if b.TokensAt(now) >= float64(b.Burst()) {
+			delete(x.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package ratelimit_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/m-mizutani/gt"
+	"github.com/secmon-lab/alertchain/pkg/infra/ratelimit"
+	"golang.org/x/time/rate"
+)
+
+func TestParseLimit(t *testing.T) {
+	testCases := map[string]struct {
+		input  string
+		expect ratelimit.Limit
+		isErr  bool
+	}{
+		"per second":    {input: "10/s", expect: ratelimit.Limit{Rate: 10, Burst: 10}},
+		"per minute":    {input: "120/m", expect: ratelimit.Limit{Rate: 2, Burst: 120}},
+		"per hour":      {input: "3600/h:5", expect: ratelimit.Limit{Rate: 1, Burst: 5}},
+		"no unit":       {input: "10", isErr: true},
+		"invalid unit":  {input: "10/d", isErr: true},
+		"zero count":    {input: "0/s", isErr: true},
+		"invalid burst": {input: "10/s:x", isErr: true},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			limit, err := ratelimit.ParseLimit(tc.input)
+			if tc.isErr {
+				gt.Error(t, err)
+				return
+			}
+			gt.NoError(t, err)
+			gt.V(t, limit).Equal(tc.expect)
+		})
+	}
+}
+
+func TestLimiter(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	clock := func() time.Time { return now }
+	perMinute := func(n int) ratelimit.Limit {
+		return ratelimit.Limit{Rate: rate.Limit(float64(n) / 60), Burst: n}
+	}
+
+	t.Run("per remote address", func(t *testing.T) {
+		limiter := ratelimit.New(ratelimit.WithRemote(perMinute(2)), ratelimit.WithNow(clock))
+		gt.V(t, limiter.Allow("my_alert", "10.0.0.1", 1).Decision).Equal(ratelimit.Pass)
+		gt.V(t, limiter.Allow("my_alert", "10.0.0.1", 1).Decision).Equal(ratelimit.Pass)
+
+		result := limiter.Allow("my_alert", "10.0.0.1", 1)
+		gt.V(t, result.Decision).Equal(ratelimit.Rejected)
+		gt.V(t, result.Scope).Equal(ratelimit.ScopeRemote)
+		gt.V(t, result.RetryAfter).Equal(30 * time.Second)
+
+		gt.V(t, limiter.Allow("my_alert", "10.0.0.2", 1).Decision).Equal(ratelimit.Pass)
+	})
+
+	t.Run("per schema with default", func(t *testing.T) {
+		limiter := ratelimit.New(
+			ratelimit.WithSchema("noisy", perMinute(1)),
+			ratelimit.WithSchema("*", perMinute(3)),
+			ratelimit.WithNow(clock),
+		)
+		gt.V(t, limiter.Allow("noisy", "10.0.0.1", 1).Decision).Equal(ratelimit.Pass)
+		gt.V(t, limiter.Allow("noisy", "10.0.0.1", 1).Scope).Equal(ratelimit.ScopeSchema)
+		for i := 0; i < 3; i++ {
+			gt.V(t, limiter.Allow("other", "10.0.0.1", 1).Decision).Equal(ratelimit.Pass)
+		}
+		gt.V(t, limiter.Allow("other", "10.0.0.1", 1).Decision).Equal(ratelimit.Rejected)
+	})
+
+	t.Run("rejected request does not consume tokens", func(t *testing.T) {
+		limiter := ratelimit.New(
+			ratelimit.WithRemote(perMinute(1)),
+			ratelimit.WithGlobal(perMinute(2)),
+			ratelimit.WithNow(clock),
+		)
+		gt.V(t, limiter.Allow("my_alert", "10.0.0.1", 1).Decision).Equal(ratelimit.Pass)
+		gt.V(t, limiter.Allow("my_alert", "10.0.0.1", 1).Scope).Equal(ratelimit.ScopeRemote)
+		gt.V(t, limiter.Allow("my_alert", "10.0.0.2", 1).Decision).Equal(ratelimit.Pass)
+		gt.V(t, limiter.Allow("my_alert", "10.0.0.3", 1).Scope).Equal(ratelimit.ScopeGlobal)
+	})
+
+	t.Run("rate class per remote address", func(t *testing.T) {
+		limiter := ratelimit.New(
+			ratelimit.WithRemote(perMinute(1)),
+			ratelimit.WithClass("low", perMinute(3)),
+			ratelimit.WithNow(clock),
+		)
+		gt.B(t, limiter.HasClass("low")).True()
+		gt.B(t, limiter.HasClass("high")).False()
+		for i := 0; i < 3; i++ {
+			gt.V(t, limiter.AllowClass("low", "10.0.0.1", 1).Decision).Equal(ratelimit.Pass)
+		}
+		gt.V(t, limiter.AllowClass("low", "10.0.0.1", 1).Scope).Equal(ratelimit.ScopeClass)
+		gt.V(t, limiter.AllowClass("low", "10.0.0.2", 1).Decision).Equal(ratelimit.Pass)
+		gt.V(t, limiter.AllowClass("high", "10.0.0.1", 1).Decision).Equal(ratelimit.Pass)
+	})
+
+	t.Run("consume tokens per event", func(t *testing.T) {
+		limiter := ratelimit.New(ratelimit.WithSchema("my_alert", perMinute(5)), ratelimit.WithNow(clock))
+		gt.V(t, limiter.Allow("my_alert", "10.0.0.1", 3).Decision).Equal(ratelimit.Pass)
+		gt.V(t, limiter.Allow("my_alert", "10.0.0.1", 3).Decision).Equal(ratelimit.Rejected)
+		gt.V(t, limiter.Allow("my_alert", "10.0.0.1", 2).Decision).Equal(ratelimit.Pass)
+		gt.V(t, limiter.Allow("my_alert", "10.0.0.1", 1).Decision).Equal(ratelimit.Rejected)
+
+		// Batch larger than burst never passes
+		limiter = ratelimit.New(ratelimit.WithSchema("my_alert", perMinute(5)), ratelimit.WithNow(clock))
+		result := limiter.Allow("my_alert", "10.0.0.1", 6)
+		gt.V(t, result.Decision).Equal(ratelimit.TooLarge)
+		gt.V(t, result.Scope).Equal(ratelimit.ScopeSchema)
+		gt.V(t, result.RetryAfter).Equal(0)
+
+		// Tokens are not consumed by the too large batch
+		gt.V(t, limiter.Allow("my_alert", "10.0.0.1", 5).Decision).Equal(ratelimit.Pass)
+	})
+
+	t.Run("batch larger than burst is not sampled", func(t *testing.T) {
+		limiter := ratelimit.New(
+			ratelimit.WithRemote(perMinute(10)),
+			ratelimit.WithGlobal(perMinute(5)),
+			ratelimit.WithSampling(1.0),
+			ratelimit.WithNow(clock),
+			ratelimit.WithRandom(func() float64 { return 0 }),
+		)
+		result := limiter.Allow("my_alert", "10.0.0.1", 6)
+		gt.V(t, result.Decision).Equal(ratelimit.TooLarge)
+		gt.V(t, result.Scope).Equal(ratelimit.ScopeGlobal)
+
+		// Token reserved from remote bucket is returned
+		gt.V(t, limiter.Allow("my_alert", "10.0.0.1", 5).Decision).Equal(ratelimit.Pass)
+		gt.V(t, limiter.Allow("my_alert", "10.0.0.1", 5).Decision).Equal(ratelimit.Sampled)
+	})
+
+	t.Run("refilled after time passes", func(t *testing.T) {
+		current := now
+		limiter := ratelimit.New(ratelimit.WithGlobal(perMinute(1)), ratelimit.WithNow(func() time.Time { return current }))
+		gt.V(t, limiter.Allow("my_alert", "10.0.0.1", 1).Decision).Equal(ratelimit.Pass)
+		gt.V(t, limiter.Allow("my_alert", "10.0.0.1", 1).Decision).Equal(ratelimit.Rejected)
+		current = current.Add(time.Minute)
+		gt.V(t, limiter.Allow("my_alert", "10.0.0.1", 1).Decision).Equal(ratelimit.Pass)
+	})
+
+	t.Run("sampling", func(t *testing.T) {
+		var random float64
+		limiter := ratelimit.New(
+			ratelimit.WithGlobal(perMinute(1)),
+			ratelimit.WithSampling(0.1),
+			ratelimit.WithNow(clock),
+			ratelimit.WithRandom(func() float64 { return random }),
+		)
+		gt.V(t, limiter.Allow("my_alert", "10.0.0.1", 1).Decision).Equal(ratelimit.Pass)
+
+		random = 0.05
+		gt.V(t, limiter.Allow("my_alert", "10.0.0.1", 1).Decision).Equal(ratelimit.Sampled)
+		random = 0.5
+		gt.V(t, limiter.Allow("my_alert", "10.0.0.1", 1).Decision).Equal(ratelimit.Dropped)
+	})
+}
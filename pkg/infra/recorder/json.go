@@ -49,6 +49,10 @@ func (x *JSONLogger) LogError(err error) {
 	x.log.Error = err.Error()
 }
 
+func (x *JSONLogger) LogTrace(trace *model.PolicyTrace) {
+	x.log.Traces = append(x.log.Traces, trace)
+}
+
 func (x *JSONLogger) Flush() error {
 	encoder := json.NewEncoder(x.w)
 	encoder.SetIndent("", "  ")
@@ -33,6 +33,10 @@ func (x *Memory) LogError(err error) {
 	x.Log.Error = err.Error()
 }
 
+func (x *Memory) LogTrace(trace *model.PolicyTrace) {
+	x.Log.Traces = append(x.Log.Traces, trace)
+}
+
 func (x *Memory) Flush() error {
 	return nil
 }
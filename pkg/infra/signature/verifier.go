@@ -0,0 +1,170 @@
+package signature
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/m-mizutani/goerr/v2"
+	"github.com/secmon-lab/alertchain/pkg/domain/types"
+)
+
+// Verifier checks HMAC signature of webhook request.
+type Verifier interface {
+	// Type is name of the signature scheme, e.g. "github". It is exposed to authz policy as verified_signatures.
+	Type() string
+	Verify(header http.Header, body []byte) error
+}
+
+// Rule applies the verifier to request of the path. The path matches exactly, or as prefix if it ends with "*".
+type Rule struct {
+	Path     string
+	Verifier Verifier
+}
+
+func (x Rule) Match(path string) bool {
+	if prefix, ok := strings.CutSuffix(x.Path, "*"); ok {
+		return strings.HasPrefix(path, prefix)
+	}
+	return x.Path == path
+}
+
+func hmacSHA256(secret string, data ...[]byte) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	for _, d := range data {
+		mac.Write(d)
+	}
+	return mac.Sum(nil)
+}
+
+func errInvalid(msg string, header string) error {
+	return goerr.New(msg, goerr.V("header", header), goerr.T(types.ErrTagUnauthorized))
+}
+
+// GitHub verifies X-Hub-Signature-256 header of GitHub webhook.
+type GitHub struct {
+	secret string
+}
+
+func NewGitHub(secret string) *GitHub {
+	return &GitHub{secret: secret}
+}
+
+func (x *GitHub) Type() string { return "github" }
+
+func (x *GitHub) Verify(header http.Header, body []byte) error {
+	const hdr = "X-Hub-Signature-256"
+	sig, ok := strings.CutPrefix(header.Get(hdr), "sha256=")
+	if !ok {
+		return errInvalid("no GitHub signature", hdr)
+	}
+
+	expected, err := hex.DecodeString(sig)
+	if err != nil || !hmac.Equal(expected, hmacSHA256(x.secret, body)) {
+		return errInvalid("invalid GitHub signature", hdr)
+	}
+	return nil
+}
+
+// Slack verifies X-Slack-Signature header of Slack request. A request older than tolerance is rejected to prevent replay attack.
+type Slack struct {
+	secret    string
+	tolerance time.Duration
+	now       func() time.Time
+}
+
+type SlackOption func(*Slack)
+
+// WithSlackTolerance sets acceptable difference between X-Slack-Request-Timestamp and current time. Default is 5 minutes.
+func WithSlackTolerance(d time.Duration) SlackOption {
+	return func(x *Slack) {
+		x.tolerance = d
+	}
+}
+
+// WithSlackNow replaces clock for the replay check. It is for testing.
+func WithSlackNow(now func() time.Time) SlackOption {
+	return func(x *Slack) {
+		x.now = now
+	}
+}
+
+func NewSlack(secret string, options ...SlackOption) *Slack {
+	s := &Slack{
+		secret:    secret,
+		tolerance: 5 * time.Minute,
+		now:       time.Now,
+	}
+	for _, opt := range options {
+		opt(s)
+	}
+	return s
+}
+
+func (x *Slack) Type() string { return "slack" }
+
+func (x *Slack) Verify(header http.Header, body []byte) error {
+	const tsHdr, sigHdr = "X-Slack-Request-Timestamp", "X-Slack-Signature"
+
+	ts := header.Get(tsHdr)
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return errInvalid("invalid Slack request timestamp", tsHdr)
+	}
+	if diff := x.now().Sub(time.Unix(unix, 0)).Abs(); diff > x.tolerance {
+		return goerr.New("Slack request timestamp is out of tolerance",
+			goerr.V("timestamp", ts),
+			goerr.V("tolerance", x.tolerance),
+			goerr.T(types.ErrTagUnauthorized),
+		)
+	}
+
+	sig, ok := strings.CutPrefix(header.Get(sigHdr), "v0=")
+	if !ok {
+		return errInvalid("no Slack signature", sigHdr)
+	}
+
+	expected, err := hex.DecodeString(sig)
+	if err != nil || !hmac.Equal(expected, hmacSHA256(x.secret, []byte("v0:"+ts+":"), body)) {
+		return errInvalid("invalid Slack signature", sigHdr)
+	}
+	return nil
+}
+
+// Generic verifies HMAC-SHA256 of request body in a header (X-Signature by default). The signature can be hex or base64 encoded, optionally with "sha256=" prefix.
+type Generic struct {
+	secret string
+	header string
+}
+
+func NewGeneric(secret, header string) *Generic {
+	if header == "" {
+		header = "X-Signature"
+	}
+	return &Generic{secret: secret, header: header}
+}
+
+func (x *Generic) Type() string { return "generic" }
+
+func (x *Generic) Verify(header http.Header, body []byte) error {
+	sig := header.Get(x.header)
+	if sig == "" {
+		return errInvalid("no signature", x.header)
+	}
+	sig = strings.TrimPrefix(sig, "sha256=")
+
+	mac := hmacSHA256(x.secret, body)
+	if expected, err := hex.DecodeString(sig); err == nil && hmac.Equal(expected, mac) {
+		return nil
+	}
+	if expected, err := base64.StdEncoding.DecodeString(sig); err == nil && hmac.Equal(expected, mac) {
+		return nil
+	}
+
+	return errInvalid("invalid signature", x.header)
+}
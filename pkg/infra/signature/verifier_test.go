@@ -0,0 +1,96 @@
+package signature_test
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/m-mizutani/gt"
+	"github.com/secmon-lab/alertchain/pkg/infra/signature"
+)
+
+const secret = "Caprice_of_the_Leaves"
+
+var body = []byte(`{"action":"opened"}`)
+
+func mac(data string) []byte {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+func TestGitHub(t *testing.T) {
+	v := signature.NewGitHub(secret)
+
+	header := http.Header{}
+	header.Set("X-Hub-Signature-256", "sha256="+hex.EncodeToString(mac(string(body))))
+	gt.NoError(t, v.Verify(header, body))
+	gt.Error(t, v.Verify(header, []byte(`{"action":"closed"}`)))
+	gt.Error(t, v.Verify(http.Header{}, body))
+}
+
+func TestSlack(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	v := signature.NewSlack(secret, signature.WithSlackNow(func() time.Time { return now }))
+
+	sign := func(ts time.Time) http.Header {
+		tsStr := strconv.FormatInt(ts.Unix(), 10)
+		header := http.Header{}
+		header.Set("X-Slack-Request-Timestamp", tsStr)
+		header.Set("X-Slack-Signature", "v0="+hex.EncodeToString(mac("v0:"+tsStr+":"+string(body))))
+		return header
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		gt.NoError(t, v.Verify(sign(now.Add(-time.Minute)), body))
+	})
+
+	t.Run("replayed request", func(t *testing.T) {
+		gt.Error(t, v.Verify(sign(now.Add(-10*time.Minute)), body))
+	})
+
+	t.Run("tampered timestamp", func(t *testing.T) {
+		header := sign(now.Add(-time.Minute))
+		header.Set("X-Slack-Request-Timestamp", strconv.FormatInt(now.Unix(), 10))
+		gt.Error(t, v.Verify(header, body))
+	})
+}
+
+func TestGeneric(t *testing.T) {
+	testCases := map[string]struct {
+		header string
+		value  string
+		valid  bool
+	}{
+		"hex":           {header: "X-Signature", value: hex.EncodeToString(mac(string(body))), valid: true},
+		"base64":        {header: "X-Signature", value: base64.StdEncoding.EncodeToString(mac(string(body))), valid: true},
+		"with prefix":   {header: "X-Signature", value: "sha256=" + hex.EncodeToString(mac(string(body))), valid: true},
+		"custom header": {header: "X-Webhook-Signature", value: hex.EncodeToString(mac(string(body))), valid: true},
+		"wrong value":   {header: "X-Signature", value: hex.EncodeToString(mac("other")), valid: false},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			v := signature.NewGeneric(secret, tc.header)
+			header := http.Header{}
+			header.Set(tc.header, tc.value)
+			if tc.valid {
+				gt.NoError(t, v.Verify(header, body))
+			} else {
+				gt.Error(t, v.Verify(header, body))
+			}
+		})
+	}
+}
+
+func TestRuleMatch(t *testing.T) {
+	gt.B(t, signature.Rule{Path: "/alert/raw/github"}.Match("/alert/raw/github")).True()
+	gt.B(t, signature.Rule{Path: "/alert/raw/github"}.Match("/alert/raw/github2")).False()
+	gt.B(t, signature.Rule{Path: "/alert/raw/*"}.Match("/alert/raw/github")).True()
+	gt.B(t, signature.Rule{Path: "/alert/raw/*"}.Match("/alert/pubsub/github")).False()
+}
@@ -0,0 +1,289 @@
+package sns
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha1" // #nosec G505, SignatureVersion 1 of SNS requires SHA1
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/m-mizutani/goerr/v2"
+	"github.com/secmon-lab/alertchain/pkg/domain/model"
+	"github.com/secmon-lab/alertchain/pkg/domain/types"
+	"github.com/secmon-lab/alertchain/pkg/utils"
+)
+
+// CertFetcher retrieves PEM encoded signing certificate of SNS from certURL.
+type CertFetcher func(ctx context.Context, certURL string) ([]byte, error)
+
+// Client verifies signature of SNS messages and confirms subscription. Signing certificates are cached by URL.
+type Client struct {
+	fetchCert  CertFetcher
+	httpClient *http.Client
+	topicARNs  map[string]struct{}
+
+	certs     map[string]*x509.Certificate
+	certMutex sync.Mutex
+}
+
+type Option func(*Client)
+
+// WithCertFetcher replaces the default certificate fetcher that downloads certificate via HTTPS.
+func WithCertFetcher(fetcher CertFetcher) Option {
+	return func(x *Client) {
+		x.fetchCert = fetcher
+	}
+}
+
+// WithHTTPClient sets HTTP client to access SubscribeURL and default certificate fetcher.
+func WithHTTPClient(client *http.Client) Option {
+	return func(x *Client) {
+		x.httpClient = client
+	}
+}
+
+// WithTopicARN allows messages of the topics. Messages of other topics are rejected, and all messages are rejected if no topic is allowed.
+func WithTopicARN(arns ...string) Option {
+	return func(x *Client) {
+		for _, arn := range arns {
+			x.topicARNs[arn] = struct{}{}
+		}
+	}
+}
+
+func New(options ...Option) *Client {
+	client := &Client{
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+		topicARNs:  make(map[string]struct{}),
+		certs:      make(map[string]*x509.Certificate),
+	}
+	client.fetchCert = client.download
+
+	for _, opt := range options {
+		opt(client)
+	}
+
+	return client
+}
+
+var certHostPattern = regexp.MustCompile(`^sns\.[a-z0-9-]+\.amazonaws\.com(\.cn)?$`)
+
+func validateCertURL(certURL string) error {
+	u, err := url.Parse(certURL)
+	if err != nil {
+		return goerr.Wrap(err, "invalid SigningCertURL", goerr.V("url", certURL), goerr.T(types.ErrTagUnauthorized))
+	}
+
+	if u.Scheme != "https" || !certHostPattern.MatchString(u.Host) || !strings.HasSuffix(u.Path, ".pem") {
+		return goerr.New("SigningCertURL is not SNS certificate", goerr.V("url", certURL), goerr.T(types.ErrTagUnauthorized))
+	}
+
+	return nil
+}
+
+func (x *Client) download(ctx context.Context, certURL string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, certURL, nil)
+	if err != nil {
+		return nil, goerr.Wrap(err, "failed to create certificate request", goerr.V("url", certURL))
+	}
+
+	resp, err := x.httpClient.Do(req)
+	if err != nil {
+		return nil, goerr.Wrap(err, "failed to fetch certificate", goerr.V("url", certURL), goerr.T(types.ErrTagSystem))
+	}
+	defer utils.SafeClose(ctx, resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, goerr.New("unexpected status code of certificate", goerr.V("url", certURL), goerr.V("code", resp.StatusCode), goerr.T(types.ErrTagSystem))
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if err != nil {
+		return nil, goerr.Wrap(err, "failed to read certificate", goerr.V("url", certURL), goerr.T(types.ErrTagSystem))
+	}
+
+	return data, nil
+}
+
+func (x *Client) getCert(ctx context.Context, certURL string) (*x509.Certificate, error) {
+	x.certMutex.Lock()
+	cert, ok := x.certs[certURL]
+	x.certMutex.Unlock()
+	if ok {
+		return cert, nil
+	}
+
+	// Fetch without lock not to block requests with cached certificate. Concurrent requests may fetch the same certificate, but it is stored only once.
+	data, err := x.fetchCert(ctx, certURL)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, goerr.New("failed to decode certificate PEM", goerr.V("url", certURL), goerr.T(types.ErrTagSystem))
+	}
+
+	parsed, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, goerr.Wrap(err, "failed to parse certificate", goerr.V("url", certURL), goerr.T(types.ErrTagSystem))
+	}
+
+	x.certMutex.Lock()
+	defer x.certMutex.Unlock()
+	if cached, ok := x.certs[certURL]; ok {
+		return cached, nil
+	}
+	x.certs[certURL] = parsed
+	return parsed, nil
+}
+
+// StringToSign builds the canonical string of the message that is signed by SNS.
+func StringToSign(msg *model.SNSMessage) ([]byte, error) {
+	type field struct {
+		key   string
+		value string
+	}
+
+	var fields []field
+	switch msg.Type {
+	case model.SNSTypeNotification:
+		fields = []field{
+			{"Message", msg.Message},
+			{"MessageId", msg.MessageID},
+			{"Subject", msg.Subject},
+			{"Timestamp", msg.Timestamp},
+			{"TopicArn", msg.TopicArn},
+			{"Type", msg.Type},
+		}
+
+	case model.SNSTypeSubscriptionConfirmation, model.SNSTypeUnsubscribeConfirmation:
+		fields = []field{
+			{"Message", msg.Message},
+			{"MessageId", msg.MessageID},
+			{"SubscribeURL", msg.SubscribeURL},
+			{"Timestamp", msg.Timestamp},
+			{"Token", msg.Token},
+			{"TopicArn", msg.TopicArn},
+			{"Type", msg.Type},
+		}
+
+	default:
+		return nil, goerr.New("unsupported SNS message type", goerr.V("type", msg.Type), goerr.T(types.ErrTagBadRequest))
+	}
+
+	var b strings.Builder
+	for _, f := range fields {
+		// Subject is included only if it is present in the message
+		if f.key == "Subject" && f.value == "" {
+			continue
+		}
+		b.WriteString(f.key + "\n" + f.value + "\n")
+	}
+
+	return []byte(b.String()), nil
+}
+
+// CheckTopic returns an error if the topic of the message is not allowed by WithTopicARN. Signature only proves that the message is sent by SNS, and any AWS account can subscribe its topic to the endpoint.
+func (x *Client) CheckTopic(msg *model.SNSMessage) error {
+	if _, ok := x.topicARNs[msg.TopicArn]; !ok {
+		return goerr.New("SNS topic is not allowed", goerr.V("topic_arn", msg.TopicArn), goerr.T(types.ErrTagForbidden))
+	}
+	return nil
+}
+
+// Verify checks the signature of the message with signing certificate of SNS.
+func (x *Client) Verify(ctx context.Context, msg *model.SNSMessage) error {
+	var hash crypto.Hash
+	switch msg.SignatureVersion {
+	case "1":
+		hash = crypto.SHA1
+	case "2":
+		hash = crypto.SHA256
+	default:
+		return goerr.New("unsupported SignatureVersion", goerr.V("version", msg.SignatureVersion), goerr.T(types.ErrTagUnauthorized))
+	}
+
+	if err := validateCertURL(msg.SigningCertURL); err != nil {
+		return err
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(msg.Signature)
+	if err != nil {
+		return goerr.Wrap(err, "failed to decode signature", goerr.T(types.ErrTagUnauthorized))
+	}
+
+	data, err := StringToSign(msg)
+	if err != nil {
+		return err
+	}
+
+	cert, err := x.getCert(ctx, msg.SigningCertURL)
+	if err != nil {
+		return err
+	}
+
+	pubKey, ok := cert.PublicKey.(*rsa.PublicKey)
+	if !ok {
+		return goerr.New("public key of certificate is not RSA", goerr.V("url", msg.SigningCertURL), goerr.T(types.ErrTagUnauthorized))
+	}
+
+	var digest []byte
+	if hash == crypto.SHA1 {
+		d := sha1.Sum(data) // #nosec G401
+		digest = d[:]
+	} else {
+		d := sha256.Sum256(data)
+		digest = d[:]
+	}
+
+	if err := rsa.VerifyPKCS1v15(pubKey, hash, digest, sig); err != nil {
+		return goerr.Wrap(err, "invalid SNS message signature",
+			goerr.V("message_id", msg.MessageID),
+			goerr.V("topic_arn", msg.TopicArn),
+			goerr.T(types.ErrTagUnauthorized),
+		)
+	}
+
+	return nil
+}
+
+// Confirm accepts the subscription by accessing SubscribeURL of the message. The message must be verified before calling Confirm.
+func (x *Client) Confirm(ctx context.Context, msg *model.SNSMessage) error {
+	if msg.SubscribeURL == "" {
+		return goerr.New("SubscribeURL is empty", goerr.V("topic_arn", msg.TopicArn), goerr.T(types.ErrTagBadRequest))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, msg.SubscribeURL, nil)
+	if err != nil {
+		return goerr.Wrap(err, "failed to create subscription confirmation request", goerr.V("url", msg.SubscribeURL), goerr.T(types.ErrTagBadRequest))
+	}
+
+	resp, err := x.httpClient.Do(req)
+	if err != nil {
+		return goerr.Wrap(err, "failed to confirm subscription", goerr.V("url", msg.SubscribeURL), goerr.T(types.ErrTagSystem))
+	}
+	defer utils.SafeClose(ctx, resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return goerr.New("unexpected status code of subscription confirmation",
+			goerr.V("url", msg.SubscribeURL),
+			goerr.V("code", resp.StatusCode),
+			goerr.V("body", string(body)),
+			goerr.T(types.ErrTagSystem),
+		)
+	}
+
+	return nil
+}
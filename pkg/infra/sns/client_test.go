@@ -0,0 +1,174 @@
+package sns_test
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/m-mizutani/goerr/v2"
+	"github.com/m-mizutani/gt"
+	"github.com/secmon-lab/alertchain/pkg/domain/model"
+	"github.com/secmon-lab/alertchain/pkg/domain/types"
+	"github.com/secmon-lab/alertchain/pkg/infra/sns"
+)
+
+const certURL = "https://sns.us-east-1.amazonaws.com/SimpleNotificationService-test.pem"
+
+func newSigner(t *testing.T) (*rsa.PrivateKey, []byte) {
+	key := gt.R1(rsa.GenerateKey(rand.Reader, 2048)).NoError(t)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "sns.amazonaws.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der := gt.R1(x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)).NoError(t)
+	return key, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+func sign(t *testing.T, key *rsa.PrivateKey, msg *model.SNSMessage) {
+	msg.SignatureVersion = "2"
+	msg.SigningCertURL = certURL
+	data := gt.R1(sns.StringToSign(msg)).NoError(t)
+	digest := sha256.Sum256(data)
+	sig := gt.R1(rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])).NoError(t)
+	msg.Signature = base64.StdEncoding.EncodeToString(sig)
+}
+
+func TestVerify(t *testing.T) {
+	key, certPEM := newSigner(t)
+
+	var fetched int
+	client := sns.New(sns.WithCertFetcher(func(ctx context.Context, url string) ([]byte, error) {
+		fetched++
+		gt.V(t, url).Equal(certURL)
+		return certPEM, nil
+	}))
+
+	newMsg := func() *model.SNSMessage {
+		msg := &model.SNSMessage{
+			Type:      model.SNSTypeNotification,
+			MessageID: "c9a0b1c2-0000-1111-2222-333344445555",
+			TopicArn:  "arn:aws:sns:us-east-1:123456789012:guardduty",
+			Subject:   "GuardDuty finding",
+			Message:   `{"detail-type":"GuardDuty Finding"}`,
+			Timestamp: "2024-01-01T00:00:00.000Z",
+		}
+		sign(t, key, msg)
+		return msg
+	}
+
+	ctx := context.Background()
+	t.Run("valid signature", func(t *testing.T) {
+		gt.NoError(t, client.Verify(ctx, newMsg()))
+		gt.NoError(t, client.Verify(ctx, newMsg()))
+		gt.N(t, fetched).Equal(1) // certificate is cached
+	})
+
+	t.Run("tampered message", func(t *testing.T) {
+		msg := newMsg()
+		msg.Message = `{"detail-type":"something else"}`
+		err := client.Verify(ctx, msg)
+		gt.Error(t, err)
+		gt.B(t, goerr.HasTag(err, types.ErrTagUnauthorized)).True()
+	})
+
+	t.Run("certificate URL not owned by SNS", func(t *testing.T) {
+		msg := newMsg()
+		msg.SigningCertURL = "https://sns.us-east-1.amazonaws.com.example.com/cert.pem"
+		err := client.Verify(ctx, msg)
+		gt.Error(t, err)
+		gt.B(t, goerr.HasTag(err, types.ErrTagUnauthorized)).True()
+	})
+
+	t.Run("unsupported signature version", func(t *testing.T) {
+		msg := newMsg()
+		msg.SignatureVersion = "3"
+		gt.Error(t, client.Verify(ctx, msg))
+	})
+}
+
+func TestConfirm(t *testing.T) {
+	var called int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called++
+		gt.V(t, r.URL.Query().Get("Token")).Equal("xxx")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	client := sns.New(sns.WithHTTPClient(srv.Client()))
+	gt.NoError(t, client.Confirm(context.Background(), &model.SNSMessage{
+		Type:         model.SNSTypeSubscriptionConfirmation,
+		SubscribeURL: srv.URL + "/?Action=ConfirmSubscription&Token=xxx",
+	}))
+	gt.N(t, called).Equal(1)
+}
+
+func TestCheckTopic(t *testing.T) {
+	allowed := &model.SNSMessage{TopicArn: "arn:aws:sns:us-east-1:123456789012:guardduty"}
+	other := &model.SNSMessage{TopicArn: "arn:aws:sns:us-east-1:999999999999:guardduty"}
+
+	t.Run("allowed topic", func(t *testing.T) {
+		client := sns.New(sns.WithTopicARN(allowed.TopicArn))
+		gt.NoError(t, client.CheckTopic(allowed))
+
+		err := client.CheckTopic(other)
+		gt.Error(t, err)
+		gt.B(t, goerr.HasTag(err, types.ErrTagForbidden)).True()
+	})
+
+	t.Run("no topic is allowed by default", func(t *testing.T) {
+		gt.Error(t, sns.New().CheckTopic(allowed))
+	})
+}
+
+func TestVerifyDoesNotWaitOtherFetch(t *testing.T) {
+	key, certPEM := newSigner(t)
+	slowURL := "https://sns.us-west-2.amazonaws.com/SimpleNotificationService-slow.pem"
+
+	release := make(chan struct{})
+	client := sns.New(sns.WithCertFetcher(func(ctx context.Context, url string) ([]byte, error) {
+		if url == slowURL {
+			<-release
+		}
+		return certPEM, nil
+	}))
+
+	newMsg := func() *model.SNSMessage {
+		msg := &model.SNSMessage{
+			Type:      model.SNSTypeNotification,
+			MessageID: "c9a0b1c2-0000-1111-2222-333344445555",
+			TopicArn:  "arn:aws:sns:us-east-1:123456789012:guardduty",
+			Message:   `{"detail-type":"GuardDuty Finding"}`,
+			Timestamp: "2024-01-01T00:00:00.000Z",
+		}
+		sign(t, key, msg)
+		return msg
+	}
+
+	ctx := context.Background()
+	gt.NoError(t, client.Verify(ctx, newMsg()))
+
+	slow := newMsg()
+	slow.SigningCertURL = slowURL
+	done := make(chan error)
+	go func() { done <- client.Verify(ctx, slow) }()
+
+	// Certificate in cache is available while the other certificate is being fetched
+	gt.NoError(t, client.Verify(ctx, newMsg()))
+
+	close(release)
+	gt.NoError(t, <-done)
+}
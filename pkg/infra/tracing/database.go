@@ -0,0 +1,166 @@
+package tracing
+
+import (
+	"context"
+	"time"
+
+	"github.com/secmon-lab/alertchain/pkg/domain/interfaces"
+	"github.com/secmon-lab/alertchain/pkg/domain/model"
+	"github.com/secmon-lab/alertchain/pkg/domain/types"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Database wraps interfaces.Database and creates a span for each operation.
+type Database struct {
+	db interfaces.Database
+}
+
+var _ interfaces.Database = &Database{}
+
+func NewDatabase(db interfaces.Database) *Database {
+	return &Database{db: db}
+}
+
+func nsAttr(ns types.Namespace) attribute.KeyValue {
+	return attribute.String("alertchain.namespace", string(ns))
+}
+
+func (x *Database) GetAttrs(ctx context.Context, ns types.Namespace) (attrs model.Attributes, err error) {
+	ctx, span := Start(ctx, "db.GetAttrs", nsAttr(ns))
+	defer func() { End(span, err) }()
+	return x.db.GetAttrs(ctx, ns)
+}
+
+func (x *Database) PutAttrs(ctx context.Context, ns types.Namespace, attrs model.Attributes) (err error) {
+	ctx, span := Start(ctx, "db.PutAttrs", nsAttr(ns))
+	defer func() { End(span, err) }()
+	return x.db.PutAttrs(ctx, ns, attrs)
+}
+
+func (x *Database) GetNamespaces(ctx context.Context, offset, limit int) (namespaces []model.NamespaceRecord, err error) {
+	ctx, span := Start(ctx, "db.GetNamespaces", attribute.Int("offset", offset), attribute.Int("limit", limit))
+	defer func() { End(span, err) }()
+	return x.db.GetNamespaces(ctx, offset, limit)
+}
+
+func (x *Database) GetPersistentAttrs(ctx context.Context, ns types.Namespace) (attrs []model.PersistentAttribute, err error) {
+	ctx, span := Start(ctx, "db.GetPersistentAttrs", nsAttr(ns))
+	defer func() { End(span, err) }()
+	return x.db.GetPersistentAttrs(ctx, ns)
+}
+
+func (x *Database) GetAttrHistory(ctx context.Context, ns types.Namespace, id types.AttrID, limit int) (history []model.AttributeChange, err error) {
+	ctx, span := Start(ctx, "db.GetAttrHistory", nsAttr(ns), attribute.String("alertchain.attr_id", string(id)))
+	defer func() { End(span, err) }()
+	return x.db.GetAttrHistory(ctx, ns, id, limit)
+}
+
+func (x *Database) DeleteAttrs(ctx context.Context, ns types.Namespace, ids []types.AttrID) (err error) {
+	ctx, span := Start(ctx, "db.DeleteAttrs", nsAttr(ns))
+	defer func() { End(span, err) }()
+	return x.db.DeleteAttrs(ctx, ns, ids)
+}
+
+func (x *Database) PutWorkflow(ctx context.Context, workflow model.WorkflowRecord) (err error) {
+	ctx, span := Start(ctx, "db.PutWorkflow", attribute.String("alertchain.workflow_id", string(workflow.ID)))
+	defer func() { End(span, err) }()
+	return x.db.PutWorkflow(ctx, workflow)
+}
+
+func (x *Database) GetWorkflows(ctx context.Context, offset, limit int) (workflows []model.WorkflowRecord, err error) {
+	ctx, span := Start(ctx, "db.GetWorkflows", attribute.Int("offset", offset), attribute.Int("limit", limit))
+	defer func() { End(span, err) }()
+	return x.db.GetWorkflows(ctx, offset, limit)
+}
+
+func (x *Database) GetWorkflowsAfter(ctx context.Context, cursor *model.WorkflowCursor, limit int) (workflows []model.WorkflowRecord, err error) {
+	ctx, span := Start(ctx, "db.GetWorkflowsAfter", attribute.Bool("cursor", cursor != nil), attribute.Int("limit", limit))
+	defer func() { End(span, err) }()
+	return x.db.GetWorkflowsAfter(ctx, cursor, limit)
+}
+
+func (x *Database) GetWorkflow(ctx context.Context, id types.WorkflowID) (workflow *model.WorkflowRecord, err error) {
+	ctx, span := Start(ctx, "db.GetWorkflow", attribute.String("alertchain.workflow_id", string(id)))
+	defer func() { End(span, err) }()
+	return x.db.GetWorkflow(ctx, id)
+}
+
+func (x *Database) GetWorkflowStats(ctx context.Context, from, to time.Time, interval time.Duration) (stats *model.Stats, err error) {
+	ctx, span := Start(ctx, "db.GetWorkflowStats",
+		attribute.String("from", from.Format(time.RFC3339)),
+		attribute.String("to", to.Format(time.RFC3339)),
+		attribute.Int64("interval", int64(interval/time.Second)),
+	)
+	defer func() { End(span, err) }()
+	return x.db.GetWorkflowStats(ctx, from, to, interval)
+}
+
+func (x *Database) SearchWorkflows(ctx context.Context, filter model.WorkflowFilter, offset, limit int) (workflows []model.WorkflowRecord, err error) {
+	ctx, span := Start(ctx, "db.SearchWorkflows", attribute.Int("offset", offset), attribute.Int("limit", limit))
+	defer func() { End(span, err) }()
+	return x.db.SearchWorkflows(ctx, filter, offset, limit)
+}
+
+func (x *Database) PutAlert(ctx context.Context, alert model.Alert) (err error) {
+	ctx, span := Start(ctx, "db.PutAlert", attribute.String("alertchain.alert_id", string(alert.ID)))
+	defer func() { End(span, err) }()
+	return x.db.PutAlert(ctx, alert)
+}
+
+func (x *Database) GetAlert(ctx context.Context, id types.AlertID) (alert *model.Alert, err error) {
+	ctx, span := Start(ctx, "db.GetAlert", attribute.String("alertchain.alert_id", string(id)))
+	defer func() { End(span, err) }()
+	return x.db.GetAlert(ctx, id)
+}
+
+func (x *Database) Lock(ctx context.Context, ns types.Namespace, timeout time.Time) (err error) {
+	ctx, span := Start(ctx, "db.Lock", nsAttr(ns))
+	defer func() { End(span, err) }()
+	return x.db.Lock(ctx, ns, timeout)
+}
+
+func (x *Database) Unlock(ctx context.Context, ns types.Namespace) (err error) {
+	ctx, span := Start(ctx, "db.Unlock", nsAttr(ns))
+	defer func() { End(span, err) }()
+	return x.db.Unlock(ctx, ns)
+}
+
+func (x *Database) PutDecisionLog(ctx context.Context, log model.DecisionLog) (err error) {
+	ctx, span := Start(ctx, "db.PutDecisionLog", attribute.String("alertchain.policy_package", log.Package))
+	defer func() { End(span, err) }()
+	return x.db.PutDecisionLog(ctx, log)
+}
+
+func (x *Database) PutAuditLog(ctx context.Context, log model.AuditLog) (err error) {
+	ctx, span := Start(ctx, "db.PutAuditLog", attribute.String("alertchain.audit_operation", log.Operation))
+	defer func() { End(span, err) }()
+	return x.db.PutAuditLog(ctx, log)
+}
+
+func (x *Database) ClaimPubSubMessage(ctx context.Context, record model.PubSubMessageRecord) (claimed *model.PubSubMessageRecord, err error) {
+	ctx, span := Start(ctx, "db.ClaimPubSubMessage")
+	defer func() { End(span, err) }()
+	return x.db.ClaimPubSubMessage(ctx, record)
+}
+
+func (x *Database) PutPubSubMessage(ctx context.Context, record model.PubSubMessageRecord) (err error) {
+	ctx, span := Start(ctx, "db.PutPubSubMessage", attribute.String("alertchain.pubsub.status", record.Status))
+	defer func() { End(span, err) }()
+	return x.db.PutPubSubMessage(ctx, record)
+}
+
+func (x *Database) DeletePubSubMessage(ctx context.Context, key string) (err error) {
+	ctx, span := Start(ctx, "db.DeletePubSubMessage")
+	defer func() { End(span, err) }()
+	return x.db.DeletePubSubMessage(ctx, key)
+}
+
+func (x *Database) Ping(ctx context.Context) (err error) {
+	ctx, span := Start(ctx, "db.Ping")
+	defer func() { End(span, err) }()
+	return x.db.Ping(ctx)
+}
+
+func (x *Database) Close() error {
+	return x.db.Close()
+}
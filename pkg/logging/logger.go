@@ -29,13 +29,22 @@ func Default() *slog.Logger {
 	return logger
 }
 
-func ReconfigureLogger(w io.Writer, level slog.Level, format Format) {
-	filter := masq.New(
+func newFilter() func(groups []string, a slog.Attr) slog.Attr {
+	return masq.New(
 		masq.WithTag("secret"),
 		masq.WithTag("quiet"),
 		masq.WithFieldPrefix("secret_"),
 		masq.WithAllowedType(reflect.TypeOf(time.Time{})),
 	)
+}
+
+// Redact returns a copy of v masked by the same rules as the logger. Fields tagged with `masq:"secret"` or `masq:"quiet"` and fields or map keys prefixed with "secret_" are redacted.
+func Redact(v any) any {
+	return newFilter()(nil, slog.Any("", v)).Value.Any()
+}
+
+func ReconfigureLogger(w io.Writer, level slog.Level, format Format) {
+	filter := newFilter()
 
 	var handler slog.Handler
 	switch format {
@@ -22,3 +22,26 @@ func TestLogger(t *testing.T) {
 		gt.S(t, buf.String()).Contains("aaa").NotContains("xxx")
 	})
 }
+
+func TestRedact(t *testing.T) {
+	type input struct {
+		Name string            `json:"name"`
+		Env  map[string]string `json:"env" masq:"secret"`
+	}
+
+	redacted := logging.Redact(input{
+		Name: "blue",
+		Env:  map[string]string{"TOKEN": "xxx"},
+	})
+	v := gt.Cast[input](t, redacted)
+	gt.V(t, v.Name).Equal("blue")
+	gt.M(t, v.Env).NotHasKey("TOKEN")
+
+	args := logging.Redact(map[string]any{
+		"secret_url": "https://example.com/xxx",
+		"channel":    "alert",
+	})
+	m := gt.Cast[map[string]any](t, args)
+	gt.B(t, m["secret_url"] == nil).True()
+	gt.V(t, m["channel"]).Equal("alert")
+}
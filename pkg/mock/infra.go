@@ -94,33 +94,72 @@ var _ interfaces.Database = &DatabaseMock{}
 //
 //		// make and configure a mocked interfaces.Database
 //		mockedDatabase := &DatabaseMock{
+//			ClaimPubSubMessageFunc: func(ctx context.Context, record model.PubSubMessageRecord) (*model.PubSubMessageRecord, error) {
+//				panic("mock out the ClaimPubSubMessage method")
+//			},
 //			CloseFunc: func() error {
 //				panic("mock out the Close method")
 //			},
+//			DeleteAttrsFunc: func(ctx context.Context, ns types.Namespace, ids []types.AttrID) error {
+//				panic("mock out the DeleteAttrs method")
+//			},
+//			DeletePubSubMessageFunc: func(ctx context.Context, key string) error {
+//				panic("mock out the DeletePubSubMessage method")
+//			},
 //			GetAlertFunc: func(ctx context.Context, id types.AlertID) (*model.Alert, error) {
 //				panic("mock out the GetAlert method")
 //			},
+//			GetAttrHistoryFunc: func(ctx context.Context, ns types.Namespace, id types.AttrID, limit int) ([]model.AttributeChange, error) {
+//				panic("mock out the GetAttrHistory method")
+//			},
 //			GetAttrsFunc: func(ctx context.Context, ns types.Namespace) (model.Attributes, error) {
 //				panic("mock out the GetAttrs method")
 //			},
+//			GetNamespacesFunc: func(ctx context.Context, offset int, limit int) ([]model.NamespaceRecord, error) {
+//				panic("mock out the GetNamespaces method")
+//			},
+//			GetPersistentAttrsFunc: func(ctx context.Context, ns types.Namespace) ([]model.PersistentAttribute, error) {
+//				panic("mock out the GetPersistentAttrs method")
+//			},
 //			GetWorkflowFunc: func(ctx context.Context, id types.WorkflowID) (*model.WorkflowRecord, error) {
 //				panic("mock out the GetWorkflow method")
 //			},
+//			GetWorkflowStatsFunc: func(ctx context.Context, from time.Time, to time.Time, interval time.Duration) (*model.Stats, error) {
+//				panic("mock out the GetWorkflowStats method")
+//			},
 //			GetWorkflowsFunc: func(ctx context.Context, offset int, limit int) ([]model.WorkflowRecord, error) {
 //				panic("mock out the GetWorkflows method")
 //			},
+//			GetWorkflowsAfterFunc: func(ctx context.Context, cursor *model.WorkflowCursor, limit int) ([]model.WorkflowRecord, error) {
+//				panic("mock out the GetWorkflowsAfter method")
+//			},
 //			LockFunc: func(ctx context.Context, ns types.Namespace, timeout time.Time) error {
 //				panic("mock out the Lock method")
 //			},
+//			PingFunc: func(ctx context.Context) error {
+//				panic("mock out the Ping method")
+//			},
 //			PutAlertFunc: func(ctx context.Context, alert model.Alert) error {
 //				panic("mock out the PutAlert method")
 //			},
 //			PutAttrsFunc: func(ctx context.Context, ns types.Namespace, attrs model.Attributes) error {
 //				panic("mock out the PutAttrs method")
 //			},
+//			PutAuditLogFunc: func(ctx context.Context, log model.AuditLog) error {
+//				panic("mock out the PutAuditLog method")
+//			},
+//			PutDecisionLogFunc: func(ctx context.Context, log model.DecisionLog) error {
+//				panic("mock out the PutDecisionLog method")
+//			},
+//			PutPubSubMessageFunc: func(ctx context.Context, record model.PubSubMessageRecord) error {
+//				panic("mock out the PutPubSubMessage method")
+//			},
 //			PutWorkflowFunc: func(ctx context.Context, workflow model.WorkflowRecord) error {
 //				panic("mock out the PutWorkflow method")
 //			},
+//			SearchWorkflowsFunc: func(ctx context.Context, filter model.WorkflowFilter, offset int, limit int) ([]model.WorkflowRecord, error) {
+//				panic("mock out the SearchWorkflows method")
+//			},
 //			UnlockFunc: func(ctx context.Context, ns types.Namespace) error {
 //				panic("mock out the Unlock method")
 //			},
@@ -131,41 +170,103 @@ var _ interfaces.Database = &DatabaseMock{}
 //
 //	}
 type DatabaseMock struct {
+	// ClaimPubSubMessageFunc mocks the ClaimPubSubMessage method.
+	ClaimPubSubMessageFunc func(ctx context.Context, record model.PubSubMessageRecord) (*model.PubSubMessageRecord, error)
+
 	// CloseFunc mocks the Close method.
 	CloseFunc func() error
 
+	// DeleteAttrsFunc mocks the DeleteAttrs method.
+	DeleteAttrsFunc func(ctx context.Context, ns types.Namespace, ids []types.AttrID) error
+
+	// DeletePubSubMessageFunc mocks the DeletePubSubMessage method.
+	DeletePubSubMessageFunc func(ctx context.Context, key string) error
+
 	// GetAlertFunc mocks the GetAlert method.
 	GetAlertFunc func(ctx context.Context, id types.AlertID) (*model.Alert, error)
 
+	// GetAttrHistoryFunc mocks the GetAttrHistory method.
+	GetAttrHistoryFunc func(ctx context.Context, ns types.Namespace, id types.AttrID, limit int) ([]model.AttributeChange, error)
+
 	// GetAttrsFunc mocks the GetAttrs method.
 	GetAttrsFunc func(ctx context.Context, ns types.Namespace) (model.Attributes, error)
 
+	// GetNamespacesFunc mocks the GetNamespaces method.
+	GetNamespacesFunc func(ctx context.Context, offset int, limit int) ([]model.NamespaceRecord, error)
+
+	// GetPersistentAttrsFunc mocks the GetPersistentAttrs method.
+	GetPersistentAttrsFunc func(ctx context.Context, ns types.Namespace) ([]model.PersistentAttribute, error)
+
 	// GetWorkflowFunc mocks the GetWorkflow method.
 	GetWorkflowFunc func(ctx context.Context, id types.WorkflowID) (*model.WorkflowRecord, error)
 
+	// GetWorkflowStatsFunc mocks the GetWorkflowStats method.
+	GetWorkflowStatsFunc func(ctx context.Context, from time.Time, to time.Time, interval time.Duration) (*model.Stats, error)
+
 	// GetWorkflowsFunc mocks the GetWorkflows method.
 	GetWorkflowsFunc func(ctx context.Context, offset int, limit int) ([]model.WorkflowRecord, error)
 
+	// GetWorkflowsAfterFunc mocks the GetWorkflowsAfter method.
+	GetWorkflowsAfterFunc func(ctx context.Context, cursor *model.WorkflowCursor, limit int) ([]model.WorkflowRecord, error)
+
 	// LockFunc mocks the Lock method.
 	LockFunc func(ctx context.Context, ns types.Namespace, timeout time.Time) error
 
+	// PingFunc mocks the Ping method.
+	PingFunc func(ctx context.Context) error
+
 	// PutAlertFunc mocks the PutAlert method.
 	PutAlertFunc func(ctx context.Context, alert model.Alert) error
 
 	// PutAttrsFunc mocks the PutAttrs method.
 	PutAttrsFunc func(ctx context.Context, ns types.Namespace, attrs model.Attributes) error
 
+	// PutAuditLogFunc mocks the PutAuditLog method.
+	PutAuditLogFunc func(ctx context.Context, log model.AuditLog) error
+
+	// PutDecisionLogFunc mocks the PutDecisionLog method.
+	PutDecisionLogFunc func(ctx context.Context, log model.DecisionLog) error
+
+	// PutPubSubMessageFunc mocks the PutPubSubMessage method.
+	PutPubSubMessageFunc func(ctx context.Context, record model.PubSubMessageRecord) error
+
 	// PutWorkflowFunc mocks the PutWorkflow method.
 	PutWorkflowFunc func(ctx context.Context, workflow model.WorkflowRecord) error
 
+	// SearchWorkflowsFunc mocks the SearchWorkflows method.
+	SearchWorkflowsFunc func(ctx context.Context, filter model.WorkflowFilter, offset int, limit int) ([]model.WorkflowRecord, error)
+
 	// UnlockFunc mocks the Unlock method.
 	UnlockFunc func(ctx context.Context, ns types.Namespace) error
 
 	// calls tracks calls to the methods.
 	calls struct {
+		// ClaimPubSubMessage holds details about calls to the ClaimPubSubMessage method.
+		ClaimPubSubMessage []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Record is the record argument value.
+			Record model.PubSubMessageRecord
+		}
 		// Close holds details about calls to the Close method.
 		Close []struct {
 		}
+		// DeleteAttrs holds details about calls to the DeleteAttrs method.
+		DeleteAttrs []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Ns is the ns argument value.
+			Ns types.Namespace
+			// Ids is the ids argument value.
+			Ids []types.AttrID
+		}
+		// DeletePubSubMessage holds details about calls to the DeletePubSubMessage method.
+		DeletePubSubMessage []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Key is the key argument value.
+			Key string
+		}
 		// GetAlert holds details about calls to the GetAlert method.
 		GetAlert []struct {
 			// Ctx is the ctx argument value.
@@ -173,6 +274,17 @@ type DatabaseMock struct {
 			// ID is the id argument value.
 			ID types.AlertID
 		}
+		// GetAttrHistory holds details about calls to the GetAttrHistory method.
+		GetAttrHistory []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Ns is the ns argument value.
+			Ns types.Namespace
+			// ID is the id argument value.
+			ID types.AttrID
+			// Limit is the limit argument value.
+			Limit int
+		}
 		// GetAttrs holds details about calls to the GetAttrs method.
 		GetAttrs []struct {
 			// Ctx is the ctx argument value.
@@ -180,6 +292,22 @@ type DatabaseMock struct {
 			// Ns is the ns argument value.
 			Ns types.Namespace
 		}
+		// GetNamespaces holds details about calls to the GetNamespaces method.
+		GetNamespaces []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Offset is the offset argument value.
+			Offset int
+			// Limit is the limit argument value.
+			Limit int
+		}
+		// GetPersistentAttrs holds details about calls to the GetPersistentAttrs method.
+		GetPersistentAttrs []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Ns is the ns argument value.
+			Ns types.Namespace
+		}
 		// GetWorkflow holds details about calls to the GetWorkflow method.
 		GetWorkflow []struct {
 			// Ctx is the ctx argument value.
@@ -187,6 +315,17 @@ type DatabaseMock struct {
 			// ID is the id argument value.
 			ID types.WorkflowID
 		}
+		// GetWorkflowStats holds details about calls to the GetWorkflowStats method.
+		GetWorkflowStats []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// From is the from argument value.
+			From time.Time
+			// To is the to argument value.
+			To time.Time
+			// Interval is the interval argument value.
+			Interval time.Duration
+		}
 		// GetWorkflows holds details about calls to the GetWorkflows method.
 		GetWorkflows []struct {
 			// Ctx is the ctx argument value.
@@ -196,6 +335,15 @@ type DatabaseMock struct {
 			// Limit is the limit argument value.
 			Limit int
 		}
+		// GetWorkflowsAfter holds details about calls to the GetWorkflowsAfter method.
+		GetWorkflowsAfter []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Cursor is the cursor argument value.
+			Cursor *model.WorkflowCursor
+			// Limit is the limit argument value.
+			Limit int
+		}
 		// Lock holds details about calls to the Lock method.
 		Lock []struct {
 			// Ctx is the ctx argument value.
@@ -205,6 +353,11 @@ type DatabaseMock struct {
 			// Timeout is the timeout argument value.
 			Timeout time.Time
 		}
+		// Ping holds details about calls to the Ping method.
+		Ping []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+		}
 		// PutAlert holds details about calls to the PutAlert method.
 		PutAlert []struct {
 			// Ctx is the ctx argument value.
@@ -221,6 +374,27 @@ type DatabaseMock struct {
 			// Attrs is the attrs argument value.
 			Attrs model.Attributes
 		}
+		// PutAuditLog holds details about calls to the PutAuditLog method.
+		PutAuditLog []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Log is the log argument value.
+			Log model.AuditLog
+		}
+		// PutDecisionLog holds details about calls to the PutDecisionLog method.
+		PutDecisionLog []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Log is the log argument value.
+			Log model.DecisionLog
+		}
+		// PutPubSubMessage holds details about calls to the PutPubSubMessage method.
+		PutPubSubMessage []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Record is the record argument value.
+			Record model.PubSubMessageRecord
+		}
 		// PutWorkflow holds details about calls to the PutWorkflow method.
 		PutWorkflow []struct {
 			// Ctx is the ctx argument value.
@@ -228,6 +402,17 @@ type DatabaseMock struct {
 			// Workflow is the workflow argument value.
 			Workflow model.WorkflowRecord
 		}
+		// SearchWorkflows holds details about calls to the SearchWorkflows method.
+		SearchWorkflows []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Filter is the filter argument value.
+			Filter model.WorkflowFilter
+			// Offset is the offset argument value.
+			Offset int
+			// Limit is the limit argument value.
+			Limit int
+		}
 		// Unlock holds details about calls to the Unlock method.
 		Unlock []struct {
 			// Ctx is the ctx argument value.
@@ -236,16 +421,65 @@ type DatabaseMock struct {
 			Ns types.Namespace
 		}
 	}
-	lockClose        sync.RWMutex
-	lockGetAlert     sync.RWMutex
-	lockGetAttrs     sync.RWMutex
-	lockGetWorkflow  sync.RWMutex
-	lockGetWorkflows sync.RWMutex
-	lockLock         sync.RWMutex
-	lockPutAlert     sync.RWMutex
-	lockPutAttrs     sync.RWMutex
-	lockPutWorkflow  sync.RWMutex
-	lockUnlock       sync.RWMutex
+	lockClaimPubSubMessage  sync.RWMutex
+	lockClose               sync.RWMutex
+	lockDeleteAttrs         sync.RWMutex
+	lockDeletePubSubMessage sync.RWMutex
+	lockGetAlert            sync.RWMutex
+	lockGetAttrHistory      sync.RWMutex
+	lockGetAttrs            sync.RWMutex
+	lockGetNamespaces       sync.RWMutex
+	lockGetPersistentAttrs  sync.RWMutex
+	lockGetWorkflow         sync.RWMutex
+	lockGetWorkflowStats    sync.RWMutex
+	lockGetWorkflows        sync.RWMutex
+	lockGetWorkflowsAfter   sync.RWMutex
+	lockLock                sync.RWMutex
+	lockPing                sync.RWMutex
+	lockPutAlert            sync.RWMutex
+	lockPutAttrs            sync.RWMutex
+	lockPutAuditLog         sync.RWMutex
+	lockPutDecisionLog      sync.RWMutex
+	lockPutPubSubMessage    sync.RWMutex
+	lockPutWorkflow         sync.RWMutex
+	lockSearchWorkflows     sync.RWMutex
+	lockUnlock              sync.RWMutex
+}
+
+// ClaimPubSubMessage calls ClaimPubSubMessageFunc.
+func (mock *DatabaseMock) ClaimPubSubMessage(ctx context.Context, record model.PubSubMessageRecord) (*model.PubSubMessageRecord, error) {
+	if mock.ClaimPubSubMessageFunc == nil {
+		panic("DatabaseMock.ClaimPubSubMessageFunc: method is nil but Database.ClaimPubSubMessage was just called")
+	}
+	callInfo := struct {
+		Ctx    context.Context
+		Record model.PubSubMessageRecord
+	}{
+		Ctx:    ctx,
+		Record: record,
+	}
+	mock.lockClaimPubSubMessage.Lock()
+	mock.calls.ClaimPubSubMessage = append(mock.calls.ClaimPubSubMessage, callInfo)
+	mock.lockClaimPubSubMessage.Unlock()
+	return mock.ClaimPubSubMessageFunc(ctx, record)
+}
+
+// ClaimPubSubMessageCalls gets all the calls that were made to ClaimPubSubMessage.
+// Check the length with:
+//
+//	len(mockedDatabase.ClaimPubSubMessageCalls())
+func (mock *DatabaseMock) ClaimPubSubMessageCalls() []struct {
+	Ctx    context.Context
+	Record model.PubSubMessageRecord
+} {
+	var calls []struct {
+		Ctx    context.Context
+		Record model.PubSubMessageRecord
+	}
+	mock.lockClaimPubSubMessage.RLock()
+	calls = mock.calls.ClaimPubSubMessage
+	mock.lockClaimPubSubMessage.RUnlock()
+	return calls
 }
 
 // Close calls CloseFunc.
@@ -275,6 +509,82 @@ func (mock *DatabaseMock) CloseCalls() []struct {
 	return calls
 }
 
+// DeleteAttrs calls DeleteAttrsFunc.
+func (mock *DatabaseMock) DeleteAttrs(ctx context.Context, ns types.Namespace, ids []types.AttrID) error {
+	if mock.DeleteAttrsFunc == nil {
+		panic("DatabaseMock.DeleteAttrsFunc: method is nil but Database.DeleteAttrs was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		Ns  types.Namespace
+		Ids []types.AttrID
+	}{
+		Ctx: ctx,
+		Ns:  ns,
+		Ids: ids,
+	}
+	mock.lockDeleteAttrs.Lock()
+	mock.calls.DeleteAttrs = append(mock.calls.DeleteAttrs, callInfo)
+	mock.lockDeleteAttrs.Unlock()
+	return mock.DeleteAttrsFunc(ctx, ns, ids)
+}
+
+// DeleteAttrsCalls gets all the calls that were made to DeleteAttrs.
+// Check the length with:
+//
+//	len(mockedDatabase.DeleteAttrsCalls())
+func (mock *DatabaseMock) DeleteAttrsCalls() []struct {
+	Ctx context.Context
+	Ns  types.Namespace
+	Ids []types.AttrID
+} {
+	var calls []struct {
+		Ctx context.Context
+		Ns  types.Namespace
+		Ids []types.AttrID
+	}
+	mock.lockDeleteAttrs.RLock()
+	calls = mock.calls.DeleteAttrs
+	mock.lockDeleteAttrs.RUnlock()
+	return calls
+}
+
+// DeletePubSubMessage calls DeletePubSubMessageFunc.
+func (mock *DatabaseMock) DeletePubSubMessage(ctx context.Context, key string) error {
+	if mock.DeletePubSubMessageFunc == nil {
+		panic("DatabaseMock.DeletePubSubMessageFunc: method is nil but Database.DeletePubSubMessage was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		Key string
+	}{
+		Ctx: ctx,
+		Key: key,
+	}
+	mock.lockDeletePubSubMessage.Lock()
+	mock.calls.DeletePubSubMessage = append(mock.calls.DeletePubSubMessage, callInfo)
+	mock.lockDeletePubSubMessage.Unlock()
+	return mock.DeletePubSubMessageFunc(ctx, key)
+}
+
+// DeletePubSubMessageCalls gets all the calls that were made to DeletePubSubMessage.
+// Check the length with:
+//
+//	len(mockedDatabase.DeletePubSubMessageCalls())
+func (mock *DatabaseMock) DeletePubSubMessageCalls() []struct {
+	Ctx context.Context
+	Key string
+} {
+	var calls []struct {
+		Ctx context.Context
+		Key string
+	}
+	mock.lockDeletePubSubMessage.RLock()
+	calls = mock.calls.DeletePubSubMessage
+	mock.lockDeletePubSubMessage.RUnlock()
+	return calls
+}
+
 // GetAlert calls GetAlertFunc.
 func (mock *DatabaseMock) GetAlert(ctx context.Context, id types.AlertID) (*model.Alert, error) {
 	if mock.GetAlertFunc == nil {
@@ -311,6 +621,50 @@ func (mock *DatabaseMock) GetAlertCalls() []struct {
 	return calls
 }
 
+// GetAttrHistory calls GetAttrHistoryFunc.
+func (mock *DatabaseMock) GetAttrHistory(ctx context.Context, ns types.Namespace, id types.AttrID, limit int) ([]model.AttributeChange, error) {
+	if mock.GetAttrHistoryFunc == nil {
+		panic("DatabaseMock.GetAttrHistoryFunc: method is nil but Database.GetAttrHistory was just called")
+	}
+	callInfo := struct {
+		Ctx   context.Context
+		Ns    types.Namespace
+		ID    types.AttrID
+		Limit int
+	}{
+		Ctx:   ctx,
+		Ns:    ns,
+		ID:    id,
+		Limit: limit,
+	}
+	mock.lockGetAttrHistory.Lock()
+	mock.calls.GetAttrHistory = append(mock.calls.GetAttrHistory, callInfo)
+	mock.lockGetAttrHistory.Unlock()
+	return mock.GetAttrHistoryFunc(ctx, ns, id, limit)
+}
+
+// GetAttrHistoryCalls gets all the calls that were made to GetAttrHistory.
+// Check the length with:
+//
+//	len(mockedDatabase.GetAttrHistoryCalls())
+func (mock *DatabaseMock) GetAttrHistoryCalls() []struct {
+	Ctx   context.Context
+	Ns    types.Namespace
+	ID    types.AttrID
+	Limit int
+} {
+	var calls []struct {
+		Ctx   context.Context
+		Ns    types.Namespace
+		ID    types.AttrID
+		Limit int
+	}
+	mock.lockGetAttrHistory.RLock()
+	calls = mock.calls.GetAttrHistory
+	mock.lockGetAttrHistory.RUnlock()
+	return calls
+}
+
 // GetAttrs calls GetAttrsFunc.
 func (mock *DatabaseMock) GetAttrs(ctx context.Context, ns types.Namespace) (model.Attributes, error) {
 	if mock.GetAttrsFunc == nil {
@@ -347,6 +701,82 @@ func (mock *DatabaseMock) GetAttrsCalls() []struct {
 	return calls
 }
 
+// GetNamespaces calls GetNamespacesFunc.
+func (mock *DatabaseMock) GetNamespaces(ctx context.Context, offset int, limit int) ([]model.NamespaceRecord, error) {
+	if mock.GetNamespacesFunc == nil {
+		panic("DatabaseMock.GetNamespacesFunc: method is nil but Database.GetNamespaces was just called")
+	}
+	callInfo := struct {
+		Ctx    context.Context
+		Offset int
+		Limit  int
+	}{
+		Ctx:    ctx,
+		Offset: offset,
+		Limit:  limit,
+	}
+	mock.lockGetNamespaces.Lock()
+	mock.calls.GetNamespaces = append(mock.calls.GetNamespaces, callInfo)
+	mock.lockGetNamespaces.Unlock()
+	return mock.GetNamespacesFunc(ctx, offset, limit)
+}
+
+// GetNamespacesCalls gets all the calls that were made to GetNamespaces.
+// Check the length with:
+//
+//	len(mockedDatabase.GetNamespacesCalls())
+func (mock *DatabaseMock) GetNamespacesCalls() []struct {
+	Ctx    context.Context
+	Offset int
+	Limit  int
+} {
+	var calls []struct {
+		Ctx    context.Context
+		Offset int
+		Limit  int
+	}
+	mock.lockGetNamespaces.RLock()
+	calls = mock.calls.GetNamespaces
+	mock.lockGetNamespaces.RUnlock()
+	return calls
+}
+
+// GetPersistentAttrs calls GetPersistentAttrsFunc.
+func (mock *DatabaseMock) GetPersistentAttrs(ctx context.Context, ns types.Namespace) ([]model.PersistentAttribute, error) {
+	if mock.GetPersistentAttrsFunc == nil {
+		panic("DatabaseMock.GetPersistentAttrsFunc: method is nil but Database.GetPersistentAttrs was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		Ns  types.Namespace
+	}{
+		Ctx: ctx,
+		Ns:  ns,
+	}
+	mock.lockGetPersistentAttrs.Lock()
+	mock.calls.GetPersistentAttrs = append(mock.calls.GetPersistentAttrs, callInfo)
+	mock.lockGetPersistentAttrs.Unlock()
+	return mock.GetPersistentAttrsFunc(ctx, ns)
+}
+
+// GetPersistentAttrsCalls gets all the calls that were made to GetPersistentAttrs.
+// Check the length with:
+//
+//	len(mockedDatabase.GetPersistentAttrsCalls())
+func (mock *DatabaseMock) GetPersistentAttrsCalls() []struct {
+	Ctx context.Context
+	Ns  types.Namespace
+} {
+	var calls []struct {
+		Ctx context.Context
+		Ns  types.Namespace
+	}
+	mock.lockGetPersistentAttrs.RLock()
+	calls = mock.calls.GetPersistentAttrs
+	mock.lockGetPersistentAttrs.RUnlock()
+	return calls
+}
+
 // GetWorkflow calls GetWorkflowFunc.
 func (mock *DatabaseMock) GetWorkflow(ctx context.Context, id types.WorkflowID) (*model.WorkflowRecord, error) {
 	if mock.GetWorkflowFunc == nil {
@@ -383,6 +813,50 @@ func (mock *DatabaseMock) GetWorkflowCalls() []struct {
 	return calls
 }
 
+// GetWorkflowStats calls GetWorkflowStatsFunc.
+func (mock *DatabaseMock) GetWorkflowStats(ctx context.Context, from time.Time, to time.Time, interval time.Duration) (*model.Stats, error) {
+	if mock.GetWorkflowStatsFunc == nil {
+		panic("DatabaseMock.GetWorkflowStatsFunc: method is nil but Database.GetWorkflowStats was just called")
+	}
+	callInfo := struct {
+		Ctx      context.Context
+		From     time.Time
+		To       time.Time
+		Interval time.Duration
+	}{
+		Ctx:      ctx,
+		From:     from,
+		To:       to,
+		Interval: interval,
+	}
+	mock.lockGetWorkflowStats.Lock()
+	mock.calls.GetWorkflowStats = append(mock.calls.GetWorkflowStats, callInfo)
+	mock.lockGetWorkflowStats.Unlock()
+	return mock.GetWorkflowStatsFunc(ctx, from, to, interval)
+}
+
+// GetWorkflowStatsCalls gets all the calls that were made to GetWorkflowStats.
+// Check the length with:
+//
+//	len(mockedDatabase.GetWorkflowStatsCalls())
+func (mock *DatabaseMock) GetWorkflowStatsCalls() []struct {
+	Ctx      context.Context
+	From     time.Time
+	To       time.Time
+	Interval time.Duration
+} {
+	var calls []struct {
+		Ctx      context.Context
+		From     time.Time
+		To       time.Time
+		Interval time.Duration
+	}
+	mock.lockGetWorkflowStats.RLock()
+	calls = mock.calls.GetWorkflowStats
+	mock.lockGetWorkflowStats.RUnlock()
+	return calls
+}
+
 // GetWorkflows calls GetWorkflowsFunc.
 func (mock *DatabaseMock) GetWorkflows(ctx context.Context, offset int, limit int) ([]model.WorkflowRecord, error) {
 	if mock.GetWorkflowsFunc == nil {
@@ -423,6 +897,46 @@ func (mock *DatabaseMock) GetWorkflowsCalls() []struct {
 	return calls
 }
 
+// GetWorkflowsAfter calls GetWorkflowsAfterFunc.
+func (mock *DatabaseMock) GetWorkflowsAfter(ctx context.Context, cursor *model.WorkflowCursor, limit int) ([]model.WorkflowRecord, error) {
+	if mock.GetWorkflowsAfterFunc == nil {
+		panic("DatabaseMock.GetWorkflowsAfterFunc: method is nil but Database.GetWorkflowsAfter was just called")
+	}
+	callInfo := struct {
+		Ctx    context.Context
+		Cursor *model.WorkflowCursor
+		Limit  int
+	}{
+		Ctx:    ctx,
+		Cursor: cursor,
+		Limit:  limit,
+	}
+	mock.lockGetWorkflowsAfter.Lock()
+	mock.calls.GetWorkflowsAfter = append(mock.calls.GetWorkflowsAfter, callInfo)
+	mock.lockGetWorkflowsAfter.Unlock()
+	return mock.GetWorkflowsAfterFunc(ctx, cursor, limit)
+}
+
+// GetWorkflowsAfterCalls gets all the calls that were made to GetWorkflowsAfter.
+// Check the length with:
+//
+//	len(mockedDatabase.GetWorkflowsAfterCalls())
+func (mock *DatabaseMock) GetWorkflowsAfterCalls() []struct {
+	Ctx    context.Context
+	Cursor *model.WorkflowCursor
+	Limit  int
+} {
+	var calls []struct {
+		Ctx    context.Context
+		Cursor *model.WorkflowCursor
+		Limit  int
+	}
+	mock.lockGetWorkflowsAfter.RLock()
+	calls = mock.calls.GetWorkflowsAfter
+	mock.lockGetWorkflowsAfter.RUnlock()
+	return calls
+}
+
 // Lock calls LockFunc.
 func (mock *DatabaseMock) Lock(ctx context.Context, ns types.Namespace, timeout time.Time) error {
 	if mock.LockFunc == nil {
@@ -463,6 +977,38 @@ func (mock *DatabaseMock) LockCalls() []struct {
 	return calls
 }
 
+// Ping calls PingFunc.
+func (mock *DatabaseMock) Ping(ctx context.Context) error {
+	if mock.PingFunc == nil {
+		panic("DatabaseMock.PingFunc: method is nil but Database.Ping was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+	}{
+		Ctx: ctx,
+	}
+	mock.lockPing.Lock()
+	mock.calls.Ping = append(mock.calls.Ping, callInfo)
+	mock.lockPing.Unlock()
+	return mock.PingFunc(ctx)
+}
+
+// PingCalls gets all the calls that were made to Ping.
+// Check the length with:
+//
+//	len(mockedDatabase.PingCalls())
+func (mock *DatabaseMock) PingCalls() []struct {
+	Ctx context.Context
+} {
+	var calls []struct {
+		Ctx context.Context
+	}
+	mock.lockPing.RLock()
+	calls = mock.calls.Ping
+	mock.lockPing.RUnlock()
+	return calls
+}
+
 // PutAlert calls PutAlertFunc.
 func (mock *DatabaseMock) PutAlert(ctx context.Context, alert model.Alert) error {
 	if mock.PutAlertFunc == nil {
@@ -539,6 +1085,114 @@ func (mock *DatabaseMock) PutAttrsCalls() []struct {
 	return calls
 }
 
+// PutAuditLog calls PutAuditLogFunc.
+func (mock *DatabaseMock) PutAuditLog(ctx context.Context, log model.AuditLog) error {
+	if mock.PutAuditLogFunc == nil {
+		panic("DatabaseMock.PutAuditLogFunc: method is nil but Database.PutAuditLog was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		Log model.AuditLog
+	}{
+		Ctx: ctx,
+		Log: log,
+	}
+	mock.lockPutAuditLog.Lock()
+	mock.calls.PutAuditLog = append(mock.calls.PutAuditLog, callInfo)
+	mock.lockPutAuditLog.Unlock()
+	return mock.PutAuditLogFunc(ctx, log)
+}
+
+// PutAuditLogCalls gets all the calls that were made to PutAuditLog.
+// Check the length with:
+//
+//	len(mockedDatabase.PutAuditLogCalls())
+func (mock *DatabaseMock) PutAuditLogCalls() []struct {
+	Ctx context.Context
+	Log model.AuditLog
+} {
+	var calls []struct {
+		Ctx context.Context
+		Log model.AuditLog
+	}
+	mock.lockPutAuditLog.RLock()
+	calls = mock.calls.PutAuditLog
+	mock.lockPutAuditLog.RUnlock()
+	return calls
+}
+
+// PutDecisionLog calls PutDecisionLogFunc.
+func (mock *DatabaseMock) PutDecisionLog(ctx context.Context, log model.DecisionLog) error {
+	if mock.PutDecisionLogFunc == nil {
+		panic("DatabaseMock.PutDecisionLogFunc: method is nil but Database.PutDecisionLog was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		Log model.DecisionLog
+	}{
+		Ctx: ctx,
+		Log: log,
+	}
+	mock.lockPutDecisionLog.Lock()
+	mock.calls.PutDecisionLog = append(mock.calls.PutDecisionLog, callInfo)
+	mock.lockPutDecisionLog.Unlock()
+	return mock.PutDecisionLogFunc(ctx, log)
+}
+
+// PutDecisionLogCalls gets all the calls that were made to PutDecisionLog.
+// Check the length with:
+//
+//	len(mockedDatabase.PutDecisionLogCalls())
+func (mock *DatabaseMock) PutDecisionLogCalls() []struct {
+	Ctx context.Context
+	Log model.DecisionLog
+} {
+	var calls []struct {
+		Ctx context.Context
+		Log model.DecisionLog
+	}
+	mock.lockPutDecisionLog.RLock()
+	calls = mock.calls.PutDecisionLog
+	mock.lockPutDecisionLog.RUnlock()
+	return calls
+}
+
+// PutPubSubMessage calls PutPubSubMessageFunc.
+func (mock *DatabaseMock) PutPubSubMessage(ctx context.Context, record model.PubSubMessageRecord) error {
+	if mock.PutPubSubMessageFunc == nil {
+		panic("DatabaseMock.PutPubSubMessageFunc: method is nil but Database.PutPubSubMessage was just called")
+	}
+	callInfo := struct {
+		Ctx    context.Context
+		Record model.PubSubMessageRecord
+	}{
+		Ctx:    ctx,
+		Record: record,
+	}
+	mock.lockPutPubSubMessage.Lock()
+	mock.calls.PutPubSubMessage = append(mock.calls.PutPubSubMessage, callInfo)
+	mock.lockPutPubSubMessage.Unlock()
+	return mock.PutPubSubMessageFunc(ctx, record)
+}
+
+// PutPubSubMessageCalls gets all the calls that were made to PutPubSubMessage.
+// Check the length with:
+//
+//	len(mockedDatabase.PutPubSubMessageCalls())
+func (mock *DatabaseMock) PutPubSubMessageCalls() []struct {
+	Ctx    context.Context
+	Record model.PubSubMessageRecord
+} {
+	var calls []struct {
+		Ctx    context.Context
+		Record model.PubSubMessageRecord
+	}
+	mock.lockPutPubSubMessage.RLock()
+	calls = mock.calls.PutPubSubMessage
+	mock.lockPutPubSubMessage.RUnlock()
+	return calls
+}
+
 // PutWorkflow calls PutWorkflowFunc.
 func (mock *DatabaseMock) PutWorkflow(ctx context.Context, workflow model.WorkflowRecord) error {
 	if mock.PutWorkflowFunc == nil {
@@ -575,6 +1229,50 @@ func (mock *DatabaseMock) PutWorkflowCalls() []struct {
 	return calls
 }
 
+// SearchWorkflows calls SearchWorkflowsFunc.
+func (mock *DatabaseMock) SearchWorkflows(ctx context.Context, filter model.WorkflowFilter, offset int, limit int) ([]model.WorkflowRecord, error) {
+	if mock.SearchWorkflowsFunc == nil {
+		panic("DatabaseMock.SearchWorkflowsFunc: method is nil but Database.SearchWorkflows was just called")
+	}
+	callInfo := struct {
+		Ctx    context.Context
+		Filter model.WorkflowFilter
+		Offset int
+		Limit  int
+	}{
+		Ctx:    ctx,
+		Filter: filter,
+		Offset: offset,
+		Limit:  limit,
+	}
+	mock.lockSearchWorkflows.Lock()
+	mock.calls.SearchWorkflows = append(mock.calls.SearchWorkflows, callInfo)
+	mock.lockSearchWorkflows.Unlock()
+	return mock.SearchWorkflowsFunc(ctx, filter, offset, limit)
+}
+
+// SearchWorkflowsCalls gets all the calls that were made to SearchWorkflows.
+// Check the length with:
+//
+//	len(mockedDatabase.SearchWorkflowsCalls())
+func (mock *DatabaseMock) SearchWorkflowsCalls() []struct {
+	Ctx    context.Context
+	Filter model.WorkflowFilter
+	Offset int
+	Limit  int
+} {
+	var calls []struct {
+		Ctx    context.Context
+		Filter model.WorkflowFilter
+		Offset int
+		Limit  int
+	}
+	mock.lockSearchWorkflows.RLock()
+	calls = mock.calls.SearchWorkflows
+	mock.lockSearchWorkflows.RUnlock()
+	return calls
+}
+
 // Unlock calls UnlockFunc.
 func (mock *DatabaseMock) Unlock(ctx context.Context, ns types.Namespace) error {
 	if mock.UnlockFunc == nil {
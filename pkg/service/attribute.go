@@ -0,0 +1,147 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/m-mizutani/goerr/v2"
+	"github.com/secmon-lab/alertchain/pkg/domain/interfaces"
+	"github.com/secmon-lab/alertchain/pkg/domain/model"
+	"github.com/secmon-lab/alertchain/pkg/domain/types"
+)
+
+// AttributeService manages persistent attributes and lock of namespace.
+type AttributeService struct {
+	db interfaces.Database
+}
+
+func NewAttributeService(db interfaces.Database) *AttributeService {
+	return &AttributeService{db: db}
+}
+
+// Put adds the attribute to the namespace, or updates value of the attribute if it has ID of existing one. The attribute is always persistent.
+func (x *AttributeService) Put(ctx context.Context, ns types.Namespace, attr model.Attribute) (*model.AttributeRecord, error) {
+	if ns == "" {
+		return nil, goerr.New("namespace is required", goerr.T(types.ErrTagBadRequest))
+	}
+	if attr.Key == "" {
+		return nil, goerr.New("key of attribute is required", goerr.T(types.ErrTagBadRequest))
+	}
+	if attr.TTL < 0 {
+		return nil, goerr.New("TTL of attribute must not be negative", goerr.V("ttl", attr.TTL), goerr.T(types.ErrTagBadRequest))
+	}
+
+	if attr.ID == "" {
+		attr.ID = types.NewAttrID()
+	}
+	attr.Persist = true
+
+	if err := x.db.PutAttrs(ctx, ns, model.Attributes{attr}); err != nil {
+		return nil, err
+	}
+	return attrsToRecord(model.Attributes{attr})[0], nil
+}
+
+// Namespaces returns namespaces that have persistent attributes.
+func (x *AttributeService) Namespaces(ctx context.Context, offset, limit *int) ([]model.NamespaceRecord, error) {
+	o, l := pagination(offset, limit)
+	if o < 0 || l < 0 {
+		return nil, goerr.New("offset and limit must not be negative", goerr.V("offset", o), goerr.V("limit", l), goerr.T(types.ErrTagBadRequest))
+	}
+	return x.db.GetNamespaces(ctx, o, l)
+}
+
+// List returns unexpired persistent attributes of the namespace.
+func (x *AttributeService) List(ctx context.Context, ns types.Namespace) ([]*model.PersistentAttributeRecord, error) {
+	if ns == "" {
+		return nil, goerr.New("namespace is required", goerr.T(types.ErrTagBadRequest))
+	}
+
+	attrs, err := x.db.GetPersistentAttrs(ctx, ns)
+	if err != nil {
+		return nil, err
+	}
+
+	records := make([]*model.PersistentAttributeRecord, len(attrs))
+	for i, attr := range attrs {
+		records[i] = &model.PersistentAttributeRecord{
+			Namespace:  string(ns),
+			ID:         string(attr.ID),
+			Key:        string(attr.Key),
+			Value:      fmt.Sprintf("%+v", attr.Value),
+			Type:       optional(string(attr.Type)),
+			TTL:        attr.TTL,
+			ExpiresAt:  attr.ExpiresAt,
+			UpdatedAt:  attr.UpdatedAt,
+			WorkflowID: optional(attr.WorkflowID),
+			Actor:      optional(attr.Actor),
+		}
+	}
+	return records, nil
+}
+
+// History returns writes of the attribute in descending order of time. It is available even after the attribute expired or was deleted.
+func (x *AttributeService) History(ctx context.Context, ns types.Namespace, id types.AttrID, limit *int) ([]*model.AttributeChangeRecord, error) {
+	_, l := pagination(nil, limit)
+	if l < 0 {
+		return nil, goerr.New("limit must not be negative", goerr.V("limit", l), goerr.T(types.ErrTagBadRequest))
+	}
+
+	history, err := x.db.GetAttrHistory(ctx, ns, id, l)
+	if err != nil {
+		return nil, err
+	}
+
+	records := make([]*model.AttributeChangeRecord, len(history))
+	for i, change := range history {
+		records[i] = &model.AttributeChangeRecord{
+			Value:      fmt.Sprintf("%+v", change.Value),
+			ExpiresAt:  change.ExpiresAt,
+			WorkflowID: optional(change.WorkflowID),
+			Actor:      optional(change.Actor),
+			CreatedAt:  change.CreatedAt,
+		}
+	}
+	return records, nil
+}
+
+// optional returns nil for zero value to fill a nullable field of GraphQL model.
+func optional[T comparable](v T) *T {
+	var zero T
+	if v == zero {
+		return nil
+	}
+	return &v
+}
+
+// Delete removes the attribute from the namespace. It returns false if the attribute does not exist.
+func (x *AttributeService) Delete(ctx context.Context, ns types.Namespace, id types.AttrID) (bool, error) {
+	attrs, err := x.db.GetAttrs(ctx, ns)
+	if err != nil {
+		return false, err
+	}
+
+	found := false
+	for _, attr := range attrs {
+		if attr.ID == id {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return false, nil
+	}
+
+	if err := x.db.DeleteAttrs(ctx, ns, []types.AttrID{id}); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// ReleaseLock releases lock of the namespace regardless of its owner. It is for recovery from a workflow that is stuck with the lock.
+func (x *AttributeService) ReleaseLock(ctx context.Context, ns types.Namespace) error {
+	if ns == "" {
+		return goerr.New("namespace is required", goerr.T(types.ErrTagBadRequest))
+	}
+	return x.db.Unlock(ctx, ns)
+}
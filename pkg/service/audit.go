@@ -0,0 +1,51 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/secmon-lab/alertchain/pkg/ctxutil"
+	"github.com/secmon-lab/alertchain/pkg/domain/interfaces"
+	"github.com/secmon-lab/alertchain/pkg/domain/model"
+	"github.com/secmon-lab/alertchain/pkg/domain/types"
+	"github.com/secmon-lab/alertchain/pkg/logging"
+)
+
+// AuditService records operations that change state of AlertChain.
+type AuditService struct {
+	db interfaces.Database
+}
+
+func NewAuditService(db interfaces.Database) *AuditService {
+	return &AuditService{db: db}
+}
+
+// Record writes the audit log into both logger and database. Actor is taken from ctx and the input is redacted.
+func (x *AuditService) Record(ctx context.Context, operation, target string, input map[string]any, opErr error) error {
+	log := model.AuditLog{
+		ID:        types.NewAuditID(),
+		Timestamp: ctxutil.Now(ctx),
+		Operation: operation,
+		Target:    target,
+	}
+	if redacted, ok := logging.Redact(input).(map[string]any); ok {
+		log.Input = redacted
+	}
+	if actor := ctxutil.GetActor(ctx); actor != nil {
+		log.Actor = *actor
+	}
+	if opErr != nil {
+		log.Error = opErr.Error()
+	}
+
+	ctxutil.Logger(ctx).Info("audit",
+		slog.String("audit_id", log.ID.String()),
+		slog.String("operation", log.Operation),
+		slog.String("target", log.Target),
+		slog.Any("input", log.Input),
+		slog.Any("actor", log.Actor),
+		slog.String("error", log.Error),
+	)
+
+	return x.db.PutAuditLog(ctx, log)
+}
@@ -3,11 +3,15 @@ package service
 import "github.com/secmon-lab/alertchain/pkg/domain/interfaces"
 
 type Services struct {
-	Workflow *WorkflowService
+	Workflow  *WorkflowService
+	Attribute *AttributeService
+	Audit     *AuditService
 }
 
 func New(db interfaces.Database) *Services {
 	return &Services{
-		Workflow: NewWorkflowService(db),
+		Workflow:  NewWorkflowService(db),
+		Attribute: NewAttributeService(db),
+		Audit:     NewAuditService(db),
 	}
 }
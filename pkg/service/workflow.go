@@ -4,12 +4,16 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"sort"
+	"sync"
+	"time"
 
 	"github.com/m-mizutani/goerr/v2"
 	"github.com/secmon-lab/alertchain/pkg/ctxutil"
 	"github.com/secmon-lab/alertchain/pkg/domain/interfaces"
 	"github.com/secmon-lab/alertchain/pkg/domain/model"
 	"github.com/secmon-lab/alertchain/pkg/domain/types"
+	"github.com/secmon-lab/alertchain/pkg/logging"
 )
 
 type WorkflowService struct {
@@ -17,29 +21,160 @@ type WorkflowService struct {
 }
 
 type Workflow struct {
-	db interfaces.Database
-	wf *model.WorkflowRecord
+	db    interfaces.Database
+	wf    *model.WorkflowRecord
+	mutex sync.Mutex
 }
 
 func NewWorkflowService(db interfaces.Database) *WorkflowService {
 	return &WorkflowService{db: db}
 }
 
+func pagination(offset, limit *int) (int, int) {
+	o, l := 0, 20
+	if offset != nil {
+		o = *offset
+	}
+	if limit != nil {
+		l = *limit
+	}
+	return o, l
+}
+
 func (x *WorkflowService) Get(ctx context.Context, offset, limit *int) ([]model.WorkflowRecord, error) {
-	if offset == nil {
-		offset = new(int)
-		*offset = 0
+	o, l := pagination(offset, limit)
+	return x.db.GetWorkflows(ctx, o, l)
+}
+
+// Connection returns a page of first workflows after the cursor. The first page is returned if after is nil.
+func (x *WorkflowService) Connection(ctx context.Context, first *int, after *string) (*model.WorkflowConnection, error) {
+	_, n := pagination(nil, first)
+	if n < 0 {
+		return nil, goerr.New("first must not be negative", goerr.V("first", n), goerr.T(types.ErrTagBadRequest))
 	}
-	if limit == nil {
-		limit = new(int)
-		*limit = 20
+	var cursor *model.WorkflowCursor
+	if after != nil {
+		c, err := model.ParseWorkflowCursor(*after)
+		if err != nil {
+			return nil, err
+		}
+		cursor = c
 	}
 
-	return x.db.GetWorkflows(ctx, *offset, *limit)
+	// Fetch one more workflow to know if there is next page
+	workflows, err := x.db.GetWorkflowsAfter(ctx, cursor, n+1)
+	if err != nil {
+		return nil, err
+	}
+	return newWorkflowConnection(workflows, n, cursor != nil), nil
+}
+
+// newWorkflowConnection builds a page of n workflows. workflows has one more workflow than n if there is next page.
+func newWorkflowConnection(workflows []model.WorkflowRecord, n int, hasPrev bool) *model.WorkflowConnection {
+	conn := &model.WorkflowConnection{
+		Edges: []*model.WorkflowEdge{},
+		Nodes: []*model.WorkflowRecord{},
+		PageInfo: &model.PageInfo{
+			HasNextPage:     len(workflows) > n,
+			HasPreviousPage: hasPrev,
+		},
+	}
+	if len(workflows) > n {
+		workflows = workflows[:n]
+	}
+
+	for i := range workflows {
+		node := &workflows[i]
+		conn.Edges = append(conn.Edges, &model.WorkflowEdge{
+			Cursor: model.NewWorkflowCursor(node).Encode(),
+			Node:   node,
+		})
+		conn.Nodes = append(conn.Nodes, node)
+	}
+	if len(conn.Edges) > 0 {
+		conn.PageInfo.StartCursor = &conn.Edges[0].Cursor
+		conn.PageInfo.EndCursor = &conn.Edges[len(conn.Edges)-1].Cursor
+	}
+
+	return conn
+}
+
+// Search returns workflows matched with the filter.
+func (x *WorkflowService) Search(ctx context.Context, filter model.WorkflowFilter, offset, limit *int) ([]model.WorkflowRecord, error) {
+	o, l := pagination(offset, limit)
+	if o < 0 || l < 0 {
+		return nil, goerr.New("offset and limit must not be negative", goerr.V("offset", o), goerr.V("limit", l), goerr.T(types.ErrTagBadRequest))
+	}
+	return x.db.SearchWorkflows(ctx, filter, o, l)
+}
+
+// maxStatsBuckets limits size of response of Stats.
+const maxStatsBuckets = 1000
+
+// Stats aggregates workflows created in [from, to). interval is width of a bucket in seconds, and 3600 by default.
+func (x *WorkflowService) Stats(ctx context.Context, from, to time.Time, interval *int) (*model.Stats, error) {
+	if !from.Before(to) {
+		return nil, goerr.New("from must be before to", goerr.V("from", from), goerr.V("to", to), goerr.T(types.ErrTagBadRequest))
+	}
+
+	width := time.Hour
+	if interval != nil {
+		if *interval <= 0 {
+			return nil, goerr.New("interval must be positive", goerr.V("interval", *interval), goerr.T(types.ErrTagBadRequest))
+		}
+		width = time.Duration(*interval) * time.Second
+	}
+	if buckets := to.Sub(from) / width; buckets >= maxStatsBuckets {
+		return nil, goerr.New("too many buckets, enlarge interval", goerr.V("buckets", buckets), goerr.V("max", maxStatsBuckets), goerr.T(types.ErrTagBadRequest))
+	}
+
+	return x.db.GetWorkflowStats(ctx, from, to, width)
 }
 
 func (x *WorkflowService) Lookup(ctx context.Context, id types.WorkflowID) (*model.WorkflowRecord, error) {
-	return nil, nil
+	return x.db.GetWorkflow(ctx, id)
+}
+
+// LookupAlert returns the stored alert of the workflow. It returns nil if the workflow or the alert is not found.
+func (x *WorkflowService) LookupAlert(ctx context.Context, id types.WorkflowID) (*model.Alert, error) {
+	wf, err := x.db.GetWorkflow(ctx, id)
+	if err != nil || wf == nil || wf.Alert == nil {
+		return nil, err
+	}
+	return x.db.GetAlert(ctx, wf.Alert.ID)
+}
+
+// Triage changes triage status of the workflow and appends the comment. Running workflow can not be changed because the record is overwritten when the workflow finishes.
+func (x *WorkflowService) Triage(ctx context.Context, id types.WorkflowID, triage, comment string) (*model.WorkflowRecord, error) {
+	wf, err := x.db.GetWorkflow(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if wf == nil {
+		return nil, goerr.New("workflow not found", goerr.V("id", id), goerr.T(types.ErrTagBadRequest))
+	}
+	if wf.Status == model.WorkflowStatusRunning {
+		return nil, goerr.New("workflow is still running", goerr.V("id", id), goerr.T(types.ErrTagBadRequest))
+	}
+
+	wf.Triage = triage
+	if comment != "" {
+		var author string
+		if actor := ctxutil.GetActor(ctx); actor != nil {
+			author = actor.Subject
+		}
+		wf.Comments = append(wf.Comments, &model.CommentRecord{
+			Author:    author,
+			Action:    triage,
+			Body:      comment,
+			CreatedAt: ctxutil.Now(ctx),
+		})
+	}
+
+	if err := x.db.PutWorkflow(ctx, *wf); err != nil {
+		return nil, err
+	}
+	return wf, nil
 }
 
 func attrsToRecord(attrs model.Attributes) []*model.AttributeRecord {
@@ -82,6 +217,8 @@ func (x *WorkflowService) Create(ctx context.Context, alert model.Alert) (*Workf
 	workflow := model.WorkflowRecord{
 		ID:        types.NewWorkflowID(),
 		CreatedAt: ctxutil.Now(ctx),
+		Status:    model.WorkflowStatusRunning,
+		Triage:    model.WorkflowTriageOpen,
 		Alert: &model.AlertRecord{
 			ID:          alert.ID,
 			Schema:      string(alert.Schema),
@@ -102,7 +239,14 @@ func (x *WorkflowService) Create(ctx context.Context, alert model.Alert) (*Workf
 	return &Workflow{db: x.db, wf: &workflow}, nil
 }
 
+func (x *Workflow) ID() types.WorkflowID {
+	return x.wf.ID
+}
+
 func (x *Workflow) UpdateLastAttrs(ctx context.Context, attrs model.Attributes) error {
+	x.mutex.Lock()
+	defer x.mutex.Unlock()
+
 	x.wf.Alert.LastAttrs = attrsToRecord(attrs)
 	if err := x.db.PutWorkflow(ctx, *x.wf); err != nil {
 		return err
@@ -110,6 +254,97 @@ func (x *Workflow) UpdateLastAttrs(ctx context.Context, attrs model.Attributes)
 	return nil
 }
 
-func (x *Workflow) AddAction(ctx context.Context, action *model.Action) error {
+// Finish records the workflow as completed, or failed with the error message if err is not nil.
+func (x *Workflow) Finish(ctx context.Context, err error) error {
+	status := model.WorkflowStatusCompleted
+	if err != nil {
+		status = model.WorkflowStatusFailed
+		x.mutex.Lock()
+		msg := err.Error()
+		x.wf.Error = &msg
+		x.mutex.Unlock()
+	}
+	return x.updateStatus(ctx, status)
+}
+
+// Interrupt records the workflow as interrupted. It is used when the workflow is stopped by shutdown of the server.
+func (x *Workflow) Interrupt(ctx context.Context) error {
+	return x.updateStatus(ctx, model.WorkflowStatusInterrupted)
+}
+
+func (x *Workflow) updateStatus(ctx context.Context, status string) error {
+	x.mutex.Lock()
+	defer x.mutex.Unlock()
+
+	now := ctxutil.Now(ctx)
+	x.wf.Status = status
+	x.wf.FinishedAt = &now
+	if err := x.db.PutWorkflow(ctx, *x.wf); err != nil {
+		return err
+	}
 	return nil
 }
+
+// toJSONString converts v to JSON after redacting secret values. It returns nil if v is nil or can not be converted.
+func toJSONString(v any) *string {
+	if v == nil {
+		return nil
+	}
+	raw, err := json.Marshal(logging.Redact(v))
+	if err != nil {
+		return nil
+	}
+	s := string(raw)
+	return &s
+}
+
+// AddActions records results of actions executed in the sequence.
+func (x *Workflow) AddActions(ctx context.Context, seq int, results []*model.ActionResult) error {
+	if len(results) == 0 {
+		return nil
+	}
+
+	x.mutex.Lock()
+	defer x.mutex.Unlock()
+
+	for _, r := range results {
+		keys := make([]string, 0, len(r.Args))
+		for k := range r.Args {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		// Redact the whole arguments because secret is identified by key
+		redacted, _ := logging.Redact(map[string]any(r.Args)).(map[string]any)
+		args := make([]*model.ArgumentRecord, 0, len(keys))
+		for _, k := range keys {
+			var value string
+			if raw, err := json.Marshal(redacted[k]); err == nil {
+				value = string(raw)
+			}
+			args = append(args, &model.ArgumentRecord{Key: k, Value: value})
+		}
+
+		var commits model.Attributes
+		for _, c := range r.Commit {
+			commits = append(commits, c.Attribute)
+		}
+
+		record := &model.ActionRecord{
+			ID:         string(r.ID),
+			Seq:        seq,
+			Uses:       string(r.Uses),
+			Args:       args,
+			Result:     toJSONString(r.Result),
+			Next:       []*model.NextRecord{{Abort: r.Abort, Attrs: attrsToRecord(commits)}},
+			StartedAt:  r.StartedAt,
+			FinishedAt: r.FinishedAt,
+		}
+		if r.Error != "" {
+			record.Error = &r.Error
+		}
+		x.wf.Actions = append(x.wf.Actions, record)
+	}
+
+	return x.db.PutWorkflow(ctx, *x.wf)
+}